@@ -9,13 +9,12 @@ import (
 
 var adfCmd = &cobra.Command{
 	Use:   "adf <filePath>",
-	Short: "(unstable) A Mac System 1-7 apple double file",
+	Short: "A Mac AppleDouble or AppleSingle file",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		PreRunHandler()
-		adf.Dump(args[0])
 
-		return nil
+		return adf.Dump(args[0])
 	},
 }
 
@@ -21,5 +21,6 @@ var dump2Cmd = &cobra.Command{
 }
 
 func init() {
+	dump2Cmd.Flags().BoolVar(&dump.DebugPalettes, "debug-palettes", false, "write the HTML of every palette into _debug")
 	rootCmd.AddCommand(dump2Cmd)
 }
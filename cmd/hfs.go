@@ -0,0 +1,32 @@
+//go:build !js
+
+package cmd
+
+import (
+	"github.com/markhughes/dirry/internal/hfs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	hfsOutput  string
+	hfsExtract bool
+	hfsDump    bool
+)
+
+var hfsCmd = &cobra.Command{
+	Use:   "hfs <imagePath>",
+	Short: "Lists, extracts and dumps the files of an HFS or hybrid ISO/HFS disc image",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		PreRunHandler()
+
+		return hfs.Hfs(args[0], hfsOutput, hfsExtract, hfsDump)
+	},
+}
+
+func init() {
+	hfsCmd.Flags().StringVarP(&hfsOutput, "output", "o", "", "folder to extract to, defaults to the dump folder")
+	hfsCmd.Flags().BoolVarP(&hfsExtract, "extract", "x", false, "extract the data and resource forks of every file")
+	hfsCmd.Flags().BoolVar(&hfsDump, "dump", false, "extract and dump every movie, cast and projector")
+	rootCmd.AddCommand(hfsCmd)
+}
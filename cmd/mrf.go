@@ -13,9 +13,8 @@ var mrfCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		PreRunHandler()
-		mrf.Dump(args[0])
 
-		return nil
+		return mrf.Dump(args[0])
 	},
 }
 
@@ -0,0 +1,34 @@
+//go:build !js
+
+package cmd
+
+import (
+	"github.com/markhughes/dirry/internal/patch"
+	"github.com/spf13/cobra"
+)
+
+var (
+	patchMember string
+	patchFile   string
+	patchOutput string
+)
+
+var patchCmd = &cobra.Command{
+	Use:   "patch <filePath> --member castLib:number --file <input>",
+	Short: "Replaces a bitmap with an image or a text member with UTF-8 text and writes the movie back out",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		PreRunHandler()
+
+		return patch.Patch(args[0], patchMember, patchFile, patchOutput)
+	},
+}
+
+func init() {
+	patchCmd.Flags().StringVarP(&patchMember, "member", "m", "", "member to replace as castLib:number, e.g. 1:12")
+	patchCmd.Flags().StringVarP(&patchFile, "file", "f", "", "image or text file to put in the member")
+	patchCmd.Flags().StringVarP(&patchOutput, "output", "o", "", "folder to write to, defaults to the dump folder")
+	patchCmd.MarkFlagRequired("member")
+	patchCmd.MarkFlagRequired("file")
+	rootCmd.AddCommand(patchCmd)
+}
@@ -0,0 +1,30 @@
+//go:build !js
+
+package cmd
+
+import (
+	"github.com/markhughes/dirry/internal/rewrite"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rewriteOutput string
+	rewriteVerify bool
+)
+
+var rewriteCmd = &cobra.Command{
+	Use:   "rewrite <filePath>",
+	Short: "Reads a movie or cast and writes it back out, checking nothing was lost with --verify",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		PreRunHandler()
+
+		return rewrite.Rewrite(args[0], rewriteOutput, rewriteVerify)
+	},
+}
+
+func init() {
+	rewriteCmd.Flags().StringVarP(&rewriteOutput, "output", "o", "", "folder to write to, defaults to the dump folder")
+	rewriteCmd.Flags().BoolVar(&rewriteVerify, "verify", false, "read the written file back and compare every resource")
+	rootCmd.AddCommand(rewriteCmd)
+}
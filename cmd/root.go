@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/markhughes/dirry/internal/consts"
 	"github.com/markhughes/dirry/internal/utils"
 	"github.com/spf13/cobra"
 )
@@ -47,6 +48,12 @@ func PreRunHandler() {
 		utils.EnableLogging = true
 	}
 
+	if consts.DirryRootError != nil {
+		utils.WarnMsg("dirry", "Could not get dirry root: %s", consts.DirryRootError)
+	} else {
+		utils.InfoMsg("dirry", "dirryRoot: %s", consts.DirryRoot)
+	}
+
 	if verbose != "" {
 		if verbose == "all" {
 			utils.EnabledDebugAll = true
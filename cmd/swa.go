@@ -9,12 +9,13 @@ import (
 
 	"github.com/markhughes/dirry/internal/consts"
 	"github.com/markhughes/dirry/internal/swa"
+	"github.com/markhughes/dirry/internal/utils"
 	"github.com/spf13/cobra"
 )
 
 var swaCmd = &cobra.Command{
 	Use:   "swa",
-	Short: "(unstable) Convert an SWA to a MP3 - it's not really working",
+	Short: "Convert an SWA (Shockwave Audio) to a MP3",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		PreRunHandler()
@@ -31,24 +32,39 @@ var swaCmd = &cobra.Command{
 		}
 		outputFileName = outputFileName + ".mp3"
 
-		fmt.Printf("Converting %v\n", filePath)
+		utils.InfoMsg("swa", "Converting %s", filePath)
 
-		bytes, fileExtension, err := swa.Swa2Mp3FromFile(filePath)
+		data, err := os.ReadFile(filePath)
 		if err != nil {
-			return err
-		} else {
-			outputFolder := filepath.Join(consts.PathDump, filepath.Base(filePath), "resources", fileExtension)
-			os.MkdirAll(outputFolder, os.ModePerm)
-
-			outputFile := filepath.Join(outputFolder, outputFileName)
+			return fmt.Errorf("could not read %s: %s", filePath, err)
+		}
 
-			err = os.WriteFile(outputFile, bytes, 0644)
+		if swa.IsSwa(data) {
+			parsed, err := swa.Parse(data)
 			if err != nil {
 				return err
 			}
 
+			header := parsed.Header
+			utils.InfoMsg("swa", "%d Hz, %d channels, %d kbps, %d frames (%.2fs), %dms preload", header.SampleRate, header.Channels, header.Bitrate/1000, header.FrameCount, header.Stream.Duration, header.Preload)
+
+			data = parsed.MP3
+		} else if data, _, err = swa.Swa2Mp3FromBytes(data); err != nil {
+			return err
+		}
+
+		outputFolder := filepath.Join(consts.PathDump, filepath.Base(filePath), "resources", "mp3")
+		os.MkdirAll(outputFolder, os.ModePerm)
+
+		outputFile := filepath.Join(outputFolder, outputFileName)
+
+		err = os.WriteFile(outputFile, data, 0644)
+		if err != nil {
+			return err
 		}
 
+		utils.SuccessMsg("swa", "Wrote %s", outputFile)
+
 		return nil
 	},
 }
@@ -0,0 +1,26 @@
+//go:build !js
+
+package cmd
+
+import (
+	"github.com/markhughes/dirry/internal/unprotect"
+	"github.com/spf13/cobra"
+)
+
+var unprotectOutput string
+
+var unprotectCmd = &cobra.Command{
+	Use:   "unprotect <filePath>",
+	Short: "Converts a shockwave (.dcr/.cct) or protected movie back into an editable .dir/.cst",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		PreRunHandler()
+
+		return unprotect.Unprotect(args[0], unprotectOutput)
+	},
+}
+
+func init() {
+	unprotectCmd.Flags().StringVarP(&unprotectOutput, "output", "o", "", "folder to write to, defaults to the dump folder")
+	rootCmd.AddCommand(unprotectCmd)
+}
@@ -2,29 +2,67 @@ package adf
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 
+	"github.com/markhughes/dirry/internal/consts"
 	"github.com/markhughes/dirry/internal/libadf"
 	"github.com/markhughes/dirry/internal/libmrf"
+	"github.com/markhughes/dirry/internal/mrf"
+	"github.com/markhughes/dirry/internal/utils"
 )
 
-func Dump(filePath string) {
-	// note: this isn't working yet.. for now users should use a manual way to dump the mrf
-
-	var adf, err = libadf.UnpackAdfFromFile(filePath)
+// Dump prints what the AppleDouble or AppleSingle file says of the file it
+// goes with, writes out the data fork when it carries one and dumps the
+// resource fork the same way mrf does
+func Dump(filePath string) error {
+	adf, err := libadf.UnpackAdfFromFile(filePath)
 	if err != nil {
+		return fmt.Errorf("error reading AppleDouble: %s", err)
+	}
 
-		fmt.Printf("Error: %v", err)
+	kind := "AppleDouble"
+	if adf.Single {
+		kind = "AppleSingle"
 	}
 
-	for k, v := range adf {
-		byt, err := libmrf.FromBytes(v)
-		if err != nil {
-			fmt.Printf("Error: %v", err)
-		} else {
-			fmt.Printf("%v\n", k)
-			fmt.Printf("bytes: %v", byt)
+	utils.InfoMsg("adf", "%s version %d", kind, adf.Version>>16)
+	utils.InfoMsg("adf", "Name: %s", adf.RealName)
+	utils.InfoMsg("adf", "Type: %s, creator: %s, flags: %04x", adf.Type, adf.Creator, adf.Flags)
+	if adf.Comment != "" {
+		utils.InfoMsg("adf", "Comment: %s", adf.Comment)
+	}
+	if !adf.Created.IsZero() {
+		utils.InfoMsg("adf", "Created: %s, modified: %s", adf.Created, adf.Modified)
+	}
 
+	if len(adf.DataFork) > 0 {
+		name := adf.RealName
+		if name == "" {
+			name = "data"
 		}
+
+		outputFolder := filepath.Join(consts.PathDump, filepath.Base(filePath), "adf")
+		os.MkdirAll(outputFolder, os.ModePerm)
+
+		fileName := filepath.Join(outputFolder, filepath.Base(filepath.FromSlash(name)))
+		err = os.WriteFile(fileName, adf.DataFork, 0644)
+		if err != nil {
+			return err
+		}
+
+		utils.InfoMsg("adf", "Data fork written to %s", fileName)
+	}
+
+	if len(adf.ResourceFork) == 0 {
+		utils.InfoMsg("adf", "No resource fork")
+		return nil
+	}
+
+	resourceFork, err := libmrf.FromBytes(adf.ResourceFork)
+	if err != nil {
+		return fmt.Errorf("error reading resource fork: %s", err)
 	}
 
+	return mrf.DumpFork(filePath, resourceFork)
 }
@@ -17,8 +17,11 @@ type BitmapInfo struct {
 	Depth                int
 }
 
-func ConvertImage(data []byte, width, height, depth int, palette palettes.PaletteValue) (BitmapInfo, []byte, error) {
-	utils.DebugMsg("bitd", "width: %v, height: %v, bitdepth: %v", width, height, depth)
+// ConvertImage turns BITD data into a PNG. pitch is the bytes per row from
+// the member, 0 when it isn't known. useAlpha keeps the alpha channel of
+// 32 bit images, otherwise they come out opaque.
+func ConvertImage(data []byte, width, height, depth, pitch int, palette palettes.PaletteValue, useAlpha bool) (BitmapInfo, []byte, error) {
+	utils.DebugMsg("bitd", "width: %v, height: %v, bitdepth: %v, pitch: %v", width, height, depth, pitch)
 
 	var err error
 	var converted []byte
@@ -51,6 +54,12 @@ func ConvertImage(data []byte, width, height, depth int, palette palettes.Palett
 			return info, nil, fmt.Errorf("failed to convert 8 bit image: %s", err)
 		}
 
+	case 16:
+		info, converted, err = Convert16BitImage(data, width, height, pitch)
+		if err != nil {
+			return info, nil, fmt.Errorf("failed to convert 16 bit image: %s", err)
+		}
+
 	case 24:
 		info, converted, err = Convert24BitImage(data, width, height)
 		if err != nil {
@@ -58,7 +67,7 @@ func ConvertImage(data []byte, width, height, depth int, palette palettes.Palett
 		}
 
 	case 32:
-		info, converted, err = Convert32BitImage(data, width, height)
+		info, converted, err = Convert32BitImage(data, width, height, useAlpha)
 		if err != nil {
 			return info, nil, fmt.Errorf("failed to convert 32 bit image: %s", err)
 		}
@@ -119,7 +128,7 @@ func Convert1BitImage(data []byte, width, height int) (BitmapInfo, []byte, error
 	}
 
 	var buf bytes.Buffer
-	if png.Encode(&buf, img) != nil {
+	if err := png.Encode(&buf, img); err != nil {
 		return info, nil, fmt.Errorf("failed to encode image: %s", err)
 	}
 
@@ -163,7 +172,7 @@ func Convert2BitImage(data []byte, width, height int) (BitmapInfo, []byte, error
 	}
 
 	var buf bytes.Buffer
-	if png.Encode(&buf, img) != nil {
+	if err := png.Encode(&buf, img); err != nil {
 		return info, nil, fmt.Errorf("failed to encode image: %s", err)
 	}
 
@@ -216,7 +225,7 @@ func Convert4BitImage(data []byte, width, height int, palette palettes.PaletteVa
 	}
 
 	var buf bytes.Buffer
-	if png.Encode(&buf, img) != nil {
+	if err := png.Encode(&buf, img); err != nil {
 		return info, nil, fmt.Errorf("failed to encode image: %s", err)
 	}
 
@@ -263,7 +272,74 @@ func Convert8BitImage(data []byte, width, height int, palette palettes.PaletteVa
 	}
 
 	var buf bytes.Buffer
-	if png.Encode(&buf, img) != nil {
+	if err := png.Encode(&buf, img); err != nil {
+		return info, nil, fmt.Errorf("failed to encode image: %s", err)
+	}
+
+	return info, buf.Bytes(), nil
+}
+
+// Convert16BitImage reads RGB555 (thousands of colours). Uncompressed rows
+// are big endian pixels, packed rows have the high bytes then the low bytes.
+func Convert16BitImage(data []byte, width, height, pitch int) (BitmapInfo, []byte, error) {
+	info := BitmapInfo{}
+	info.Depth = 16
+
+	// rows are padded out to an even number of pixels
+	if pitch < width*2 {
+		pitch = (width*2 + 3) &^ 3
+	}
+
+	var err error
+	planar := false
+	if len(data) == pitch*height {
+		data, err = unpackPackbits1(data)
+		info.IsPackBitsCompressed = false
+		info.PackbitsCompression = 1
+	} else if len(data) == width*2*height {
+		// some older files don't pad the rows
+		pitch = width * 2
+		data, err = unpackPackbits1(data)
+		info.IsPackBitsCompressed = false
+		info.PackbitsCompression = 1
+	} else {
+		data, err = unpackPackbits16(data, pitch/2)
+		info.IsPackBitsCompressed = true
+		info.PackbitsCompression = 16
+		planar = true
+	}
+
+	if err != nil {
+		return info, nil, fmt.Errorf("failed to decompress image data: %s", err)
+	}
+
+	if len(data) < pitch*height {
+		return info, nil, fmt.Errorf("expected %d bytes of image data, have %d", pitch*height, len(data))
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		row := data[y*pitch : (y+1)*pitch]
+		for x := 0; x < width; x++ {
+			var pixel uint16
+			if planar {
+				pixel = uint16(row[x])<<8 | uint16(row[x+pitch/2])
+			} else {
+				pixel = uint16(row[x*2])<<8 | uint16(row[x*2+1])
+			}
+
+			r := uint8(pixel>>10) & 0x1f
+			g := uint8(pixel>>5) & 0x1f
+			b := uint8(pixel) & 0x1f
+
+			// spread 5 bits over 8 so white stays white
+			img.Set(x, y, color.RGBA{R: r<<3 | r>>2, G: g<<3 | g>>2, B: b<<3 | b>>2, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
 		return info, nil, fmt.Errorf("failed to encode image: %s", err)
 	}
 
@@ -305,14 +381,14 @@ func Convert24BitImage(data []byte, width, height int) (BitmapInfo, []byte, erro
 	}
 
 	var buf bytes.Buffer
-	if png.Encode(&buf, img) != nil {
+	if err := png.Encode(&buf, img); err != nil {
 		return info, nil, fmt.Errorf("failed to encode image: %s", err)
 	}
 
 	return info, buf.Bytes(), nil
 }
 
-func Convert32BitImage(data []byte, width, height int) (BitmapInfo, []byte, error) {
+func Convert32BitImage(data []byte, width, height int, useAlpha bool) (BitmapInfo, []byte, error) {
 	info := BitmapInfo{}
 	info.Depth = 32
 
@@ -333,22 +409,31 @@ func Convert32BitImage(data []byte, width, height int) (BitmapInfo, []byte, erro
 		return info, nil, fmt.Errorf("failed to decompress image data: %s", err)
 	}
 
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if len(data) < uncompressedSize {
+		return info, nil, fmt.Errorf("expected %d bytes of image data, have %d", uncompressedSize, len(data))
+	}
+
+	// not premultiplied, the colour of see through pixels is kept as is
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
 
-	// Iterate over the decompressed stuff
+	// the unpackers give RGB then the alpha flipped over
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
-			r := data[(y*width+x)*4+1]
-			g := data[(y*width+x)*4+2]
-			b := data[(y*width+x)*4+3]
-			a := 255 - data[(y*width+x)*4]
+			r := data[(y*width+x)*4]
+			g := data[(y*width+x)*4+1]
+			b := data[(y*width+x)*4+2]
+
+			a := uint8(255)
+			if useAlpha {
+				a = 255 - data[(y*width+x)*4+3]
+			}
 
-			img.Set(x, y, color.RGBA{R: r, G: g, B: b, A: a})
+			img.SetNRGBA(x, y, color.NRGBA{R: r, G: g, B: b, A: a})
 		}
 	}
 
 	var buf bytes.Buffer
-	if png.Encode(&buf, img) != nil {
+	if err := png.Encode(&buf, img); err != nil {
 		return info, nil, fmt.Errorf("failed to encode image: %s", err)
 	}
 
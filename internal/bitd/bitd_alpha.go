@@ -0,0 +1,95 @@
+package bitd
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+)
+
+// MergeAlpha puts the alpha plane of an ALFA chunk onto a converted image.
+// The plane is one byte per pixel, either raw or packed like an 8 bit BITD.
+func MergeAlpha(converted []byte, alpha []byte, width, height int) ([]byte, error) {
+	plane, rowBytes, err := unpackAlpha(alpha, width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := png.Decode(bytes.NewReader(converted))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode converted image: %s", err)
+	}
+
+	img := image.NewNRGBA(decoded.Bounds())
+	draw.Draw(img, img.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+
+	bounds := img.Bounds()
+	for y := 0; y < height && y < bounds.Dy(); y++ {
+		for x := 0; x < width && x < bounds.Dx(); x++ {
+			img.Pix[y*img.Stride+x*4+3] = plane[y*rowBytes+x]
+		}
+	}
+
+	var buf bytes.Buffer
+	err = png.Encode(&buf, img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %s", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func unpackAlpha(alpha []byte, width, height int) ([]byte, int, error) {
+	if height <= 0 || width <= 0 {
+		return nil, 0, fmt.Errorf("bad alpha size %dx%d", width, height)
+	}
+
+	// rows are padded to an even width like 8 bit images
+	paddedWidth := (width + 1) &^ 1
+
+	var plane []byte
+	switch len(alpha) {
+	case width * height, paddedWidth * height:
+		plane = alpha
+	default:
+		var err error
+		plane, err = unpackPackbits8(alpha)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to decompress alpha: %s", err)
+		}
+	}
+
+	rowBytes := len(plane) / height
+	if rowBytes < width {
+		return nil, 0, fmt.Errorf("alpha has %d bytes, too short for %dx%d", len(plane), width, height)
+	}
+
+	return plane, rowBytes, nil
+}
+
+// EncodeAlpha makes an ALFA plane from the alpha of an image, packed like
+// an 8 bit BITD unless that doesn't make it any smaller
+func EncodeAlpha(img image.Image) []byte {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+
+	var raw, packed bytes.Buffer
+
+	row := make([]byte, (width+1)&^1)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := 0; x < width; x++ {
+			_, _, _, a := img.At(bounds.Min.X+x, y).RGBA()
+			row[x] = uint8(a >> 8)
+		}
+
+		raw.Write(row)
+		packed.Write(packBits(row))
+	}
+
+	if packed.Len() >= raw.Len() {
+		return raw.Bytes()
+	}
+
+	return packed.Bytes()
+}
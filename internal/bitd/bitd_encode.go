@@ -0,0 +1,154 @@
+package bitd
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/markhughes/dirry/internal/palettes"
+)
+
+// Pitch is how many bytes a row of a BITD takes. Rows up to 8 bits are
+// padded to a whole 16 bits, thousands of colours to 32 bits.
+func Pitch(width, depth int) int {
+	switch depth {
+	case 16:
+		return (width*2 + 3) &^ 3
+	case 24:
+		return width * 3
+	case 32:
+		return width * 4
+	default:
+		return (width*depth + 15) / 16 * 2
+	}
+}
+
+// EncodeImage makes the BITD data for an image at the given depth, the
+// other way round from ConvertImage. Each row is packed on its own and the
+// rows are written raw when packing doesn't make them any smaller.
+// Colours are matched to the nearest in the palette up to 8 bits.
+func EncodeImage(img image.Image, depth int, palette palettes.PaletteValue) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	pitch := Pitch(width, depth)
+
+	var raw, packed bytes.Buffer
+
+	row := make([]byte, pitch)
+	for y := 0; y < height; y++ {
+		for i := range row {
+			row[i] = 0
+		}
+
+		for x := 0; x < width; x++ {
+			pixel := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+
+			switch depth {
+			case 1:
+				// set bits come out white from Convert1BitImage
+				if gray(pixel) >= 128 {
+					row[x/8] |= 0x80 >> uint(x%8)
+				}
+
+			case 2:
+				level := byte((int(gray(pixel)) + 42) / 85)
+				row[x/4] |= level << uint((3-x%4)*2)
+
+			case 4:
+				index := nearest(pixel, palette, 16)
+				if x%2 == 0 {
+					row[x/2] |= index << 4
+				} else {
+					row[x/2] |= index
+				}
+
+			case 8:
+				row[x] = nearest(pixel, palette, 256)
+
+			case 16:
+				// the high bytes of the row then the low bytes
+				value := uint16(pixel.R>>3)<<10 | uint16(pixel.G>>3)<<5 | uint16(pixel.B>>3)
+				row[x] = byte(value >> 8)
+				row[x+pitch/2] = byte(value)
+
+			case 24:
+				row[x] = pixel.R
+				row[x+width] = pixel.G
+				row[x+width*2] = pixel.B
+
+			case 32:
+				row[x] = pixel.A
+				row[x+width] = pixel.R
+				row[x+width*2] = pixel.G
+				row[x+width*3] = pixel.B
+
+			default:
+				return nil, fmt.Errorf("unsupported bit depth: %d", depth)
+			}
+		}
+
+		raw.Write(rawRow(row, depth, width))
+		packed.Write(packBits(row))
+	}
+
+	if packed.Len() >= raw.Len() {
+		return raw.Bytes(), nil
+	}
+
+	return packed.Bytes(), nil
+}
+
+// rawRow is a packed row as it's stored uncompressed, the planes of the
+// deeper images are interleaved back into pixels
+func rawRow(row []byte, depth, width int) []byte {
+	planes := 0
+	switch depth {
+	case 16:
+		planes, width = 2, len(row)/2
+	case 24:
+		planes = 3
+	case 32:
+		planes = 4
+	default:
+		return row
+	}
+
+	out := make([]byte, len(row))
+	for x := 0; x < width; x++ {
+		for plane := 0; plane < planes; plane++ {
+			out[x*planes+plane] = row[x+width*plane]
+		}
+	}
+
+	return out
+}
+
+func gray(pixel color.NRGBA) uint8 {
+	return uint8((299*int(pixel.R) + 587*int(pixel.G) + 114*int(pixel.B)) / 1000)
+}
+
+// nearest finds the closest colour in the first count entries of a palette
+func nearest(pixel color.NRGBA, palette palettes.PaletteValue, count int) uint8 {
+	if palette.Size > 0 && int(palette.Size) < count {
+		count = int(palette.Size)
+	}
+
+	best, bestDistance := 0, -1
+	for i := 0; i < count; i++ {
+		entry := palette.Palette[i]
+		dr := int(entry.R) - int(pixel.R)
+		dg := int(entry.G) - int(pixel.G)
+		db := int(entry.B) - int(pixel.B)
+
+		distance := dr*dr + dg*dg + db*db
+		if bestDistance == -1 || distance < bestDistance {
+			best, bestDistance = i, distance
+			if distance == 0 {
+				break
+			}
+		}
+	}
+
+	return uint8(best)
+}
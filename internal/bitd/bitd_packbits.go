@@ -71,6 +71,62 @@ func unpackPackbits8(input []byte) ([]byte, error) {
 	return output.Bytes(), nil
 }
 
+// unpackPackbits16 keeps the rows planar, each row of the output is the
+// high bytes of the pixels followed by the low bytes
+func unpackPackbits16(input []byte, width int) ([]byte, error) {
+	reader := bytes.NewReader(input)
+
+	var output bytes.Buffer
+
+	buffer := make([]byte, width*2)
+
+	j := 0
+	for {
+		var count int8
+		err := binary.Read(reader, binary.BigEndian, &count)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read count byte: %s", err)
+		}
+
+		if count >= 0 {
+			data := make([]byte, count+1)
+			_, err := io.ReadFull(reader, data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read data bytes: %s", err)
+			}
+
+			if j+len(data) > len(buffer) {
+				return nil, fmt.Errorf("packed run crosses a row")
+			}
+			copy(buffer[j:], data)
+			j += len(data)
+		} else {
+			var data byte
+			err := binary.Read(reader, binary.BigEndian, &data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read data byte: %s", err)
+			}
+
+			if j+int(1-count) > len(buffer) {
+				return nil, fmt.Errorf("packed run crosses a row")
+			}
+			for i := int8(0); i < 1-count; i++ {
+				buffer[j] = data
+				j++
+			}
+		}
+
+		if j == len(buffer) {
+			output.Write(buffer)
+			j = 0
+		}
+	}
+
+	return output.Bytes(), nil
+}
+
 func unpackPackbits24(input []byte, width int) ([]byte, error) {
 	reader := bytes.NewReader(input)
 
@@ -178,3 +234,39 @@ func unpackPackbits32(input []byte, width int) ([]byte, error) {
 
 	return output.Bytes(), nil
 }
+
+// packBits is the other way round from unpackPackbits8. Runs of three or
+// more bytes are repeated and anything else is copied. The unpackers count
+// in an int8 so neither goes over 127 bytes.
+func packBits(input []byte) []byte {
+	var output bytes.Buffer
+
+	i := 0
+	for i < len(input) {
+		run := 1
+		for i+run < len(input) && run < 127 && input[i+run] == input[i] {
+			run++
+		}
+
+		if run >= 3 {
+			output.WriteByte(byte(int8(1 - run)))
+			output.WriteByte(input[i])
+			i += run
+			continue
+		}
+
+		// copy up to the start of the next run
+		start := i
+		for i < len(input) && i-start < 127 {
+			if i+2 < len(input) && input[i] == input[i+1] && input[i] == input[i+2] {
+				break
+			}
+			i++
+		}
+
+		output.WriteByte(byte(i - start - 1))
+		output.Write(input[start:i])
+	}
+
+	return output.Bytes()
+}
@@ -20,7 +20,7 @@ func Convert(filepath string, width int, height int, bitdepth int) {
 		panic(err)
 	}
 
-	info, bytes, err := bitd.ConvertImage(data, width, height, bitdepth, pallette)
+	info, bytes, err := bitd.ConvertImage(data, width, height, bitdepth, 0, pallette, true)
 	if err != nil {
 		panic(err)
 	}
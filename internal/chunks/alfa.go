@@ -0,0 +1,52 @@
+package chunks
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/markhughes/dirry/internal/binary_reader"
+)
+
+// AlfaChunk is the alpha plane of a bitmap member, kept apart from its BITD
+type AlfaChunk struct {
+	Reader *binary_reader.BinaryReader `json:"-"`
+
+	Length int
+	Data   []byte `json:"-"`
+}
+
+func (chunk *AlfaChunk) Read(endian binary.ByteOrder) error {
+	var err error
+
+	chunk.Length = int(chunk.Reader.Length)
+	chunk.Data, err = chunk.Reader.ReadBytes(chunk.Length)
+	if err != nil {
+		return fmt.Errorf("error reading alpha data: %s", err)
+	}
+
+	return nil
+}
+
+func ReadAlfaChunkRaw(r *binary_reader.BinaryReader, endian binary.ByteOrder, isAfterburner bool) (*AlfaChunk, error) {
+	chunk := &AlfaChunk{
+		Reader: r,
+	}
+
+	r.Seek(0, 0)
+	err := chunk.Read(endian)
+	if err != nil {
+		return nil, err
+	}
+
+	return chunk, nil
+}
+
+func (c *AlfaChunk) ToJSON() (string, error) {
+	bytes, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"image"
 	"os"
 	"path/filepath"
 
@@ -22,9 +23,12 @@ type BitmapChunk struct {
 	Member  *members.MemberBitmap
 	Data    []byte
 	Info    bitd.BitmapInfo
+
+	// HasAlpha is set once an ALFA chunk has been merged in
+	HasAlpha bool
 }
 
-func (chunk *BitmapChunk) Read(endian binary.ByteOrder, castChunk *CastChunk) error {
+func (chunk *BitmapChunk) Read(endian binary.ByteOrder, castChunk *CastChunk, castLib int) error {
 	var err error
 
 	if member, ok := castChunk.Member.(*members.MemberBitmap); ok {
@@ -33,8 +37,7 @@ func (chunk *BitmapChunk) Read(endian binary.ByteOrder, castChunk *CastChunk) er
 		return fmt.Errorf("castChunk.Member is not a BitmapCastMember")
 	}
 
-	var clut palettes.PaletteValue
-	clut, err = palettes.RetrievePallete(palettes.Clut(chunk.Member.Clut))
+	clut, err := chunk.palette(castLib)
 	if err != nil {
 		return err
 	}
@@ -73,7 +76,7 @@ func (chunk *BitmapChunk) Read(endian binary.ByteOrder, castChunk *CastChunk) er
 		return err
 	}
 
-	chunk.Info, chunk.Data, err = bitd.ConvertImage(data, int(width), int(height), int(chunk.Member.BitsPerPixel), clut)
+	chunk.Info, chunk.Data, err = bitd.ConvertImage(data, int(width), int(height), int(chunk.Member.BitsPerPixel), int(chunk.Member.Pitch), clut, chunk.Member.UseAlpha())
 	if err != nil {
 		return err
 	}
@@ -81,7 +84,56 @@ func (chunk *BitmapChunk) Read(endian binary.ByteOrder, castChunk *CastChunk) er
 
 }
 
-func ReadBitmapChunkRaw(r *binary_reader.BinaryReader, endian binary.ByteOrder, castChunk *CastChunk, isAfterburner bool) (*BitmapChunk, error) {
+// palette finds the member's palette, builtin ones are negative and anything
+// else is a palette member. Without a library the palette is in the same
+// cast as the bitmap.
+func (chunk *BitmapChunk) palette(castLib int) (palettes.PaletteValue, error) {
+	if chunk.Member.Clut <= 0 {
+		return palettes.RetrievePallete(palettes.Clut(chunk.Member.Clut))
+	}
+
+	lib := chunk.Member.CastMemberID
+	if lib <= 0 {
+		lib = castLib
+	}
+	if lib <= 0 {
+		lib = 1
+	}
+
+	return palettes.RetrieveMemberPallete(palettes.Member{CastLib: lib, Number: int(chunk.Member.Clut)})
+}
+
+// EncodeBitmap makes the BITD data of an image for a member, at its depth
+// and with its palette
+func EncodeBitmap(member *members.MemberBitmap, castLib int, img image.Image) ([]byte, error) {
+	chunk := &BitmapChunk{Member: member}
+
+	var clut palettes.PaletteValue
+	if member.BitsPerPixel == 4 || member.BitsPerPixel == 8 {
+		var err error
+		clut, err = chunk.palette(castLib)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return bitd.EncodeImage(img, int(member.BitsPerPixel), clut)
+}
+
+// MergeAlpha applies the alpha plane from the member's ALFA chunk
+func (chunk *BitmapChunk) MergeAlpha(alpha *AlfaChunk) error {
+	data, err := bitd.MergeAlpha(chunk.Data, alpha.Data, int(chunk.Member.InitialRect.Width), int(chunk.Member.InitialRect.Height))
+	if err != nil {
+		return err
+	}
+
+	chunk.Data = data
+	chunk.HasAlpha = true
+
+	return nil
+}
+
+func ReadBitmapChunkRaw(r *binary_reader.BinaryReader, endian binary.ByteOrder, castChunk *CastChunk, castLib int, isAfterburner bool) (*BitmapChunk, error) {
 	var err error
 
 	chunk := &BitmapChunk{
@@ -91,7 +143,7 @@ func ReadBitmapChunkRaw(r *binary_reader.BinaryReader, endian binary.ByteOrder,
 	chunk.Reader.HexDump(true)
 
 	r.Seek(0, 0)
-	err = chunk.Read(binary.BigEndian, castChunk)
+	err = chunk.Read(binary.BigEndian, castChunk, castLib)
 	if err != nil {
 		return nil, err
 	}
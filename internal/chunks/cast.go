@@ -111,6 +111,9 @@ type CastChunk struct {
 
 	Properties CommonMemberProperties
 
+	// ScriptId is the 1-based index of the member script in the LctX
+	ScriptId uint32
+
 	Member CastMember
 
 	BasicContent []string
@@ -119,14 +122,30 @@ type CastChunk struct {
 	PropertyOffsets []int32
 }
 
-func (chunk *CastChunk) Save(projectName string, name string, pkg string) {
-	var outputFolder string
+func castOutputFolder(projectName string, name string, pkg string) string {
 	if pkg == "" {
-		outputFolder = filepath.Join(consts.PathDump, projectName, "converted", "CASt", name)
-	} else {
-		outputFolder = filepath.Join(consts.PathDump, pkg, "file", projectName, "converted", "CASt", name)
+		return filepath.Join(consts.PathDump, projectName, "converted", "CASt", name)
 	}
 
+	return filepath.Join(consts.PathDump, pkg, "file", projectName, "converted", "CASt", name)
+}
+
+// SaveFile writes extra output for the member next to what Save writes,
+// e.g. the script disassembly
+func (chunk *CastChunk) SaveFile(projectName string, name string, pkg string, fileName string, content string) error {
+	var outputFolder = castOutputFolder(projectName, name, pkg)
+
+	err := os.MkdirAll(outputFolder, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(outputFolder, fileName), []byte(content), 0644)
+}
+
+func (chunk *CastChunk) Save(projectName string, name string, pkg string) {
+	var outputFolder = castOutputFolder(projectName, name, pkg)
+
 	os.MkdirAll(outputFolder, os.ModePerm)
 
 	// save name to name.txt
@@ -304,6 +323,7 @@ func ReadCastChunkRaw(r *bytes.Reader, v version.Version, endian binary.ByteOrde
 
 		basicData03 := binary.BigEndian.Uint32(basicData[16:20])
 		// log.Printf("basicData03 = %08x\n", basicData03)
+		chunk.ScriptId = basicData03
 
 		// fmt.Printf("basicData = %v\n", hex.Dump(basicData[21:]))
 		chunk.BasicContent = make([]string, 0)
@@ -334,8 +354,9 @@ func ReadCastChunkRaw(r *bytes.Reader, v version.Version, endian binary.ByteOrde
 			}
 
 			var basicDataReader = bytes.NewReader(basicData)
-			// 22 + nstruct*4
-			var what = make([]byte, 22+nstruct*4)
+			// the offset table has nstruct+1 entries, the last one is the
+			// length of the items, and the items follow straight after it
+			var what = make([]byte, 22+(int(nstruct)+1)*4)
 			basicDataReader.Read(what)
 
 			for i := 0; i < int(nstruct); i++ {
@@ -368,17 +389,15 @@ func ReadCastChunkRaw(r *bytes.Reader, v version.Version, endian binary.ByteOrde
 
 					case 9:
 						var guid [16]byte
-						copy(guid[:], data[:16])
+						copy(guid[:], data)
 						chunk.Properties.XtraGUID = guid
 
 					case 10:
 						chunk.Properties.XtraName = value
 
 					case 12:
-						for i := 0; i < stlen/4; i++ {
-							var point int32
-							binary.Read(bytes.NewReader(basicData[chunk.PropertyOffsets[i]+22:chunk.PropertyOffsets[i]+26]), binary.BigEndian, &point)
-							chunk.Properties.RegistrationPoints = append(chunk.Properties.RegistrationPoints, point)
+						for j := 0; j+4 <= stlen; j += 4 {
+							chunk.Properties.RegistrationPoints = append(chunk.Properties.RegistrationPoints, int32(binary.BigEndian.Uint32(data[j:])))
 						}
 
 					case 16:
@@ -386,12 +405,12 @@ func ReadCastChunkRaw(r *bytes.Reader, v version.Version, endian binary.ByteOrde
 
 					case 17:
 						var date int32
-						binary.Read(bytes.NewReader(basicData[chunk.PropertyOffsets[i]+22:chunk.PropertyOffsets[i]+26]), binary.BigEndian, &date)
+						binary.Read(bytes.NewReader(data), binary.BigEndian, &date)
 						chunk.Properties.CreationDate = date * 1000
 
 					case 18:
 						var date int32
-						binary.Read(bytes.NewReader(basicData[chunk.PropertyOffsets[i]+22:chunk.PropertyOffsets[i]+26]), binary.BigEndian, &date)
+						binary.Read(bytes.NewReader(data), binary.BigEndian, &date)
 
 						chunk.Properties.ModifiedDate = date * 1000
 
@@ -403,12 +422,12 @@ func ReadCastChunkRaw(r *bytes.Reader, v version.Version, endian binary.ByteOrde
 
 					case 21:
 						var compression int32
-						binary.Read(bytes.NewReader(basicData[chunk.PropertyOffsets[i]+22:chunk.PropertyOffsets[i]+26]), binary.BigEndian, &compression)
+						binary.Read(bytes.NewReader(data), binary.BigEndian, &compression)
 						chunk.Properties.ImageCompression = int(compression)
 
 					case 22:
 						var quality int32
-						binary.Read(bytes.NewReader(basicData[chunk.PropertyOffsets[i]+22:chunk.PropertyOffsets[i]+26]), binary.BigEndian, &quality)
+						binary.Read(bytes.NewReader(data), binary.BigEndian, &quality)
 						chunk.Properties.ImageQuality = int(quality)
 
 					}
@@ -436,6 +455,9 @@ func ReadCastChunkRaw(r *bytes.Reader, v version.Version, endian binary.ByteOrde
 		case Xtra:
 			xtraMember := &members.MemberXtra{}
 			chunk.Member = xtraMember
+		case FilmLoop:
+			filmLoopMember := &members.MemberFilmLoop{}
+			chunk.Member = filmLoopMember
 		default:
 			return chunk, &errors.UnhandledCastTypeError{CastTypeName: CastType(dataType).String(), CastType: dataType}
 		}
@@ -449,6 +471,46 @@ func ReadCastChunkRaw(r *bytes.Reader, v version.Version, endian binary.ByteOrde
 
 }
 
+// CastSpecificData finds the member data in a CASt, what ReadCastChunkRaw
+// gives the member to decode, so it can be changed where it is
+func CastSpecificData(b []byte, endian binary.ByteOrder) (int, int, error) {
+	if len(b) < 12 {
+		return 0, 0, fmt.Errorf("cast is only %d bytes", len(b))
+	}
+
+	dataType := int32(endian.Uint32(b))
+	if (int64(dataType) & 0xFFFFFF00) == 0 {
+		additionalSize := int(int32(endian.Uint32(b[4:])))
+		headerSize := int(int32(endian.Uint32(b[8:])))
+
+		offset := 12 + additionalSize
+		if additionalSize < 0 || headerSize < 0 || offset+headerSize > len(b) {
+			return 0, 0, fmt.Errorf("[d5] cast data is outside the chunk")
+		}
+
+		return offset, headerSize, nil
+	}
+
+	if len(b) < 14 {
+		return 0, 0, fmt.Errorf("[d4] cast is only %d bytes", len(b))
+	}
+
+	headerSize := int(int16(endian.Uint16(b[4:])))
+
+	// the type is either a whole int32 or just its first byte
+	offset := 14
+	dataType = int32(endian.Uint32(b[10:]))
+	if dataType < 1 || dataType > 15 {
+		offset = 11
+	}
+
+	if headerSize < 1 || offset+headerSize-1 > len(b) {
+		return 0, 0, fmt.Errorf("[d4] cast data is outside the chunk")
+	}
+
+	return offset, headerSize - 1, nil
+}
+
 func (c *CastChunk) ToJSON() (string, error) {
 	bytes, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
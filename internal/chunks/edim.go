@@ -10,6 +10,7 @@ import (
 
 	"github.com/h2non/filetype"
 	"github.com/markhughes/dirry/internal/consts"
+	"github.com/markhughes/dirry/internal/swa"
 )
 
 type EdimChunk struct {
@@ -17,6 +18,9 @@ type EdimChunk struct {
 	Extension string
 	MIME      string
 	Binary    []byte
+
+	// Swa is set when the media was Shockwave Audio, Binary is then the MP3
+	Swa *swa.Header
 }
 
 func ReadEdimChunkRaw(r *bytes.Reader, length int, endian binary.ByteOrder) (*EdimChunk, error) {
@@ -55,6 +59,19 @@ func processEdimChunk(chunk *EdimChunk, endian binary.ByteOrder) (*EdimChunk, er
 	chunk.Extension = kind.Extension
 	chunk.MIME = kind.MIME.Value
 
+	// sound members compressed to SWA keep the container in here
+	if kind == filetype.Unknown && swa.IsSwa(chunk.Binary) {
+		parsed, err := swa.Parse(chunk.Binary)
+		if err != nil {
+			return nil, err
+		}
+
+		chunk.Swa = &parsed.Header
+		chunk.Binary = parsed.MP3
+		chunk.Extension = "mp3"
+		chunk.MIME = "audio/mpeg"
+	}
+
 	return chunk, nil
 }
 
@@ -4,11 +4,21 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
 
 	"github.com/markhughes/dirry/internal/binary_reader"
 	"github.com/markhughes/dirry/internal/utils"
 )
 
+// where the config says which platform the movie was saved on, from
+// Director 4 on
+const (
+	configPlatformOffset = 56
+
+	PlatformMac     = -1
+	PlatformWindows = 1024
+)
+
 type InfoChunk struct {
 	Reader *binary_reader.BinaryReader
 
@@ -32,6 +42,10 @@ type InfoChunk struct {
 	CommentFont  int16
 	CommentSize  int16
 	CommentStyle int16
+
+	// Platform is PlatformMac or PlatformWindows, zero in a config too old
+	// to have it
+	Platform int16
 }
 
 func (chunk *InfoChunk) Read(endian binary.ByteOrder) error {
@@ -100,6 +114,14 @@ func (chunk *InfoChunk) Read(endian binary.ByteOrder) error {
 		return err
 	}
 
+	if chunk.Reader.Size() >= configPlatformOffset+2 {
+		chunk.Reader.Seek(configPlatformOffset, io.SeekStart)
+		chunk.Platform, err = chunk.Reader.ReadInt16(binary.BigEndian)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 
 }
@@ -4,10 +4,50 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
 
 	"github.com/markhughes/dirry/internal/binary_reader"
+	"github.com/markhughes/dirry/internal/version"
 )
 
+// Literal types in the Lscr literal table
+const (
+	LiteralString = 1
+	LiteralInt    = 4
+	LiteralFloat  = 9
+)
+
+type LscrLiteral struct {
+	Type   uint32
+	Offset uint32
+	Value  interface{}
+}
+
+type LscrHandler struct {
+	NameID         int16
+	VectorPos      uint16
+	CompiledLength uint32
+	CompiledOffset uint32
+	ArgumentCount  uint16
+	ArgumentOffset uint32
+	LocalsCount    uint16
+	LocalsOffset   uint32
+	GlobalsCount   uint16
+	GlobalsOffset  uint32
+	Unknown1       uint32
+	Unknown2       uint16
+	LineCount      uint16
+	LineOffset     uint32
+	StackHeight    uint32
+
+	ArgumentNameIDs []int16
+	LocalNameIDs    []int16
+	GlobalNameIDs   []int16
+
+	Bytecode []byte
+}
+
 type LscrChunk struct {
 	Reader *binary_reader.BinaryReader
 
@@ -35,11 +75,24 @@ type LscrChunk struct {
 	LiteralsOffset       uint32
 	LiteralsDataCount    uint32
 	LiteralsDataOffset   uint32
+
+	PropertyNameIDs []int16
+	GlobalNameIDs   []int16
+	Handlers        []*LscrHandler
+	Literals        []*LscrLiteral
+
+	// CapitalX is set for movies with a LctX (rather than Lctx), they
+	// have a longer handler record and unscaled variable indexes
+	CapitalX bool
+
+	directorVersion version.Version
 }
 
 func (chunk *LscrChunk) Read(endian binary.ByteOrder) error {
 	var err error
 
+	chunk.Reader.Seek(8, 0)
+
 	chunk.TotalLength, err = chunk.Reader.ReadUInt32(endian)
 	if err != nil {
 		return err
@@ -158,20 +211,244 @@ func (chunk *LscrChunk) Read(endian binary.ByteOrder) error {
 		return err
 	}
 
+	chunk.PropertyNameIDs, err = chunk.readNameIDs(chunk.PropertiesOffset, chunk.PropertiesCount, endian)
+	if err != nil {
+		return fmt.Errorf("error reading properties: %s", err)
+	}
+
+	chunk.GlobalNameIDs, err = chunk.readNameIDs(chunk.GlobalsOffset, chunk.GlobalsCount, endian)
+	if err != nil {
+		return fmt.Errorf("error reading globals: %s", err)
+	}
+
+	chunk.Reader.Seek(int64(chunk.HandlersOffset), 0)
+	for i := 0; i < int(chunk.HandlersCount); i++ {
+		handler, err := chunk.readHandlerRecord(endian)
+		if err != nil {
+			return fmt.Errorf("error reading handler %d: %s", i, err)
+		}
+
+		chunk.Handlers = append(chunk.Handlers, handler)
+	}
+
+	for i, handler := range chunk.Handlers {
+		handler.ArgumentNameIDs, err = chunk.readNameIDs(handler.ArgumentOffset, handler.ArgumentCount, endian)
+		if err != nil {
+			return fmt.Errorf("error reading handler %d arguments: %s", i, err)
+		}
+
+		handler.LocalNameIDs, err = chunk.readNameIDs(handler.LocalsOffset, handler.LocalsCount, endian)
+		if err != nil {
+			return fmt.Errorf("error reading handler %d locals: %s", i, err)
+		}
+
+		handler.GlobalNameIDs, err = chunk.readNameIDs(handler.GlobalsOffset, handler.GlobalsCount, endian)
+		if err != nil {
+			return fmt.Errorf("error reading handler %d globals: %s", i, err)
+		}
+
+		data := chunk.Reader.GetBytes()
+		end := uint64(handler.CompiledOffset) + uint64(handler.CompiledLength)
+		if end > uint64(len(data)) {
+			return fmt.Errorf("handler %d bytecode is out of bounds", i)
+		}
+
+		handler.Bytecode = data[handler.CompiledOffset:end]
+	}
+
+	chunk.Reader.Seek(int64(chunk.LiteralsOffset), 0)
+	for i := 0; i < int(chunk.LiteralsCount); i++ {
+		literal := &LscrLiteral{}
+
+		if chunk.directorVersion.IsGreaterThanOrEqualTo(version.Director_5_0_0) {
+			literal.Type, err = chunk.Reader.ReadUInt32(endian)
+		} else {
+			var literalType uint16
+			literalType, err = chunk.Reader.ReadUInt16(endian)
+			literal.Type = uint32(literalType)
+		}
+		if err != nil {
+			return fmt.Errorf("error reading literal %d type: %s", i, err)
+		}
+
+		literal.Offset, err = chunk.Reader.ReadUInt32(endian)
+		if err != nil {
+			return fmt.Errorf("error reading literal %d offset: %s", i, err)
+		}
+
+		chunk.Literals = append(chunk.Literals, literal)
+	}
+
+	for i, literal := range chunk.Literals {
+		err = chunk.readLiteralValue(literal, endian)
+		if err != nil {
+			return fmt.Errorf("error reading literal %d: %s", i, err)
+		}
+	}
+
 	return nil
 
 }
 
-func ReadLscrChunkRaw(r *binary_reader.BinaryReader, endian binary.ByteOrder, isAfterburner bool) (*LscrChunk, error) {
+func (chunk *LscrChunk) readNameIDs(offset uint32, count uint16, endian binary.ByteOrder) ([]int16, error) {
+	ids := make([]int16, 0, count)
+
+	chunk.Reader.Seek(int64(offset), 0)
+	for i := 0; i < int(count); i++ {
+		id, err := chunk.Reader.ReadInt16(endian)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func (chunk *LscrChunk) readHandlerRecord(endian binary.ByteOrder) (*LscrHandler, error) {
+	var err error
+	handler := &LscrHandler{}
+
+	fields := []interface{}{
+		&handler.NameID,
+		&handler.VectorPos,
+		&handler.CompiledLength,
+		&handler.CompiledOffset,
+		&handler.ArgumentCount,
+		&handler.ArgumentOffset,
+		&handler.LocalsCount,
+		&handler.LocalsOffset,
+		&handler.GlobalsCount,
+		&handler.GlobalsOffset,
+		&handler.Unknown1,
+		&handler.Unknown2,
+		&handler.LineCount,
+		&handler.LineOffset,
+	}
+
+	if chunk.CapitalX {
+		fields = append(fields, &handler.StackHeight)
+	}
+
+	for _, field := range fields {
+		err = binary.Read(chunk.Reader.GetUnsafeBytesReader(), endian, field)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return handler, nil
+}
+
+func (chunk *LscrChunk) readLiteralValue(literal *LscrLiteral, endian binary.ByteOrder) error {
+	// ints are kept in the record itself
+	if literal.Type == LiteralInt {
+		literal.Value = int32(literal.Offset)
+		return nil
+	}
+
+	chunk.Reader.Seek(int64(chunk.LiteralsDataOffset)+int64(literal.Offset), 0)
+
+	length, err := chunk.Reader.ReadUInt32(endian)
+	if err != nil {
+		return err
+	}
+
+	if int(length) > chunk.Reader.Size() {
+		return fmt.Errorf("literal length %d is too large", length)
+	}
+
+	switch literal.Type {
+	case LiteralString:
+		if length == 0 {
+			literal.Value = ""
+			break
+		}
+
+		// the length includes the null terminator
+		data, err := chunk.Reader.ReadBytes(int(length))
+		if err != nil {
+			return err
+		}
+
+		literal.Value = string(data[:length-1])
+
+	case LiteralFloat:
+		data, err := chunk.Reader.ReadBytes(int(length))
+		if err != nil {
+			return err
+		}
+
+		switch length {
+		case 8:
+			literal.Value = math.Float64frombits(endian.Uint64(data))
+		case 10:
+			literal.Value = appleFloat80(data)
+		default:
+			literal.Value = 0.0
+		}
+
+	default:
+		literal.Value = nil
+	}
+
+	return nil
+}
+
+// appleFloat80 converts a 68k extended precision (80 bit) float
+func appleFloat80(data []byte) float64 {
+	exponent := int(binary.BigEndian.Uint16(data[0:2]))
+	mantissa := binary.BigEndian.Uint64(data[2:10])
+
+	sign := 1.0
+	if exponent&0x8000 != 0 {
+		sign = -1.0
+	}
+	exponent &= 0x7fff
+
+	if exponent == 0 && mantissa == 0 {
+		return 0
+	}
+
+	if exponent == 0x7fff {
+		if mantissa<<1 == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+
+	value, _ := new(big.Float).SetMantExp(new(big.Float).SetUint64(mantissa), exponent-16383-63).Float64()
+	return sign * value
+}
+
+// VariableMultiplier is what argument, local and literal indexes in the
+// bytecode are scaled by
+func (chunk *LscrChunk) VariableMultiplier() int32 {
+	if chunk.CapitalX {
+		return 1
+	}
+
+	if chunk.directorVersion.IsGreaterThanOrEqualTo(version.Director_5_0_0) {
+		return 8
+	}
+
+	return 6
+}
+
+func ReadLscrChunkRaw(r *binary_reader.BinaryReader, v version.Version, capitalX bool, endian binary.ByteOrder, isAfterburner bool) (*LscrChunk, error) {
 	var err error
 	chunk := &LscrChunk{
-		Reader: r,
+		Reader:          r,
+		CapitalX:        capitalX,
+		directorVersion: v,
 	}
 
 	chunk.Reader.HexDump(true)
 
+	// Lingo scripts are always big endian
 	r.Seek(0, 0)
-	err = chunk.Read(endian)
+	err = chunk.Read(binary.BigEndian)
 	if err != nil {
 		return nil, err
 	}
@@ -3,6 +3,7 @@ package chunks
 import (
 	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"io"
 	"strings"
 
@@ -14,144 +15,149 @@ type CastLib struct {
 	Name        string
 	Path        string
 	ItemCount   int16
-	NumId       int16
 	StorageType int
 	LibId       int
+
+	// members are numbered from MinMember, the CAS* of the library starts there
+	MinMember       int16
+	MaxMember       int16
+	PreloadSettings uint16
+
+	// ResourceId is what the library's chunks are keyed to in the KEY*
+	ResourceId int32
 }
 
 type MCsLChunk struct {
 	Reader *binary_reader.BinaryReader
 
-	Count    uint32
-	UnkCount uint32
-	CastLibs []CastLib
+	Count        uint16
+	ItemsPerCast uint16
+	CastLibs     []CastLib
 }
 
+// Read walks the item list, each library is ItemsPerCast items (name, path,
+// preload settings and the member range) found through the offset table
+// rather than read one after another as the items can be padded.
 func (chunk *MCsLChunk) Read(endian binary.ByteOrder) error {
-	chunk.Reader.Seek(4, io.SeekCurrent)
-
-	count, err := chunk.Reader.ReadUInt32(endian)
+	dataOffset, err := chunk.Reader.ReadUInt32(endian)
 	if err != nil {
 		return err
 	}
-	chunk.Count = count
-	utils.DebugMsg("mcsl", "count: %d\n", count)
 
-	if _, err := chunk.Reader.Seek(2, io.SeekCurrent); err != nil {
+	// unknown
+	chunk.Reader.ReadUInt16(endian)
+
+	chunk.Count, err = chunk.Reader.ReadUInt16(endian)
+	if err != nil {
 		return err
 	}
+	utils.DebugMsg("mcsl", "count: %d\n", chunk.Count)
 
-	unkCount, err := chunk.Reader.ReadUInt32(endian)
+	chunk.ItemsPerCast, err = chunk.Reader.ReadUInt16(endian)
 	if err != nil {
 		return err
 	}
-	chunk.UnkCount = unkCount + 1 // add 1
 
-	// Ignore 4*UnkCount bytes
-	if _, err := chunk.Reader.Seek(4*int64(chunk.UnkCount), io.SeekCurrent); err != nil {
+	if _, err := chunk.Reader.Seek(int64(dataOffset), io.SeekStart); err != nil {
 		return err
 	}
 
-	for i := 0; i < int(chunk.Count); i++ {
-		pos, _ := chunk.Reader.Seek(0, io.SeekCurrent)
-		utils.DebugMsg("mcsl", "Reading castlib %d @ %d\n", i, pos)
+	offsetCount, err := chunk.Reader.ReadUInt16(endian)
+	if err != nil {
+		return err
+	}
 
-		// Read nameSize
-		nameSize, err := chunk.Reader.ReadUInt8()
+	offsets := make([]uint32, offsetCount)
+	for i := range offsets {
+		offsets[i], err = chunk.Reader.ReadUInt32(endian)
 		if err != nil {
 			return err
 		}
-		utils.DebugMsg("mcsl", "nameSize: %d\n", nameSize)
-
-		var name string
-		if nameSize > 0 {
-			// Read name
-			nameBytes, err := chunk.Reader.ReadBytes(int(nameSize))
-			if err != nil {
-				return err
-			}
+	}
 
-			name = string(nameBytes)
+	itemsLength, err := chunk.Reader.ReadUInt32(endian)
+	if err != nil {
+		return err
+	}
 
-			// remove trailing null characters if any
-			name = strings.TrimRight(name, "\x00")
-		}
-		utils.DebugMsg("mcsl", "name: %s\n", name)
+	items, err := chunk.Reader.ReadBytes(int(itemsLength))
+	if err != nil {
+		return fmt.Errorf("error reading cast list items: %s", err)
+	}
 
-		// Ignore 1 byte
-		if _, err := chunk.Reader.Seek(1, io.SeekCurrent); err != nil {
-			return err
+	item := func(i int) []byte {
+		if i >= len(offsets) || offsets[i] > itemsLength {
+			return nil
 		}
 
-		// Read pathSize
-		pathSize, err := chunk.Reader.ReadUInt8()
-		if err != nil {
-			return err
-		}
-		utils.DebugMsg("mcsl", "pathSize: %d\n", pathSize)
-
-		var path string
-		if pathSize > 1 {
-			// Read path
-			pathBytes, err := chunk.Reader.ReadBytes(int(pathSize) + 1) // Add 1 to also read the trailing null byte
-			if err != nil {
-				return err
-			}
-			path = string(pathBytes)
-
-			// remove trailing null characters if any
-			path = strings.TrimRight(path, "\x00")
-		} else if pathSize == 1 {
-			// If pathSize is 1, we skip 1 byte
-			if _, err := chunk.Reader.Seek(1, io.SeekCurrent); err != nil {
-				return err
-			}
+		end := itemsLength
+		if i+1 < len(offsets) && offsets[i+1] >= offsets[i] && offsets[i+1] <= itemsLength {
+			end = offsets[i+1]
 		}
 
-		utils.DebugMsg("mcsl", "path: %s\n", path)
+		return items[offsets[i]:end]
+	}
 
-		// unknown purpose of this
-		if _, err := chunk.Reader.Seek(1, io.SeekCurrent); err != nil {
-			return err
-		}
+	perCast := int(chunk.ItemsPerCast)
+	for i := 0; i < int(chunk.Count); i++ {
+		// the first item isn't part of any library
+		base := i*perCast + 1
 
-		// unknown purpose of this
-		chunk.Reader.ReadInt8()
+		lib := CastLib{LibId: i + 1}
 
-		// unknown purpose of this
-		chunk.Reader.ReadInt8()
+		if perCast >= 1 {
+			lib.Name = readPascalItem(item(base))
+		}
 
-		// itemCount
-		itemCount, err := chunk.Reader.ReadInt16(endian)
-		if err != nil {
-			return err
+		if perCast >= 2 {
+			lib.Path = readPascalItem(item(base + 1))
 		}
-		utils.DebugMsg("mcsl", "itemCount: %d\n", itemCount)
-		// Ignore 2 bytes?
 
-		numId, err := chunk.Reader.ReadInt16(endian)
-		if err != nil {
-			return err
+		if perCast >= 3 {
+			if data := item(base + 2); len(data) >= 2 {
+				lib.PreloadSettings = binary.BigEndian.Uint16(data)
+			}
 		}
 
-		// Read libId
-		libId, err := chunk.Reader.ReadInt16(endian)
-		if err != nil {
-			return err
+		if perCast >= 4 {
+			if data := item(base + 3); len(data) >= 8 {
+				lib.MinMember = int16(binary.BigEndian.Uint16(data))
+				lib.MaxMember = int16(binary.BigEndian.Uint16(data[2:]))
+				lib.ResourceId = int32(binary.BigEndian.Uint32(data[4:]))
+			}
+		}
+
+		if lib.MaxMember >= lib.MinMember && lib.MinMember > 0 {
+			lib.ItemCount = lib.MaxMember - lib.MinMember + 1
 		}
-		libId -= 1023
 
-		var storageType = 1
-		if path != "" {
-			storageType = 0
+		lib.StorageType = 1
+		if lib.Path != "" {
+			lib.StorageType = 0
 		}
 
-		chunk.CastLibs = append(chunk.CastLibs, CastLib{Name: name, Path: path, ItemCount: itemCount, LibId: int(libId), StorageType: storageType, NumId: (numId)})
+		utils.DebugMsg("mcsl", "castlib %d: %s (%s) members %d-%d, resource %d\n", lib.LibId, lib.Name, lib.Path, lib.MinMember, lib.MaxMember, lib.ResourceId)
+
+		chunk.CastLibs = append(chunk.CastLibs, lib)
 	}
 
 	return nil
 }
 
+// readPascalItem is a length prefixed string, empty items are empty strings
+func readPascalItem(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	length := int(data[0])
+	if length > len(data)-1 {
+		length = len(data) - 1
+	}
+
+	return strings.TrimRight(string(data[1:1+length]), "\x00")
+}
+
 func ReadMcslChunkRaw(r *binary_reader.BinaryReader, endian binary.ByteOrder, isAfterburner bool) (*MCsLChunk, error) {
 	var err error
 	chunk := &MCsLChunk{
@@ -0,0 +1,139 @@
+package chunks
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+
+	"github.com/markhughes/dirry/internal/binary_reader"
+	"github.com/markhughes/dirry/internal/members"
+	"github.com/markhughes/dirry/internal/utils"
+	"github.com/markhughes/dirry/internal/version"
+)
+
+// FilmLoopChunk is the score of a film loop member, the frames are kept the
+// same way as the VWSC of the movie
+type FilmLoopChunk struct {
+	Member *members.MemberFilmLoop
+	Score  *ScoreChunk
+}
+
+// FilmLoopSequence is the frames a film loop plays, in order, with the
+// members it plays in them so the animation can be put back together
+type FilmLoopSequence struct {
+	CastLib int
+	Number  int
+
+	InitialRect utils.Rect
+	Loop        bool
+	Crop        bool
+	Center      bool
+	Sound       bool
+
+	Members []ScoreMemberRef
+	Frames  []FilmLoopFrame
+}
+
+type FilmLoopFrame struct {
+	Frame int32
+
+	Tempo   uint8
+	Script  ScoreMemberRef
+	Sound1  ScoreMemberRef
+	Sound2  ScoreMemberRef
+	Sprites []ScoreSprite
+}
+
+// Sequence lists the frames of the film loop, castLib and number are where
+// the film loop member itself is
+func (chunk *FilmLoopChunk) Sequence(castLib int, number int) *FilmLoopSequence {
+	sequence := &FilmLoopSequence{
+		CastLib: castLib,
+		Number:  number,
+	}
+
+	if chunk.Member != nil {
+		sequence.InitialRect = chunk.Member.InitialRect
+		sequence.Loop = chunk.Member.Loop
+		sequence.Crop = chunk.Member.Crop
+		sequence.Center = chunk.Member.Center
+		sequence.Sound = chunk.Member.Sound
+	}
+
+	frameNums := make([]int32, 0, len(chunk.Score.Frames))
+	for frameNum := range chunk.Score.Frames {
+		frameNums = append(frameNums, frameNum)
+	}
+	sort.Slice(frameNums, func(i, j int) bool { return frameNums[i] < frameNums[j] })
+
+	seen := make(map[ScoreMemberRef]bool)
+	addMember := func(ref ScoreMemberRef) {
+		if ref.Member == 0 || seen[ref] {
+			return
+		}
+
+		seen[ref] = true
+		sequence.Members = append(sequence.Members, ref)
+	}
+
+	for _, frameNum := range frameNums {
+		frame := chunk.Score.Frames[frameNum]
+
+		sequence.Frames = append(sequence.Frames, FilmLoopFrame{
+			Frame:   frameNum,
+			Tempo:   frame.Tempo,
+			Script:  frame.Script,
+			Sound1:  frame.Sound1,
+			Sound2:  frame.Sound2,
+			Sprites: frame.Sprites,
+		})
+
+		for _, sprite := range frame.Sprites {
+			addMember(sprite.Member)
+		}
+		addMember(frame.Sound1)
+		addMember(frame.Sound2)
+	}
+
+	return sequence
+}
+
+func (sequence *FilmLoopSequence) ToJSON() (string, error) {
+	bytes, err := json.MarshalIndent(sequence, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
+
+// ReadFilmLoopChunkRaw reads the SCVW of a film loop, castChunk is the
+// member it is keyed to and can be nil when that wasn't found
+func ReadFilmLoopChunkRaw(r *binary_reader.BinaryReader, castChunk *CastChunk, v version.Version, endian binary.ByteOrder, isAfterburner bool) (*FilmLoopChunk, error) {
+	chunk := &FilmLoopChunk{}
+
+	if castChunk != nil {
+		if member, ok := castChunk.Member.(*members.MemberFilmLoop); ok {
+			chunk.Member = member
+		} else {
+			utils.WarnMsg("SCVW", "SCVW is keyed to a %s member, not a film loop", castChunk.Type)
+		}
+	}
+
+	var err error
+	chunk.Score, err = ReadScoreChunkRaw(r, v, endian, isAfterburner)
+	if err != nil {
+		return nil, err
+	}
+
+	return chunk, nil
+}
+
+func (c *FilmLoopChunk) ToJSON() (string, error) {
+	bytes, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
@@ -4,69 +4,121 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/markhughes/dirry/internal/binary_reader"
 	"github.com/markhughes/dirry/internal/consts"
+	"github.com/markhughes/dirry/internal/snd"
+	"github.com/markhughes/dirry/internal/swa"
+	"github.com/markhughes/dirry/internal/utils"
 )
 
 type SndChunk struct {
-	Chunk *BinaryChunk
-	Type  int16
+	Reader *binary_reader.BinaryReader `json:"-"`
+
+	Sound *snd.Sound
+
+	// Swa is set instead of Sound when the member was compressed with
+	// Shockwave Audio
+	Swa *swa.Swa
 }
 
-func ReadSndChunk(r *os.File, endian binary.ByteOrder, offset int64) (*SndChunk, error) {
-	_, err := r.Seek(offset, io.SeekStart)
+func (chunk *SndChunk) Read(endian binary.ByteOrder) error {
+	data, err := chunk.Reader.ReadBytes(int(chunk.Reader.Length))
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("error reading snd data: %s", err)
+	}
+
+	// snd resources are always the Mac layout, big endian
+	chunk.Sound, err = snd.Decode(data)
+	if err == nil {
+		return nil
 	}
 
-	chunk := &SndChunk{}
+	if swa.IsSwa(data) {
+		chunk.Swa, err = swa.Parse(data)
+		if err != nil {
+			return fmt.Errorf("error reading swa: %s", err)
+		}
+
+		return nil
+	}
 
-	chunk.Chunk, err = FromBinaryAt(r, "snd ", endian, offset)
+	return fmt.Errorf("error decoding snd: %s", err)
+}
+
+func ReadSndChunkRaw(r *binary_reader.BinaryReader, endian binary.ByteOrder, isAfterburner bool) (*SndChunk, error) {
+	chunk := &SndChunk{
+		Reader: r,
+	}
+
+	r.Seek(0, 0)
+	err := chunk.Read(endian)
 	if err != nil {
 		return nil, err
 	}
 
-	err = binary.Read(chunk.Chunk.r, binary.LittleEndian, &chunk.Type)
+	return chunk, nil
+}
+
+func (c *SndChunk) ToJSON() (string, error) {
+	bytes, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read the file type: %v", err)
+		return "", err
 	}
 
-	return chunk, nil
+	return string(bytes), nil
 }
 
-func (c *SndChunk) Save(projectName string, name string) {
-	outputFolder := filepath.Join(consts.PathDump, projectName, "converted", "snd")
-	os.MkdirAll(outputFolder, os.ModePerm) // Ensure the output directory exists
-	outputFile := filepath.Join(outputFolder, name+".act")
+// Save writes the sound as a WAV with a JSON sidecar holding the loop
+// points, and SWA as the MP3 it wraps.
+func (c *SndChunk) Save(projectName string, name string, pkg string) error {
+	var outputFolder string
+	if pkg == "" {
+		outputFolder = filepath.Join(consts.PathDump, projectName, "converted", "snd")
+	} else {
+		outputFolder = filepath.Join(consts.PathDump, pkg, "file", projectName, "converted", "snd")
+	}
 
-	targetFile, err := os.Create(outputFile)
+	err := os.MkdirAll(outputFolder, os.ModePerm)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	defer targetFile.Close()
 
-	snd := make([]byte, 0)
+	// member names can have anything in them
+	name = strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(name)
+
+	if c.Swa != nil {
+		outputFile := filepath.Join(outputFolder, name+".mp3")
+		err = os.WriteFile(outputFile, c.Swa.MP3, 0644)
+		if err != nil {
+			return err
+		}
 
-	// Write the snd file to disk.
-	_, err = targetFile.Write(snd)
+		utils.DebugMsg("snd", "Saved to %s\n", outputFile)
+
+		return nil
+	}
+
+	outputFile := filepath.Join(outputFolder, name+".wav")
+	err = os.WriteFile(outputFile, c.Sound.WAV(), 0644)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	err = targetFile.Sync()
+	sidecar, err := json.MarshalIndent(c.Sound, "", "  ")
 	if err != nil {
-		panic(err)
+		return err
 	}
-}
 
-func (c *SndChunk) ToJSON() (string, error) {
-	bytes, err := json.MarshalIndent(c, "", "  ")
+	err = os.WriteFile(filepath.Join(outputFolder, name+".json"), sidecar, 0644)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	return string(bytes), nil
+	utils.DebugMsg("snd", "Saved to %s\n", outputFile)
+
+	return nil
 }
@@ -0,0 +1,127 @@
+package chunks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/markhughes/dirry/internal/binary_reader"
+	"github.com/markhughes/dirry/internal/consts"
+	"github.com/markhughes/dirry/internal/snd"
+)
+
+// testSnd is a resource with a buffer command pointing at the header after
+// it, a format 1 one has a sampled synth modifier first
+func testSnd(format int, header []byte) []byte {
+	var out bytes.Buffer
+	binary.Write(&out, binary.BigEndian, uint16(format))
+	if format == 1 {
+		binary.Write(&out, binary.BigEndian, []uint16{1, 5, 0, 0})
+	} else {
+		binary.Write(&out, binary.BigEndian, uint16(0))
+	}
+
+	binary.Write(&out, binary.BigEndian, []uint16{1, 0x8051, 0})
+	binary.Write(&out, binary.BigEndian, uint32(out.Len()+4))
+	out.Write(header)
+
+	return out.Bytes()
+}
+
+func testSndHeader(encoding byte, channels uint32, sampleSize uint16, data []byte) []byte {
+	if encoding == snd.EncodingStandard {
+		var out bytes.Buffer
+		binary.Write(&out, binary.BigEndian, []uint32{0, uint32(len(data)), 11025 << 16, 2, 4})
+		out.Write([]byte{encoding, 60})
+		out.Write(data)
+		return out.Bytes()
+	}
+
+	header := make([]byte, 64)
+	binary.BigEndian.PutUint32(header[4:], channels)
+	binary.BigEndian.PutUint32(header[8:], 11025<<16)
+	header[20] = encoding
+	binary.BigEndian.PutUint32(header[22:], uint32(len(data))/channels/uint32(sampleSize/8))
+	if encoding == snd.EncodingCompressed {
+		copy(header[40:], "twos")
+		binary.BigEndian.PutUint16(header[62:], sampleSize)
+	} else {
+		binary.BigEndian.PutUint16(header[48:], sampleSize)
+	}
+
+	return append(header, data...)
+}
+
+func TestSndSave(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		channels   uint16
+		sampleSize uint16
+		loop       bool
+	}{
+		{"format 1 standard", testSnd(1, testSndHeader(snd.EncodingStandard, 1, 8, []byte{1, 2, 3, 4, 5, 6})), 1, 8, true},
+		{"format 2 standard", testSnd(2, testSndHeader(snd.EncodingStandard, 1, 8, []byte{1, 2, 3, 4, 5, 6})), 1, 8, true},
+		{"extended", testSnd(1, testSndHeader(snd.EncodingExtended, 2, 16, []byte{1, 2, 3, 4, 5, 6, 7, 8})), 2, 16, false},
+		{"compressed", testSnd(1, testSndHeader(snd.EncodingCompressed, 1, 16, []byte{1, 2, 3, 4})), 1, 16, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			consts.PathDump = t.TempDir()
+
+			reader, err := binary_reader.NewBinaryReader(test.data, int32(len(test.data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			chunk, err := ReadSndChunkRaw(reader, binary.BigEndian, false)
+			if err != nil {
+				t.Fatalf("could not read snd: %s", err)
+			}
+
+			if err := chunk.Save("movie.dir", "1_beep", ""); err != nil {
+				t.Fatalf("could not save: %s", err)
+			}
+
+			folder := filepath.Join(consts.PathDump, "movie.dir", "converted", "snd")
+			wav, err := os.ReadFile(filepath.Join(folder, "1_beep.wav"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(wav[0:4]) != "RIFF" || string(wav[8:12]) != "WAVE" {
+				t.Fatalf("not a WAV: % x", wav[:12])
+			}
+
+			channels := binary.LittleEndian.Uint16(wav[22:])
+			rate := binary.LittleEndian.Uint32(wav[24:])
+			sampleSize := binary.LittleEndian.Uint16(wav[34:])
+			if channels != test.channels || rate != 11025 || sampleSize != test.sampleSize {
+				t.Errorf("WAV is %d channels at %d Hz and %d bit, want %d at 11025 and %d",
+					channels, rate, sampleSize, test.channels, test.sampleSize)
+			}
+
+			if hasLoop := bytes.Contains(wav, []byte("smpl")); hasLoop != test.loop {
+				t.Errorf("smpl chunk is there %v, want %v", hasLoop, test.loop)
+			}
+
+			sidecar, err := os.ReadFile(filepath.Join(folder, "1_beep.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var sound snd.Sound
+			if err := json.Unmarshal(sidecar, &sound); err != nil {
+				t.Fatal(err)
+			}
+
+			if test.loop && (sound.LoopStart != 2 || sound.LoopEnd != 4) {
+				t.Errorf("sidecar loop is %d to %d, want 2 to 4", sound.LoopStart, sound.LoopEnd)
+			}
+		})
+	}
+}
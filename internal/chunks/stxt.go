@@ -1,6 +1,7 @@
 package chunks
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,10 @@ import (
 	"github.com/markhughes/dirry/internal/utils"
 )
 
+// each style is an offset, height, ascent, font, style, padding, size and
+// a 48 bit colour
+const stxtStyleLength = 20
+
 type Formatting struct {
 	Offset       int32
 	Height       int16
@@ -23,6 +28,11 @@ type Formatting struct {
 	Padding      int
 	FontSize     int16
 	Colour       palettes.Pixel24
+
+	// only the Text xtra keeps these
+	Strikeout   bool   `json:",omitempty"`
+	Alignment   string `json:",omitempty"`
+	CharSpacing int16  `json:",omitempty"`
 }
 
 type StyledTextChunk struct {
@@ -33,6 +43,9 @@ type StyledTextChunk struct {
 
 	Unk1 int32
 	Unk2 int32
+
+	// windows is set when the text is Windows-1252, it is Mac Roman otherwise
+	windows bool
 }
 
 func ReadStxtChunkRaw(r *binary_reader.BinaryReader, fonts map[uint32]*Font, endian binary.ByteOrder, isAfterburner bool) (*StyledTextChunk, error) {
@@ -163,6 +176,52 @@ func (chunk *StyledTextChunk) Read(endian binary.ByteOrder, fonts map[uint32]*Fo
 	return nil
 }
 
+// ReplaceStxtText gives the STXT with its text swapped for another. The
+// styles are kept as they were, less any that start past the new text.
+func ReplaceStxtText(b []byte, text []byte) ([]byte, error) {
+	endian := binary.BigEndian
+
+	if len(b) < 12 {
+		return nil, fmt.Errorf("STXT is only %d bytes", len(b))
+	}
+
+	headerLength := int(endian.Uint32(b))
+	textLength := int(endian.Uint32(b[4:]))
+	stylesOffset := headerLength + textLength
+	if headerLength < 12 || stylesOffset+2 > len(b) {
+		return nil, fmt.Errorf("STXT text is outside the chunk")
+	}
+
+	count := int(endian.Uint16(b[stylesOffset:]))
+	styles := b[stylesOffset+2:]
+	if len(styles) < count*stxtStyleLength {
+		return nil, fmt.Errorf("STXT has %d styles but only room for %d", count, len(styles)/stxtStyleLength)
+	}
+
+	kept := make([]byte, 0, count*stxtStyleLength)
+	for i := 0; i < count; i++ {
+		style := styles[i*stxtStyleLength : (i+1)*stxtStyleLength]
+
+		// the first style covers the text even when there isn't any
+		if i > 0 && int(endian.Uint32(style)) >= len(text) {
+			continue
+		}
+
+		kept = append(kept, style...)
+	}
+
+	var out bytes.Buffer
+	out.Write(b[:4])
+	binary.Write(&out, endian, uint32(len(text)))
+	binary.Write(&out, endian, uint32(2+len(kept)))
+	out.Write(b[12:headerLength])
+	out.Write(text)
+	binary.Write(&out, endian, uint16(len(kept)/stxtStyleLength))
+	out.Write(kept)
+
+	return out.Bytes(), nil
+}
+
 func (c *StyledTextChunk) ToJSON() (string, error) {
 	bytes, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
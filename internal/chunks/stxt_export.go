@@ -0,0 +1,286 @@
+package chunks
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/markhughes/dirry/internal/macroman"
+	"github.com/markhughes/dirry/internal/palettes"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// styledParagraph is a line of the text split where its formatting changes
+type styledParagraph struct {
+	Alignment string
+	Spans     []styledSpan
+}
+
+type styledSpan struct {
+	Text   string
+	Format Formatting
+}
+
+// formattingAt is the formatting of the character at offset and where the
+// next one starts, the formattings are in order of their offsets
+func (c *StyledTextChunk) formattingAt(offset int) (Formatting, int) {
+	format := Formatting{}
+	if len(c.Formattings) > 0 {
+		format = c.Formattings[0]
+	}
+
+	for _, next := range c.Formattings {
+		if int(next.Offset) > offset {
+			return format, int(next.Offset)
+		}
+
+		format = next
+	}
+
+	return format, len(c.Text)
+}
+
+// paragraphs splits the text on the carriage returns Director ends lines
+// with, and each line where its formatting changes
+func (c *StyledTextChunk) paragraphs() []styledParagraph {
+	var paragraphs []styledParagraph
+
+	start := 0
+	for _, line := range strings.Split(c.Text, "\r") {
+		end := start + len(line)
+		paragraph := styledParagraph{}
+
+		for from := start; ; {
+			format, next := c.formattingAt(from)
+			to := end
+			if next < end {
+				to = next
+			}
+
+			paragraph.Spans = append(paragraph.Spans, styledSpan{Text: c.Text[from:to], Format: format})
+			if to >= end {
+				break
+			}
+
+			from = to
+		}
+
+		paragraph.Alignment = paragraph.Spans[0].Format.Alignment
+		paragraphs = append(paragraphs, paragraph)
+
+		start = end + 1
+	}
+
+	return paragraphs
+}
+
+// decode turns text in the character set of the movie into UTF-8
+func (c *StyledTextChunk) decode(text string) string {
+	if c.windows {
+		value, err := charmap.Windows1252.NewDecoder().String(text)
+		if err == nil {
+			return value
+		}
+	}
+
+	return macroman.ConvertMacRomanToUTF8(text)
+}
+
+// ToPlainText is the text as UTF-8 with a new line ending each line
+func (c *StyledTextChunk) ToPlainText() string {
+	return strings.ReplaceAll(c.decode(c.Text), "\r", "\n")
+}
+
+// ToHTML is the text as a HTML document, a paragraph for each line and a span
+// for each formatting
+func (c *StyledTextChunk) ToHTML() []byte {
+	var out = bytes.NewBufferString("<html><body>\n")
+
+	for _, paragraph := range c.paragraphs() {
+		if paragraph.Alignment != "" {
+			fmt.Fprintf(out, "<p style=\"text-align: %s\">", paragraph.Alignment)
+		} else {
+			out.WriteString("<p>")
+		}
+
+		for _, span := range paragraph.Spans {
+			if span.Text == "" {
+				continue
+			}
+
+			fmt.Fprintf(out, "<span style=\"%s\">%s</span>", htmlStyle(span.Format), html.EscapeString(c.decode(span.Text)))
+		}
+
+		if len(paragraph.Spans) == 1 && paragraph.Spans[0].Text == "" {
+			out.WriteString("<br>")
+		}
+
+		out.WriteString("</p>\n")
+	}
+
+	out.WriteString("</body></html>\n")
+	return out.Bytes()
+}
+
+func htmlStyle(format Formatting) string {
+	var style []string
+
+	if format.FontName != "" {
+		style = append(style, fmt.Sprintf("font-family: '%s'", html.EscapeString(format.FontName)))
+	}
+
+	if format.FontSize > 0 {
+		style = append(style, fmt.Sprintf("font-size: %dpt", format.FontSize))
+	}
+
+	style = append(style, fmt.Sprintf("color: #%02x%02x%02x", format.Colour.R, format.Colour.G, format.Colour.B))
+
+	if format.Bold {
+		style = append(style, "font-weight: bold")
+	}
+
+	if format.Italic {
+		style = append(style, "font-style: italic")
+	}
+
+	var decoration []string
+	if format.Underline {
+		decoration = append(decoration, "underline")
+	}
+	if format.Strikeout {
+		decoration = append(decoration, "line-through")
+	}
+	if len(decoration) > 0 {
+		style = append(style, "text-decoration: "+strings.Join(decoration, " "))
+	}
+
+	if format.CharSpacing != 0 {
+		style = append(style, fmt.Sprintf("letter-spacing: %dpt", format.CharSpacing))
+	}
+
+	return strings.Join(style, "; ")
+}
+
+var rtfAlignments = map[string]string{
+	"left":    "\\ql",
+	"center":  "\\qc",
+	"right":   "\\qr",
+	"justify": "\\qj",
+}
+
+// ToRTF is the text as RTF, which can keep it in Mac Roman or Windows-1252
+func (c *StyledTextChunk) ToRTF() []byte {
+	paragraphs := c.paragraphs()
+
+	// the font and colour tables, in the order they're first used
+	var fonts []string
+	var colours []palettes.Pixel24
+	fontIndex := make(map[string]int)
+	colourIndex := make(map[palettes.Pixel24]int)
+
+	for _, paragraph := range paragraphs {
+		for _, span := range paragraph.Spans {
+			name := rtfFontName(span.Format)
+			if _, ok := fontIndex[name]; !ok {
+				fontIndex[name] = len(fonts)
+				fonts = append(fonts, name)
+			}
+
+			if _, ok := colourIndex[span.Format.Colour]; !ok {
+				// colour 0 is the default, the first one in the table is 1
+				colourIndex[span.Format.Colour] = len(colours) + 1
+				colours = append(colours, span.Format.Colour)
+			}
+		}
+	}
+
+	charset := "\\mac"
+	if c.windows {
+		charset = "\\ansi\\ansicpg1252"
+	}
+
+	var out = bytes.NewBufferString("{\\rtf1" + charset + "\\deff0\n{\\fonttbl")
+	for i, name := range fonts {
+		fmt.Fprintf(out, "{\\f%d %s;}", i, rtfEscape(name))
+	}
+
+	out.WriteString("}\n{\\colortbl;")
+	for _, colour := range colours {
+		fmt.Fprintf(out, "\\red%d\\green%d\\blue%d;", colour.R, colour.G, colour.B)
+	}
+	out.WriteString("}\n")
+
+	for i, paragraph := range paragraphs {
+		out.WriteString("\\pard")
+		if alignment, ok := rtfAlignments[paragraph.Alignment]; ok {
+			out.WriteString(alignment)
+		}
+
+		for _, span := range paragraph.Spans {
+			format := span.Format
+
+			fmt.Fprintf(out, "\\plain\\f%d\\cf%d", fontIndex[rtfFontName(format)], colourIndex[format.Colour])
+			if format.FontSize > 0 {
+				fmt.Fprintf(out, "\\fs%d", format.FontSize*2)
+			}
+			if format.Bold {
+				out.WriteString("\\b")
+			}
+			if format.Italic {
+				out.WriteString("\\i")
+			}
+			if format.Underline {
+				out.WriteString("\\ul")
+			}
+			if format.Strikeout {
+				out.WriteString("\\strike")
+			}
+			if format.CharSpacing != 0 {
+				// in twips
+				fmt.Fprintf(out, "\\expndtw%d", int(format.CharSpacing)*20)
+			}
+
+			out.WriteString(" ")
+			out.WriteString(rtfEscape(span.Text))
+		}
+
+		if i < len(paragraphs)-1 {
+			out.WriteString("\\par")
+		}
+		out.WriteString("\n")
+	}
+
+	out.WriteString("}\n")
+	return out.Bytes()
+}
+
+func rtfFontName(format Formatting) string {
+	if format.FontName != "" {
+		return format.FontName
+	}
+
+	return fmt.Sprintf("Font %d", format.FontId)
+}
+
+// rtfEscape escapes the characters RTF uses and writes the rest of the
+// character set as hex
+func rtfEscape(text string) string {
+	var out strings.Builder
+
+	for _, b := range []byte(text) {
+		switch {
+		case b == '\\' || b == '{' || b == '}':
+			out.WriteByte('\\')
+			out.WriteByte(b)
+		case b == '\t':
+			out.WriteString("\\tab ")
+		case b < 0x20 || b >= 0x80:
+			fmt.Fprintf(&out, "\\'%02x", b)
+		default:
+			out.WriteByte(b)
+		}
+	}
+
+	return out.String()
+}
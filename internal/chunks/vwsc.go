@@ -0,0 +1,541 @@
+package chunks
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/markhughes/dirry/internal/binary_reader"
+	"github.com/markhughes/dirry/internal/utils"
+	"github.com/markhughes/dirry/internal/version"
+)
+
+// The score is always big endian, even in XFIR movies
+var scoreEndian = binary.BigEndian
+
+type ScoreMemberRef struct {
+	CastLib int16
+	Member  int16
+}
+
+type ScoreTransition struct {
+	Member ScoreMemberRef
+
+	// Director 4 keeps the transition in the frame, later
+	// versions point at a transition cast member instead
+	Type       uint8
+	Duration   uint8
+	ChunkSize  uint8
+	ChangeArea bool
+}
+
+type ScorePalette struct {
+	Member ScoreMemberRef
+
+	Speed      uint8
+	FirstColor uint8
+	LastColor  uint8
+	FrameCount uint16
+	CycleCount uint16
+
+	ColorCycling bool
+	FadeToBlack  bool
+	FadeToWhite  bool
+	AutoReverse  bool
+	OverTime     bool
+}
+
+type ScoreSprite struct {
+	Channel int
+
+	Type   uint8
+	Member ScoreMemberRef
+	Script ScoreMemberRef
+
+	Ink     uint8
+	Trails  bool
+	Stretch bool
+	Blend   uint8
+
+	ForeColor uint8
+	BackColor uint8
+
+	Rect      utils.Rect
+	Thickness uint8
+	Editable  bool
+	Moveable  bool
+}
+
+type ScoreFrame struct {
+	Label string
+
+	Tempo      uint8
+	Script     ScoreMemberRef
+	Sound1     ScoreMemberRef
+	Sound2     ScoreMemberRef
+	Transition ScoreTransition
+	Palette    ScorePalette
+
+	// Only the channels with something in them
+	Sprites []ScoreSprite
+}
+
+// ScoreInterval is a sprite span from the D6+ score, it carries the
+// behaviors attached to the sprite.
+type ScoreInterval struct {
+	StartFrame int32
+	EndFrame   int32
+	Channel    int32
+	Behaviors  []ScoreMemberRef
+}
+
+type ScoreChunk struct {
+	Reader *binary_reader.BinaryReader
+
+	FrameCount       int32
+	FramesVersion    int16
+	SpriteRecordSize int16
+	ChannelCount     int16
+	SpriteChannels   int16
+
+	// Keyed by frame number, starting from 1
+	Frames    map[int32]*ScoreFrame
+	Intervals []ScoreInterval
+
+	directorVersion version.Version
+}
+
+func (chunk *ScoreChunk) Read(endian binary.ByteOrder) error {
+	chunk.Frames = make(map[int32]*ScoreFrame)
+
+	_, err := chunk.Reader.ReadInt32(scoreEndian)
+	if err != nil {
+		return err
+	}
+
+	marker, err := chunk.Reader.ReadInt32(scoreEndian)
+	if err != nil {
+		return err
+	}
+
+	// D4 and D5 scores are just the frame data, newer ones wrap it in
+	// a table of entries with the sprite intervals after it
+	if marker != -3 {
+		return chunk.readFrames(chunk.Reader.GetBytes())
+	}
+
+	entries, err := chunk.readEntries()
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		return fmt.Errorf("score has no entries")
+	}
+
+	err = chunk.readFrames(entries[0])
+	if err != nil {
+		return err
+	}
+
+	// entry 1 is the interval order, then each interval is
+	// a primary, secondary and tertiary entry
+	for i := 2; i+1 < len(entries); i += 3 {
+		primary := entries[i]
+		if len(primary) < 20 {
+			continue
+		}
+
+		interval := ScoreInterval{
+			StartFrame: int32(scoreEndian.Uint32(primary[0:])),
+			EndFrame:   int32(scoreEndian.Uint32(primary[4:])),
+			Channel:    int32(scoreEndian.Uint32(primary[16:])),
+		}
+
+		secondary := entries[i+1]
+		for j := 0; j+8 <= len(secondary); j += 8 {
+			interval.Behaviors = append(interval.Behaviors, ScoreMemberRef{
+				CastLib: int16(scoreEndian.Uint16(secondary[j:])),
+				Member:  int16(scoreEndian.Uint16(secondary[j+2:])),
+			})
+		}
+
+		chunk.Intervals = append(chunk.Intervals, interval)
+	}
+
+	return nil
+}
+
+func (chunk *ScoreChunk) readEntries() ([][]byte, error) {
+	// unknown, always 12
+	_, err := chunk.Reader.ReadInt32(scoreEndian)
+	if err != nil {
+		return nil, err
+	}
+
+	entryCount, err := chunk.Reader.ReadInt32(scoreEndian)
+	if err != nil {
+		return nil, err
+	}
+
+	// entry count + 1, then the sum of the entry sizes
+	chunk.Reader.ReadInt32(scoreEndian)
+	chunk.Reader.ReadInt32(scoreEndian)
+
+	if entryCount < 0 || int(entryCount) > chunk.Reader.Size()/4 {
+		return nil, fmt.Errorf("invalid score entry count: %d", entryCount)
+	}
+
+	offsets := make([]int32, entryCount+1)
+	for i := range offsets {
+		offsets[i], err = chunk.Reader.ReadInt32(scoreEndian)
+		if err != nil {
+			return nil, fmt.Errorf("error reading score entry offsets: %s", err)
+		}
+	}
+
+	data := chunk.Reader.GetBytes()
+	base := chunk.Reader.Pos()
+
+	entries := make([][]byte, entryCount)
+	for i := range entries {
+		start := base + int64(offsets[i])
+		end := base + int64(offsets[i+1])
+		if start < base || end < start || end > int64(len(data)) {
+			return nil, fmt.Errorf("score entry %d is out of bounds", i)
+		}
+
+		entries[i] = data[start:end]
+	}
+
+	return entries, nil
+}
+
+func (chunk *ScoreChunk) readFrames(data []byte) error {
+	r, err := binary_reader.NewBinaryReader(data, int32(len(data)))
+	if err != nil {
+		return err
+	}
+
+	framesEnd, err := r.ReadInt32(scoreEndian)
+	if err != nil {
+		return fmt.Errorf("error reading frames length: %s", err)
+	}
+
+	// offset to the first frame, always the header size
+	r.ReadInt32(scoreEndian)
+
+	chunk.FrameCount, err = r.ReadInt32(scoreEndian)
+	if err != nil {
+		return fmt.Errorf("error reading frame count: %s", err)
+	}
+
+	chunk.FramesVersion, _ = r.ReadInt16(scoreEndian)
+	chunk.SpriteRecordSize, _ = r.ReadInt16(scoreEndian)
+	chunk.ChannelCount, _ = r.ReadInt16(scoreEndian)
+	displayed, err := r.ReadInt16(scoreEndian)
+	if err != nil {
+		return fmt.Errorf("error reading frames header: %s", err)
+	}
+
+	var minRecordSize int16 = 24
+	if chunk.isD4() {
+		minRecordSize = 20
+	}
+
+	if chunk.SpriteRecordSize < minRecordSize || chunk.ChannelCount <= chunk.mainSlots() {
+		return fmt.Errorf("invalid score channels: %d x %d bytes", chunk.ChannelCount, chunk.SpriteRecordSize)
+	}
+
+	if chunk.FramesVersion > 13 {
+		chunk.SpriteChannels = displayed
+	} else {
+		chunk.SpriteChannels = chunk.ChannelCount - chunk.mainSlots()
+	}
+
+	if framesEnd <= 0 || int(framesEnd) > len(data) {
+		framesEnd = int32(len(data))
+	}
+
+	// every frame only stores what changed since the previous one
+	channelData := make([]byte, int(chunk.ChannelCount)*int(chunk.SpriteRecordSize))
+
+	for frameNum := int32(1); r.Pos()+2 <= int64(framesEnd); frameNum++ {
+		frameSize, err := r.ReadUInt16(scoreEndian)
+		if err != nil {
+			return fmt.Errorf("error reading frame %d size: %s", frameNum, err)
+		}
+
+		remaining := int(frameSize) - 2
+		for remaining > 0 {
+			channelSize, err := r.ReadUInt16(scoreEndian)
+			if err != nil {
+				return fmt.Errorf("error reading frame %d: %s", frameNum, err)
+			}
+
+			channelOffset, err := r.ReadUInt16(scoreEndian)
+			if err != nil {
+				return fmt.Errorf("error reading frame %d: %s", frameNum, err)
+			}
+
+			if r.Pos()+int64(channelSize) > int64(len(data)) {
+				return fmt.Errorf("frame %d is truncated", frameNum)
+			}
+
+			delta, err := r.ReadBytes(int(channelSize))
+			if err != nil {
+				return fmt.Errorf("error reading frame %d: %s", frameNum, err)
+			}
+
+			if int(channelOffset)+len(delta) > len(channelData) {
+				return fmt.Errorf("frame %d writes past the channel data (%d+%d)", frameNum, channelOffset, channelSize)
+			}
+
+			copy(channelData[channelOffset:], delta)
+			remaining -= int(channelSize) + 4
+		}
+
+		chunk.Frames[frameNum] = chunk.decodeFrame(channelData)
+	}
+
+	if int32(len(chunk.Frames)) != chunk.FrameCount {
+		utils.WarnMsg("VWSC", "Score header has %d frames, decoded %d", chunk.FrameCount, len(chunk.Frames))
+	}
+
+	return nil
+}
+
+// mainSlots is how many sprite records the main channels (tempo,
+// palette, transition, sounds and script) take up
+func (chunk *ScoreChunk) mainSlots() int16 {
+	if chunk.directorVersion.IsLessThan(version.Director_6_0_0) {
+		return 2
+	}
+
+	return 6
+}
+
+func (chunk *ScoreChunk) isD4() bool {
+	return chunk.directorVersion.IsLessThan(version.Director_5_0_0)
+}
+
+func (chunk *ScoreChunk) decodeFrame(channelData []byte) *ScoreFrame {
+	frame := &ScoreFrame{}
+	size := int(chunk.SpriteRecordSize)
+
+	chunk.decodeMain(channelData[:int(chunk.mainSlots())*size], frame)
+
+	first := int(chunk.mainSlots())
+	for i := first; i < int(chunk.ChannelCount); i++ {
+		sprite := chunk.decodeSprite(channelData[i*size : (i+1)*size])
+		if sprite.Type == 0 && sprite.Member.Member == 0 {
+			continue
+		}
+
+		sprite.Channel = i - first + 1
+		frame.Sprites = append(frame.Sprites, sprite)
+	}
+
+	return frame
+}
+
+func (chunk *ScoreChunk) decodeMain(data []byte, frame *ScoreFrame) {
+	if chunk.mainSlots() > 2 {
+		chunk.decodeMainRecords(data, frame)
+		return
+	}
+
+	// the palette channel is the second record
+	var palette = data[chunk.SpriteRecordSize:]
+
+	if chunk.isD4() {
+		transFlags := data[3]
+
+		frame.Script = memberRef(1, int16(scoreEndian.Uint16(data[0:])))
+		frame.Transition.Duration = transFlags & 0x7f
+		frame.Transition.ChangeArea = transFlags&0x80 != 0
+		frame.Transition.ChunkSize = data[4]
+		frame.Tempo = data[5]
+		frame.Transition.Type = data[6]
+		frame.Sound1 = memberRef(1, int16(scoreEndian.Uint16(data[8:])))
+		frame.Sound2 = memberRef(1, int16(scoreEndian.Uint16(data[10:])))
+
+		frame.Palette.Member = memberRef(1, int16(scoreEndian.Uint16(palette[0:])))
+		palette = palette[2:]
+	} else {
+		frame.Script = memberRef(int16(scoreEndian.Uint16(data[0:])), int16(scoreEndian.Uint16(data[2:])))
+		frame.Sound1 = memberRef(int16(scoreEndian.Uint16(data[4:])), int16(scoreEndian.Uint16(data[6:])))
+		frame.Sound2 = memberRef(int16(scoreEndian.Uint16(data[8:])), int16(scoreEndian.Uint16(data[10:])))
+		frame.Transition.Member = memberRef(int16(scoreEndian.Uint16(data[12:])), int16(scoreEndian.Uint16(data[14:])))
+		frame.Tempo = data[21]
+
+		frame.Palette.Member = memberRef(int16(scoreEndian.Uint16(palette[0:])), int16(scoreEndian.Uint16(palette[2:])))
+		palette = palette[4:]
+	}
+
+	decodePalette(palette, frame)
+}
+
+// the main channels from Director 6 on, each in a record of its own
+const (
+	mainRecordScript = iota
+	mainRecordTempo
+	mainRecordTransition
+	mainRecordSound2
+	mainRecordSound1
+	mainRecordPalette
+)
+
+// decodeMainRecords reads the main channels of a Director 6 or later frame.
+// Each record is laid out like a sprite, the first four bytes are its sprite
+// list index and the member comes after them.
+func (chunk *ScoreChunk) decodeMainRecords(data []byte, frame *ScoreFrame) {
+	size := int(chunk.SpriteRecordSize)
+	record := func(index int) []byte {
+		return data[index*size : (index+1)*size]
+	}
+
+	recordMember := func(index int) ScoreMemberRef {
+		r := record(index)
+		return memberRef(int16(scoreEndian.Uint16(r[4:])), int16(scoreEndian.Uint16(r[6:])))
+	}
+
+	frame.Script = recordMember(mainRecordScript)
+	frame.Transition.Member = recordMember(mainRecordTransition)
+	frame.Sound1 = recordMember(mainRecordSound1)
+	frame.Sound2 = recordMember(mainRecordSound2)
+
+	// the tempo has two bytes of flags before it
+	frame.Tempo = record(mainRecordTempo)[6]
+
+	frame.Palette.Member = recordMember(mainRecordPalette)
+	decodePalette(record(mainRecordPalette)[8:], frame)
+}
+
+// decodePalette reads the settings that come after the palette member
+func decodePalette(palette []byte, frame *ScoreFrame) {
+	if frame.Palette.Member.Member == 0 {
+		return
+	}
+
+	flags := palette[1]
+
+	frame.Palette.Speed = palette[0]
+	frame.Palette.FirstColor = palette[2]
+	frame.Palette.LastColor = palette[3]
+	frame.Palette.FrameCount = scoreEndian.Uint16(palette[4:])
+	frame.Palette.CycleCount = scoreEndian.Uint16(palette[6:])
+	frame.Palette.ColorCycling = flags&0x80 != 0
+	frame.Palette.FadeToBlack = flags&0x60 == 0x60
+	frame.Palette.FadeToWhite = flags&0x60 == 0x40
+	frame.Palette.AutoReverse = flags&0x10 != 0
+	frame.Palette.OverTime = flags&0x04 != 0
+}
+
+func (chunk *ScoreChunk) decodeSprite(data []byte) ScoreSprite {
+	var sprite ScoreSprite
+	var ink, colorCode uint8
+	var rect []byte
+
+	switch {
+	case chunk.isD4():
+		sprite.Type = data[1]
+		sprite.ForeColor = data[2]
+		sprite.BackColor = data[3]
+		sprite.Thickness = data[4]
+		ink = data[5]
+		sprite.Member = memberRef(1, int16(scoreEndian.Uint16(data[6:])))
+		rect = data[8:16]
+		sprite.Script = memberRef(1, int16(scoreEndian.Uint16(data[16:])))
+		colorCode = data[18]
+		sprite.Blend = data[19]
+
+	case chunk.directorVersion.IsLessThan(version.Director_6_0_0):
+		sprite.Type = data[0]
+		ink = data[1]
+		sprite.Member = memberRef(int16(scoreEndian.Uint16(data[2:])), int16(scoreEndian.Uint16(data[4:])))
+		sprite.Script = memberRef(int16(scoreEndian.Uint16(data[6:])), int16(scoreEndian.Uint16(data[8:])))
+		sprite.ForeColor = data[10]
+		sprite.BackColor = data[11]
+		rect = data[12:20]
+		colorCode = data[20]
+		sprite.Blend = data[21]
+		sprite.Thickness = data[22]
+
+	default:
+		sprite.Type = data[0]
+		ink = data[1]
+		sprite.ForeColor = data[2]
+		sprite.BackColor = data[3]
+		sprite.Member = memberRef(int16(scoreEndian.Uint16(data[4:])), int16(scoreEndian.Uint16(data[6:])))
+		sprite.Script = memberRef(int16(scoreEndian.Uint16(data[8:])), int16(scoreEndian.Uint16(data[10:])))
+		rect = data[12:20]
+		colorCode = data[20]
+		sprite.Blend = data[21]
+		sprite.Thickness = data[22]
+	}
+
+	sprite.Ink = ink & 0x3f
+	sprite.Trails = ink&0x40 != 0
+	sprite.Stretch = ink&0x80 != 0
+	sprite.Editable = colorCode&0x40 != 0
+	sprite.Moveable = colorCode&0x80 != 0
+
+	top := int16(scoreEndian.Uint16(rect[0:]))
+	left := int16(scoreEndian.Uint16(rect[2:]))
+	height := int16(scoreEndian.Uint16(rect[4:]))
+	width := int16(scoreEndian.Uint16(rect[6:]))
+
+	sprite.Rect = utils.Rect{
+		Left:   left,
+		Top:    top,
+		Right:  left + width,
+		Bottom: top + height,
+		Width:  width,
+		Height: height,
+	}
+
+	return sprite
+}
+
+func memberRef(castLib int16, member int16) ScoreMemberRef {
+	if member == 0 {
+		return ScoreMemberRef{}
+	}
+
+	return ScoreMemberRef{CastLib: castLib, Member: member}
+}
+
+// ApplyLabels names the frames from the VWLB chunk
+func (chunk *ScoreChunk) ApplyLabels(labels map[int16]string) {
+	for frameNum, label := range labels {
+		if frame, ok := chunk.Frames[int32(frameNum)]; ok {
+			frame.Label = label
+		}
+	}
+}
+
+func ReadScoreChunkRaw(r *binary_reader.BinaryReader, v version.Version, endian binary.ByteOrder, isAfterburner bool) (*ScoreChunk, error) {
+	var err error
+	chunk := &ScoreChunk{
+		Reader:          r,
+		directorVersion: v,
+	}
+
+	r.Seek(0, 0)
+	err = chunk.Read(endian)
+	if err != nil {
+		return nil, err
+	}
+
+	return chunk, nil
+}
+
+func (c *ScoreChunk) ToJSON() (string, error) {
+	bytes, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
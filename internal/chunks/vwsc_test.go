@@ -0,0 +1,291 @@
+package chunks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/markhughes/dirry/internal/binary_reader"
+	"github.com/markhughes/dirry/internal/utils"
+	"github.com/markhughes/dirry/internal/version"
+)
+
+// scoreDelta is one channel change in a frame, the bytes to copy over the
+// channel data at the offset
+type scoreDelta struct {
+	offset int
+	data   []byte
+}
+
+func put16(data []byte, offset int, values ...int) {
+	for i, value := range values {
+		binary.BigEndian.PutUint16(data[offset+i*2:], uint16(value))
+	}
+}
+
+// testScoreFrames is the frame data of a score, the 20 byte header and then
+// each frame as its size and its deltas
+func testScoreFrames(framesVersion, recordSize, channels, displayed int16, frames ...[]scoreDelta) []byte {
+	var body bytes.Buffer
+	for _, frame := range frames {
+		size := 2
+		for _, delta := range frame {
+			size += 4 + len(delta.data)
+		}
+
+		binary.Write(&body, binary.BigEndian, uint16(size))
+		for _, delta := range frame {
+			binary.Write(&body, binary.BigEndian, []uint16{uint16(len(delta.data)), uint16(delta.offset)})
+			body.Write(delta.data)
+		}
+	}
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.BigEndian, []int32{int32(20 + body.Len()), 20, int32(len(frames))})
+	binary.Write(&out, binary.BigEndian, []int16{framesVersion, recordSize, channels, displayed})
+	out.Write(body.Bytes())
+
+	return out.Bytes()
+}
+
+// testScoreEntries wraps the frames in the entry table of a D6+ score
+func testScoreEntries(entries ...[]byte) []byte {
+	var out bytes.Buffer
+	size := 0
+	for _, entry := range entries {
+		size += len(entry)
+	}
+
+	binary.Write(&out, binary.BigEndian, []int32{0, -3, 12, int32(len(entries)), int32(len(entries) + 1), int32(size)})
+
+	offset := 0
+	binary.Write(&out, binary.BigEndian, int32(0))
+	for _, entry := range entries {
+		offset += len(entry)
+		binary.Write(&out, binary.BigEndian, int32(offset))
+	}
+
+	for _, entry := range entries {
+		out.Write(entry)
+	}
+
+	return out.Bytes()
+}
+
+func readTestScore(data []byte, v version.Version) (*ScoreChunk, error) {
+	reader, err := binary_reader.NewBinaryReader(data, int32(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadScoreChunkRaw(reader, v, binary.BigEndian, false)
+}
+
+// testPalette is the palette settings after the member, speed 10, colour
+// cycling and auto reverse over colours 1 to 254
+func testPalette(data []byte, offset int) {
+	copy(data[offset:], []byte{10, 0x90, 1, 254})
+	put16(data, offset+4, 3, 2)
+}
+
+func testRect(data []byte, offset int) {
+	put16(data, offset, 10, 20, 30, 40)
+}
+
+var testScorePalette = ScorePalette{
+	Member:       ScoreMemberRef{CastLib: 1, Member: 3},
+	Speed:        10,
+	FirstColor:   1,
+	LastColor:    254,
+	FrameCount:   3,
+	CycleCount:   2,
+	ColorCycling: true,
+	AutoReverse:  true,
+}
+
+var testScoreRect = utils.Rect{Left: 20, Top: 10, Right: 60, Bottom: 40, Width: 40, Height: 30}
+
+func TestReadScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   version.Version
+		score     func() []byte
+		channels  int16
+		frame     ScoreFrame
+		intervals []ScoreInterval
+	}{
+		{
+			name:    "director 4",
+			version: version.Director_4_0_0,
+			score: func() []byte {
+				data := make([]byte, 4*20)
+				put16(data, 0, 5)
+				copy(data[3:], []byte{0x85, 2, 30, 7})
+				put16(data, 8, 8, 9)
+
+				put16(data, 20, 3)
+				testPalette(data, 22)
+
+				copy(data[41:], []byte{1, 255, 0, 1, 0x48})
+				put16(data, 46, 10)
+				testRect(data, 48)
+				put16(data, 56, 11)
+				copy(data[58:], []byte{0x80, 50})
+
+				return testScoreFrames(7, 20, 4, 0,
+					[]scoreDelta{{0, data}},
+					[]scoreDelta{{5, []byte{60}}, {46, []byte{0, 12}}})
+			},
+			channels: 2,
+			frame: ScoreFrame{
+				Tempo:      30,
+				Script:     ScoreMemberRef{1, 5},
+				Sound1:     ScoreMemberRef{1, 8},
+				Sound2:     ScoreMemberRef{1, 9},
+				Transition: ScoreTransition{Type: 7, Duration: 5, ChunkSize: 2, ChangeArea: true},
+				Palette:    testScorePalette,
+				Sprites: []ScoreSprite{{
+					Channel: 1, Type: 1, Member: ScoreMemberRef{1, 10}, Script: ScoreMemberRef{1, 11},
+					Ink: 8, Trails: true, Blend: 50, ForeColor: 255, Rect: testScoreRect, Thickness: 1, Moveable: true,
+				}},
+			},
+		},
+		{
+			name:    "director 5",
+			version: version.Director_5_0_0,
+			score: func() []byte {
+				data := make([]byte, 4*24)
+				put16(data, 0, 1, 5, 1, 8, 2, 9, 1, 7)
+				data[21] = 30
+
+				put16(data, 24, 1, 3)
+				testPalette(data, 28)
+
+				copy(data[48:], []byte{1, 0x88})
+				put16(data, 50, 1, 10, 1, 11)
+				copy(data[58:], []byte{255, 0})
+				testRect(data, 60)
+				copy(data[68:], []byte{0x40, 50, 1})
+
+				return testScoreFrames(12, 24, 4, 0,
+					[]scoreDelta{{0, data}},
+					[]scoreDelta{{21, []byte{60}}, {52, []byte{0, 12}}})
+			},
+			channels: 2,
+			frame: ScoreFrame{
+				Tempo:      30,
+				Script:     ScoreMemberRef{1, 5},
+				Sound1:     ScoreMemberRef{1, 8},
+				Sound2:     ScoreMemberRef{2, 9},
+				Transition: ScoreTransition{Member: ScoreMemberRef{1, 7}},
+				Palette:    testScorePalette,
+				Sprites: []ScoreSprite{{
+					Channel: 1, Type: 1, Member: ScoreMemberRef{1, 10}, Script: ScoreMemberRef{1, 11},
+					Ink: 8, Stretch: true, Blend: 50, ForeColor: 255, Rect: testScoreRect, Thickness: 1, Editable: true,
+				}},
+			},
+		},
+		{
+			name:    "director 6",
+			version: version.Director_6_0_0,
+			score: func() []byte {
+				data := make([]byte, 8*24)
+				put16(data, 4, 1, 5)
+				data[24+6] = 30
+				put16(data, 48+4, 1, 7)
+				put16(data, 72+4, 2, 9)
+				put16(data, 96+4, 1, 8)
+
+				put16(data, 120+4, 1, 3)
+				testPalette(data, 120+8)
+
+				copy(data[144:], []byte{1, 0x48, 255, 0})
+				put16(data, 148, 1, 10, 1, 11)
+				testRect(data, 156)
+				copy(data[164:], []byte{0xc0, 50, 1})
+
+				frames := testScoreFrames(14, 24, 8, 150,
+					[]scoreDelta{{0, data}},
+					[]scoreDelta{{30, []byte{60}}, {150, []byte{0, 12}}})
+
+				primary := make([]byte, 20)
+				binary.BigEndian.PutUint32(primary[0:], 1)
+				binary.BigEndian.PutUint32(primary[4:], 2)
+				binary.BigEndian.PutUint32(primary[16:], 1)
+
+				secondary := make([]byte, 8)
+				put16(secondary, 0, 1, 12)
+
+				return testScoreEntries(frames, make([]byte, 4), primary, secondary, nil)
+			},
+			channels: 150,
+			frame: ScoreFrame{
+				Tempo:      30,
+				Script:     ScoreMemberRef{1, 5},
+				Sound1:     ScoreMemberRef{1, 8},
+				Sound2:     ScoreMemberRef{2, 9},
+				Transition: ScoreTransition{Member: ScoreMemberRef{1, 7}},
+				Palette:    testScorePalette,
+				Sprites: []ScoreSprite{{
+					Channel: 1, Type: 1, Member: ScoreMemberRef{1, 10}, Script: ScoreMemberRef{1, 11},
+					Ink: 8, Trails: true, Blend: 50, ForeColor: 255, Rect: testScoreRect, Thickness: 1, Editable: true, Moveable: true,
+				}},
+			},
+			intervals: []ScoreInterval{
+				{StartFrame: 1, EndFrame: 2, Channel: 1, Behaviors: []ScoreMemberRef{{1, 12}}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			score, err := readTestScore(test.score(), test.version)
+			if err != nil {
+				t.Fatalf("could not read score: %s", err)
+			}
+
+			if score.FrameCount != 2 || len(score.Frames) != 2 {
+				t.Fatalf("score has %d frames and decoded %d, want 2", score.FrameCount, len(score.Frames))
+			}
+
+			if score.SpriteChannels != test.channels {
+				t.Errorf("score has %d sprite channels, want %d", score.SpriteChannels, test.channels)
+			}
+
+			if !reflect.DeepEqual(*score.Frames[1], test.frame) {
+				t.Errorf("frame 1 is\n%+v\nwant\n%+v", *score.Frames[1], test.frame)
+			}
+
+			// frame 2 only has the tempo and member number, the rest
+			// carries over from frame 1
+			want := test.frame
+			want.Tempo = 60
+			want.Sprites = append([]ScoreSprite(nil), test.frame.Sprites...)
+			want.Sprites[0].Member.Member = 12
+			if !reflect.DeepEqual(*score.Frames[2], want) {
+				t.Errorf("frame 2 is\n%+v\nwant\n%+v", *score.Frames[2], want)
+			}
+
+			if !reflect.DeepEqual(score.Intervals, test.intervals) {
+				t.Errorf("intervals are %+v, want %+v", score.Intervals, test.intervals)
+			}
+
+			score.ApplyLabels(map[int16]string{1: "start", 5: "missing"})
+			if score.Frames[1].Label != "start" || score.Frames[2].Label != "" {
+				t.Errorf("labels are %q and %q, want \"start\" and none", score.Frames[1].Label, score.Frames[2].Label)
+			}
+
+			if _, ok := score.Frames[5]; ok {
+				t.Errorf("a label added frame 5")
+			}
+		})
+	}
+}
+
+func TestReadScoreDeltaPastChannels(t *testing.T) {
+	data := testScoreFrames(12, 24, 4, 0, []scoreDelta{{4*24 - 1, []byte{1, 2}}})
+	if _, err := readTestScore(data, version.Director_5_0_0); err == nil {
+		t.Fatalf("read a frame writing past the channel data")
+	}
+}
@@ -11,7 +11,9 @@ import (
 	"github.com/markhughes/dirry/internal/consts"
 	"github.com/markhughes/dirry/internal/errors"
 	"github.com/markhughes/dirry/internal/members"
+	"github.com/markhughes/dirry/internal/swf"
 	"github.com/markhughes/dirry/internal/utils"
+	"github.com/markhughes/dirry/internal/w3d"
 	"github.com/markhughes/dirry/internal/xmed"
 )
 
@@ -23,6 +25,13 @@ type XmedChunk struct {
 
 	Data []byte
 	Meta []byte
+
+	// Converted is what Data was converted to, by file extension
+	Converted map[string][]byte `json:"-"`
+
+	// Windows is set for a movie saved on Windows, its text is Windows-1252
+	// rather than Mac Roman
+	Windows bool `json:"-"`
 }
 
 func (chunk *XmedChunk) Read(castChunk *CastChunk, endian binary.ByteOrder) error {
@@ -50,8 +59,13 @@ func (chunk *XmedChunk) Read(castChunk *CastChunk, endian binary.ByteOrder) erro
 			chunk.Member = &members.MemberXtra{
 				Type: "shockwave3d",
 			}
-		} else if bytes[12] == 'F' && bytes[13] == 'W' && bytes[14] == 'S' {
-			utils.InfoMsg("xmed", "found FWS")
+		} else if string(bytes[:4]) == "FFFF" {
+			utils.InfoMsg("xmed", "found FFFF")
+			chunk.Member = &members.MemberXtra{
+				Type: "text",
+			}
+		} else if (bytes[12] == 'F' || bytes[12] == 'C') && bytes[13] == 'W' && bytes[14] == 'S' {
+			utils.InfoMsg("xmed", "found %s", bytes[12:15])
 			chunk.Member = &members.MemberXtra{
 				Type: "flash",
 			}
@@ -72,21 +86,11 @@ func (chunk *XmedChunk) Read(castChunk *CastChunk, endian binary.ByteOrder) erro
 	chunk.Extension = "unknown." + chunk.Member.Type + ".bin"
 
 	switch chunk.Member.Type {
-	case "flash":
-		chunk.Data, err = xmed.CreateFlashBinary(chunk.Reader.GetUnsafeBytesReader(), endian)
+	case "flash", "vectorShape": // director used flash for vectorShapes lol
+		err = chunk.readFlash(endian)
 		if err != nil {
-			return fmt.Errorf("could not create flash binary: %v", err)
+			return err
 		}
-		chunk.Extension = "swf"
-		chunk.Decoded = true
-
-	case "vectorShape": // director used flash for vectorShapes lol
-		chunk.Data, err = xmed.CreateFlashBinary(chunk.Reader.GetUnsafeBytesReader(), endian)
-		if err != nil {
-			return fmt.Errorf("could not create flash binary: %v", err)
-		}
-		chunk.Extension = "swf"
-		chunk.Decoded = true
 
 	case "havok": // -- is this just a 3d file?
 	case "shockwave3d":
@@ -95,18 +99,63 @@ func (chunk *XmedChunk) Read(castChunk *CastChunk, endian binary.ByteOrder) erro
 			return fmt.Errorf("could not create shockwave3d binary: %v", err)
 		}
 
+		scene, err := w3d.Parse(chunk.Data)
+		if err != nil {
+			utils.WarnMsg("xmed", "Could not read shockwave3d scene: %s", err)
+		} else {
+			chunk.Meta, err = json.Marshal(scene)
+			if err != nil {
+				return fmt.Errorf("could not convert shockwave3d scene to json: %v", err)
+			}
+
+			gltf, err := scene.GLTF()
+			if err != nil {
+				utils.WarnMsg("xmed", "Could not convert shockwave3d scene to glTF: %s", err)
+			} else {
+				chunk.Converted = map[string][]byte{"gltf": gltf}
+			}
+		}
+
 		chunk.Extension = "ifx"
 		chunk.Decoded = true
 
 	case "font":
-		chunk.Data, chunk.Meta, err = xmed.CreateFontBinary(chunk.Reader, endian)
+		var ttf []byte
+		chunk.Data, chunk.Meta, ttf, err = xmed.CreateFontBinary(chunk.Reader, endian)
 		if err != nil {
 			return fmt.Errorf("could not create font binary: %v", err)
 		}
 
+		if ttf != nil {
+			chunk.Converted = map[string][]byte{"ttf": ttf}
+		}
+
 		chunk.Extension = "pfr"
 		chunk.Decoded = true
 
+	case "text":
+		text, err := xmed.ReadText(chunk.Reader)
+		if err != nil {
+			return fmt.Errorf("could not read text: %v", err)
+		}
+
+		member := newTextMember(text)
+		styled := member.Styled(chunk.Windows)
+
+		chunk.Meta, err = member.ToJSON()
+		if err != nil {
+			return fmt.Errorf("could not convert text to json: %v", err)
+		}
+
+		chunk.Data = []byte(styled.ToPlainText())
+		chunk.Converted = map[string][]byte{
+			"rtf":  styled.ToRTF(),
+			"html": styled.ToHTML(),
+		}
+
+		chunk.Extension = "txt"
+		chunk.Decoded = true
+
 	default:
 		chunk.Decoded = true
 
@@ -116,10 +165,59 @@ func (chunk *XmedChunk) Read(castChunk *CastChunk, endian binary.ByteOrder) erro
 
 }
 
-func ReadXmedChunkRaw(r *binary_reader.BinaryReader, castChunk *CastChunk, endian binary.ByteOrder, isAfterburner bool) (*XmedChunk, error) {
+// FlashMeta is the header before the SWF and what is in the SWF
+type FlashMeta struct {
+	Xmed xmed.FlashHeader
+	Swf  *swf.Movie
+}
+
+// readFlash reads the SWF of a flash or vector shape member, a vector shape
+// is a single DefineShape which is converted to SVG
+func (chunk *XmedChunk) readFlash(endian binary.ByteOrder) error {
+	var err error
+	meta := FlashMeta{}
+
+	chunk.Data, meta.Xmed, err = xmed.CreateFlashBinary(chunk.Reader.GetUnsafeBytesReader(), endian)
+	if err != nil {
+		return fmt.Errorf("could not create flash binary: %v", err)
+	}
+
+	chunk.Extension = "swf"
+	chunk.Decoded = true
+
+	meta.Swf, err = swf.Parse(chunk.Data)
+	if err != nil {
+		utils.WarnMsg("xmed", "Could not read SWF: %s", err)
+	} else if chunk.Member.Type == "vectorShape" {
+		shapes, err := meta.Swf.Shapes()
+		if err != nil {
+			utils.WarnMsg("xmed", "Could not read vector shape: %s", err)
+		}
+
+		if len(shapes) > 1 {
+			utils.WarnMsg("xmed", "Vector shape has %d shapes, only the first is converted", len(shapes))
+		}
+
+		if len(shapes) > 0 {
+			chunk.Converted = map[string][]byte{"svg": shapes[0].SVG()}
+		} else if err == nil {
+			utils.WarnMsg("xmed", "Vector shape has no shape in it")
+		}
+	}
+
+	chunk.Meta, err = json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("could not convert flash meta to json: %v", err)
+	}
+
+	return nil
+}
+
+func ReadXmedChunkRaw(r *binary_reader.BinaryReader, castChunk *CastChunk, windows bool, endian binary.ByteOrder, isAfterburner bool) (*XmedChunk, error) {
 	var err error
 	chunk := &XmedChunk{
-		Reader: r,
+		Reader:  r,
+		Windows: windows,
 	}
 
 	chunk.Reader.HexDump(true)
@@ -168,6 +266,16 @@ func (c *XmedChunk) Save(projectName string, name string, pkg string) {
 		}
 	}
 
+	for extension, data := range c.Converted {
+		outputFile := filepath.Join(outputFolder, name+"."+extension)
+
+		err := os.WriteFile(outputFile, data, 0644)
+		if err != nil {
+			fmt.Printf("could not write file...: %v\n", err)
+			return
+		}
+	}
+
 	{
 		metaOutputFile := filepath.Join(outputFolder, name+"."+c.Extension+".json")
 
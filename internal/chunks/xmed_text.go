@@ -0,0 +1,110 @@
+package chunks
+
+import (
+	"encoding/json"
+
+	"github.com/markhughes/dirry/internal/xmed"
+)
+
+// TextMember is a text member kept by the Text xtra, its runs are turned into
+// the same formattings an STXT has so both export the same way
+type TextMember struct {
+	Text        string
+	Formattings []Formatting
+
+	AntiAlias          bool
+	AntiAliasThreshold int
+	Kerning            bool
+	KerningThreshold   int
+	WordWrap           bool
+
+	Paragraphs []xmed.TextParagraph
+	Unknown    map[string]string `json:",omitempty"`
+}
+
+func newTextMember(text *xmed.Text) *TextMember {
+	member := &TextMember{
+		Text:               text.Text,
+		AntiAlias:          text.AntiAlias,
+		AntiAliasThreshold: text.AntiAliasThreshold,
+		Kerning:            text.Kerning,
+		KerningThreshold:   text.KerningThreshold,
+		WordWrap:           text.WordWrap,
+		Paragraphs:         text.Paragraphs,
+		Unknown:            text.Unknown,
+	}
+
+	// a formatting can only have one alignment, so the runs are split where
+	// a paragraph starts
+	offsets := make(map[int]bool)
+	for _, run := range text.Runs {
+		offsets[run.Offset] = true
+	}
+
+	runs := text.Runs
+	for _, paragraph := range text.Paragraphs {
+		if offsets[paragraph.Offset] {
+			continue
+		}
+
+		run := xmed.TextRun{}
+		for _, before := range text.Runs {
+			if before.Offset <= paragraph.Offset {
+				run = before
+			}
+		}
+
+		run.Offset = paragraph.Offset
+		runs = insertRun(runs, run)
+	}
+
+	for _, run := range runs {
+		alignment := ""
+		for _, paragraph := range text.Paragraphs {
+			if paragraph.Offset <= run.Offset {
+				alignment = paragraph.Alignment
+			}
+		}
+
+		member.Formattings = append(member.Formattings, Formatting{
+			Offset:      int32(run.Offset),
+			FontId:      int16(run.FontId),
+			FontName:    text.Fonts[run.FontId],
+			Bold:        run.Bold,
+			Italic:      run.Italic,
+			Underline:   run.Underline,
+			Strikeout:   run.Strikeout,
+			FontSize:    int16(run.FontSize),
+			Colour:      run.Colour,
+			Alignment:   alignment,
+			CharSpacing: int16(run.CharSpacing),
+		})
+	}
+
+	return member
+}
+
+// insertRun puts a run in with the others, which are in order of offset
+func insertRun(runs []xmed.TextRun, run xmed.TextRun) []xmed.TextRun {
+	i := 0
+	for i < len(runs) && runs[i].Offset < run.Offset {
+		i++
+	}
+
+	out := append([]xmed.TextRun{}, runs[:i]...)
+	out = append(out, run)
+	return append(out, runs[i:]...)
+}
+
+// Styled is the member as styled text to export
+func (member *TextMember) Styled(windows bool) *StyledTextChunk {
+	return &StyledTextChunk{
+		Text:        member.Text,
+		Formattings: member.Formattings,
+		windows:     windows,
+	}
+}
+
+func (member *TextMember) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(member, "", "  ")
+}
@@ -0,0 +1,132 @@
+package chunks
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/markhughes/dirry/internal/binary_reader"
+	"github.com/markhughes/dirry/internal/palettes"
+	"github.com/markhughes/dirry/internal/xmed"
+)
+
+// textSection is a Text xtra section, its hex id and length and then the data
+func textSection(id int, data string) string {
+	return fmt.Sprintf("%04X%08X%s", id, len(data), data)
+}
+
+// testText is a text member in the layout xmed.ReadText expects, it is put
+// together here rather than saved by Director
+func testText() []byte {
+	return []byte("FFFF" +
+		textSection(0x0000, "0001"+"0007"+"000C"+"000E") +
+		textSection(0x0002, "It\x92s\rnext") +
+		textSection(0x0004, "0002"+"0001"+"5,Arial"+"0002"+"7,Georgia") +
+		textSection(0x0005, "0002"+
+			"00000000"+"00"+"0000"+"0000"+"0000"+"0000"+"0000"+"0000"+
+			"00000005"+"01"+"0004"+"0000"+"FFF8"+"0000"+"0006"+"000C") +
+		textSection(0x0006, "0002"+
+			"00000000"+"0001"+"000C"+"0001"+"FF0000"+"0000"+
+			"00000005"+"0002"+"0012"+"0006"+"000080"+"FFFF") +
+		textSection(0x0009, "ABCD"))
+}
+
+func readTestText(data []byte) (*xmed.Text, error) {
+	reader, err := binary_reader.NewBinaryReader(data, int32(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	return xmed.ReadText(reader)
+}
+
+func TestReadText(t *testing.T) {
+	text, err := readTestText(testText())
+	if err != nil {
+		t.Fatalf("could not read text: %s", err)
+	}
+
+	if text.Version != 1 || !text.AntiAlias || !text.Kerning || !text.WordWrap {
+		t.Errorf("settings are %+v", text)
+	}
+
+	if text.AntiAliasThreshold != 12 || text.KerningThreshold != 14 {
+		t.Errorf("thresholds are %d and %d, want 12 and 14", text.AntiAliasThreshold, text.KerningThreshold)
+	}
+
+	// the text is kept in the movie's character set, it is only decoded when
+	// it is exported
+	if text.Text != "It\x92s\rnext" {
+		t.Errorf("text is %q", text.Text)
+	}
+
+	fonts := map[int]string{1: "Arial", 2: "Georgia"}
+	if !reflect.DeepEqual(text.Fonts, fonts) {
+		t.Errorf("fonts are %v, want %v", text.Fonts, fonts)
+	}
+
+	paragraphs := []xmed.TextParagraph{
+		{Offset: 0, Alignment: "left"},
+		{Offset: 5, Alignment: "center", LeftIndent: 4, FirstIndent: -8, SpaceAfter: 6, LineSpacing: 12},
+	}
+	if !reflect.DeepEqual(text.Paragraphs, paragraphs) {
+		t.Errorf("paragraphs are %+v, want %+v", text.Paragraphs, paragraphs)
+	}
+
+	runs := []xmed.TextRun{
+		{Offset: 0, FontId: 1, FontSize: 12, Bold: true, Colour: palettes.Pixel24{R: 0xff}},
+		{Offset: 5, FontId: 2, FontSize: 18, Italic: true, Underline: true, Colour: palettes.Pixel24{B: 0x80}, CharSpacing: -1},
+	}
+	if !reflect.DeepEqual(text.Runs, runs) {
+		t.Errorf("runs are %+v, want %+v", text.Runs, runs)
+	}
+
+	if text.Unknown["0009"] != "ABCD" {
+		t.Errorf("unknown sections are %v", text.Unknown)
+	}
+}
+
+func TestReadTextTruncated(t *testing.T) {
+	data := testText()
+	if _, err := readTestText(data[:len(data)-2]); err == nil {
+		t.Fatalf("read a truncated section")
+	}
+}
+
+func TestTextMemberCharset(t *testing.T) {
+	text, err := readTestText(testText())
+	if err != nil {
+		t.Fatalf("could not read text: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		windows bool
+		plain   string
+		rtf     string
+	}{
+		{"mac", false, "Itís\nnext", `{\rtf1\mac`},
+		{"windows", true, "It’s\nnext", `{\rtf1\ansi\ansicpg1252`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			styled := newTextMember(text).Styled(test.windows)
+
+			if plain := styled.ToPlainText(); plain != test.plain {
+				t.Errorf("plain text is %q, want %q", plain, test.plain)
+			}
+
+			if rtf := styled.ToRTF(); !bytes.HasPrefix(rtf, []byte(test.rtf)) {
+				t.Errorf("rtf starts %q, want %q", rtf[:len(test.rtf)], test.rtf)
+			}
+
+			line := strings.Split(test.plain, "\n")[0]
+			if html := styled.ToHTML(); !bytes.Contains(html, []byte(line)) {
+				t.Errorf("html does not have %q", line)
+			}
+		})
+	}
+}
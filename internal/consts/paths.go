@@ -1,7 +1,6 @@
 package consts
 
 import (
-	"fmt"
 	"os"
 	"path"
 	"path/filepath"
@@ -10,12 +9,17 @@ import (
 
 // enum style  const of output paths
 
+var DirryRoot = ""
 var PathZips = ""
 var PathDump = ""
 var LogsDir = ""
 var PalettesDir = ""
 var PatternsDir = ""
 
+// DirryRootError is why DirryRoot couldn't be found, the paths are left
+// empty. It isn't printed here as a library has nowhere to print it to.
+var DirryRootError error
+
 func GetDirryRoot() (string, error) {
 	if strings.Contains(os.Args[0], os.TempDir()) {
 		// Running through `go run`
@@ -37,12 +41,11 @@ func GetDirryRoot() (string, error) {
 func init() {
 	dirryRoot, err := GetDirryRoot()
 	if err != nil {
-		fmt.Println("NOTE: could not get dirry root")
+		DirryRootError = err
 	} else {
-
-		fmt.Printf("dirryRoot: %s\n", dirryRoot)
-		os.MkdirAll(path.Join(dirryRoot), os.ModePerm)
-
+		// nothing is created here, the output directories are made when
+		// something is actually written to them
+		DirryRoot = dirryRoot
 		PathZips = path.Join(dirryRoot, "out", "zips")
 		PathDump = path.Join(dirryRoot, "out", "dump")
 		LogsDir = path.Join(dirryRoot, "logs")
@@ -6,11 +6,18 @@ import (
 
 	"github.com/markhughes/dirry/internal/chunks"
 	"github.com/markhughes/dirry/internal/errors"
+	"github.com/markhughes/dirry/internal/lingo"
+	"github.com/markhughes/dirry/internal/members"
 	"github.com/markhughes/dirry/internal/palettes"
 	"github.com/markhughes/dirry/internal/shockwave"
 	"github.com/markhughes/dirry/internal/utils"
+	"github.com/markhughes/dirry/internal/version"
 )
 
+// DebugPalettes writes the HTML of every palette the movie used into
+// _debug, it is off unless asked for as it is written on every dump
+var DebugPalettes bool
+
 func Dump(filePath string, pkg string, extraOffset int64) {
 	utils.PrintHeader()
 	utils.InfoMsg("dump", " > The Directory Utility <\n\n")
@@ -31,12 +38,21 @@ func Dump(filePath string, pkg string, extraOffset int64) {
 		utils.InfoMsg("dump", "Expanded %s to %d files\n", filePath, len(expanded))
 
 		for i := range expanded {
+			if expanded[i].Xtra {
+				utils.InfoMsg("dump", "Extracted %s\n", expanded[i].Path)
+				continue
+			}
+
 			utils.InfoMsg("dump", "Dumping %s with offset %d\n", expanded[i].Path, int64(expanded[i].MinusOffset))
 			Dump(expanded[i].Path, filepath.Base(filePath), int64(expanded[i].MinusOffset))
 		}
 		return
 	}
 
+	if err := shockwave.Export(); err != nil {
+		utils.ErrorMsg("dump", "Error exporting chunks for %s: %s\n", filePath, err)
+	}
+
 	chunkMapJson, err := shockwave.ChunkMap.ToJson()
 	if err != nil {
 		utils.ErrorMsg("dump", "Error converting chunkmap to JSON: %s\n", err)
@@ -50,6 +66,14 @@ func Dump(filePath string, pkg string, extraOffset int64) {
 	var content = ""
 	var pendingResourceIds = make([]int, 0)
 
+	// frame labels from VWLB, the score is decoded after so it can use them
+	var labels map[int16]string
+
+	// the scripts need the names and contexts, collected as we go
+	var scriptContexts = make([]scriptContext, 0)
+	var nameTables = make(map[int32]*chunks.LnamChunk)
+	var castLists = make(map[int32][]int32)
+
 	for i := range resources {
 		content = ""
 		var resource = resources[i]
@@ -189,6 +213,10 @@ func Dump(filePath string, pkg string, extraOffset int64) {
 				break
 			}
 
+			for _, entry := range caschunk.Entries {
+				castLists[resource.CastId] = append(castLists[resource.CastId], entry.Index)
+			}
+
 			content, err = caschunk.ToJSON()
 			if err != nil {
 				utils.ErrorMsg("dump", "Error converting CAS* chunk to JSON: %s\n", err)
@@ -250,8 +278,6 @@ func Dump(filePath string, pkg string, extraOffset int64) {
 				break
 			}
 
-			palettes.RegisterPallete(palettes.Clut(resource.ResourceId), clutchunk.Palette)
-
 			clutchunk.Save(filepath.Base(shockwave.FilePath), fmt.Sprint(resource.ResourceId), "")
 
 		case "LctX":
@@ -267,6 +293,8 @@ func Dump(filePath string, pkg string, extraOffset int64) {
 				break
 			}
 
+			scriptContexts = append(scriptContexts, scriptContext{castLib: resource.CastId, lctx: lctxchunk})
+
 			content, err = lctxchunk.ToJSON()
 			if err != nil {
 				utils.ErrorMsg("dump", "Error converting LctX chunk to JSON: %s", err)
@@ -286,31 +314,14 @@ func Dump(filePath string, pkg string, extraOffset int64) {
 				break
 			}
 
+			nameTables[resource.ResourceId] = lnamchunk
+
 			content, err = lnamchunk.ToJSON()
 			if err != nil {
 				utils.ErrorMsg("dump", "Error converting Lnam chunk to JSON: %s", err)
 				break
 			}
 
-		case "Lscr":
-			reader, err := resource.GetReader()
-			if err != nil {
-				utils.ErrorMsg("dump", "Error getting reader for Lscr resource: %s", err)
-				break
-			}
-
-			lscrchunk, err := chunks.ReadLscrChunkRaw(reader, shockwave.Endian, shockwave.IsAfterburner())
-			if err != nil {
-				utils.ErrorMsg("dump", "Error reading Lscr chunk: %s", err)
-				break
-			}
-
-			content, err = lscrchunk.ToJSON()
-			if err != nil {
-				utils.ErrorMsg("dump", "Error converting Lscr chunk to JSON: %s", err)
-				break
-			}
-
 		case "VWFI":
 			reader, err := resource.GetReader()
 			if err != nil {
@@ -343,6 +354,8 @@ func Dump(filePath string, pkg string, extraOffset int64) {
 				break
 			}
 
+			labels = vwlbchunk.Labels
+
 			content, err = vwlbchunk.ToJSON()
 			if err != nil {
 				utils.ErrorMsg("dump", "Error converting VWLB chunk to JSON: %s", err)
@@ -448,6 +461,14 @@ func Dump(filePath string, pkg string, extraOffset int64) {
 		}
 	}
 
+	var owners = scriptOwners(shockwave.Casts, scriptContexts, castLists)
+	var capitalX = len(shockwave.ChunkMap.GetResourcesByTag("LctX")) > 0
+	var alphaResources = shockwave.ChunkMap.GetResourcesByTag("ALFA")
+
+	// palettes are members, the bitmaps look them up by cast library and number
+	shockwave.LoadPalettes()
+	var castMembers = shockwave.CastMembers()
+
 	// These resources are dependent on a cast chunk or something else being parsed first
 	for _, i := range pendingResourceIds {
 		content = ""
@@ -473,9 +494,144 @@ func Dump(filePath string, pkg string, extraOffset int64) {
 				break
 			}
 
+		case "Lscr":
+			reader, err := resource.GetReader()
+			if err != nil {
+				utils.ErrorMsg("dump", "Error getting reader for Lscr resource: %s", err)
+				break
+			}
+
+			lscrchunk, err := chunks.ReadLscrChunkRaw(reader, shockwave.Version, capitalX, shockwave.Endian, shockwave.IsAfterburner())
+			if err != nil {
+				utils.ErrorMsg("dump", "Error reading Lscr chunk: %s", err)
+				break
+			}
+
+			content, err = lscrchunk.ToJSON()
+			if err != nil {
+				utils.ErrorMsg("dump", "Error converting Lscr chunk to JSON: %s", err)
+				break
+			}
+
+			castId, ok := owners[resource.ResourceId]
+			if !ok {
+				utils.WarnMsg("dump", "No cast member found for Lscr %d", resource.ResourceId)
+				break
+			}
+
+			script := lingo.NewScript(lscrchunk, scriptNames(resource.ResourceId, scriptContexts, nameTables))
+			if member, ok := shockwave.Casts[castId].Member.(*members.MemberScript); ok {
+				script.Type = lingo.ScriptType(member.ScriptType)
+			}
+
+			err = shockwave.Casts[castId].SaveFile(filepath.Base(shockwave.FilePath), fmt.Sprint(castId), shockwave.PkgName, "script.lasm", lingo.Disassemble(script))
+			if err != nil {
+				utils.ErrorMsg("dump", "Error saving disassembly for Lscr %d: %s", resource.ResourceId, err)
+			}
+
+			// protected movies strip the source, rebuild it from the bytecode
+			if shockwave.Casts[castId].Properties.ScriptText == "" {
+				dotSyntax := shockwave.Version.IsGreaterThanOrEqualTo(version.Director_7_0_0)
+				castLibs := shockwave.Version.IsGreaterThanOrEqualTo(version.Director_5_0_0)
+
+				err = shockwave.Casts[castId].SaveFile(filepath.Base(shockwave.FilePath), fmt.Sprint(castId), shockwave.PkgName, "script.ls", lingo.Decompile(script, dotSyntax, castLibs))
+				if err != nil {
+					utils.ErrorMsg("dump", "Error saving decompiled script for Lscr %d: %s", resource.ResourceId, err)
+				}
+			}
+
+		case "VWSC":
+			reader, err := resource.GetReader()
+			if err != nil {
+				utils.ErrorMsg("dump", "Error getting reader for VWSC resource: %s", err)
+				break
+			}
+
+			scorechunk, err := chunks.ReadScoreChunkRaw(reader, shockwave.Version, shockwave.Endian, shockwave.IsAfterburner())
+			if err != nil {
+				utils.ErrorMsg("dump", "Error reading VWSC chunk: %s", err)
+				break
+			}
+
+			scorechunk.ApplyLabels(labels)
+
+			content, err = scorechunk.ToJSON()
+			if err != nil {
+				utils.ErrorMsg("dump", "Error converting VWSC chunk to JSON: %s", err)
+				break
+			}
+
+		case "SCVW":
+			// a film loop's score, keyed to its member
+			var cast = shockwave.Casts[resource.CastId]
+
+			reader, err := resource.GetReader()
+			if err != nil {
+				utils.ErrorMsg("dump", "Error getting reader for SCVW resource: %s", err)
+				break
+			}
+
+			filmloopchunk, err := chunks.ReadFilmLoopChunkRaw(reader, cast, shockwave.Version, shockwave.Endian, shockwave.IsAfterburner())
+			if err != nil {
+				utils.ErrorMsg("dump", "Error reading SCVW chunk: %s", err)
+				break
+			}
+
+			content, err = filmloopchunk.ToJSON()
+			if err != nil {
+				utils.ErrorMsg("dump", "Error converting SCVW chunk to JSON: %s", err)
+				break
+			}
+
+			if cast == nil {
+				utils.WarnMsg("dump", "SCVW cast %d not found for resource %d, the frames are only in the chunk JSON", resource.CastId, resource.ResourceId)
+				break
+			}
+
+			member := castMembers[resource.CastId]
+			sequence, err := filmloopchunk.Sequence(member.CastLib, member.Number).ToJSON()
+			if err != nil {
+				utils.ErrorMsg("dump", "Error converting film loop %d to JSON: %s", resource.CastId, err)
+				break
+			}
+
+			err = cast.SaveFile(filepath.Base(shockwave.FilePath), fmt.Sprint(resource.CastId), shockwave.PkgName, "filmloop.json", sequence)
+			if err != nil {
+				utils.ErrorMsg("dump", "Error saving film loop %d: %s", resource.CastId, err)
+			}
+
 		case "snd ":
-			// TODO
-			break
+			reader, err := resource.GetReader()
+			if err != nil {
+				utils.ErrorMsg("dump", "Error getting reader for snd resource: %s", err)
+				break
+			}
+
+			chunk, err := chunks.ReadSndChunkRaw(reader, shockwave.Endian, shockwave.IsAfterburner())
+			if err != nil {
+				utils.ErrorMsg("dump", "Error reading snd chunk: %s", err)
+				break
+			}
+
+			content, err = chunk.ToJSON()
+			if err != nil {
+				utils.ErrorMsg("dump", "Error converting snd chunk to JSON: %s", err)
+				break
+			}
+
+			// named after the member when there is one
+			name := fmt.Sprint(resource.ResourceId)
+			if cast := shockwave.Casts[resource.CastId]; cast != nil {
+				name = fmt.Sprint(resource.CastId)
+				if cast.Properties.Name != "" {
+					name += "_" + cast.Properties.Name
+				}
+			}
+
+			err = chunk.Save(filepath.Base(shockwave.FilePath), name, shockwave.PkgName)
+			if err != nil {
+				utils.ErrorMsg("dump", "Error saving snd %d: %s", resource.ResourceId, err)
+			}
 
 		case "BITD":
 			var cast = shockwave.Casts[resource.CastId]
@@ -490,12 +646,42 @@ func Dump(filePath string, pkg string, extraOffset int64) {
 				break
 			}
 
-			chunk, err := chunks.ReadBitmapChunkRaw(reader, shockwave.Endian, cast, shockwave.IsAfterburner())
+			chunk, err := chunks.ReadBitmapChunkRaw(reader, shockwave.Endian, cast, castMembers[resource.CastId].CastLib, shockwave.IsAfterburner())
 			if err != nil {
 				utils.ErrorMsg("dump", "Error reading BITD chunk: %s", err)
 				break
 			}
 
+			// the alpha can be kept in its own ALFA chunk under the same member,
+			// it only means something when the member draws with its alpha
+			for _, alphaResource := range alphaResources {
+				if alphaResource.CastId != resource.CastId {
+					continue
+				}
+
+				if !chunk.Member.UseAlpha() {
+					utils.DebugMsg("dump", "Not merging ALFA into BITD %d, the member doesn't use its alpha", resource.ResourceId)
+					break
+				}
+
+				alphaReader, err := alphaResource.GetReader()
+				if err != nil {
+					utils.ErrorMsg("dump", "Error getting reader for ALFA resource: %s", err)
+					break
+				}
+
+				alfachunk, err := chunks.ReadAlfaChunkRaw(alphaReader, shockwave.Endian, shockwave.IsAfterburner())
+				if err != nil {
+					utils.ErrorMsg("dump", "Error reading ALFA chunk: %s", err)
+					break
+				}
+
+				err = chunk.MergeAlpha(alfachunk)
+				if err != nil {
+					utils.ErrorMsg("dump", "Error merging ALFA into BITD %d: %s", resource.ResourceId, err)
+				}
+			}
+
 			content, err = chunk.ToJSON()
 			if err != nil {
 				utils.ErrorMsg("dump", "Error converting BITD chunk to JSON: %s", err)
@@ -506,6 +692,26 @@ func Dump(filePath string, pkg string, extraOffset int64) {
 
 			break
 
+		case "ALFA":
+			// merged into the BITD of the member, this is only the record of it
+			reader, err := resource.GetReader()
+			if err != nil {
+				utils.ErrorMsg("dump", "Error getting reader for ALFA resource: %s", err)
+				break
+			}
+
+			alfachunk, err := chunks.ReadAlfaChunkRaw(reader, shockwave.Endian, shockwave.IsAfterburner())
+			if err != nil {
+				utils.ErrorMsg("dump", "Error reading ALFA chunk: %s", err)
+				break
+			}
+
+			content, err = alfachunk.ToJSON()
+			if err != nil {
+				utils.ErrorMsg("dump", "Error converting ALFA chunk to JSON: %s", err)
+				break
+			}
+
 		case "XMED":
 			// even if the cast is not found, we will try to detect it and parse it ourselves
 			var cast = shockwave.Casts[resource.CastId]
@@ -516,7 +722,7 @@ func Dump(filePath string, pkg string, extraOffset int64) {
 				break
 			}
 
-			chunk, err := chunks.ReadXmedChunkRaw(reader, cast, shockwave.Endian, shockwave.IsAfterburner())
+			chunk, err := chunks.ReadXmedChunkRaw(reader, cast, shockwave.IsWindows(), shockwave.Endian, shockwave.IsAfterburner())
 			if err != nil {
 				if _, ok := err.(*errors.UnhandledXtraTypeError); ok {
 					utils.WarnMsg("dump", "Could not decode XMED chunk: %s", err)
@@ -552,4 +758,8 @@ func Dump(filePath string, pkg string, extraOffset int64) {
 		}
 
 	}
+
+	if DebugPalettes {
+		palettes.StoreAsHtml()
+	}
 }
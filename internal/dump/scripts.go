@@ -0,0 +1,62 @@
+package dump
+
+import (
+	"github.com/markhughes/dirry/internal/chunks"
+)
+
+// scriptContext is a LctX with the cast library that owns it
+type scriptContext struct {
+	castLib int32
+	lctx    *chunks.LctxChunk
+}
+
+// scriptOwners maps each Lscr resource id to the CASt that owns it. A
+// member points at its script by the script id, which is an index into
+// the LctX of the member's cast library.
+func scriptOwners(casts map[int32]*chunks.CastChunk, contexts []scriptContext, castLists map[int32][]int32) map[int32]int32 {
+	owners := make(map[int32]int32)
+
+	allCasts := make([]int32, 0, len(casts))
+	for castId := range casts {
+		allCasts = append(allCasts, castId)
+	}
+
+	for _, context := range contexts {
+		members, ok := castLists[context.castLib]
+		if !ok || len(contexts) == 1 {
+			// nothing to tell the libraries apart, try every member
+			members = allCasts
+		}
+
+		for _, castId := range members {
+			cast := casts[castId]
+			if cast == nil || cast.ScriptId == 0 || int(cast.ScriptId) > len(context.lctx.ScriptFiles) {
+				continue
+			}
+
+			owners[context.lctx.ScriptFiles[cast.ScriptId-1].Index] = castId
+		}
+	}
+
+	return owners
+}
+
+// scriptNames finds the Lnam used by a Lscr through the LctX listing it
+func scriptNames(lscrId int32, contexts []scriptContext, nameTables map[int32]*chunks.LnamChunk) *chunks.LnamChunk {
+	for _, context := range contexts {
+		for _, file := range context.lctx.ScriptFiles {
+			if file.Index == lscrId {
+				return nameTables[context.lctx.TableId]
+			}
+		}
+	}
+
+	// older movies only have the one name table
+	if len(nameTables) == 1 {
+		for _, lnam := range nameTables {
+			return lnam
+		}
+	}
+
+	return nil
+}
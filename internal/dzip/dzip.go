@@ -23,10 +23,14 @@ func DZip(filePath string, pkg string) error {
 		fmt.Printf("Expanded %s to %d files\n", filePath, len(expanded))
 
 		for i := range expanded {
-			fmt.Printf("Dumping %s\n", expanded[i])
+			if expanded[i].Xtra {
+				continue
+			}
+
+			fmt.Printf("Dumping %s\n", expanded[i].Path)
 			var err = DZip(expanded[i].Path, filepath.Base(filePath))
 			if err != nil {
-				fmt.Printf("Error dumping file %s: %s\n", expanded[i], err)
+				fmt.Printf("Error dumping file %s: %s\n", expanded[i].Path, err)
 				return fmt.Errorf("error dumping file: %s", err)
 			}
 		}
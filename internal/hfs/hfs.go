@@ -0,0 +1,135 @@
+package hfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/markhughes/dirry/internal/consts"
+	"github.com/markhughes/dirry/internal/dump"
+	"github.com/markhughes/dirry/internal/libhfs"
+	"github.com/markhughes/dirry/internal/shockwave"
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// Hfs lists the files of an HFS disc image with their type and creator.
+// With extract the forks are written into outputFolder, or the dump folder
+// when it's empty, the resource fork as a .rsrc next to the data fork. With
+// dumpMovies every movie, cast and projector is dumped as well.
+func Hfs(imagePath string, outputFolder string, extract bool, dumpMovies bool) error {
+	image, err := os.Open(imagePath)
+	if err != nil {
+		return fmt.Errorf("error opening image: %s", err)
+	}
+	defer image.Close()
+
+	info, err := image.Stat()
+	if err != nil {
+		return fmt.Errorf("error opening image: %s", err)
+	}
+
+	volume, err := libhfs.Open(image, info.Size())
+	if err != nil {
+		return fmt.Errorf("error reading HFS volume: %s", err)
+	}
+
+	utils.InfoMsg("hfs", "Volume %s, %d files in %d folders", volume.Name, len(volume.Files), len(volume.Folders))
+
+	for _, file := range volume.Files {
+		fmt.Printf("%-4s %-4s %10d %10d  %s\n", printable(file.Type), printable(file.Creator), file.DataLength, file.ResourceLength, file.Path)
+	}
+
+	if !extract && !dumpMovies {
+		return nil
+	}
+
+	if outputFolder == "" {
+		outputFolder = filepath.Join(consts.PathDump, filepath.Base(imagePath), "hfs")
+	}
+
+	movies := make([]string, 0)
+	for _, file := range volume.Files {
+		path, err := extractFile(volume, file, outputFolder)
+		if err != nil {
+			utils.WarnMsg("hfs", "Could not extract %s: %s", file.Path, err)
+			continue
+		}
+
+		if isDirectorFile(volume, file) {
+			movies = append(movies, path)
+		}
+	}
+
+	utils.SuccessMsg("hfs", "Extracted %d files into %s", len(volume.Files), outputFolder)
+
+	if !dumpMovies {
+		return nil
+	}
+
+	for _, path := range movies {
+		dump.Dump(path, "", 0)
+	}
+
+	return nil
+}
+
+// extractFile writes the data fork where the file was on the volume, and the
+// resource fork next to it when there is one
+func extractFile(volume *libhfs.Volume, file *libhfs.File, outputFolder string) (string, error) {
+	path := filepath.Join(outputFolder, filepath.FromSlash(file.Path))
+	os.MkdirAll(filepath.Dir(path), os.ModePerm)
+
+	data, err := volume.ReadData(file)
+	if err != nil {
+		return "", fmt.Errorf("error reading data fork: %s", err)
+	}
+
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	if file.ResourceLength == 0 {
+		return path, nil
+	}
+
+	resource, err := volume.ReadResource(file)
+	if err != nil {
+		return "", fmt.Errorf("error reading resource fork: %s", err)
+	}
+
+	return path, os.WriteFile(path+".rsrc", resource, 0644)
+}
+
+// isDirectorFile is whether dump has anything to read, a movie or cast by
+// its type and creator, or an application with a movie where a projector
+// keeps it
+func isDirectorFile(volume *libhfs.Volume, file *libhfs.File) bool {
+	if shockwave.IsMacMovie(file.Type, file.Creator) {
+		return true
+	}
+
+	if file.Type != "APPL" || file.DataLength == 0 {
+		return false
+	}
+
+	data, err := volume.ReadData(file)
+	if err != nil {
+		return false
+	}
+
+	return shockwave.IsMacProjector(data)
+}
+
+// printable keeps a type or creator code in one column when it has control
+// characters, or nothing at all, in it
+func printable(code string) string {
+	out := []byte(code)
+	for i, c := range out {
+		if c < 0x20 || c >= 0x7F {
+			out[i] = '.'
+		}
+	}
+
+	return string(out)
+}
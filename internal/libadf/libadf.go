@@ -3,7 +3,38 @@ package libadf
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"os"
+	"time"
+
+	"github.com/markhughes/dirry/internal/macroman"
+)
+
+const (
+	ADF_MAGIC        = 0x00051607
+	ADF_SINGLE_MAGIC = 0x00051600
+
+	ADF_VERSION_1 = 0x00010000
+	ADF_VERSION_2 = 0x00020000
+)
+
+// the entries that are understood, the rest are only kept in Entries
+const (
+	EntryDataFork     = 1
+	EntryResourceFork = 2
+	EntryRealName     = 3
+	EntryComment      = 4
+	EntryFileInfo     = 7 // version 1 only, dates since 1904
+	EntryFileDates    = 8 // version 2, dates since 2000
+	EntryFinderInfo   = 9
+)
+
+// a date version 2 doesn't know
+const unknownDate = -0x80000000
+
+var (
+	epoch1904 = time.Date(1904, time.January, 1, 0, 0, 0, 0, time.UTC)
+	epoch2000 = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
 )
 
 type AdfEntry struct {
@@ -12,7 +43,32 @@ type AdfEntry struct {
 	Length  int
 }
 
-func UnpackAdfFromFile(path string) (map[int][]byte, error) {
+// AppleDouble is an AppleDouble or AppleSingle file, AppleSingle has the
+// data fork in it as well
+type AppleDouble struct {
+	Version uint32
+	Single  bool
+
+	// the home file system in version 1, zeroes in version 2
+	Filler []byte
+
+	Entries map[int][]byte
+
+	RealName string
+	Comment  string
+	Type     string
+	Creator  string
+	Flags    uint16
+
+	Created  time.Time
+	Modified time.Time
+	Backup   time.Time
+
+	DataFork     []byte
+	ResourceFork []byte
+}
+
+func UnpackAdfFromFile(path string) (*AppleDouble, error) {
 	adfData, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -21,51 +77,103 @@ func UnpackAdfFromFile(path string) (map[int][]byte, error) {
 	return UnpackAdf(adfData)
 }
 
-// incomplete
-func UnpackAdf(adfData []byte) (map[int][]byte, error) {
-	const ADF_MAGIC = 0x00051607
-	const ADF_VERSION = 0x00020000
+// IsAppleDouble is whether data starts like an AppleDouble or AppleSingle
+// file
+func IsAppleDouble(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+
+	magic := binary.BigEndian.Uint32(data)
+	return magic == ADF_MAGIC || magic == ADF_SINGLE_MAGIC
+}
 
+// UnpackAdf reads the entries of an AppleDouble or AppleSingle file, of
+// either version, and what's known of them
+func UnpackAdf(adfData []byte) (*AppleDouble, error) {
 	if len(adfData) < 26 {
 		return nil, errors.New("adfData too short")
 	}
 
-	magic := binary.BigEndian.Uint32(adfData[0:4])
-	if magic != ADF_MAGIC {
+	be := binary.BigEndian
+
+	if !IsAppleDouble(adfData) {
 		return nil, errors.New("AppleDouble magic number not found")
 	}
 
-	version := binary.BigEndian.Uint32(adfData[4:8])
-	if version != ADF_VERSION {
-		return nil, errors.New("not a Version 2 ADF")
+	adf := &AppleDouble{
+		Version: be.Uint32(adfData[4:8]),
+		Single:  be.Uint32(adfData[0:4]) == ADF_SINGLE_MAGIC,
+		Filler:  adfData[8:24],
+		Entries: make(map[int][]byte),
 	}
 
-	filler := adfData[8:24]
-	numEntries := binary.BigEndian.Uint16(adfData[24:26])
-
-	entries := map[int][]byte{
-		0: filler, // Entry #0 is invalid -- use it for the filler..
+	if adf.Version != ADF_VERSION_1 && adf.Version != ADF_VERSION_2 {
+		return nil, fmt.Errorf("unknown AppleDouble version %08x", adf.Version)
 	}
 
-	adfData = adfData[26:]
+	numEntries := int(be.Uint16(adfData[24:26]))
+
+	// entry offsets are from the start of the file
+	table := adfData[26:]
 
-	for i := 0; i < int(numEntries); i++ {
-		if len(adfData) < 12 {
+	for i := 0; i < numEntries; i++ {
+		if len(table) < 12 {
 			return nil, errors.New("adfData too short for entry")
 		}
 
-		entryId := int(binary.BigEndian.Uint32(adfData[0:4]))
-		offset := int(binary.BigEndian.Uint32(adfData[4:8]))
-		length := int(binary.BigEndian.Uint32(adfData[8:12]))
+		entryId := int(be.Uint32(table[0:4]))
+		offset := int(be.Uint32(table[4:8]))
+		length := int(be.Uint32(table[8:12]))
 
-		adfData = adfData[12:]
+		table = table[12:]
 
 		if offset+length > len(adfData) {
-			return entries, errors.New("entry data extends beyond end of adfData")
+			return nil, fmt.Errorf("entry %d extends beyond end of adfData", entryId)
 		}
 
-		entries[entryId] = adfData[offset : offset+length]
+		adf.Entries[entryId] = adfData[offset : offset+length]
+	}
+
+	adf.DataFork = adf.Entries[EntryDataFork]
+	adf.ResourceFork = adf.Entries[EntryResourceFork]
+	adf.RealName = macroman.ConvertMacRomanToUTF8(string(adf.Entries[EntryRealName]))
+	adf.Comment = macroman.ConvertMacRomanToUTF8(string(adf.Entries[EntryComment]))
+
+	// the Finder info starts with the type, creator and flags
+	if info := adf.Entries[EntryFinderInfo]; len(info) >= 10 {
+		adf.Type = string(info[0:4])
+		adf.Creator = string(info[4:8])
+		adf.Flags = be.Uint16(info[8:10])
+	}
+
+	if dates := adf.Entries[EntryFileDates]; adf.Version == ADF_VERSION_2 && len(dates) >= 12 {
+		adf.Created = date2000(int32(be.Uint32(dates[0:])))
+		adf.Modified = date2000(int32(be.Uint32(dates[4:])))
+		adf.Backup = date2000(int32(be.Uint32(dates[8:])))
+	}
+
+	if info := adf.Entries[EntryFileInfo]; adf.Version == ADF_VERSION_1 && len(info) >= 12 {
+		adf.Created = date1904(be.Uint32(info[0:]))
+		adf.Modified = date1904(be.Uint32(info[4:]))
+		adf.Backup = date1904(be.Uint32(info[8:]))
+	}
+
+	return adf, nil
+}
+
+func date2000(seconds int32) time.Time {
+	if seconds == unknownDate {
+		return time.Time{}
+	}
+
+	return epoch2000.Add(time.Duration(seconds) * time.Second)
+}
+
+func date1904(seconds uint32) time.Time {
+	if seconds == 0 {
+		return time.Time{}
 	}
 
-	return entries, nil
+	return epoch1904.Add(time.Duration(seconds) * time.Second)
 }
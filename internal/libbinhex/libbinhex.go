@@ -0,0 +1,225 @@
+package libbinhex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/markhughes/dirry/internal/macroman"
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// the line a BinHex 4.0 file starts with, mail headers and all can come
+// before it
+const marker = "(This file must be converted with BinHex"
+
+// each character is six bits
+const alphabet = "!\"#$%&'()*+,-012345689@ABCDEFGHIJKLMNPQRSTUVXYZ[`abcdefhijklmpqr"
+
+// a run is the byte before it repeated, a count of zero is the marker itself
+const runMarker = 0x90
+
+type BinHex struct {
+	Name    string
+	Type    string
+	Creator string
+	Flags   uint16
+
+	DataFork     []byte
+	ResourceFork []byte
+}
+
+func UnpackBinHexFromFile(path string) (*BinHex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return UnpackBinHex(data)
+}
+
+// IsBinHex is whether the BinHex marker line is near enough the start
+func IsBinHex(data []byte) bool {
+	if len(data) > 4096 {
+		data = data[:4096]
+	}
+
+	return bytes.Contains(data, []byte(marker))
+}
+
+// UnpackBinHex decodes a BinHex 4.0 file into its forks, checking the CRC of
+// the header and of each fork
+func UnpackBinHex(data []byte) (*BinHex, error) {
+	start := bytes.Index(data, []byte(marker))
+	if start < 0 {
+		return nil, errors.New("BinHex marker not found")
+	}
+
+	encoded, err := decodeCharacters(data[start+len(marker):])
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := expandRuns(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(decoded) == 0 {
+		return nil, errors.New("BinHex data is empty")
+	}
+
+	r := &reader{data: decoded}
+
+	nameLength := int(r.next(1)[0])
+	if nameLength < 1 || nameLength > 63 {
+		return nil, fmt.Errorf("name is %d characters", nameLength)
+	}
+
+	// the name, a zero version, the type, creator, flags and fork lengths
+	header := r.next(nameLength + 1 + 4 + 4 + 2 + 4 + 4)
+	if err := r.checkCRC("header", decoded[:1+len(header)]); err != nil {
+		return nil, err
+	}
+
+	be := binary.BigEndian
+
+	bh := &BinHex{Name: macroman.ConvertMacRomanToUTF8(string(header[:nameLength]))}
+
+	header = header[nameLength+1:]
+	bh.Type = string(header[0:4])
+	bh.Creator = string(header[4:8])
+	bh.Flags = be.Uint16(header[8:])
+
+	dataLength := int(be.Uint32(header[10:]))
+	resourceLength := int(be.Uint32(header[14:]))
+
+	bh.DataFork = r.next(dataLength)
+	if err := r.checkCRC("data fork", bh.DataFork); err != nil {
+		return nil, err
+	}
+
+	bh.ResourceFork = r.next(resourceLength)
+	if err := r.checkCRC("resource fork", bh.ResourceFork); err != nil {
+		return nil, err
+	}
+
+	return bh, nil
+}
+
+// decodeCharacters turns everything between the colons into bytes, line
+// breaks and other whitespace are skipped
+func decodeCharacters(data []byte) ([]byte, error) {
+	begin := bytes.IndexByte(data, ':')
+	if begin < 0 {
+		return nil, errors.New("BinHex data not found")
+	}
+	data = data[begin+1:]
+
+	var values [256]int
+	for i := range values {
+		values[i] = -1
+	}
+	for i := 0; i < len(alphabet); i++ {
+		values[alphabet[i]] = i
+	}
+
+	out := make([]byte, 0, len(data)*3/4)
+
+	var bits uint32
+	count := 0
+	for _, c := range data {
+		if c == ':' {
+			return out, nil
+		}
+
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+
+		value := values[c]
+		if value < 0 {
+			return nil, fmt.Errorf("%q is not a BinHex character", c)
+		}
+
+		bits = bits<<6 | uint32(value)
+		count += 6
+		if count >= 8 {
+			count -= 8
+			out = append(out, byte(bits>>uint(count)))
+		}
+	}
+
+	return nil, errors.New("BinHex data has no end")
+}
+
+func expandRuns(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+
+	for i := 0; i < len(data); i++ {
+		if data[i] != runMarker {
+			out = append(out, data[i])
+			continue
+		}
+
+		i++
+		if i >= len(data) {
+			return nil, errors.New("run has no length")
+		}
+
+		count := int(data[i])
+		if count == 0 {
+			out = append(out, runMarker)
+			continue
+		}
+
+		if len(out) == 0 {
+			return nil, errors.New("run has nothing to repeat")
+		}
+
+		last := out[len(out)-1]
+		for j := 1; j < count; j++ {
+			out = append(out, last)
+		}
+	}
+
+	return out, nil
+}
+
+// reader hands out the decoded data, once it runs out it only gives nil and
+// the error is kept for the CRC check to return
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil || n < 0 || r.pos+n > len(r.data) {
+		if r.err == nil {
+			r.err = errors.New("BinHex data ends early")
+		}
+		return nil
+	}
+
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+// checkCRC reads the CRC after a part and checks it against what it covers
+func (r *reader) checkCRC(part string, covered []byte) error {
+	crc := r.next(2)
+	if r.err != nil {
+		return r.err
+	}
+
+	if want, got := binary.BigEndian.Uint16(crc), utils.CRC16(covered); want != got {
+		return fmt.Errorf("%s CRC is %04x, the file says %04x", part, got, want)
+	}
+
+	return nil
+}
@@ -0,0 +1,495 @@
+package libhfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+
+	"github.com/markhughes/dirry/internal/macroman"
+)
+
+const sectorSize = 512
+
+// the master directory block is always the third sector of the volume
+const mdbOffset = 1024
+
+// the catalog ids that are always the same
+const (
+	rootFolderId = 2
+	extentsId    = 3
+	catalogId    = 4
+)
+
+// catalog record types
+const (
+	recordFolder = 1
+	recordFile   = 2
+)
+
+// b-tree node types
+const (
+	nodeLeaf = 0xFF
+)
+
+// the fork types in an extents key
+const (
+	forkData     = 0x00
+	forkResource = 0xFF
+)
+
+type extent struct {
+	Start uint16
+	Count uint16
+}
+
+type fork struct {
+	Length  uint32
+	Extents []extent
+}
+
+type Volume struct {
+	Name string
+
+	// seconds since 1904
+	Created  uint32
+	Modified uint32
+
+	r      io.ReaderAt
+	offset int64
+
+	blockSize  uint32
+	firstBlock int64
+
+	extents map[extentsKey][]extent
+
+	Files   []*File
+	Folders map[uint32]*Folder
+}
+
+type Folder struct {
+	Id       uint32
+	ParentId uint32
+	Name     string
+}
+
+type File struct {
+	Id       uint32
+	ParentId uint32
+	Name     string
+
+	// Path is from the root of the volume, with slashes between folders
+	Path string
+
+	Type    string
+	Creator string
+	Flags   uint16
+
+	Created  uint32
+	Modified uint32
+
+	DataLength     uint32
+	ResourceLength uint32
+
+	data     fork
+	resource fork
+}
+
+type extentsKey struct {
+	Fork   byte
+	FileId uint32
+}
+
+// Open reads an HFS volume from an image. The volume can start the image, be
+// a partition of an Apple partition map, as hybrid ISO/HFS discs have, or
+// follow the header of a Disk Copy 4.2 image.
+func Open(r io.ReaderAt, size int64) (*Volume, error) {
+	offsets := []int64{0, 84}
+
+	partitions, err := hfsPartitions(r)
+	if err == nil {
+		offsets = append(partitions, offsets...)
+	}
+
+	for _, offset := range offsets {
+		if offset+mdbOffset+sectorSize > size {
+			continue
+		}
+
+		volume, err := openVolume(r, offset)
+		if err == nil {
+			return volume, nil
+		}
+
+		// a volume that was found but is broken says more than not finding one
+		if _, ok := err.(*volumeError); ok {
+			return nil, err
+		}
+	}
+
+	return nil, errors.New("no HFS volume found")
+}
+
+// volumeError is a problem with a volume that is there, as opposed to there
+// being no volume at an offset
+type volumeError struct {
+	err error
+}
+
+func (e *volumeError) Error() string {
+	return e.err.Error()
+}
+
+// hfsPartitions lists where the Apple_HFS partitions start
+func hfsPartitions(r io.ReaderAt) ([]int64, error) {
+	block := make([]byte, sectorSize)
+	if _, err := r.ReadAt(block, 0); err != nil {
+		return nil, err
+	}
+
+	if string(block[:2]) != "ER" {
+		return nil, errors.New("no Apple partition map")
+	}
+
+	blockSize := int64(binary.BigEndian.Uint16(block[2:]))
+	if blockSize == 0 {
+		blockSize = sectorSize
+	}
+
+	offsets := make([]int64, 0)
+
+	// the map says how many entries it has in each of them
+	count := int64(1)
+	for i := int64(1); i <= count; i++ {
+		if _, err := r.ReadAt(block, i*sectorSize); err != nil {
+			break
+		}
+
+		if string(block[:2]) != "PM" {
+			break
+		}
+
+		count = int64(binary.BigEndian.Uint32(block[4:]))
+		if cString(block[48:80]) == "Apple_HFS" {
+			offsets = append(offsets, int64(binary.BigEndian.Uint32(block[8:]))*blockSize)
+		}
+	}
+
+	return offsets, nil
+}
+
+func openVolume(r io.ReaderAt, offset int64) (*Volume, error) {
+	mdb := make([]byte, sectorSize)
+	if _, err := r.ReadAt(mdb, offset+mdbOffset); err != nil {
+		return nil, err
+	}
+
+	be := binary.BigEndian
+
+	switch string(mdb[:2]) {
+	case "BD":
+	case "H+", "HX":
+		return nil, &volumeError{errors.New("HFS+ volumes aren't supported")}
+	default:
+		return nil, fmt.Errorf("no HFS volume at %d", offset)
+	}
+
+	// an HFS wrapper around an HFS+ volume only has a read me in it
+	if string(mdb[0x7C:0x7E]) == "H+" {
+		return nil, &volumeError{errors.New("HFS+ volumes aren't supported")}
+	}
+
+	volume := &Volume{
+		Name:       pascalString(mdb[0x24:0x40]),
+		Created:    be.Uint32(mdb[0x02:]),
+		Modified:   be.Uint32(mdb[0x06:]),
+		r:          r,
+		offset:     offset,
+		blockSize:  be.Uint32(mdb[0x14:]),
+		firstBlock: int64(be.Uint16(mdb[0x1C:])) * sectorSize,
+		extents:    make(map[extentsKey][]extent),
+		Folders:    make(map[uint32]*Folder),
+	}
+
+	if volume.blockSize == 0 || volume.blockSize%sectorSize != 0 {
+		return nil, &volumeError{fmt.Errorf("allocation block size %d isn't a multiple of %d", volume.blockSize, sectorSize)}
+	}
+
+	extentsFile := fork{Length: be.Uint32(mdb[0x82:]), Extents: readExtents(mdb[0x86:])}
+	catalogFile := fork{Length: be.Uint32(mdb[0x92:]), Extents: readExtents(mdb[0x96:])}
+
+	// the extents file never overflows, the catalog can
+	extentsTree, err := volume.readFork(extentsFile, extentsId, forkData)
+	if err != nil {
+		return nil, &volumeError{fmt.Errorf("error reading extents file: %s", err)}
+	}
+
+	err = walkLeaves(extentsTree, volume.readExtentRecord)
+	if err != nil {
+		return nil, &volumeError{fmt.Errorf("error reading extents file: %s", err)}
+	}
+
+	catalogTree, err := volume.readFork(catalogFile, catalogId, forkData)
+	if err != nil {
+		return nil, &volumeError{fmt.Errorf("error reading catalog file: %s", err)}
+	}
+
+	err = walkLeaves(catalogTree, volume.readCatalogRecord)
+	if err != nil {
+		return nil, &volumeError{fmt.Errorf("error reading catalog file: %s", err)}
+	}
+
+	for _, file := range volume.Files {
+		file.Path = volume.path(file.ParentId, file.Name)
+	}
+
+	sort.Slice(volume.Files, func(i, j int) bool {
+		return volume.Files[i].Path < volume.Files[j].Path
+	})
+
+	return volume, nil
+}
+
+// path is where a file is from the root folder, the root being the volume
+// itself isn't in it
+func (volume *Volume) path(parentId uint32, name string) string {
+	parts := []string{sanitize(name)}
+
+	// a broken catalog could loop, no real volume is this deep
+	for depth := 0; parentId != rootFolderId && depth < 100; depth++ {
+		folder, ok := volume.Folders[parentId]
+		if !ok {
+			break
+		}
+
+		parts = append([]string{sanitize(folder.Name)}, parts...)
+		parentId = folder.ParentId
+	}
+
+	return path.Join(parts...)
+}
+
+// sanitize keeps a Mac name, which can have a slash in it, as one part of a
+// path
+func sanitize(name string) string {
+	out := []rune(name)
+	for i, c := range out {
+		if c == '/' {
+			out[i] = ':'
+		}
+	}
+
+	if string(out) == "" || string(out) == "." || string(out) == ".." {
+		return "_" + string(out)
+	}
+
+	return string(out)
+}
+
+// ReadData reads the data fork of a file
+func (volume *Volume) ReadData(file *File) ([]byte, error) {
+	return volume.readFork(file.data, file.Id, forkData)
+}
+
+// ReadResource reads the resource fork of a file
+func (volume *Volume) ReadResource(file *File) ([]byte, error) {
+	return volume.readFork(file.resource, file.Id, forkResource)
+}
+
+// readFork reads the allocation blocks of a fork in order, the first three
+// extents are in the catalog and any more are in the extents file
+func (volume *Volume) readFork(f fork, fileId uint32, forkType byte) ([]byte, error) {
+	extents := append([]extent{}, f.Extents...)
+	extents = append(extents, volume.extents[extentsKey{forkType, fileId}]...)
+
+	out := make([]byte, 0)
+	for _, e := range extents {
+		if uint32(len(out)) >= f.Length {
+			break
+		}
+
+		if e.Count == 0 {
+			continue
+		}
+
+		length := int64(e.Count) * int64(volume.blockSize)
+		if remaining := int64(f.Length) - int64(len(out)); length > remaining {
+			length = remaining
+		}
+
+		chunk := make([]byte, length)
+		offset := volume.offset + volume.firstBlock + int64(e.Start)*int64(volume.blockSize)
+		if _, err := volume.r.ReadAt(chunk, offset); err != nil {
+			return nil, fmt.Errorf("error reading blocks %d to %d: %s", e.Start, int(e.Start)+int(e.Count), err)
+		}
+
+		out = append(out, chunk...)
+	}
+
+	if uint32(len(out)) < f.Length {
+		return nil, fmt.Errorf("fork has %d of its %d bytes", len(out), f.Length)
+	}
+
+	return out, nil
+}
+
+func readExtents(b []byte) []extent {
+	extents := make([]extent, 0, 3)
+	for i := 0; i < 3; i++ {
+		extents = append(extents, extent{
+			Start: binary.BigEndian.Uint16(b[i*4:]),
+			Count: binary.BigEndian.Uint16(b[i*4+2:]),
+		})
+	}
+
+	return extents
+}
+
+// walkLeaves calls record with the key and data of every leaf record, the
+// leaves are linked in key order from the first one the header names
+func walkLeaves(tree []byte, record func(key []byte, data []byte) error) error {
+	if len(tree) < sectorSize {
+		return errors.New("b-tree has no header node")
+	}
+
+	be := binary.BigEndian
+
+	// the header record follows the node descriptor
+	header := tree[14:]
+	firstLeaf := be.Uint32(header[10:])
+	nodeSize := int(be.Uint16(header[18:]))
+	if nodeSize < sectorSize {
+		nodeSize = sectorSize
+	}
+
+	visited := make(map[uint32]bool)
+	for node := firstLeaf; node != 0; {
+		if visited[node] {
+			return errors.New("b-tree leaves loop")
+		}
+		visited[node] = true
+
+		start := int(node) * nodeSize
+		if start+nodeSize > len(tree) {
+			return fmt.Errorf("node %d is past the end of the b-tree", node)
+		}
+		b := tree[start : start+nodeSize]
+
+		if b[8] != nodeLeaf {
+			return fmt.Errorf("node %d is not a leaf", node)
+		}
+
+		count := int(be.Uint16(b[10:]))
+		for i := 0; i < count; i++ {
+			// the record offsets are at the end of the node, backwards
+			at := nodeSize - 2*(i+1)
+			if at < 14 {
+				return fmt.Errorf("node %d has too many records", node)
+			}
+
+			offset := int(be.Uint16(b[at:]))
+			if offset < 14 || offset >= nodeSize {
+				return fmt.Errorf("record %d of node %d is outside it", i, node)
+			}
+
+			keyLength := int(b[offset])
+			dataOffset := offset + 1 + keyLength
+			if dataOffset%2 == 1 {
+				dataOffset++
+			}
+			if dataOffset > nodeSize {
+				return fmt.Errorf("record %d of node %d is outside it", i, node)
+			}
+
+			if err := record(b[offset+1:offset+1+keyLength], b[dataOffset:]); err != nil {
+				return err
+			}
+		}
+
+		node = be.Uint32(b[0:])
+	}
+
+	return nil
+}
+
+func (volume *Volume) readExtentRecord(key []byte, data []byte) error {
+	if len(key) < 7 || len(data) < 12 {
+		return errors.New("extents record is too short")
+	}
+
+	k := extentsKey{Fork: key[0], FileId: binary.BigEndian.Uint32(key[1:])}
+	volume.extents[k] = append(volume.extents[k], readExtents(data)...)
+
+	return nil
+}
+
+func (volume *Volume) readCatalogRecord(key []byte, data []byte) error {
+	if len(key) < 6 || len(data) < 2 {
+		return errors.New("catalog record is too short")
+	}
+
+	be := binary.BigEndian
+
+	parentId := be.Uint32(key[1:])
+	name := pascalString(key[5:])
+
+	switch data[0] {
+	case recordFolder:
+		if len(data) < 70 {
+			return errors.New("folder record is too short")
+		}
+
+		id := be.Uint32(data[6:])
+		volume.Folders[id] = &Folder{Id: id, ParentId: parentId, Name: name}
+
+	case recordFile:
+		if len(data) < 102 {
+			return errors.New("file record is too short")
+		}
+
+		file := &File{
+			Id:             be.Uint32(data[20:]),
+			ParentId:       parentId,
+			Name:           name,
+			Type:           string(data[4:8]),
+			Creator:        string(data[8:12]),
+			Flags:          be.Uint16(data[12:]),
+			DataLength:     be.Uint32(data[26:]),
+			ResourceLength: be.Uint32(data[36:]),
+			Created:        be.Uint32(data[44:]),
+			Modified:       be.Uint32(data[48:]),
+		}
+
+		file.data = fork{Length: file.DataLength, Extents: readExtents(data[74:])}
+		file.resource = fork{Length: file.ResourceLength, Extents: readExtents(data[86:])}
+
+		volume.Files = append(volume.Files, file)
+	}
+
+	return nil
+}
+
+func pascalString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+
+	length := int(b[0])
+	if length > len(b)-1 {
+		length = len(b) - 1
+	}
+
+	return macroman.ConvertMacRomanToUTF8(string(b[1 : 1+length]))
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+
+	return string(b)
+}
@@ -0,0 +1,144 @@
+package libmacbinary
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/markhughes/dirry/internal/macroman"
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+const headerLength = 128
+
+// the header CRC covers everything before it
+const crcOffset = 124
+
+type MacBinary struct {
+	// Version is 1, 2 or 3 for MacBinary I, II and III
+	Version int
+
+	Name    string
+	Type    string
+	Creator string
+
+	// Finder flags, the high byte is kept from MacBinary I and the low byte
+	// was added in II
+	Flags uint16
+
+	// seconds since 1904
+	Created  uint32
+	Modified uint32
+
+	DataFork     []byte
+	ResourceFork []byte
+}
+
+func UnpackMacBinaryFromFile(path string) (*MacBinary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return UnpackMacBinary(data)
+}
+
+// IsMacBinary is whether the header makes sense as MacBinary, there's no
+// magic number to go by before MacBinary III
+func IsMacBinary(data []byte) bool {
+	if len(data) < headerLength || data[0] != 0 || data[74] != 0 || data[82] != 0 {
+		return false
+	}
+
+	nameLength := int(data[1])
+	if nameLength < 1 || nameLength > 63 {
+		return false
+	}
+
+	dataLength := int(binary.BigEndian.Uint32(data[83:87]))
+	resourceLength := int(binary.BigEndian.Uint32(data[87:91]))
+
+	return headerLength+padded(dataLength)+resourceLength <= len(data)
+}
+
+// HasHeaderCRC is whether the header has the CRC MacBinary II and III put
+// after it, which is all that tells them from a file starting with a zero
+func HasHeaderCRC(data []byte) bool {
+	return len(data) >= headerLength && binary.BigEndian.Uint16(data[crcOffset:]) == utils.CRC16(data[:crcOffset])
+}
+
+// headerVersion works out which MacBinary wrote the header. II and III
+// have a CRC of it, I left everything after the dates empty.
+func headerVersion(header []byte) (int, error) {
+	be := binary.BigEndian
+
+	if HasHeaderCRC(header) {
+		if string(header[102:106]) == "mBIN" {
+			return 3, nil
+		}
+		return 2, nil
+	}
+
+	for _, b := range header[99:126] {
+		if b != 0 {
+			return 0, fmt.Errorf("header CRC is %04x, the file says %04x", utils.CRC16(header[:crcOffset]), be.Uint16(header[crcOffset:]))
+		}
+	}
+
+	return 1, nil
+}
+
+// UnpackMacBinary splits a MacBinary file into its forks, the header CRC
+// has to match for MacBinary II and III
+func UnpackMacBinary(data []byte) (*MacBinary, error) {
+	if !IsMacBinary(data) {
+		return nil, errors.New("not a MacBinary file")
+	}
+
+	version, err := headerVersion(data[:headerLength])
+	if err != nil {
+		return nil, err
+	}
+
+	be := binary.BigEndian
+
+	mb := &MacBinary{
+		Version:  version,
+		Name:     macroman.ConvertMacRomanToUTF8(string(data[2 : 2+int(data[1])])),
+		Type:     string(data[65:69]),
+		Creator:  string(data[69:73]),
+		Flags:    uint16(data[73]) << 8,
+		Created:  be.Uint32(data[91:95]),
+		Modified: be.Uint32(data[95:99]),
+	}
+
+	dataLength := int(be.Uint32(data[83:87]))
+	resourceLength := int(be.Uint32(data[87:91]))
+
+	start := headerLength
+	if version > 1 {
+		mb.Flags |= uint16(data[101])
+
+		// with a secondary header before the data fork
+		start += padded(int(be.Uint16(data[120:122])))
+	}
+
+	if start+dataLength > len(data) {
+		return nil, errors.New("data fork extends beyond end of file")
+	}
+	mb.DataFork = data[start : start+dataLength]
+
+	start += padded(dataLength)
+	if start+resourceLength > len(data) {
+		return nil, errors.New("resource fork extends beyond end of file")
+	}
+	mb.ResourceFork = data[start : start+resourceLength]
+
+	return mb, nil
+}
+
+// each part is padded out to the next 128 bytes
+func padded(length int) int {
+	return (length + headerLength - 1) / headerLength * headerLength
+}
@@ -41,12 +41,33 @@ func FromFile(path string) (*ResourceFork, error) {
 	return FromBytes(data)
 }
 
+// IsResourceFork is whether the header of data points to a resource data
+// and map that fit in it
+func IsResourceFork(data []byte) bool {
+	if len(data) < 16 {
+		return false
+	}
+
+	dataOffset := uint64(binary.BigEndian.Uint32(data[0:]))
+	mapOffset := uint64(binary.BigEndian.Uint32(data[4:]))
+	dataLength := uint64(binary.BigEndian.Uint32(data[8:]))
+	mapLength := uint64(binary.BigEndian.Uint32(data[12:]))
+
+	return dataOffset >= 16 && mapLength >= 30 &&
+		dataOffset+dataLength <= uint64(len(data)) &&
+		mapOffset+mapLength <= uint64(len(data))
+}
+
 func FromBytes(data []byte) (*ResourceFork, error) {
 	rf := &ResourceFork{}
 	if len(data) == 0 {
 		return rf, nil
 	}
 
+	if !IsResourceFork(data) {
+		return nil, fmt.Errorf("not a resource fork")
+	}
+
 	r := bytes.NewReader(data)
 	var dataOffset, mapOffset, dataLength, mapLength uint32
 
@@ -83,6 +104,10 @@ func FromBytes(data []byte) (*ResourceFork, error) {
 
 	utils.DebugMsg("mrf", "\n")
 
+	if int(typelistOffsetInMap) > len(mapSection) || int(namelistOffsetInMap) > len(mapSection) {
+		return nil, fmt.Errorf("resource map lists are outside the map")
+	}
+
 	uTypes := bytes.NewReader(mapSection[typelistOffsetInMap:])
 	uNames := bytes.NewReader(mapSection[namelistOffsetInMap:])
 
@@ -139,8 +164,15 @@ func FromBytes(data []byte) (*ResourceFork, error) {
 			utils.DebugMsg("mrf", "resource.PackedAttr: %d\n", resource.PackedAttr)
 			utils.DebugMsg("mrf", "resource.Junk: %d\n", resource.Junk)
 
+			if int(resource.DataOffset) > len(dataSection) {
+				return nil, fmt.Errorf("%s %d is outside the resource data", resource.Type, resource.Id)
+			}
+
 			dataR := bytes.NewReader(dataSection[resource.DataOffset:])
 			binary.Read(dataR, binary.BigEndian, &resource.DataSize)
+			if int64(resource.DataSize) > int64(dataR.Len()) {
+				return nil, fmt.Errorf("%s %d runs past the resource data", resource.Type, resource.Id)
+			}
 			resourceData := make([]byte, resource.DataSize)
 			dataR.Read(resourceData)
 			resource.Data = resourceData
@@ -0,0 +1,543 @@
+package lingo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// node is an expression, dot picks between dot syntax (D7+) and the
+// older verbose syntax where they differ
+type node interface {
+	lingo(dot bool) string
+}
+
+type literalNode struct {
+	text string
+}
+
+func (n *literalNode) lingo(dot bool) string {
+	return n.text
+}
+
+// isZero is how the bytecode marks a missing chunk range or cast lib
+func isZero(n node) bool {
+	literal, ok := n.(*literalNode)
+	return ok && literal.text == "0"
+}
+
+type varNode struct {
+	name string
+}
+
+func (n *varNode) lingo(dot bool) string {
+	return n.name
+}
+
+type errorNode struct {
+	text string
+}
+
+func (n *errorNode) lingo(dot bool) string {
+	return "ERROR"
+}
+
+var binaryOperators = map[Opcode]struct {
+	symbol     string
+	precedence int
+}{
+	OpOr:           {"or", 1},
+	OpAnd:          {"and", 2},
+	OpLt:           {"<", 3},
+	OpLtEq:         {"<=", 3},
+	OpNtEq:         {"<>", 3},
+	OpEq:           {"=", 3},
+	OpGt:           {">", 3},
+	OpGtEq:         {">=", 3},
+	OpContainsStr:  {"contains", 3},
+	OpContains0Str: {"starts", 3},
+	OpJoinStr:      {"&", 4},
+	OpJoinPadStr:   {"&&", 4},
+	OpAdd:          {"+", 5},
+	OpSub:          {"-", 5},
+	OpMul:          {"*", 6},
+	OpDiv:          {"/", 6},
+	OpMod:          {"mod", 6},
+}
+
+type binaryNode struct {
+	op          Opcode
+	left, right node
+}
+
+func (n *binaryNode) precedence() int {
+	return binaryOperators[n.op].precedence
+}
+
+func (n *binaryNode) lingo(dot bool) string {
+	left := n.left.lingo(dot)
+	if child, ok := n.left.(*binaryNode); ok && child.precedence() < n.precedence() {
+		left = "(" + left + ")"
+	}
+
+	right := n.right.lingo(dot)
+	if child, ok := n.right.(*binaryNode); ok && child.precedence() <= n.precedence() {
+		right = "(" + right + ")"
+	}
+
+	return left + " " + binaryOperators[n.op].symbol + " " + right
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+func (n *unaryNode) lingo(dot bool) string {
+	operand := n.operand.lingo(dot)
+	if _, ok := n.operand.(*binaryNode); ok {
+		operand = "(" + operand + ")"
+	}
+
+	return n.op + operand
+}
+
+type argListNode struct {
+	args  []node
+	noRet bool
+}
+
+func (n *argListNode) lingo(dot bool) string {
+	return joinNodes(n.args, dot)
+}
+
+func joinNodes(nodes []node, dot bool) string {
+	out := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		out = append(out, n.lingo(dot))
+	}
+
+	return strings.Join(out, ", ")
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n *listNode) lingo(dot bool) string {
+	return "[" + joinNodes(n.items, dot) + "]"
+}
+
+type propListNode struct {
+	items []node
+}
+
+func (n *propListNode) lingo(dot bool) string {
+	if len(n.items) == 0 {
+		return "[:]"
+	}
+
+	pairs := make([]string, 0, len(n.items)/2)
+	for i := 0; i+1 < len(n.items); i += 2 {
+		pairs = append(pairs, n.items[i].lingo(dot)+": "+n.items[i+1].lingo(dot))
+	}
+
+	return "[" + strings.Join(pairs, ", ") + "]"
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+func (n *callNode) lingo(dot bool) string {
+	return n.name + "(" + joinNodes(n.args, dot) + ")"
+}
+
+// statement is how a call is written when the result isn't used
+func (n *callNode) statement(dot bool) string {
+	if dot {
+		return n.lingo(dot)
+	}
+
+	if len(n.args) == 0 {
+		return n.name
+	}
+
+	return n.name + " " + joinNodes(n.args, dot)
+}
+
+type objCallNode struct {
+	method string
+	obj    node
+	args   []node
+}
+
+func (n *objCallNode) lingo(dot bool) string {
+	if !dot {
+		return n.method + "(" + joinNodes(append([]node{n.obj}, n.args...), dot) + ")"
+	}
+
+	if n.method == "getAt" && len(n.args) == 1 {
+		return n.obj.lingo(dot) + "[" + n.args[0].lingo(dot) + "]"
+	}
+
+	return n.obj.lingo(dot) + "." + n.method + "(" + joinNodes(n.args, dot) + ")"
+}
+
+func (n *objCallNode) statement(dot bool) string {
+	if dot && n.method == "setAt" && len(n.args) == 2 {
+		return n.obj.lingo(dot) + "[" + n.args[0].lingo(dot) + "] = " + n.args[1].lingo(dot)
+	}
+
+	if dot {
+		return n.lingo(dot)
+	}
+
+	return n.method + " " + joinNodes(append([]node{n.obj}, n.args...), dot)
+}
+
+type newObjNode struct {
+	objType string
+	args    []node
+}
+
+func (n *newObjNode) lingo(dot bool) string {
+	return "new " + n.objType + "(" + joinNodes(n.args, dot) + ")"
+}
+
+// theNode is a movie level property, e.g. `the mouseH`
+type theNode struct {
+	prop string
+}
+
+func (n *theNode) lingo(dot bool) string {
+	return "the " + n.prop
+}
+
+// propNode is a property of something, `the prop of obj` or `obj.prop`
+type propNode struct {
+	obj  node
+	prop string
+
+	// some properties only have a verbose form
+	verbose bool
+}
+
+func (n *propNode) lingo(dot bool) string {
+	if dot && !n.verbose && !strings.Contains(n.prop, " ") {
+		obj := n.obj.lingo(dot)
+		if _, ok := n.obj.(*binaryNode); ok {
+			obj = "(" + obj + ")"
+		}
+
+		return obj + "." + n.prop
+	}
+
+	return "the " + n.prop + " of " + n.obj.lingo(dot)
+}
+
+// memberNode is a member, field, script or sprite reference
+type memberNode struct {
+	kind    string
+	id      node
+	castLib node
+}
+
+func (n *memberNode) lingo(dot bool) string {
+	hasLib := n.castLib != nil && !isZero(n.castLib)
+
+	if dot {
+		if hasLib {
+			return n.kind + "(" + n.id.lingo(dot) + ", " + n.castLib.lingo(dot) + ")"
+		}
+
+		return n.kind + "(" + n.id.lingo(dot) + ")"
+	}
+
+	id := n.id.lingo(dot)
+	if _, ok := n.id.(*binaryNode); ok {
+		id = "(" + id + ")"
+	}
+
+	if hasLib {
+		return n.kind + " " + id + " of castLib " + n.castLib.lingo(dot)
+	}
+
+	return n.kind + " " + id
+}
+
+var chunkTypeNames = map[int]string{
+	1: "char",
+	2: "word",
+	3: "item",
+	4: "line",
+}
+
+type chunkNode struct {
+	chunkType   int
+	first, last node
+	str         node
+}
+
+func (n *chunkNode) lingo(dot bool) string {
+	out := chunkTypeNames[n.chunkType] + " " + n.first.lingo(dot)
+	if n.last != nil && !isZero(n.last) {
+		out += " to " + n.last.lingo(dot)
+	}
+
+	return out + " of " + n.str.lingo(dot)
+}
+
+// chunkCountNode is `the number of chars in x`
+type chunkCountNode struct {
+	chunkType int
+	str       node
+}
+
+func (n *chunkCountNode) lingo(dot bool) string {
+	return "the number of " + chunkTypeNames[n.chunkType] + "s in " + n.str.lingo(dot)
+}
+
+// lastChunkNode is `the last word of x`
+type lastChunkNode struct {
+	chunkType int
+	str       node
+}
+
+func (n *lastChunkNode) lingo(dot bool) string {
+	return "the last " + chunkTypeNames[n.chunkType] + " of " + n.str.lingo(dot)
+}
+
+type spriteTestNode struct {
+	first, second node
+	within        bool
+}
+
+func (n *spriteTestNode) lingo(dot bool) string {
+	if n.within {
+		return "sprite " + n.first.lingo(dot) + " within " + n.second.lingo(dot)
+	}
+
+	return "sprite " + n.first.lingo(dot) + " intersects " + n.second.lingo(dot)
+}
+
+// statements
+
+type stmt interface{}
+
+type exprStmt struct {
+	expr node
+}
+
+type assignStmt struct {
+	target node
+	value  node
+}
+
+type putStmt struct {
+	putType int
+	target  node
+	value   node
+}
+
+type chunkStmt struct {
+	verb  string
+	chunk node
+}
+
+type returnStmt struct {
+	value node
+}
+
+type exitStmt struct{}
+
+type exitRepeatStmt struct{}
+
+type nextRepeatStmt struct{}
+
+type commentStmt struct {
+	text string
+}
+
+type ifStmt struct {
+	cond node
+	then []stmt
+	els  []stmt
+}
+
+type repeatWhileStmt struct {
+	cond node
+	body []stmt
+}
+
+type repeatWithToStmt struct {
+	variable   string
+	start, end node
+	down       bool
+	body       []stmt
+}
+
+type repeatWithInStmt struct {
+	variable string
+	list     node
+	body     []stmt
+}
+
+type caseClause struct {
+	values []node
+	body   []stmt
+}
+
+type caseStmt struct {
+	subject      node
+	clauses      []caseClause
+	otherwise    []stmt
+	hasOtherwise bool
+}
+
+type tellStmt struct {
+	window node
+	body   []stmt
+}
+
+var putTypeNames = map[int]string{
+	1: "into",
+	2: "after",
+	3: "before",
+}
+
+type writer struct {
+	sb  strings.Builder
+	dot bool
+}
+
+func (w *writer) line(indent int, format string, args ...interface{}) {
+	w.sb.WriteString(strings.Repeat("  ", indent))
+	fmt.Fprintf(&w.sb, format, args...)
+	w.sb.WriteString("\n")
+}
+
+func (w *writer) block(indent int, stmts []stmt) {
+	for _, s := range stmts {
+		w.stmt(indent, s)
+	}
+}
+
+func (w *writer) stmt(indent int, s stmt) {
+	dot := w.dot
+
+	switch s := s.(type) {
+	case *exprStmt:
+		switch expr := s.expr.(type) {
+		case *callNode:
+			w.line(indent, "%s", expr.statement(dot))
+		case *objCallNode:
+			w.line(indent, "%s", expr.statement(dot))
+		default:
+			w.line(indent, "%s", expr.lingo(dot))
+		}
+
+	case *assignStmt:
+		switch s.target.(type) {
+		case *varNode:
+			w.line(indent, "%s = %s", s.target.lingo(dot), s.value.lingo(dot))
+		default:
+			if dot {
+				w.line(indent, "%s = %s", s.target.lingo(dot), s.value.lingo(dot))
+			} else {
+				w.line(indent, "set %s to %s", s.target.lingo(dot), s.value.lingo(dot))
+			}
+		}
+
+	case *putStmt:
+		w.line(indent, "put %s %s %s", s.value.lingo(dot), putTypeNames[s.putType], s.target.lingo(dot))
+
+	case *chunkStmt:
+		w.line(indent, "%s %s", s.verb, s.chunk.lingo(dot))
+
+	case *returnStmt:
+		if s.value == nil {
+			w.line(indent, "return")
+		} else {
+			w.line(indent, "return %s", s.value.lingo(dot))
+		}
+
+	case *exitStmt:
+		w.line(indent, "exit")
+
+	case *exitRepeatStmt:
+		w.line(indent, "exit repeat")
+
+	case *nextRepeatStmt:
+		w.line(indent, "next repeat")
+
+	case *commentStmt:
+		w.line(indent, "-- %s", s.text)
+
+	case *ifStmt:
+		w.ifStmt(indent, s, false)
+
+	case *repeatWhileStmt:
+		w.line(indent, "repeat while %s", s.cond.lingo(dot))
+		w.block(indent+1, s.body)
+		w.line(indent, "end repeat")
+
+	case *repeatWithToStmt:
+		direction := "to"
+		if s.down {
+			direction = "down to"
+		}
+
+		w.line(indent, "repeat with %s = %s %s %s", s.variable, s.start.lingo(dot), direction, s.end.lingo(dot))
+		w.block(indent+1, s.body)
+		w.line(indent, "end repeat")
+
+	case *repeatWithInStmt:
+		w.line(indent, "repeat with %s in %s", s.variable, s.list.lingo(dot))
+		w.block(indent+1, s.body)
+		w.line(indent, "end repeat")
+
+	case *caseStmt:
+		w.line(indent, "case %s of", s.subject.lingo(dot))
+		for _, clause := range s.clauses {
+			w.line(indent+1, "%s:", joinNodes(clause.values, dot))
+			w.block(indent+2, clause.body)
+		}
+
+		if s.hasOtherwise {
+			w.line(indent+1, "otherwise:")
+			w.block(indent+2, s.otherwise)
+		}
+		w.line(indent, "end case")
+
+	case *tellStmt:
+		w.line(indent, "tell %s", s.window.lingo(dot))
+		w.block(indent+1, s.body)
+		w.line(indent, "end tell")
+	}
+}
+
+func (w *writer) ifStmt(indent int, s *ifStmt, elseIf bool) {
+	if elseIf {
+		w.line(indent, "else if %s then", s.cond.lingo(w.dot))
+	} else {
+		w.line(indent, "if %s then", s.cond.lingo(w.dot))
+	}
+	w.block(indent+1, s.then)
+
+	if len(s.els) == 1 {
+		if nested, ok := s.els[0].(*ifStmt); ok {
+			w.ifStmt(indent, nested, true)
+			return
+		}
+	}
+
+	if len(s.els) > 0 {
+		w.line(indent, "else")
+		w.block(indent+1, s.els)
+	}
+
+	if !elseIf {
+		w.line(indent, "end if")
+	}
+}
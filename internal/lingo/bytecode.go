@@ -0,0 +1,88 @@
+package lingo
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+type Instruction struct {
+	// Offset is the position of the instruction in the handler bytecode
+	Offset int
+	Size   int
+
+	Op      uint8
+	Opcode  Opcode
+	Operand int32
+}
+
+// DecodeBytecode splits a handler's compiled code into instructions. The
+// operand size is in the top two bits of the op byte: 0x40 is one byte,
+// 0x80 two and 0xc0 four.
+func DecodeBytecode(code []byte) ([]Instruction, error) {
+	instructions := make([]Instruction, 0)
+
+	for pos := 0; pos < len(code); {
+		instruction := Instruction{
+			Offset: pos,
+			Op:     code[pos],
+			Opcode: Opcode(code[pos]),
+		}
+
+		if instruction.Op >= 0x40 {
+			instruction.Opcode = Opcode(0x40 + instruction.Op%0x40)
+		}
+
+		var operandSize int
+		switch {
+		case instruction.Op >= 0xc0:
+			operandSize = 4
+		case instruction.Op >= 0x80:
+			operandSize = 2
+		case instruction.Op >= 0x40:
+			operandSize = 1
+		}
+
+		if pos+1+operandSize > len(code) {
+			return instructions, fmt.Errorf("truncated %s at %d", instruction.Opcode, pos)
+		}
+
+		operand := code[pos+1 : pos+1+operandSize]
+		switch operandSize {
+		case 4:
+			instruction.Operand = int32(binary.BigEndian.Uint32(operand))
+		case 2:
+			// the pushes are signed, everything else is an index
+			if instruction.Opcode == OpPushInt16 || instruction.Opcode == OpPushInt8 {
+				instruction.Operand = int32(int16(binary.BigEndian.Uint16(operand)))
+			} else {
+				instruction.Operand = int32(binary.BigEndian.Uint16(operand))
+			}
+		case 1:
+			if instruction.Opcode == OpPushInt8 {
+				instruction.Operand = int32(int8(operand[0]))
+			} else {
+				instruction.Operand = int32(operand[0])
+			}
+		}
+
+		instruction.Size = 1 + operandSize
+		instructions = append(instructions, instruction)
+
+		pos += instruction.Size
+	}
+
+	return instructions, nil
+}
+
+// JumpTarget is where a jmp, jmpifz or endrepeat goes to
+func (instruction Instruction) JumpTarget() int {
+	if instruction.Opcode == OpEndRepeat {
+		return instruction.Offset - int(instruction.Operand)
+	}
+
+	return instruction.Offset + int(instruction.Operand)
+}
+
+func (instruction Instruction) HasOperand() bool {
+	return instruction.Op >= 0x40
+}
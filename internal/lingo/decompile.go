@@ -0,0 +1,1019 @@
+package lingo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/markhughes/dirry/internal/chunks"
+)
+
+var moviePropertyNames = map[int32]string{
+	0x00: "floatPrecision",
+	0x01: "mouseDownScript",
+	0x02: "mouseUpScript",
+	0x03: "keyDownScript",
+	0x04: "keyUpScript",
+	0x05: "timeoutScript",
+	0x06: "short time",
+	0x07: "abbr time",
+	0x08: "long time",
+	0x09: "short date",
+	0x0a: "abbr date",
+	0x0b: "long date",
+}
+
+var menuPropertyNames = map[int32]string{
+	0x01: "name",
+	0x02: "number of menuItems",
+}
+
+var menuItemPropertyNames = map[int32]string{
+	0x01: "name",
+	0x02: "checkMark",
+	0x03: "enabled",
+	0x04: "script",
+}
+
+var soundPropertyNames = map[int32]string{
+	0x01: "volume",
+}
+
+var spritePropertyNames = map[int32]string{
+	0x01: "type",
+	0x02: "backColor",
+	0x03: "bottom",
+	0x04: "castNum",
+	0x05: "constraint",
+	0x06: "cursor",
+	0x07: "foreColor",
+	0x08: "height",
+	0x09: "immediate",
+	0x0a: "ink",
+	0x0b: "left",
+	0x0c: "lineSize",
+	0x0d: "locH",
+	0x0e: "locV",
+	0x0f: "movieRate",
+	0x10: "movieTime",
+	0x11: "pattern",
+	0x12: "puppet",
+	0x13: "right",
+	0x14: "startTime",
+	0x15: "stopTime",
+	0x16: "stretch",
+	0x17: "top",
+	0x18: "trails",
+	0x19: "visible",
+	0x1a: "volume",
+	0x1b: "width",
+	0x1c: "blend",
+	0x1d: "scriptNum",
+	0x1e: "moveableSprite",
+	0x1f: "editableText",
+	0x20: "scoreColor",
+	0x21: "loc",
+	0x22: "rect",
+	0x23: "memberNum",
+	0x24: "castLibNum",
+	0x25: "member",
+	0x26: "scriptInstanceList",
+	0x27: "currentTime",
+	0x28: "mostRecentCuePoint",
+	0x29: "tweened",
+	0x2a: "name",
+}
+
+var animationPropertyNames = map[int32]string{
+	0x01: "beepOn",
+	0x02: "buttonStyle",
+	0x03: "centerStage",
+	0x04: "checkBoxAccess",
+	0x05: "checkboxType",
+	0x06: "colorDepth",
+	0x07: "colorQD",
+	0x08: "exitLock",
+	0x09: "fixStageSize",
+	0x0a: "fullColorPermit",
+	0x0b: "imageDirect",
+	0x0c: "doubleClick",
+	0x0d: "key",
+	0x0e: "lastClick",
+	0x0f: "lastEvent",
+	0x10: "keyCode",
+	0x11: "lastKey",
+	0x12: "lastRoll",
+	0x13: "timeoutLapsed",
+	0x14: "multiSound",
+	0x15: "pauseState",
+	0x16: "quickTimePresent",
+	0x17: "selEnd",
+	0x18: "selStart",
+	0x19: "soundEnabled",
+	0x1a: "soundLevel",
+	0x1b: "stageColor",
+	0x1d: "switchColorDepth",
+	0x1e: "timeoutKeyDown",
+	0x1f: "timeoutLength",
+	0x20: "timeoutMouse",
+	0x21: "timeoutPlay",
+	0x22: "timer",
+	0x23: "preLoadRAM",
+	0x24: "videoForWindowsPresent",
+	0x25: "netPresent",
+	0x26: "safePlayer",
+	0x27: "soundKeepDevice",
+	0x28: "soundMixMedia",
+}
+
+var animation2PropertyNames = map[int32]string{
+	0x01: "perFrameHook",
+	0x02: "number of castMembers",
+	0x03: "number of menus",
+	0x04: "number of castLibs",
+	0x05: "number of xtras",
+}
+
+var memberPropertyNames = map[int32]string{
+	0x01: "name",
+	0x02: "text",
+	0x03: "textStyle",
+	0x04: "textFont",
+	0x05: "textHeight",
+	0x06: "textAlign",
+	0x07: "textSize",
+	0x08: "picture",
+	0x09: "hilite",
+	0x0a: "number",
+	0x0b: "size",
+	0x0c: "loop",
+	0x0d: "duration",
+	0x0e: "controller",
+	0x0f: "directToStage",
+	0x10: "sound",
+	0x11: "foreColor",
+	0x12: "backColor",
+	0x13: "type",
+}
+
+func propertyName(names map[int32]string, id int32) string {
+	if name, ok := names[id]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("UNKNOWN_PROP_%d", id)
+}
+
+// loop is the repeat a handler is currently inside, jumps to next or end
+// are `next repeat` and `exit repeat`
+type loop struct {
+	next, end int
+}
+
+type decompiler struct {
+	script       *Script
+	handler      *chunks.LscrHandler
+	instructions []Instruction
+
+	// index maps a bytecode offset to the instruction there
+	index map[int]int
+
+	stack []node
+	loops []loop
+
+	// versions before D5 have no cast libraries on member references
+	castLibs bool
+}
+
+func (d *decompiler) push(n node) {
+	d.stack = append(d.stack, n)
+}
+
+func (d *decompiler) pop() node {
+	if len(d.stack) == 0 {
+		return &errorNode{text: "stack underflow"}
+	}
+
+	n := d.stack[len(d.stack)-1]
+	d.stack = d.stack[:len(d.stack)-1]
+
+	return n
+}
+
+func (d *decompiler) popArgs() *argListNode {
+	if args, ok := d.pop().(*argListNode); ok {
+		return args
+	}
+
+	return &argListNode{}
+}
+
+// target is the instruction index a jump lands on, jumps past the end of
+// the handler go to len(instructions)
+func (d *decompiler) target(instruction Instruction) int {
+	if i, ok := d.index[instruction.JumpTarget()]; ok {
+		return i
+	}
+
+	return len(d.instructions)
+}
+
+func (d *decompiler) at(i int, opcode Opcode) bool {
+	return i >= 0 && i < len(d.instructions) && d.instructions[i].Opcode == opcode
+}
+
+func (d *decompiler) name(instruction Instruction) string {
+	return d.script.Name(int(instruction.Operand))
+}
+
+func (d *decompiler) variable(instruction Instruction) string {
+	switch instruction.Opcode {
+	case OpGetParam, OpSetParam:
+		return d.script.ArgumentName(d.handler, instruction.Operand)
+	case OpGetLocal, OpSetLocal:
+		return d.script.LocalName(d.handler, instruction.Operand)
+	}
+
+	return d.name(instruction)
+}
+
+// readVar pops the reference used by put and the chunk ops
+func (d *decompiler) readVar(varType int32) node {
+	var castLib node
+	if varType == 6 && d.castLibs {
+		castLib = d.pop()
+	}
+
+	id := d.pop()
+
+	switch varType {
+	case 1, 2, 3:
+		// globals and properties are pushed as a symbol of their name
+		if symbol, ok := id.(*literalNode); ok && strings.HasPrefix(symbol.text, "#") {
+			return &varNode{name: symbol.text[1:]}
+		}
+
+		return id
+	case 4:
+		return d.indexedName(id, d.handler.ArgumentNameIDs)
+	case 5:
+		return d.indexedName(id, d.handler.LocalNameIDs)
+	case 6:
+		return &memberNode{kind: "field", id: id, castLib: castLib}
+	}
+
+	return &errorNode{text: fmt.Sprintf("unknown var type %d", varType)}
+}
+
+func (d *decompiler) indexedName(id node, names []int16) node {
+	literal, ok := id.(*literalNode)
+	if !ok {
+		return id
+	}
+
+	value, err := strconv.Atoi(literal.text)
+	if err != nil {
+		return id
+	}
+
+	index := value / int(d.script.Lscr.VariableMultiplier())
+	if index >= 0 && index < len(names) {
+		return &varNode{name: d.script.Name(int(names[index]))}
+	}
+
+	return id
+}
+
+// readChunkRef pops a chunk range, any part that is zero isn't used
+func (d *decompiler) readChunkRef(str node) node {
+	lastLine, firstLine := d.pop(), d.pop()
+	lastItem, firstItem := d.pop(), d.pop()
+	lastWord, firstWord := d.pop(), d.pop()
+	lastChar, firstChar := d.pop(), d.pop()
+
+	if !isZero(firstLine) {
+		str = &chunkNode{chunkType: 4, first: firstLine, last: lastLine, str: str}
+	}
+
+	if !isZero(firstItem) {
+		str = &chunkNode{chunkType: 3, first: firstItem, last: lastItem, str: str}
+	}
+
+	if !isZero(firstWord) {
+		str = &chunkNode{chunkType: 2, first: firstWord, last: lastWord, str: str}
+	}
+
+	if !isZero(firstChar) {
+		str = &chunkNode{chunkType: 1, first: firstChar, last: lastChar, str: str}
+	}
+
+	return str
+}
+
+// property reads the target of the old get and set ops, the operand says
+// what kind of thing the property belongs to
+func (d *decompiler) property(propertyType int32, id int32) node {
+	switch propertyType {
+	case 0x00:
+		if id <= 0x0b {
+			return &theNode{prop: propertyName(moviePropertyNames, id)}
+		}
+
+		return &lastChunkNode{chunkType: int(id - 0x0b), str: d.pop()}
+
+	case 0x01:
+		return &chunkCountNode{chunkType: int(id), str: d.pop()}
+
+	case 0x02:
+		menu := d.pop()
+		return &propNode{obj: &memberNode{kind: "menu", id: menu}, prop: propertyName(menuPropertyNames, id), verbose: true}
+
+	case 0x03:
+		menu := d.pop()
+		item := d.pop()
+		return &propNode{
+			obj:     &literalNode{text: "menuItem " + item.lingo(false) + " of menu " + menu.lingo(false)},
+			prop:    propertyName(menuItemPropertyNames, id),
+			verbose: true,
+		}
+
+	case 0x04:
+		return &propNode{obj: &memberNode{kind: "sound", id: d.pop()}, prop: propertyName(soundPropertyNames, id)}
+
+	case 0x06:
+		return &propNode{obj: &memberNode{kind: "sprite", id: d.pop()}, prop: propertyName(spritePropertyNames, id)}
+
+	case 0x07:
+		return &theNode{prop: propertyName(animationPropertyNames, id)}
+
+	case 0x08:
+		if id == 0x02 && d.castLibs {
+			castLib := d.pop()
+			if !isZero(castLib) {
+				return &propNode{obj: &memberNode{kind: "castLib", id: castLib}, prop: propertyName(animation2PropertyNames, id), verbose: true}
+			}
+		}
+
+		return &theNode{prop: propertyName(animation2PropertyNames, id)}
+	}
+
+	if propertyType >= 0x09 && propertyType <= 0x15 {
+		var castLib node
+		if d.castLibs {
+			castLib = d.pop()
+		}
+		memberId := d.pop()
+
+		kind := "cast"
+		switch {
+		case propertyType == 0x0b || propertyType == 0x0c:
+			kind = "field"
+		case propertyType == 0x14 || propertyType == 0x15:
+			kind = "script"
+		case d.castLibs:
+			kind = "member"
+		}
+
+		var entity node = &memberNode{kind: kind, id: memberId, castLib: castLib}
+		if propertyType == 0x0a || propertyType == 0x0c || propertyType == 0x15 {
+			entity = d.readChunkRef(entity)
+		}
+
+		return &propNode{obj: entity, prop: propertyName(memberPropertyNames, id)}
+	}
+
+	return &errorNode{text: fmt.Sprintf("unknown property type %d", propertyType)}
+}
+
+// expression handles the ops that only move values on the stack
+func (d *decompiler) expression(instruction Instruction) bool {
+	switch instruction.Opcode {
+	case OpPushZero:
+		d.push(&literalNode{text: "0"})
+
+	case OpPushInt8, OpPushInt16, OpPushInt32:
+		d.push(&literalNode{text: strconv.Itoa(int(instruction.Operand))})
+
+	case OpPushFloat32:
+		d.push(&literalNode{text: formatFloat(float64(math.Float32frombits(uint32(instruction.Operand))))})
+
+	case OpPushCons:
+		d.push(&literalNode{text: FormatLiteral(d.script.Literal(instruction.Operand))})
+
+	case OpPushSymb:
+		d.push(&literalNode{text: "#" + d.name(instruction)})
+
+	case OpPushVarRef, OpGetTopLevelProp:
+		d.push(&varNode{name: d.name(instruction)})
+
+	case OpPushChunkVarRef:
+		d.push(d.readVar(instruction.Operand))
+
+	case OpMul, OpAdd, OpSub, OpDiv, OpMod, OpJoinStr, OpJoinPadStr, OpLt, OpLtEq, OpNtEq, OpEq,
+		OpGt, OpGtEq, OpAnd, OpOr, OpContainsStr, OpContains0Str:
+		right := d.pop()
+		left := d.pop()
+		d.push(&binaryNode{op: instruction.Opcode, left: left, right: right})
+
+	case OpInv:
+		d.push(&unaryNode{op: "-", operand: d.pop()})
+
+	case OpNot:
+		d.push(&unaryNode{op: "not ", operand: d.pop()})
+
+	case OpPushArgList, OpPushArgListNoRet:
+		count := int(instruction.Operand)
+		if count > len(d.stack) {
+			count = len(d.stack)
+		}
+
+		args := append([]node{}, d.stack[len(d.stack)-count:]...)
+		d.stack = d.stack[:len(d.stack)-count]
+		d.push(&argListNode{args: args, noRet: instruction.Opcode == OpPushArgListNoRet})
+
+	case OpPushList:
+		d.push(&listNode{items: d.popArgs().args})
+
+	case OpPushPropList:
+		d.push(&propListNode{items: d.popArgs().args})
+
+	case OpGetGlobal, OpGetGlobal2, OpGetProp, OpGetParam, OpGetLocal:
+		d.push(&varNode{name: d.variable(instruction)})
+
+	case OpGetMovieProp:
+		d.push(&theNode{prop: d.name(instruction)})
+
+	case OpGetObjProp, OpGetChainedProp:
+		d.push(&propNode{obj: d.pop(), prop: d.name(instruction)})
+
+	case OpTheBuiltin:
+		d.pop()
+		d.push(&theNode{prop: d.name(instruction)})
+
+	case OpNewObj:
+		d.push(&newObjNode{objType: d.name(instruction), args: d.popArgs().args})
+
+	case OpGet:
+		id := d.pop()
+		value, _ := strconv.Atoi(id.lingo(false))
+		d.push(d.property(instruction.Operand, int32(value)))
+
+	case OpGetChunk:
+		d.push(d.readChunkRef(d.pop()))
+
+	case OpGetField:
+		var castLib node
+		if d.castLibs {
+			castLib = d.pop()
+		}
+		d.push(&memberNode{kind: "field", id: d.pop(), castLib: castLib})
+
+	case OpOntoSpr, OpIntoSpr:
+		second := d.pop()
+		first := d.pop()
+		d.push(&spriteTestNode{first: first, second: second, within: instruction.Opcode == OpIntoSpr})
+
+	case OpSwap:
+		if len(d.stack) >= 2 {
+			n := len(d.stack)
+			d.stack[n-1], d.stack[n-2] = d.stack[n-2], d.stack[n-1]
+		}
+
+	default:
+		return false
+	}
+
+	return true
+}
+
+// call turns a call op into a statement when the result is thrown away,
+// otherwise the call stays on the stack
+func (d *decompiler) call(instruction Instruction) stmt {
+	args := d.popArgs()
+
+	var call node
+	switch instruction.Opcode {
+	case OpExtCall:
+		name := d.name(instruction)
+		if name == "return" && args.noRet {
+			if len(args.args) == 0 {
+				return &returnStmt{}
+			}
+
+			return &returnStmt{value: args.args[0]}
+		}
+
+		call = &callNode{name: name, args: args.args}
+
+	case OpLocalCall:
+		call = &callNode{name: d.script.HandlerName(int(instruction.Operand)), args: args.args}
+
+	case OpTellCall:
+		call = &callNode{name: d.name(instruction), args: args.args}
+
+	case OpObjCall:
+		if len(args.args) == 0 {
+			call = &callNode{name: d.name(instruction)}
+		} else {
+			call = &objCallNode{method: d.name(instruction), obj: args.args[0], args: args.args[1:]}
+		}
+
+	case OpObjCallV4:
+		obj := d.readVar(instruction.Operand)
+		call = &callNode{name: obj.lingo(false), args: args.args}
+	}
+
+	if args.noRet {
+		return &exprStmt{expr: call}
+	}
+
+	d.push(call)
+
+	return nil
+}
+
+// block decompiles the instructions from start up to end
+func (d *decompiler) block(start int, end int) []stmt {
+	stmts := make([]stmt, 0)
+
+	for i := start; i < end; {
+		instruction := d.instructions[i]
+
+		if d.expression(instruction) {
+			i++
+			continue
+		}
+
+		switch instruction.Opcode {
+		case OpSetGlobal, OpSetGlobal2, OpSetProp, OpSetParam, OpSetLocal:
+			stmts = append(stmts, &assignStmt{target: &varNode{name: d.variable(instruction)}, value: d.pop()})
+
+		case OpSetMovieProp:
+			stmts = append(stmts, &assignStmt{target: &theNode{prop: d.name(instruction)}, value: d.pop()})
+
+		case OpSetObjProp:
+			value := d.pop()
+			obj := d.pop()
+			stmts = append(stmts, &assignStmt{target: &propNode{obj: obj, prop: d.name(instruction)}, value: value})
+
+		case OpSet:
+			id := d.pop()
+			value := d.pop()
+			propertyId, _ := strconv.Atoi(id.lingo(false))
+			stmts = append(stmts, &assignStmt{target: d.property(instruction.Operand, int32(propertyId)), value: value})
+
+		case OpPut:
+			target := d.readVar(instruction.Operand & 0xf)
+			stmts = append(stmts, &putStmt{putType: int(instruction.Operand>>4) & 0xf, target: target, value: d.pop()})
+
+		case OpPutChunk:
+			target := d.readChunkRef(d.readVar(instruction.Operand & 0xf))
+			stmts = append(stmts, &putStmt{putType: int(instruction.Operand>>4) & 0xf, target: target, value: d.pop()})
+
+		case OpDeleteChunk:
+			stmts = append(stmts, &chunkStmt{verb: "delete", chunk: d.readChunkRef(d.readVar(instruction.Operand))})
+
+		case OpHiliteChunk:
+			var castLib node
+			if d.castLibs {
+				castLib = d.pop()
+			}
+			field := &memberNode{kind: "field", id: d.pop(), castLib: castLib}
+			stmts = append(stmts, &chunkStmt{verb: "hilite", chunk: d.readChunkRef(field)})
+
+		case OpExtCall, OpLocalCall, OpTellCall, OpObjCall, OpObjCallV4:
+			if s := d.call(instruction); s != nil {
+				stmts = append(stmts, s)
+			}
+
+		case OpPop:
+			for n := 0; n < int(instruction.Operand); n++ {
+				d.pop()
+			}
+
+		case OpRet, OpRetFactory:
+			// the last ret is the end of the handler
+			if i != len(d.instructions)-1 {
+				stmts = append(stmts, &exitStmt{})
+			}
+
+		case OpJmpIfZ:
+			s, next := d.jmpIfZ(i, &stmts)
+			stmts = append(stmts, s)
+			i = next
+			continue
+
+		case OpPeek:
+			if s, next, ok := d.repeatWithIn(i); ok {
+				stmts = append(stmts, s)
+				i = next
+				continue
+			}
+
+			if s, next, ok := d.caseStatement(i); ok {
+				stmts = append(stmts, s)
+				i = next
+				continue
+			}
+
+			stmts = append(stmts, &commentStmt{text: fmt.Sprintf("unhandled peek at %d", instruction.Offset)})
+
+		case OpJmp:
+			stmts = append(stmts, d.jmp(instruction))
+
+		case OpStartTell:
+			window := d.pop()
+			endTell := d.endTell(i)
+			stmts = append(stmts, &tellStmt{window: window, body: d.block(i+1, endTell)})
+			i = endTell + 1
+			continue
+
+		default:
+			stmts = append(stmts, &commentStmt{text: fmt.Sprintf("unhandled %s at %d", instruction.Opcode, instruction.Offset)})
+		}
+
+		i++
+	}
+
+	return stmts
+}
+
+func (d *decompiler) jmp(instruction Instruction) stmt {
+	if len(d.loops) > 0 {
+		current := d.loops[len(d.loops)-1]
+		switch d.target(instruction) {
+		case current.end:
+			return &exitRepeatStmt{}
+		case current.next:
+			return &nextRepeatStmt{}
+		}
+	}
+
+	return &commentStmt{text: fmt.Sprintf("jmp to %d", instruction.JumpTarget())}
+}
+
+// isLoopJump is a jmp that leaves or restarts the loop we are in
+func (d *decompiler) isLoopJump(instruction Instruction) bool {
+	if len(d.loops) == 0 {
+		return false
+	}
+
+	current := d.loops[len(d.loops)-1]
+	target := d.target(instruction)
+
+	return target == current.end || target == current.next
+}
+
+func (d *decompiler) loopBody(l loop, start int, end int) []stmt {
+	d.loops = append(d.loops, l)
+	body := d.block(start, end)
+	d.loops = d.loops[:len(d.loops)-1]
+
+	return body
+}
+
+// jmpIfZ is an if, or a loop when the block ends by jumping back above it
+func (d *decompiler) jmpIfZ(i int, stmts *[]stmt) (stmt, int) {
+	instruction := d.instructions[i]
+	target := d.target(instruction)
+	cond := d.pop()
+
+	// a jmpifz only ever jumps forward past its block, one to itself or
+	// above it would have block go round forever
+	if target <= i {
+		return &commentStmt{text: fmt.Sprintf("jmpifz %s to %d", cond.lingo(false), instruction.JumpTarget())}, i + 1
+	}
+
+	if target-1 > i && d.at(target-1, OpEndRepeat) {
+		loopStart := d.target(d.instructions[target-1])
+		if loopStart <= i {
+			if s, ok := d.repeatWithTo(i, target, cond, stmts); ok {
+				return s, target
+			}
+
+			body := d.loopBody(loop{next: loopStart, end: target}, i+1, target-1)
+			return &repeatWhileStmt{cond: cond, body: body}, target
+		}
+	}
+
+	if target-1 > i && d.at(target-1, OpJmp) && !d.isLoopJump(d.instructions[target-1]) {
+		end := d.target(d.instructions[target-1])
+		if end > target {
+			then := d.block(i+1, target-1)
+			els := d.block(target, end)
+			return &ifStmt{cond: cond, then: then, els: els}, end
+		}
+	}
+
+	return &ifStmt{cond: cond, then: d.block(i+1, target)}, target
+}
+
+// repeatWithTo spots the counter a `repeat with i = a to b` adds to at the
+// bottom of the loop, the start value is the assignment just before it
+func (d *decompiler) repeatWithTo(i int, target int, cond node, stmts *[]stmt) (stmt, bool) {
+	step := target - 5
+	if step <= i || len(*stmts) == 0 {
+		return nil, false
+	}
+
+	stepBy := d.instructions[step]
+	get := d.instructions[step+1]
+	set := d.instructions[step+3]
+	if stepBy.Opcode != OpPushInt8 || (stepBy.Operand != 1 && stepBy.Operand != -1) ||
+		!d.at(step+2, OpAdd) || set.Opcode != get.Opcode+(OpSetGlobal2-OpGetGlobal2) || set.Operand != get.Operand {
+		return nil, false
+	}
+
+	variable := d.variable(get)
+
+	compare, ok := cond.(*binaryNode)
+	down := stepBy.Operand == -1
+	if !ok || (!down && compare.op != OpLtEq) || (down && compare.op != OpGtEq) {
+		return nil, false
+	}
+
+	if counter, ok := compare.left.(*varNode); !ok || counter.name != variable {
+		return nil, false
+	}
+
+	assign, ok := (*stmts)[len(*stmts)-1].(*assignStmt)
+	if !ok {
+		return nil, false
+	}
+
+	if counter, ok := assign.target.(*varNode); !ok || counter.name != variable {
+		return nil, false
+	}
+	*stmts = (*stmts)[:len(*stmts)-1]
+
+	body := d.loopBody(loop{next: step, end: target}, i+1, step)
+
+	return &repeatWithToStmt{
+		variable: variable,
+		start:    assign.value,
+		end:      compare.right,
+		down:     down,
+		body:     body,
+	}, true
+}
+
+// repeatWithIn matches the fixed sequence `repeat with x in list` compiles
+// to, the list, its count and the index are kept on the stack
+func (d *decompiler) repeatWithIn(i int) (stmt, int, bool) {
+	pattern := []Opcode{OpPeek, OpPushArgList, OpExtCall, OpPushInt8, OpPeek, OpPeek, OpLtEq, OpJmpIfZ,
+		OpPeek, OpPeek, OpPushArgList, OpExtCall}
+	for n, opcode := range pattern {
+		if !d.at(i+n, opcode) {
+			return nil, 0, false
+		}
+	}
+
+	if d.name(d.instructions[i+2]) != "count" || d.name(d.instructions[i+11]) != "getAt" {
+		return nil, 0, false
+	}
+
+	set := d.instructions[i+12]
+	switch set.Opcode {
+	case OpSetGlobal, OpSetGlobal2, OpSetProp, OpSetParam, OpSetLocal:
+	default:
+		return nil, 0, false
+	}
+
+	end := d.target(d.instructions[i+7])
+	if !d.at(end-1, OpEndRepeat) || !d.at(end-2, OpAdd) || !d.at(end-3, OpPushInt8) || !d.at(end, OpPop) {
+		return nil, 0, false
+	}
+
+	list := d.pop()
+	body := d.loopBody(loop{next: end - 3, end: end}, i+13, end-3)
+
+	return &repeatWithInStmt{variable: d.variable(set), list: list, body: body}, end + 1, true
+}
+
+// caseStatement reads a case, each label is a `peek 0` of the subject
+// compared against a value. Labels sharing a body use <> and jump into
+// it, every body jumps to the `pop 1` that drops the subject at the end.
+func (d *decompiler) caseStatement(i int) (stmt, int, bool) {
+	if d.instructions[i].Operand != 0 || len(d.stack) == 0 {
+		return nil, 0, false
+	}
+
+	subject := d.pop()
+	s := &caseStmt{subject: subject}
+	end := -1
+
+	j := i
+	for d.at(j, OpPeek) && d.instructions[j].Operand == 0 {
+		values := make([]node, 0)
+
+		for {
+			value, compare, ok := d.caseValue(j + 1)
+			if !ok || !d.at(compare+1, OpJmpIfZ) {
+				d.push(subject)
+				return nil, 0, false
+			}
+			values = append(values, value)
+			j = compare + 2
+
+			if d.instructions[compare].Opcode == OpEq {
+				break
+			}
+		}
+
+		next := d.target(d.instructions[j-1])
+		bodyEnd := next
+		if d.at(next-1, OpJmp) && next-1 >= j {
+			if target := d.target(d.instructions[next-1]); target >= next && !d.isLoopJump(d.instructions[next-1]) {
+				end = target
+				bodyEnd = next - 1
+			}
+		}
+
+		s.clauses = append(s.clauses, caseClause{values: values, body: d.block(j, bodyEnd)})
+		j = next
+	}
+
+	if end < 0 {
+		end = j
+	}
+
+	if j < end {
+		s.hasOtherwise = true
+		s.otherwise = d.block(j, end)
+	}
+
+	if !d.at(end, OpPop) {
+		return s, end, true
+	}
+
+	return s, end + 1, true
+}
+
+// caseValue runs the instructions of a case label until its value is the
+// only thing on the stack and the next op compares it
+func (d *decompiler) caseValue(start int) (node, int, bool) {
+	saved := d.stack
+	d.stack = nil
+	defer func() { d.stack = saved }()
+
+	for j := start; j < len(d.instructions); j++ {
+		opcode := d.instructions[j].Opcode
+		if len(d.stack) == 1 && (opcode == OpEq || opcode == OpNtEq) {
+			return d.stack[0], j, true
+		}
+
+		if !d.expression(d.instructions[j]) {
+			if opcode != OpExtCall && opcode != OpLocalCall && opcode != OpObjCall {
+				return nil, 0, false
+			}
+
+			if s := d.call(d.instructions[j]); s != nil {
+				return nil, 0, false
+			}
+		}
+	}
+
+	return nil, 0, false
+}
+
+func (d *decompiler) endTell(i int) int {
+	depth := 0
+	for j := i + 1; j < len(d.instructions); j++ {
+		switch d.instructions[j].Opcode {
+		case OpStartTell:
+			depth++
+		case OpEndTell:
+			if depth == 0 {
+				return j
+			}
+			depth--
+		}
+	}
+
+	return len(d.instructions)
+}
+
+func (script *Script) decompileHandler(handler *chunks.LscrHandler, castLibs bool) ([]stmt, error) {
+	instructions, err := DecodeBytecode(handler.Bytecode)
+
+	d := &decompiler{
+		script:       script,
+		handler:      handler,
+		instructions: instructions,
+		index:        make(map[int]int, len(instructions)),
+		castLibs:     castLibs,
+	}
+
+	for i, instruction := range instructions {
+		d.index[instruction.Offset] = i
+	}
+
+	return d.block(0, len(instructions)), err
+}
+
+// Decompile rebuilds the Lingo source of a script. dotSyntax writes
+// properties and method calls the Director 7 way, castLibs is set from
+// Director 5 where member references carry a cast library.
+func Decompile(script *Script, dotSyntax bool, castLibs bool) string {
+	w := &writer{dot: dotSyntax}
+	lscr := script.Lscr
+
+	switch script.Type {
+	case ScriptTypeParent:
+		w.line(0, "-- parent script")
+	case ScriptTypeScore:
+		w.line(0, "-- behavior")
+	}
+
+	if properties := script.properties(); len(properties) > 0 {
+		w.line(0, "property %s", strings.Join(properties, ", "))
+	}
+
+	if len(lscr.GlobalNameIDs) > 0 {
+		w.line(0, "global %s", strings.Join(script.names(lscr.GlobalNameIDs), ", "))
+	}
+
+	for n, handler := range lscr.Handlers {
+		if n > 0 || w.sb.Len() > 0 {
+			w.sb.WriteString("\n")
+		}
+
+		name := script.Name(int(handler.NameID))
+		args := script.names(handler.ArgumentNameIDs)
+
+		// objects are always called with themselves first, a name table
+		// without it still has to call it me
+		if script.isObject() && len(args) > 0 && strings.HasPrefix(args[0], "UNKNOWN_NAME_") {
+			args[0] = "me"
+		}
+
+		if len(args) > 0 {
+			w.line(0, "on %s %s", name, strings.Join(args, ", "))
+		} else {
+			w.line(0, "on %s", name)
+		}
+
+		if len(handler.GlobalNameIDs) > 0 {
+			w.line(1, "global %s", strings.Join(script.names(handler.GlobalNameIDs), ", "))
+		}
+
+		body, err := script.decompileHandler(handler, castLibs)
+		w.block(1, body)
+
+		// new() gives back whatever the new handler returns, without
+		// return me the caller gets VOID instead of the instance
+		if script.Type == ScriptTypeParent && strings.EqualFold(name, "new") && !returnsValue(body) {
+			w.line(1, "-- new does not return me, new() gives VOID")
+		}
+
+		if err != nil {
+			w.line(1, "-- error: %s", err)
+		}
+
+		w.line(0, "end")
+	}
+
+	return w.sb.String()
+}
+
+// properties are the ones the script declares, followed by any its handlers
+// use that it doesn't, so the source compiles back to the same script
+func (script *Script) properties() []string {
+	properties := script.names(script.Lscr.PropertyNameIDs)
+	if !script.isObject() {
+		return properties
+	}
+
+	declared := make(map[string]bool, len(properties))
+	for _, property := range properties {
+		declared[strings.ToLower(property)] = true
+	}
+
+	for _, handler := range script.Lscr.Handlers {
+		instructions, _ := DecodeBytecode(handler.Bytecode)
+		for _, instruction := range instructions {
+			if instruction.Opcode != OpGetProp && instruction.Opcode != OpSetProp {
+				continue
+			}
+
+			property := script.Name(int(instruction.Operand))
+			if !declared[strings.ToLower(property)] {
+				declared[strings.ToLower(property)] = true
+				properties = append(properties, property)
+			}
+		}
+	}
+
+	return properties
+}
+
+// returnsValue is set when a handler ends by returning something
+func returnsValue(body []stmt) bool {
+	if len(body) == 0 {
+		return false
+	}
+
+	ret, ok := body[len(body)-1].(*returnStmt)
+	return ok && ret.value != nil
+}
@@ -0,0 +1,51 @@
+package lingo
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markhughes/dirry/internal/chunks"
+)
+
+func TestDecompileBackwardJmpIfZ(t *testing.T) {
+	tests := []struct {
+		name     string
+		bytecode []byte
+		comment  string
+	}{
+		// pushzero, jmpifz 0, ret
+		{"self", []byte{0x03, 0x55, 0x00, 0x01}, "-- jmpifz 0 to 1"},
+		// pushzero, jmpifz -1, ret
+		{"backward", []byte{0x03, 0xd5, 0xff, 0xff, 0xff, 0xff, 0x01}, "-- jmpifz 0 to 0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			script := &Script{
+				Lscr: &chunks.LscrChunk{
+					Handlers: []*chunks.LscrHandler{{NameID: 0, Bytecode: test.bytecode}},
+				},
+				Names: []string{"loop"},
+			}
+
+			done := make(chan string, 1)
+			go func() {
+				done <- Decompile(script, false, true)
+			}()
+
+			select {
+			case source := <-done:
+				if !strings.Contains(source, test.comment) {
+					t.Errorf("source does not have %q:\n%s", test.comment, source)
+				}
+
+				if !strings.HasSuffix(source, "end\n") {
+					t.Errorf("handler does not end:\n%s", source)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("decompiling did not finish")
+			}
+		})
+	}
+}
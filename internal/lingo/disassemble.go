@@ -0,0 +1,245 @@
+package lingo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/markhughes/dirry/internal/chunks"
+)
+
+// ScriptType is the kind of script member the Lscr was compiled from
+type ScriptType uint8
+
+const (
+	ScriptTypeUnknown ScriptType = 0
+	ScriptTypeScore   ScriptType = 1 // behaviors, called score scripts before Director 6
+	ScriptTypeMovie   ScriptType = 3
+	ScriptTypeParent  ScriptType = 7
+)
+
+// Script is a compiled Lscr with the Lnam names it refers to.
+type Script struct {
+	Lscr  *chunks.LscrChunk
+	Names []string
+
+	// Type is set from the script member, parent scripts and behaviors are
+	// objects and are decompiled as one
+	Type ScriptType
+}
+
+// isObject is set for scripts whose handlers are called with me
+func (script *Script) isObject() bool {
+	return script.Type == ScriptTypeParent || script.Type == ScriptTypeScore
+}
+
+func NewScript(lscr *chunks.LscrChunk, lnam *chunks.LnamChunk) *Script {
+	script := &Script{Lscr: lscr}
+	if lnam != nil {
+		script.Names = lnam.Names
+	}
+
+	return script
+}
+
+// Name looks up a name id, unknown ids keep their number so nothing is lost
+func (script *Script) Name(id int) string {
+	if id >= 0 && id < len(script.Names) {
+		return script.Names[id]
+	}
+
+	return fmt.Sprintf("UNKNOWN_NAME_%d", id)
+}
+
+func (script *Script) names(ids []int16) []string {
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, script.Name(int(id)))
+	}
+
+	return out
+}
+
+func (script *Script) HandlerName(index int) string {
+	if index >= 0 && index < len(script.Lscr.Handlers) {
+		return script.Name(int(script.Lscr.Handlers[index].NameID))
+	}
+
+	return fmt.Sprintf("UNKNOWN_HANDLER_%d", index)
+}
+
+func (script *Script) ArgumentName(handler *chunks.LscrHandler, operand int32) string {
+	index := int(operand / script.Lscr.VariableMultiplier())
+	if index >= 0 && index < len(handler.ArgumentNameIDs) {
+		return script.Name(int(handler.ArgumentNameIDs[index]))
+	}
+
+	return fmt.Sprintf("UNKNOWN_ARG_%d", index)
+}
+
+func (script *Script) LocalName(handler *chunks.LscrHandler, operand int32) string {
+	index := int(operand / script.Lscr.VariableMultiplier())
+	if index >= 0 && index < len(handler.LocalNameIDs) {
+		return script.Name(int(handler.LocalNameIDs[index]))
+	}
+
+	return fmt.Sprintf("UNKNOWN_LOCAL_%d", index)
+}
+
+func (script *Script) Literal(operand int32) *chunks.LscrLiteral {
+	index := int(operand / script.Lscr.VariableMultiplier())
+	if index >= 0 && index < len(script.Lscr.Literals) {
+		return script.Lscr.Literals[index]
+	}
+
+	return nil
+}
+
+// FormatLiteral writes a literal the way it would appear in Lingo source
+func FormatLiteral(literal *chunks.LscrLiteral) string {
+	if literal == nil {
+		return "VOID"
+	}
+
+	switch value := literal.Value.(type) {
+	case string:
+		return quoteString(value)
+	case int32:
+		return strconv.Itoa(int(value))
+	case float64:
+		return formatFloat(value)
+	}
+
+	return "VOID"
+}
+
+func quoteString(value string) string {
+	if value == "" {
+		return "EMPTY"
+	}
+
+	// Lingo strings have no escapes, anything special is joined in
+	replacer := strings.NewReplacer(
+		"\"", "\" & QUOTE & \"",
+		"\r", "\" & RETURN & \"",
+		"\n", "\" & RETURN & \"",
+		"\t", "\" & TAB & \"",
+	)
+
+	quoted := "\"" + replacer.Replace(value) + "\""
+	quoted = strings.TrimPrefix(quoted, "\"\" & ")
+	quoted = strings.TrimSuffix(quoted, " & \"\"")
+
+	return quoted
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return fmt.Sprint(value)
+	}
+
+	out := strconv.FormatFloat(value, 'f', -1, 64)
+	if !strings.Contains(out, ".") {
+		out += ".0"
+	}
+
+	return out
+}
+
+// describe explains what an operand refers to, used as the comment in
+// the disassembly
+func (script *Script) describe(handler *chunks.LscrHandler, instruction Instruction) string {
+	switch instruction.Opcode {
+	case OpPushCons:
+		return FormatLiteral(script.Literal(instruction.Operand))
+
+	case OpGetParam, OpSetParam:
+		return script.ArgumentName(handler, instruction.Operand)
+
+	case OpGetLocal, OpSetLocal:
+		return script.LocalName(handler, instruction.Operand)
+
+	case OpLocalCall:
+		return script.HandlerName(int(instruction.Operand))
+
+	case OpPushSymb, OpPushVarRef, OpGetGlobal, OpGetGlobal2, OpSetGlobal, OpSetGlobal2,
+		OpGetProp, OpSetProp, OpGetMovieProp, OpSetMovieProp, OpGetObjProp, OpSetObjProp,
+		OpGetChainedProp, OpGetTopLevelProp, OpExtCall, OpObjCall, OpObjCallV4, OpTellCall,
+		OpTheBuiltin, OpNewObj, OpPushChunkVarRef:
+		return script.Name(int(instruction.Operand))
+
+	case OpJmp, OpJmpIfZ, OpEndRepeat:
+		return fmt.Sprintf("-> [%d]", instruction.JumpTarget())
+
+	case OpPushFloat32:
+		return formatFloat(float64(math.Float32frombits(uint32(instruction.Operand))))
+	}
+
+	return ""
+}
+
+// Disassemble lists every handler of the script with its bytecode, names
+// are resolved through the Lnam
+func Disassemble(script *Script) string {
+	var sb strings.Builder
+	lscr := script.Lscr
+
+	fmt.Fprintf(&sb, "-- Lscr script %d, member %d\n", lscr.ScriptNumber, lscr.CastID&0xffff)
+
+	if len(lscr.PropertyNameIDs) > 0 {
+		fmt.Fprintf(&sb, "-- properties: %s\n", strings.Join(script.names(lscr.PropertyNameIDs), ", "))
+	}
+
+	if len(lscr.GlobalNameIDs) > 0 {
+		fmt.Fprintf(&sb, "-- globals: %s\n", strings.Join(script.names(lscr.GlobalNameIDs), ", "))
+	}
+
+	if len(lscr.Literals) > 0 {
+		sb.WriteString("--\n-- literals:\n")
+		for i, literal := range lscr.Literals {
+			fmt.Fprintf(&sb, "--   %d: %s\n", i, FormatLiteral(literal))
+		}
+	}
+
+	for _, handler := range lscr.Handlers {
+		sb.WriteString("\n")
+
+		fmt.Fprintf(&sb, "on %s", script.Name(int(handler.NameID)))
+		if len(handler.ArgumentNameIDs) > 0 {
+			fmt.Fprintf(&sb, " %s", strings.Join(script.names(handler.ArgumentNameIDs), ", "))
+		}
+		sb.WriteString("\n")
+
+		if len(handler.LocalNameIDs) > 0 {
+			fmt.Fprintf(&sb, "  -- locals: %s\n", strings.Join(script.names(handler.LocalNameIDs), ", "))
+		}
+
+		if len(handler.GlobalNameIDs) > 0 {
+			fmt.Fprintf(&sb, "  -- globals: %s\n", strings.Join(script.names(handler.GlobalNameIDs), ", "))
+		}
+
+		instructions, err := DecodeBytecode(handler.Bytecode)
+
+		for _, instruction := range instructions {
+			line := instruction.Opcode.String()
+			if instruction.HasOperand() {
+				line += " " + strconv.Itoa(int(instruction.Operand))
+			}
+
+			if comment := script.describe(handler, instruction); comment != "" {
+				fmt.Fprintf(&sb, "  [%4d] %-24s -- %s\n", instruction.Offset, line, comment)
+			} else {
+				fmt.Fprintf(&sb, "  [%4d] %s\n", instruction.Offset, line)
+			}
+		}
+
+		if err != nil {
+			fmt.Fprintf(&sb, "  -- error: %s\n", err)
+		}
+
+		sb.WriteString("end\n")
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,177 @@
+package lingo
+
+import "fmt"
+
+type Opcode uint8
+
+// Single byte opcodes
+const (
+	OpRet            Opcode = 0x01
+	OpRetFactory     Opcode = 0x02
+	OpPushZero       Opcode = 0x03
+	OpMul            Opcode = 0x04
+	OpAdd            Opcode = 0x05
+	OpSub            Opcode = 0x06
+	OpDiv            Opcode = 0x07
+	OpMod            Opcode = 0x08
+	OpInv            Opcode = 0x09
+	OpJoinStr        Opcode = 0x0a
+	OpJoinPadStr     Opcode = 0x0b
+	OpLt             Opcode = 0x0c
+	OpLtEq           Opcode = 0x0d
+	OpNtEq           Opcode = 0x0e
+	OpEq             Opcode = 0x0f
+	OpGt             Opcode = 0x10
+	OpGtEq           Opcode = 0x11
+	OpAnd            Opcode = 0x12
+	OpOr             Opcode = 0x13
+	OpNot            Opcode = 0x14
+	OpContainsStr    Opcode = 0x15
+	OpContains0Str   Opcode = 0x16
+	OpGetChunk       Opcode = 0x17
+	OpHiliteChunk    Opcode = 0x18
+	OpOntoSpr        Opcode = 0x19
+	OpIntoSpr        Opcode = 0x1a
+	OpGetField       Opcode = 0x1b
+	OpStartTell      Opcode = 0x1c
+	OpEndTell        Opcode = 0x1d
+	OpPushList       Opcode = 0x1e
+	OpPushPropList   Opcode = 0x1f
+	OpSwap           Opcode = 0x21
+	OpCallJavaScript Opcode = 0x26
+)
+
+// Opcodes with an operand, the top two bits of the byte in the bytecode
+// give the operand size so these are the normalised values
+const (
+	OpPushInt8         Opcode = 0x41
+	OpPushArgListNoRet Opcode = 0x42
+	OpPushArgList      Opcode = 0x43
+	OpPushCons         Opcode = 0x44
+	OpPushSymb         Opcode = 0x45
+	OpPushVarRef       Opcode = 0x46
+	OpGetGlobal2       Opcode = 0x48
+	OpGetGlobal        Opcode = 0x49
+	OpGetProp          Opcode = 0x4a
+	OpGetParam         Opcode = 0x4b
+	OpGetLocal         Opcode = 0x4c
+	OpSetGlobal2       Opcode = 0x4e
+	OpSetGlobal        Opcode = 0x4f
+	OpSetProp          Opcode = 0x50
+	OpSetParam         Opcode = 0x51
+	OpSetLocal         Opcode = 0x52
+	OpJmp              Opcode = 0x53
+	OpEndRepeat        Opcode = 0x54
+	OpJmpIfZ           Opcode = 0x55
+	OpLocalCall        Opcode = 0x56
+	OpExtCall          Opcode = 0x57
+	OpObjCallV4        Opcode = 0x58
+	OpPut              Opcode = 0x59
+	OpPutChunk         Opcode = 0x5a
+	OpDeleteChunk      Opcode = 0x5b
+	OpGet              Opcode = 0x5c
+	OpSet              Opcode = 0x5d
+	OpGetMovieProp     Opcode = 0x5f
+	OpSetMovieProp     Opcode = 0x60
+	OpGetObjProp       Opcode = 0x61
+	OpSetObjProp       Opcode = 0x62
+	OpTellCall         Opcode = 0x63
+	OpPeek             Opcode = 0x64
+	OpPop              Opcode = 0x65
+	OpTheBuiltin       Opcode = 0x66
+	OpObjCall          Opcode = 0x67
+	OpPushChunkVarRef  Opcode = 0x6d
+	OpPushInt16        Opcode = 0x6e
+	OpPushInt32        Opcode = 0x6f
+	OpGetChainedProp   Opcode = 0x70
+	OpPushFloat32      Opcode = 0x71
+	OpGetTopLevelProp  Opcode = 0x72
+	OpNewObj           Opcode = 0x73
+)
+
+var opcodeNames = map[Opcode]string{
+	OpRet:              "ret",
+	OpRetFactory:       "retfactory",
+	OpPushZero:         "pushzero",
+	OpMul:              "mul",
+	OpAdd:              "add",
+	OpSub:              "sub",
+	OpDiv:              "div",
+	OpMod:              "mod",
+	OpInv:              "inv",
+	OpJoinStr:          "joinstr",
+	OpJoinPadStr:       "joinpadstr",
+	OpLt:               "lt",
+	OpLtEq:             "lteq",
+	OpNtEq:             "nteq",
+	OpEq:               "eq",
+	OpGt:               "gt",
+	OpGtEq:             "gteq",
+	OpAnd:              "and",
+	OpOr:               "or",
+	OpNot:              "not",
+	OpContainsStr:      "containsstr",
+	OpContains0Str:     "contains0str",
+	OpGetChunk:         "getchunk",
+	OpHiliteChunk:      "hilitechunk",
+	OpOntoSpr:          "ontospr",
+	OpIntoSpr:          "intospr",
+	OpGetField:         "getfield",
+	OpStartTell:        "starttell",
+	OpEndTell:          "endtell",
+	OpPushList:         "pushlist",
+	OpPushPropList:     "pushproplist",
+	OpSwap:             "swap",
+	OpCallJavaScript:   "calljavascript",
+	OpPushInt8:         "pushint8",
+	OpPushArgListNoRet: "pusharglistnoret",
+	OpPushArgList:      "pusharglist",
+	OpPushCons:         "pushcons",
+	OpPushSymb:         "pushsymb",
+	OpPushVarRef:       "pushvarref",
+	OpGetGlobal2:       "getglobal2",
+	OpGetGlobal:        "getglobal",
+	OpGetProp:          "getprop",
+	OpGetParam:         "getparam",
+	OpGetLocal:         "getlocal",
+	OpSetGlobal2:       "setglobal2",
+	OpSetGlobal:        "setglobal",
+	OpSetProp:          "setprop",
+	OpSetParam:         "setparam",
+	OpSetLocal:         "setlocal",
+	OpJmp:              "jmp",
+	OpEndRepeat:        "endrepeat",
+	OpJmpIfZ:           "jmpifz",
+	OpLocalCall:        "localcall",
+	OpExtCall:          "extcall",
+	OpObjCallV4:        "objcallv4",
+	OpPut:              "put",
+	OpPutChunk:         "putchunk",
+	OpDeleteChunk:      "deletechunk",
+	OpGet:              "get",
+	OpSet:              "set",
+	OpGetMovieProp:     "getmovieprop",
+	OpSetMovieProp:     "setmovieprop",
+	OpGetObjProp:       "getobjprop",
+	OpSetObjProp:       "setobjprop",
+	OpTellCall:         "tellcall",
+	OpPeek:             "peek",
+	OpPop:              "pop",
+	OpTheBuiltin:       "thebuiltin",
+	OpObjCall:          "objcall",
+	OpPushChunkVarRef:  "pushchunkvarref",
+	OpPushInt16:        "pushint16",
+	OpPushInt32:        "pushint32",
+	OpGetChainedProp:   "getchainedprop",
+	OpPushFloat32:      "pushfloat32",
+	OpGetTopLevelProp:  "gettoplevelprop",
+	OpNewObj:           "newobj",
+}
+
+func (op Opcode) String() string {
+	if name, ok := opcodeNames[op]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("unk%02x", uint8(op))
+}
@@ -1,5 +1,7 @@
 package macroman
 
+import "fmt"
+
 const MacRomanStart = 128
 
 var MacRomanTable = []string{
@@ -25,3 +27,30 @@ func ConvertMacRomanToUTF8(macRomanStr string) string {
 	}
 	return result
 }
+
+// ConvertUTF8ToMacRoman is the other way round, characters Mac Roman doesn't
+// have are an error rather than being dropped
+func ConvertUTF8ToMacRoman(utf8Str string) (string, error) {
+	result := make([]byte, 0, len(utf8Str))
+	for _, char := range utf8Str {
+		if char < MacRomanStart {
+			result = append(result, byte(char))
+			continue
+		}
+
+		found := false
+		for i, mapped := range MacRomanTable {
+			if mapped != "" && mapped == string(char) {
+				result = append(result, byte(MacRomanStart+i))
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return "", fmt.Errorf("%q has no Mac Roman character", char)
+		}
+	}
+
+	return string(result), nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"fmt"
 
 	"github.com/markhughes/dirry/internal/palettes"
 	"github.com/markhughes/dirry/internal/utils"
@@ -26,6 +27,15 @@ type MemberBitmap struct {
 	CastMemberID int
 }
 
+// BitmapFlagUseAlpha is the "use alpha" setting of a 32 bit member
+const BitmapFlagUseAlpha = 0x10
+
+// UseAlpha is whether a 32 bit member draws with its alpha channel, the
+// channel is there either way but only means something with the flag
+func (m *MemberBitmap) UseAlpha() bool {
+	return m.BitsPerPixel == 32 && m.Flags&BitmapFlagUseAlpha != 0
+}
+
 func (m *MemberBitmap) ToJson() (string, error) {
 	bytes, err := json.Marshal(m)
 	if err != nil {
@@ -35,6 +45,46 @@ func (m *MemberBitmap) ToJson() (string, error) {
 	return string(bytes), nil
 }
 
+// Resize gives the member data with a new size and row length. The rects
+// keep their top left corner and the flags kept in the top bits of the row
+// length are left alone.
+func (m *MemberBitmap) Resize(b []byte, v version.Version, width, height, pitch int) ([]byte, error) {
+	// the first is the only rect from Director 6
+	rects := 2
+	var mask uint16 = 0x7fff
+	if v.IsGreaterThanOrEqualTo(version.Director_6_0_0) {
+		rects = 1
+		mask = 0x3fff
+	} else if v.IsGreaterThanOrEqualTo(version.Director_4_0_0) {
+		mask = 0x0fff
+	}
+
+	if len(b) < 2+8*rects {
+		return nil, fmt.Errorf("bitmap member data is %d bytes, too short for its rects", len(b))
+	}
+
+	if pitch > int(mask) || width > 0x7fff || height > 0x7fff {
+		return nil, fmt.Errorf("%dx%d is too big for the member", width, height)
+	}
+
+	out := make([]byte, len(b))
+	copy(out, b)
+
+	flags := binary.BigEndian.Uint16(out) &^ mask
+	binary.BigEndian.PutUint16(out, flags|uint16(pitch))
+
+	for i := 0; i < rects; i++ {
+		rect := out[2+8*i:]
+		top := int16(binary.BigEndian.Uint16(rect))
+		left := int16(binary.BigEndian.Uint16(rect[2:]))
+
+		binary.BigEndian.PutUint16(rect[4:], uint16(top+int16(height)))
+		binary.BigEndian.PutUint16(rect[6:], uint16(left+int16(width)))
+	}
+
+	return out, nil
+}
+
 func (m *MemberBitmap) FromBytes(b []byte, v version.Version, flags uint8) error {
 	var reader = bytes.NewReader(b)
 	var err error
@@ -202,6 +252,7 @@ func (m *MemberBitmap) FromBytes(b []byte, v version.Version, flags uint8) error
 		m.RegY = Y
 		m.RegX = X
 		m.BitsPerPixel = uint16(bitsPerPixel)
+		m.Pitch = m.Bytes
 
 	} else {
 
@@ -237,11 +288,25 @@ func (m *MemberBitmap) FromBytes(b []byte, v version.Version, flags uint8) error
 
 			binary.Read(reader, binary.BigEndian, &bitDepth)
 
-			binary.Read(reader, binary.BigEndian, &palette)
+			// same as D5, the library comes before the member
 			binary.Read(reader, binary.BigEndian, &castLib)
+			binary.Read(reader, binary.BigEndian, &palette)
 
+			if palette <= 0 {
+				// built in palette
+				palette = palette - 1
+				castLib = 0
+			} else if castLib == -1 {
+				// in the bitmap's own cast library
+				castLib = 0
+			}
+		} else {
+			// no depth means a 1 bit bitmap
+			bitDepth = 1
+			palette = int16(palettes.ClutSystemMac)
 		}
 		m.Bytes = stride
+		m.Pitch = stride
 		m.InitialRect = rectangle
 		m.BoundingRect = rectangle
 		m.RegY = Y
@@ -0,0 +1,77 @@
+package members
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/markhughes/dirry/internal/utils"
+	"github.com/markhughes/dirry/internal/version"
+)
+
+// the film loop flags, Director 4 moved the loop one and 5 moved it back
+const (
+	filmLoopFlagCenter   = 0x01
+	filmLoopFlagNoCrop   = 0x02
+	filmLoopFlagSound    = 0x08
+	filmLoopFlagNoLoop   = 0x20
+	filmLoopFlagNoLoopD4 = 0x40
+)
+
+// MemberFilmLoop is the settings of a film loop, the frames it plays are
+// in its own score (SCVW) keyed to the member
+type MemberFilmLoop struct {
+	InitialRect utils.Rect
+	Flags       uint32
+
+	Loop   bool
+	Crop   bool
+	Center bool
+	Sound  bool
+}
+
+func (m *MemberFilmLoop) ToJson() (string, error) {
+	bytes, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
+
+func (m *MemberFilmLoop) FromBytes(b []byte, v version.Version, flags uint8) error {
+	var err error
+	var reader = bytes.NewReader(b)
+
+	m.InitialRect, err = utils.ReadRect(reader, binary.BigEndian)
+	if err != nil {
+		return err
+	}
+
+	noLoop := uint32(filmLoopFlagNoLoop)
+
+	if v.IsLessThan(version.Director_4_0_0) {
+		var flags16 uint16
+		flags16, err = utils.ReadUInt16(reader, binary.BigEndian)
+		m.Flags = uint32(flags16)
+	} else {
+		m.Flags, err = utils.ReadUInt32(reader, binary.BigEndian)
+		if v.IsLessThan(version.Director_5_0_0) {
+			noLoop = filmLoopFlagNoLoopD4
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	m.Loop = m.Flags&noLoop == 0
+	m.Crop = m.Flags&filmLoopFlagNoCrop == 0
+	m.Center = m.Flags&filmLoopFlagCenter != 0
+	m.Sound = m.Flags&filmLoopFlagSound != 0
+
+	utils.DebugMsg("members/filmloop", "initialRect: %v\n", m.InitialRect)
+	utils.DebugMsg("members/filmloop", "flags: 0x%x\n", m.Flags)
+
+	return nil
+}
@@ -1,60 +1,236 @@
 package mrf
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"image"
+	"image/png"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/markhughes/dirry/internal/consts"
+	"github.com/markhughes/dirry/internal/libadf"
+	"github.com/markhughes/dirry/internal/libbinhex"
+	"github.com/markhughes/dirry/internal/libmacbinary"
 	"github.com/markhughes/dirry/internal/libmrf"
+	"github.com/markhughes/dirry/internal/snd"
+	"github.com/markhughes/dirry/internal/utils"
 )
 
-func Dump(filePath string) {
+// readResourceFork takes the resource fork out of an AppleDouble, MacBinary
+// or BinHex file, anything else is read as the fork itself
+func readResourceFork(filePath string) (*libmrf.ResourceFork, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if libadf.IsAppleDouble(data) {
+		adf, err := libadf.UnpackAdf(data)
+		if err != nil {
+			return nil, err
+		}
+
+		utils.InfoMsg("mrf", "AppleDouble file %s, type %s and creator %s", adf.RealName, adf.Type, adf.Creator)
+		data = adf.ResourceFork
+	} else if libmacbinary.IsMacBinary(data) {
+		mb, err := libmacbinary.UnpackMacBinary(data)
+		if err != nil {
+			return nil, err
+		}
+
+		utils.InfoMsg("mrf", "MacBinary file %s, type %s and creator %s", mb.Name, mb.Type, mb.Creator)
+		data = mb.ResourceFork
+	} else if libbinhex.IsBinHex(data) {
+		bh, err := libbinhex.UnpackBinHex(data)
+		if err != nil {
+			return nil, err
+		}
 
-	var resourceFork, err = libmrf.FromFile(filePath)
+		utils.InfoMsg("mrf", "BinHex file %s, type %s and creator %s", bh.Name, bh.Type, bh.Creator)
+		data = bh.ResourceFork
+	}
+
+	return libmrf.FromBytes(data)
+}
+
+func Dump(filePath string) error {
+	resourceFork, err := readResourceFork(filePath)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("error reading resource fork: %s", err)
 	}
 
+	return DumpFork(filePath, resourceFork)
+}
+
+// DumpFork writes out the resources of a fork that came from filePath, each
+// as it is and the ones we can read as something that opens today
+func DumpFork(filePath string, resourceFork *libmrf.ResourceFork) error {
+	outputRoot := filepath.Join(consts.PathDump, filepath.Base(filePath), "mrf")
+
 	var codeResources []libmrf.Resource
+	icons := make(map[string][]byte)
 
 	for _, resource := range resourceFork.Resources {
-		outputFolder := filepath.Join(consts.PathDump, filepath.Base(filePath), "mrf", "binary", resource.Type)
-		os.MkdirAll(outputFolder, os.ModePerm)
+		if _, ok := iconSizes[resource.Type]; ok {
+			icons[fmt.Sprintf("%s %d", resource.Type, resource.Id)] = resource.Data
+		}
+	}
 
-		// dump data into a file dump/<file name>/<resource type>/<resource name>
-		var fileName = filepath.Join(outputFolder, resource.Name)
-		var file, err = os.Create(fileName)
+	for _, resource := range resourceFork.Resources {
+		name := fileName(resource.Name)
+
+		// dump data into a file dump/<file name>/mrf/binary/<resource type>/<resource name>
+		err := writeFile(filepath.Join(outputRoot, "binary", fileName(resource.Type)), name, resource.Data)
+		if err != nil {
+			return err
+		}
+
+		if resource.Type == "CODE" {
+			codeResources = append(codeResources, resource)
+		}
+
+		err = decodeResource(outputRoot, name, resource, icons)
 		if err != nil {
-			panic(err)
+			utils.WarnMsg("mrf", "Could not read %s %d %s: %s", resource.Type, resource.Id, resource.Name, err)
 		}
-		defer file.Close()
+	}
 
-		_, err = file.Write(resource.Data)
+	if len(codeResources) > 0 {
+		codeMap, err := decodeCodeMap(codeResources)
 		if err != nil {
-			panic(err)
+			utils.WarnMsg("mrf", "Could not map the CODE segments: %s", err)
+			return nil
 		}
 
-		if resource.Type == "CODE" || resource.Type == "CSND" {
-			codeResources = append(codeResources, resource)
+		out, err := json.MarshalIndent(codeMap, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		err = writeFile(outputRoot, "code.json", out)
+		if err != nil {
+			return err
+		}
+
+		utils.InfoMsg("mrf", "%d CODE segments and %d jump table entries", len(codeMap.Segments), len(codeMap.JumpTable))
+	}
+
+	return nil
+}
+
+// decodeResource writes out a resource of a type we know as PNG, WAV, text
+// or JSON, icons is every icon by type and id for the colour ones to find
+// their mask
+func decodeResource(outputRoot string, name string, resource libmrf.Resource, icons map[string][]byte) error {
+	switch resource.Type {
+	case "TEXT":
+		return writeFile(filepath.Join(outputRoot, "text"), name+".txt", []byte(macText(resource.Data)))
+
+	case "STR ":
+		str, _, err := pascalString(resource.Data, 0)
+		if err != nil {
+			return err
 		}
 
-		if resource.Type == "TEXT" {
-			var text = string(resource.Data)
-			outputFolder := filepath.Join(consts.PathDump, filepath.Base(filePath), "mrf", "text")
-			os.MkdirAll(outputFolder, os.ModePerm)
+		return writeFile(filepath.Join(outputRoot, "text"), name+".txt", []byte(str+"\n"))
+
+	case "STR#":
+		strs, err := decodeStringList(resource.Data)
+		if err != nil {
+			return err
+		}
 
-			var fileName = filepath.Join(outputFolder, resource.Name+".txt")
-			var file, err = os.Create(fileName)
-			if err != nil {
-				panic(err)
-			}
-			defer file.Close()
+		return writeFile(filepath.Join(outputRoot, "text"), name+".txt", []byte(strings.Join(strs, "\n")+"\n"))
 
-			fmt.Fprintf(file, "%s", text)
+	case "vers":
+		version, err := decodeVersion(resource.Data)
+		if err != nil {
+			return err
+		}
 
+		out, err := json.MarshalIndent(version, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		return writeFile(filepath.Join(outputRoot, "vers"), name+".json", out)
+
+	case "snd ":
+		sound, err := snd.Decode(resource.Data)
+		if err != nil {
+			return err
 		}
 
+		return writeFile(filepath.Join(outputRoot, "snd"), name+".wav", sound.WAV())
+
+	case "PICT":
+		picture, err := decodePict(resource.Data)
+		if err != nil {
+			return err
+		}
+
+		if picture.quickTime {
+			utils.WarnMsg("mrf", "PICT %s has QuickTime compressed images that aren't drawn", resource.Name)
+		}
+		if picture.skipped > 0 {
+			utils.DebugMsg("mrf", "PICT %s skipped %d drawing opcodes, only bitmaps and rectangles are drawn", resource.Name, picture.skipped)
+		}
+
+		return writePng(filepath.Join(outputRoot, "pict"), name, picture.canvas)
+
+	case "cicn":
+		img, err := decodeColorIcon(resource.Data)
+		if err != nil {
+			return err
+		}
+
+		return writePng(filepath.Join(outputRoot, "icons", "cicn"), name, img)
+	}
+
+	if _, ok := iconSizes[resource.Type]; ok {
+		mask := icons[fmt.Sprintf("%s %d", iconMasks[resource.Type], resource.Id)]
+		if mask != nil {
+			// the mask is the second half of the 1 bit icon list
+			mask = mask[len(mask)/2:]
+		}
+
+		img, err := decodeIcon(resource.Type, resource.Data, mask)
+		if err != nil {
+			return err
+		}
+
+		return writePng(filepath.Join(outputRoot, "icons", fileName(resource.Type)), name, img)
+	}
+
+	return nil
+}
+
+func writePng(outputFolder string, name string, img image.Image) error {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(outputFolder, name+".png", buf.Bytes())
+}
+
+func writeFile(outputFolder string, name string, data []byte) error {
+	os.MkdirAll(outputFolder, os.ModePerm)
+
+	return os.WriteFile(filepath.Join(outputFolder, name), data, 0644)
+}
+
+// fileName keeps resource names, which can have anything in them, to one
+// path element
+func fileName(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_", ":", "_", "\x00", "_").Replace(name)
+	if name == "" || name == "." || name == ".." {
+		return "_" + name
 	}
 
+	return name
 }
@@ -0,0 +1,108 @@
+package mrf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/markhughes/dirry/internal/libmrf"
+)
+
+// CodeMap is what the CODE resources of a 68k application say of how its
+// code is split into segments and reached through the jump table
+type CodeMap struct {
+	AboveA5         int              `json:"aboveA5"`
+	BelowA5         int              `json:"belowA5"`
+	JumpTableSize   int              `json:"jumpTableSize"`
+	JumpTableOffset int              `json:"jumpTableOffset"`
+	JumpTable       []JumpTableEntry `json:"jumpTable"`
+	Segments        []CodeSegment    `json:"segments"`
+}
+
+// JumpTableEntry is an unloaded entry, the routine at Offset in Segment
+type JumpTableEntry struct {
+	Entry   int `json:"entry"`
+	Segment int `json:"segment"`
+	Offset  int `json:"offset"`
+}
+
+type CodeSegment struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+	Size int    `json:"size"`
+
+	// Far segments have the 32 bit header of the far model
+	Far bool `json:"far"`
+
+	FirstEntry int `json:"firstEntry"`
+	Entries    int `json:"entries"`
+}
+
+// decodeCodeMap reads CODE 0, the jump table, and the header of every other
+// CODE resource
+func decodeCodeMap(resources []libmrf.Resource) (*CodeMap, error) {
+	codeMap := &CodeMap{}
+	foundJumpTable := false
+
+	for _, resource := range resources {
+		data := resource.Data
+
+		if resource.Id == 0 {
+			if len(data) < 16 {
+				return nil, fmt.Errorf("CODE 0 is %d bytes", len(data))
+			}
+
+			foundJumpTable = true
+			codeMap.AboveA5 = int(binary.BigEndian.Uint32(data[0:]))
+			codeMap.BelowA5 = int(binary.BigEndian.Uint32(data[4:]))
+			codeMap.JumpTableSize = int(binary.BigEndian.Uint32(data[8:]))
+			codeMap.JumpTableOffset = int(binary.BigEndian.Uint32(data[12:]))
+
+			// offset, move.w #segment,-(sp) then _LoadSeg
+			for pos := 16; pos+8 <= len(data) && pos-16 < codeMap.JumpTableSize; pos += 8 {
+				if binary.BigEndian.Uint16(data[pos+2:]) != 0x3F3C || binary.BigEndian.Uint16(data[pos+6:]) != 0xA9F0 {
+					continue
+				}
+
+				codeMap.JumpTable = append(codeMap.JumpTable, JumpTableEntry{
+					Entry:   (pos - 16) / 8,
+					Segment: int(binary.BigEndian.Uint16(data[pos+4:])),
+					Offset:  int(binary.BigEndian.Uint16(data[pos:])),
+				})
+			}
+
+			continue
+		}
+
+		if len(data) < 4 {
+			return nil, fmt.Errorf("CODE %d is %d bytes", resource.Id, len(data))
+		}
+
+		segment := CodeSegment{
+			Id:   int(resource.Id),
+			Name: resource.Name,
+			Size: len(data),
+		}
+
+		if binary.BigEndian.Uint16(data) == 0xFFFF && len(data) >= 40 {
+			segment.Far = true
+			segment.FirstEntry = int(binary.BigEndian.Uint32(data[4:])) / 8
+			segment.Entries = int(binary.BigEndian.Uint32(data[8:]))
+		} else {
+			segment.FirstEntry = int(binary.BigEndian.Uint16(data[0:])) / 8
+			segment.Entries = int(binary.BigEndian.Uint16(data[2:]))
+		}
+
+		codeMap.Segments = append(codeMap.Segments, segment)
+	}
+
+	if !foundJumpTable {
+		return nil, fmt.Errorf("no CODE 0 with the jump table")
+	}
+
+	sort.Slice(codeMap.Segments, func(i, j int) bool {
+		return codeMap.Segments[i].Id < codeMap.Segments[j].Id
+	})
+
+	return codeMap, nil
+}
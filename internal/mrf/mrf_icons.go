@@ -0,0 +1,165 @@
+package mrf
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/markhughes/dirry/internal/palettes"
+)
+
+// the 16 colours of the Mac's 4 bit system palette, icl4 and ics4 use it
+var clut4 = []color.NRGBA{
+	{0xFF, 0xFF, 0xFF, 0xFF}, {0xFC, 0xF3, 0x05, 0xFF}, {0xFF, 0x64, 0x02, 0xFF}, {0xDD, 0x08, 0x06, 0xFF},
+	{0xF2, 0x08, 0x84, 0xFF}, {0x46, 0x00, 0xA5, 0xFF}, {0x00, 0x00, 0xD4, 0xFF}, {0x02, 0xAB, 0xEA, 0xFF},
+	{0x1F, 0xB7, 0x14, 0xFF}, {0x00, 0x64, 0x11, 0xFF}, {0x56, 0x2C, 0x05, 0xFF}, {0x90, 0x71, 0x3A, 0xFF},
+	{0xC0, 0xC0, 0xC0, 0xFF}, {0x80, 0x80, 0x80, 0xFF}, {0x40, 0x40, 0x40, 0xFF}, {0x00, 0x00, 0x00, 0xFF},
+}
+
+var (
+	black = color.NRGBA{0x00, 0x00, 0x00, 0xFF}
+	white = color.NRGBA{0xFF, 0xFF, 0xFF, 0xFF}
+)
+
+// iconSizes is the width of each icon type, they're all square
+var iconSizes = map[string]int{
+	"ICN#": 32,
+	"icl4": 32,
+	"icl8": 32,
+	"ics#": 16,
+	"ics4": 16,
+	"ics8": 16,
+}
+
+// iconMasks is the type of the 1 bit icon list with the mask for a colour
+// icon of the same id
+var iconMasks = map[string]string{
+	"icl4": "ICN#",
+	"icl8": "ICN#",
+	"ics4": "ics#",
+	"ics8": "ics#",
+}
+
+// decodeIcon draws an icon family member, mask is the 1 bit icon list of the
+// same id when there is one
+func decodeIcon(resourceType string, data []byte, mask []byte) (*image.NRGBA, error) {
+	size := iconSizes[resourceType]
+	rowBytes := size / 8
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+
+	var depth int
+	var clut []color.NRGBA
+
+	switch resourceType {
+	case "ICN#", "ics#":
+		// the icon and then its mask
+		depth = 1
+		mask = data[minInt(len(data), rowBytes*size):]
+	case "icl4", "ics4":
+		depth = 4
+		clut = clut4
+	case "icl8", "ics8":
+		depth = 8
+		clut = systemClut()
+	default:
+		return nil, fmt.Errorf("%s is not an icon", resourceType)
+	}
+
+	if len(data) < size*size*depth/8 {
+		return nil, fmt.Errorf("%s is %d bytes, it should be %d", resourceType, len(data), size*size*depth/8)
+	}
+
+	pitch := size * depth / 8
+	for y := 0; y < size; y++ {
+		row := data[y*pitch : (y+1)*pitch]
+
+		for x := 0; x < size; x++ {
+			value := indexAt(row, x, depth)
+
+			pixel := white
+			if clut != nil {
+				pixel = clut[value]
+			} else if value != 0 {
+				pixel = black
+			}
+
+			if len(mask) >= rowBytes*size && indexAt(mask[y*rowBytes:], x, 1) == 0 {
+				pixel.A = 0
+			}
+
+			img.SetNRGBA(x, y, pixel)
+		}
+	}
+
+	return img, nil
+}
+
+// decodeColorIcon draws a cicn, a PixMap with its own colour table along
+// with a mask and a 1 bit icon for black and white screens
+func decodeColorIcon(data []byte) (*image.NRGBA, error) {
+	r := &pictReader{data: data}
+
+	r.skip(4) // baseAddr
+	pm := &pixMap{}
+	pm.RowBytes = r.u16() & 0x3FFF
+	pm.Bounds = r.rect()
+	r.readPixMap(pm)
+
+	r.skip(4) // the mask's baseAddr
+	maskRowBytes := r.u16()
+	maskBounds := r.rect()
+
+	r.skip(4) // the icon's baseAddr
+	iconRowBytes := r.u16()
+	iconBounds := r.rect()
+
+	r.skip(4) // iconData handle
+
+	mask := r.bytes(maskRowBytes * maskBounds.Dy())
+	r.skip(iconRowBytes * iconBounds.Dy())
+	clut := r.colorTable()
+	pixels := r.bytes(pm.RowBytes * pm.Bounds.Dy())
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if pm.PixelSize > 8 {
+		return nil, fmt.Errorf("cicn with %d bit pixels", pm.PixelSize)
+	}
+
+	width, height := pm.Bounds.Dx(), pm.Bounds.Dy()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		row := pixels[y*pm.RowBytes : (y+1)*pm.RowBytes]
+
+		for x := 0; x < width; x++ {
+			pixel := clut[indexAt(row, x, pm.PixelSize)]
+
+			if maskRowBytes > 0 && y < maskBounds.Dy() && x < maskBounds.Dx() && indexAt(mask[y*maskRowBytes:], x, 1) == 0 {
+				pixel.A = 0
+			}
+
+			img.SetNRGBA(x, y, pixel)
+		}
+	}
+
+	return img, nil
+}
+
+// systemClut is the Mac system palette, the one 8 bit icons are drawn in
+func systemClut() []color.NRGBA {
+	clut := make([]color.NRGBA, 256)
+
+	palette, err := palettes.RetrievePallete(palettes.ClutSystemMac)
+	if err != nil {
+		return clut
+	}
+
+	for i, pixel := range palette.Palette {
+		clut[i] = color.NRGBA{pixel.R, pixel.G, pixel.B, 0xFF}
+	}
+
+	return clut
+}
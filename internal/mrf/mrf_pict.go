@@ -0,0 +1,693 @@
+package mrf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// https://developer.apple.com/library/archive/documentation/mac/QuickDraw/QuickDraw-458.html
+
+// pictReader keeps the first error, everything read after it is zero
+type pictReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *pictReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || r.pos+n > len(r.data) {
+		if r.err == nil {
+			r.err = fmt.Errorf("PICT truncated at %d reading %d bytes", r.pos, n)
+		}
+		return make([]byte, maxInt(n, 0))
+	}
+
+	out := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return out
+}
+
+func (r *pictReader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *pictReader) u8() int {
+	return int(r.bytes(1)[0])
+}
+
+func (r *pictReader) u16() int {
+	return int(binary.BigEndian.Uint16(r.bytes(2)))
+}
+
+func (r *pictReader) i16() int {
+	return int(int16(binary.BigEndian.Uint16(r.bytes(2))))
+}
+
+func (r *pictReader) u32() int {
+	return int(binary.BigEndian.Uint32(r.bytes(4)))
+}
+
+// rect reads a QuickDraw rectangle, top, left, bottom then right
+func (r *pictReader) rect() image.Rectangle {
+	top, left := r.i16(), r.i16()
+	bottom, right := r.i16(), r.i16()
+	return image.Rect(left, top, right, bottom)
+}
+
+// rgb reads a 48 bit RGBColor
+func (r *pictReader) rgb() color.NRGBA {
+	red, green, blue := r.u16(), r.u16(), r.u16()
+	return color.NRGBA{uint8(red >> 8), uint8(green >> 8), uint8(blue >> 8), 0xFF}
+}
+
+// colorTable reads a ColorTable, entries either say their index or are in
+// order when the device flag is set
+func (r *pictReader) colorTable() []color.NRGBA {
+	r.skip(4) // ctSeed
+	flags := r.u16()
+	size := r.u16() + 1
+
+	table := make([]color.NRGBA, 256)
+	for i := range table {
+		table[i] = color.NRGBA{0, 0, 0, 0xFF}
+	}
+
+	for i := 0; i < size && r.err == nil; i++ {
+		value := r.u16()
+		entry := r.rgb()
+
+		if flags&0x8000 != 0 {
+			value = i
+		}
+		if value < len(table) {
+			table[value] = entry
+		}
+	}
+
+	return table
+}
+
+// withSize skips something that starts with its own size, regions and
+// polygons, and returns the bounding box after it
+func (r *pictReader) withSize() image.Rectangle {
+	size := r.u16()
+	if size < 10 {
+		r.skip(size - 2)
+		return image.Rectangle{}
+	}
+
+	bounds := r.rect()
+	r.skip(size - 10)
+	return bounds
+}
+
+// pixMap is what the bits opcodes and a cicn say of their pixels
+type pixMap struct {
+	RowBytes  int
+	Bounds    image.Rectangle
+	PackType  int
+	PixelSize int
+	CmpCount  int
+}
+
+// readPixMap reads the PixMap after its rowBytes and bounds
+func (r *pictReader) readPixMap(pm *pixMap) {
+	r.skip(2) // pmVersion
+	pm.PackType = r.u16()
+	r.skip(12) // packSize, hRes and vRes
+	r.skip(2)  // pixelType
+	pm.PixelSize = r.u16()
+	pm.CmpCount = r.u16()
+	r.skip(14) // cmpSize, planeBytes, pmTable and pmReserved
+
+	// a pack type of 0 is the default for the depth
+	if pm.PackType == 0 && pm.PixelSize == 16 {
+		pm.PackType = 3
+	} else if pm.PackType == 0 && pm.PixelSize == 32 {
+		pm.PackType = 4
+	}
+}
+
+// rows reads the pixel data of a bits opcode, a row at a time as each is
+// packed on its own
+func (r *pictReader) rows(pm *pixMap) [][]byte {
+	height := pm.Bounds.Dy()
+	width := pm.Bounds.Dx()
+	rows := make([][]byte, 0, maxInt(height, 0))
+
+	for y := 0; y < height && r.err == nil; y++ {
+		if pm.RowBytes < 8 || pm.PackType == 1 {
+			rows = append(rows, r.bytes(pm.RowBytes))
+			continue
+		}
+
+		if pm.PackType == 2 {
+			rows = append(rows, r.bytes(width*3))
+			continue
+		}
+
+		var count int
+		if pm.RowBytes > 250 {
+			count = r.u16()
+		} else {
+			count = r.u8()
+		}
+
+		packed := r.bytes(count)
+		if pm.PackType == 3 {
+			rows = append(rows, unpackBitsWords(packed))
+		} else {
+			rows = append(rows, unpackBits(packed))
+		}
+	}
+
+	return rows
+}
+
+// pixel is the colour at x of a row, clut is nil for direct pixels and for
+// a 1 bit BitMap which draws in fg and bg
+func (pm *pixMap) pixel(row []byte, x int, clut []color.NRGBA, fg, bg color.NRGBA) color.NRGBA {
+	switch pm.PixelSize {
+	case 16:
+		if 2*x+1 >= len(row) {
+			return bg
+		}
+		value := int(binary.BigEndian.Uint16(row[2*x:]))
+		return color.NRGBA{expand5(value >> 10), expand5(value >> 5), expand5(value), 0xFF}
+	case 32:
+		width := pm.Bounds.Dx()
+		switch pm.PackType {
+		case 4:
+			plane := 0
+			if pm.CmpCount == 4 {
+				plane = width
+			}
+			if plane+2*width+x >= len(row) {
+				return bg
+			}
+			return color.NRGBA{row[plane+x], row[plane+width+x], row[plane+2*width+x], 0xFF}
+		case 2:
+			if 3*x+2 >= len(row) {
+				return bg
+			}
+			return color.NRGBA{row[3*x], row[3*x+1], row[3*x+2], 0xFF}
+		default:
+			if 4*x+3 >= len(row) {
+				return bg
+			}
+			return color.NRGBA{row[4*x+1], row[4*x+2], row[4*x+3], 0xFF}
+		}
+	case 24:
+		if 3*x+2 >= len(row) {
+			return bg
+		}
+		return color.NRGBA{row[3*x], row[3*x+1], row[3*x+2], 0xFF}
+	}
+
+	value := indexAt(row, x, pm.PixelSize)
+	if clut == nil {
+		if value != 0 {
+			return fg
+		}
+		return bg
+	}
+
+	return clut[value&0xFF]
+}
+
+// pict draws a picture onto an image the size of its frame
+type pict struct {
+	version int
+	frame   image.Rectangle
+	clip    image.Rectangle
+	canvas  *image.NRGBA
+
+	fg, bg   color.NRGBA
+	penPat   []byte
+	fillPat  []byte
+	penSize  image.Point
+	lastRect image.Rectangle
+
+	bitmaps   int
+	skipped   int
+	quickTime bool
+}
+
+// decodePict draws what it can of a PICT, the bitmaps and rectangles, and
+// counts the drawing it had to skip
+func decodePict(data []byte) (*pict, error) {
+	p, err := readPict(data)
+
+	// PICT files have a 512 byte header in front of what the resource has
+	if err != nil && len(data) > 512 {
+		if p2, err2 := readPict(data[512:]); err2 == nil {
+			return p2, nil
+		}
+	}
+
+	return p, err
+}
+
+func readPict(data []byte) (*pict, error) {
+	r := &pictReader{data: data}
+
+	r.skip(2) // the size, only the low 16 bits of it
+	p := &pict{
+		frame:   r.rect(),
+		fg:      color.NRGBA{0, 0, 0, 0xFF},
+		bg:      color.NRGBA{0xFF, 0xFF, 0xFF, 0xFF},
+		penSize: image.Pt(1, 1),
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	switch {
+	case r.pos+2 <= len(data) && data[r.pos] == 0x11 && data[r.pos+1] == 0x01:
+		p.version = 1
+		r.skip(2)
+	case r.pos+4 <= len(data) && binary.BigEndian.Uint32(data[r.pos:]) == 0x001102FF:
+		p.version = 2
+		r.skip(4)
+	default:
+		return nil, fmt.Errorf("not a version 1 or 2 PICT")
+	}
+
+	if p.frame.Empty() || p.frame.Dx() > 0x4000 || p.frame.Dy() > 0x4000 {
+		return nil, fmt.Errorf("PICT frame %v is not usable", p.frame)
+	}
+
+	p.clip = p.frame
+	p.canvas = image.NewNRGBA(image.Rect(0, 0, p.frame.Dx(), p.frame.Dy()))
+	fillRect(p.canvas, p.canvas.Bounds(), p.bg)
+
+	for r.err == nil {
+		var opcode int
+		if p.version == 1 {
+			opcode = r.u8()
+		} else {
+			if r.pos%2 == 1 {
+				r.skip(1)
+			}
+			opcode = r.u16()
+		}
+
+		if r.err != nil {
+			break
+		}
+
+		if opcode == 0x00FF || (p.version == 1 && opcode == 0xFF) {
+			return p, nil
+		}
+
+		p.opcode(r, opcode)
+	}
+
+	// whatever was drawn before the picture ran out is kept
+	if p.bitmaps > 0 {
+		return p, nil
+	}
+
+	return nil, r.err
+}
+
+func (p *pict) opcode(r *pictReader, opcode int) {
+	switch {
+	case opcode == 0x0000, opcode == 0x001C, opcode == 0x001E, opcode >= 0x0017 && opcode <= 0x0019:
+		// NOP, HiliteMode and DefHilite
+	case opcode == 0x0001:
+		p.clip = r.withSize().Intersect(p.frame)
+	case opcode == 0x0002:
+		r.skip(8) // BkPat
+	case opcode == 0x0003, opcode == 0x0005, opcode == 0x0008, opcode == 0x000D, opcode == 0x0015, opcode == 0x0016:
+		r.skip(2) // TxFont, TxMode, PnMode, TxSize, PnLocHFrac and ChExtra
+	case opcode == 0x0004:
+		r.skip(1) // TxFace
+	case opcode == 0x0006, opcode == 0x000B, opcode == 0x000C:
+		r.skip(4) // SpExtra, OvSize and Origin
+	case opcode == 0x0007:
+		v, h := r.i16(), r.i16()
+		p.penSize = image.Pt(h, v)
+	case opcode == 0x0009:
+		p.penPat = r.bytes(8)
+	case opcode == 0x000A:
+		p.fillPat = r.bytes(8)
+	case opcode == 0x000E:
+		p.fg = oldColor(r.u32(), p.fg)
+	case opcode == 0x000F:
+		p.bg = oldColor(r.u32(), p.bg)
+	case opcode == 0x0010:
+		r.skip(8) // TxRatio
+	case opcode == 0x0011:
+		r.skip(p.version) // Version
+	case opcode >= 0x0012 && opcode <= 0x0014:
+		pattern := p.pixPat(r)
+		if opcode == 0x0013 {
+			p.penPat = pattern
+		} else if opcode == 0x0014 {
+			p.fillPat = pattern
+		}
+	case opcode == 0x001A:
+		p.fg = r.rgb()
+	case opcode == 0x001B:
+		p.bg = r.rgb()
+	case opcode == 0x001D, opcode == 0x001F:
+		r.skip(6) // HiliteColor and OpColor
+	case opcode == 0x0020:
+		r.skip(8)
+		p.skipped++
+	case opcode == 0x0021:
+		r.skip(4)
+		p.skipped++
+	case opcode == 0x0022:
+		r.skip(6)
+		p.skipped++
+	case opcode == 0x0023:
+		r.skip(2)
+		p.skipped++
+	case opcode == 0x0028:
+		r.skip(4)
+		r.skip(r.u8())
+		p.skipped++
+	case opcode == 0x0029, opcode == 0x002A:
+		r.skip(1)
+		r.skip(r.u8())
+		p.skipped++
+	case opcode == 0x002B:
+		r.skip(2)
+		r.skip(r.u8())
+		p.skipped++
+	case opcode >= 0x0024 && opcode <= 0x0027, opcode >= 0x002C && opcode <= 0x002F:
+		r.skip(r.u16())
+	case opcode >= 0x0030 && opcode <= 0x0037:
+		p.lastRect = r.rect()
+		p.drawRect(opcode & 0x07)
+	case opcode >= 0x0038 && opcode <= 0x003F:
+		p.drawRect(opcode & 0x07)
+	case opcode >= 0x0040 && opcode <= 0x0047, opcode >= 0x0050 && opcode <= 0x0057:
+		r.skip(8) // round rectangles and ovals
+		p.skipped++
+	case opcode >= 0x0060 && opcode <= 0x0067:
+		r.skip(12)
+		p.skipped++
+	case opcode >= 0x0068 && opcode <= 0x006F:
+		r.skip(4)
+		p.skipped++
+	case opcode >= 0x0070 && opcode <= 0x0077, opcode >= 0x0080 && opcode <= 0x0087:
+		r.withSize() // polygons and regions
+		p.skipped++
+	case opcode >= 0x0048 && opcode <= 0x004F, opcode >= 0x0058 && opcode <= 0x005F,
+		opcode >= 0x0078 && opcode <= 0x007F, opcode >= 0x0088 && opcode <= 0x008F:
+		// the same round rectangle, oval, polygon or region again
+		p.skipped++
+	case opcode == 0x0090, opcode == 0x0091, opcode == 0x0098, opcode == 0x0099:
+		p.bits(r, false, opcode&0x01 != 0)
+	case opcode == 0x009A, opcode == 0x009B:
+		p.bits(r, true, opcode&0x01 != 0)
+	case opcode == 0x00A0:
+		r.skip(2) // ShortComment
+	case opcode == 0x00A1:
+		r.skip(2) // LongComment
+		r.skip(r.u16())
+	case opcode >= 0x0092 && opcode <= 0x0097, opcode >= 0x009C && opcode <= 0x009F, opcode >= 0x00A2 && opcode <= 0x00AF:
+		r.skip(r.u16())
+	case opcode >= 0x00B0 && opcode <= 0x00CF, opcode >= 0x8000 && opcode <= 0x80FF:
+		// reserved, no data
+	case opcode >= 0x00D0 && opcode <= 0x00FE:
+		r.skip(r.u32())
+	case opcode == 0x0C00:
+		p.header(r.bytes(24))
+	case opcode >= 0x0100 && opcode <= 0x7FFF:
+		// reserved, and the version 2 HeaderOp, two bytes of data for
+		// each in the high byte
+		r.skip((opcode >> 8) * 2)
+	case opcode == 0x8200, opcode == 0x8201:
+		r.skip(r.u32())
+		p.quickTime = true
+	default:
+		r.skip(r.u32())
+	}
+}
+
+// header takes the frame from an extended version 2 HeaderOp, which draws
+// in the resolution the picture was made at rather than 72 dpi
+func (p *pict) header(data []byte) {
+	if binary.BigEndian.Uint16(data) != 0xFFFE {
+		return
+	}
+
+	sr := &pictReader{data: data[12:20]}
+	frame := sr.rect()
+	if frame.Empty() || frame == p.frame || frame.Dx() > 0x4000 || frame.Dy() > 0x4000 {
+		return
+	}
+
+	p.frame = frame
+	p.clip = frame
+	p.canvas = image.NewNRGBA(image.Rect(0, 0, frame.Dx(), frame.Dy()))
+	fillRect(p.canvas, p.canvas.Bounds(), p.bg)
+}
+
+// pixPat reads a pixel pattern and keeps the plain pattern it has for
+// QuickDraw without colour
+func (p *pict) pixPat(r *pictReader) []byte {
+	patType := r.u16()
+	pattern := r.bytes(8)
+
+	switch patType {
+	case 1:
+		pm := &pixMap{}
+		pm.RowBytes = r.u16() & 0x3FFF
+		pm.Bounds = r.rect()
+		r.readPixMap(pm)
+		r.colorTable()
+		r.rows(pm)
+	case 2:
+		r.skip(6) // the RGB it dithers
+	}
+
+	return pattern
+}
+
+// bits draws BitsRect, PackBitsRect, DirectBitsRect and their region forms
+func (p *pict) bits(r *pictReader, direct bool, region bool) {
+	if direct {
+		r.skip(4) // baseAddr
+	}
+
+	pm := &pixMap{PixelSize: 1, CmpCount: 1}
+	rowBytes := r.u16()
+	pm.RowBytes = rowBytes & 0x3FFF
+	pm.Bounds = r.rect()
+
+	// a BitMap is 1 bit and has nothing more to it
+	var clut []color.NRGBA
+	if direct || rowBytes&0x8000 != 0 {
+		r.readPixMap(pm)
+		if !direct {
+			clut = r.colorTable()
+		}
+	}
+
+	src := r.rect()
+	dst := r.rect()
+	mode := r.u16()
+
+	clip := p.clip
+	if region {
+		clip = r.withSize().Intersect(clip)
+	}
+
+	rows := r.rows(pm)
+	if r.err != nil {
+		return
+	}
+
+	p.bitmaps++
+
+	// the transparent modes leave what's behind the background colour
+	transparent := mode == 1 || mode == 36
+
+	area := dst.Intersect(clip)
+	if src.Empty() || dst.Empty() {
+		return
+	}
+
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		sy := src.Min.Y + (y-dst.Min.Y)*src.Dy()/dst.Dy() - pm.Bounds.Min.Y
+		if sy < 0 || sy >= len(rows) {
+			continue
+		}
+
+		for x := area.Min.X; x < area.Max.X; x++ {
+			sx := src.Min.X + (x-dst.Min.X)*src.Dx()/dst.Dx() - pm.Bounds.Min.X
+			if sx < 0 || sx >= pm.Bounds.Dx() {
+				continue
+			}
+
+			pixel := pm.pixel(rows[sy], sx, clut, p.fg, p.bg)
+			if transparent && pixel == p.bg {
+				continue
+			}
+
+			p.canvas.SetNRGBA(x-p.frame.Min.X, y-p.frame.Min.Y, pixel)
+		}
+	}
+}
+
+// drawRect does frameRect, paintRect, eraseRect and fillRect in one colour
+// each, patterns are drawn as whichever colour has most of them
+func (p *pict) drawRect(verb int) {
+	area := p.lastRect.Intersect(p.clip).Sub(p.frame.Min)
+
+	switch verb {
+	case 0:
+		rect := p.lastRect.Sub(p.frame.Min)
+		clip := p.clip.Sub(p.frame.Min)
+		colour := p.patternColor(p.penPat)
+		fillRect(p.canvas, image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y+p.penSize.Y).Intersect(clip), colour)
+		fillRect(p.canvas, image.Rect(rect.Min.X, rect.Max.Y-p.penSize.Y, rect.Max.X, rect.Max.Y).Intersect(clip), colour)
+		fillRect(p.canvas, image.Rect(rect.Min.X, rect.Min.Y, rect.Min.X+p.penSize.X, rect.Max.Y).Intersect(clip), colour)
+		fillRect(p.canvas, image.Rect(rect.Max.X-p.penSize.X, rect.Min.Y, rect.Max.X, rect.Max.Y).Intersect(clip), colour)
+	case 1:
+		fillRect(p.canvas, area, p.patternColor(p.penPat))
+	case 2:
+		fillRect(p.canvas, area, p.bg)
+	case 4:
+		fillRect(p.canvas, area, p.patternColor(p.fillPat))
+	default:
+		p.skipped++
+	}
+}
+
+func (p *pict) patternColor(pattern []byte) color.NRGBA {
+	set := 0
+	for _, b := range pattern {
+		for ; b != 0; b &= b - 1 {
+			set++
+		}
+	}
+
+	if pattern != nil && set < 32 {
+		return p.bg
+	}
+
+	return p.fg
+}
+
+// oldColor is the colour of one of the eight QuickDraw started with
+func oldColor(value int, fallback color.NRGBA) color.NRGBA {
+	switch value {
+	case 33:
+		return color.NRGBA{0x00, 0x00, 0x00, 0xFF}
+	case 30:
+		return color.NRGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	case 205:
+		return color.NRGBA{0xDD, 0x08, 0x06, 0xFF}
+	case 341:
+		return color.NRGBA{0x1F, 0xB7, 0x14, 0xFF}
+	case 409:
+		return color.NRGBA{0x00, 0x00, 0xD4, 0xFF}
+	case 273:
+		return color.NRGBA{0x02, 0xAB, 0xEA, 0xFF}
+	case 137:
+		return color.NRGBA{0xF2, 0x08, 0x84, 0xFF}
+	case 69:
+		return color.NRGBA{0xFC, 0xF3, 0x05, 0xFF}
+	}
+
+	return fallback
+}
+
+func fillRect(img *image.NRGBA, rect image.Rectangle, colour color.NRGBA) {
+	rect = rect.Intersect(img.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.SetNRGBA(x, y, colour)
+		}
+	}
+}
+
+// indexAt is the x'th pixel of a row of 1, 2, 4 or 8 bit pixels
+func indexAt(row []byte, x int, depth int) int {
+	if depth != 1 && depth != 2 && depth != 4 && depth != 8 {
+		depth = 8
+	}
+
+	bit := x * depth
+	if bit/8 >= len(row) {
+		return 0
+	}
+
+	shift := 8 - depth - bit%8
+	return int(row[bit/8]>>uint(shift)) & (1<<uint(depth) - 1)
+}
+
+func expand5(value int) uint8 {
+	value &= 0x1F
+	return uint8(value<<3 | value>>2)
+}
+
+// unpackBits undoes PackBits, a count of n >= 0 has n+1 bytes after it and
+// a negative one repeats the next byte 1-n times
+func unpackBits(packed []byte) []byte {
+	out := make([]byte, 0, len(packed)*2)
+
+	for i := 0; i < len(packed); {
+		count := int(int8(packed[i]))
+		i++
+
+		if count >= 0 {
+			end := minInt(i+count+1, len(packed))
+			out = append(out, packed[i:end]...)
+			i = end
+		} else if count != -128 && i < len(packed) {
+			for j := 0; j < 1-count; j++ {
+				out = append(out, packed[i])
+			}
+			i++
+		}
+	}
+
+	return out
+}
+
+// unpackBitsWords is PackBits where what's counted and repeated is 16 bits
+func unpackBitsWords(packed []byte) []byte {
+	out := make([]byte, 0, len(packed)*2)
+
+	for i := 0; i < len(packed); {
+		count := int(int8(packed[i]))
+		i++
+
+		if count >= 0 {
+			end := minInt(i+(count+1)*2, len(packed))
+			out = append(out, packed[i:end]...)
+			i = end
+		} else if count != -128 && i+1 < len(packed) {
+			for j := 0; j < 1-count; j++ {
+				out = append(out, packed[i], packed[i+1])
+			}
+			i += 2
+		}
+	}
+
+	return out
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
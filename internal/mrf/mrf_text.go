@@ -0,0 +1,118 @@
+package mrf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/markhughes/dirry/internal/macroman"
+)
+
+// macText is Mac Roman text with its carriage returns as UTF-8 with line
+// feeds
+func macText(data []byte) string {
+	text := macroman.ConvertMacRomanToUTF8(string(data))
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+}
+
+// pascalString reads a length byte and that many characters at pos, and
+// returns where the next thing starts
+func pascalString(data []byte, pos int) (string, int, error) {
+	if pos >= len(data) {
+		return "", pos, fmt.Errorf("string at %d is past the end", pos)
+	}
+
+	end := pos + 1 + int(data[pos])
+	if end > len(data) {
+		return "", pos, fmt.Errorf("string at %d runs past the end", pos)
+	}
+
+	return macText(data[pos+1 : end]), end, nil
+}
+
+// decodeStringList reads a STR#, a count and then that many strings
+func decodeStringList(data []byte) ([]string, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("STR# is %d bytes", len(data))
+	}
+
+	count := int(binary.BigEndian.Uint16(data))
+	pos := 2
+
+	strs := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		var str string
+		var err error
+
+		str, pos, err = pascalString(data, pos)
+		if err != nil {
+			return strs, err
+		}
+
+		strs = append(strs, str)
+	}
+
+	return strs, nil
+}
+
+// Version is a vers resource, what the Finder shows in Get Info
+type Version struct {
+	Version    string `json:"version"`
+	Major      int    `json:"major"`
+	Minor      int    `json:"minor"`
+	Bugfix     int    `json:"bugfix"`
+	Stage      string `json:"stage"`
+	NonRelease int    `json:"nonRelease"`
+	Region     int    `json:"region"`
+	Short      string `json:"short"`
+	Long       string `json:"long"`
+}
+
+var versionStages = map[byte]string{
+	0x20: "development",
+	0x40: "alpha",
+	0x60: "beta",
+	0x80: "final",
+}
+
+// decodeVersion reads a vers, the numbers are binary coded decimal
+func decodeVersion(data []byte) (*Version, error) {
+	if len(data) < 7 {
+		return nil, fmt.Errorf("vers is %d bytes", len(data))
+	}
+
+	v := &Version{
+		Major:      bcd(data[0]),
+		Minor:      int(data[1] >> 4),
+		Bugfix:     int(data[1] & 0x0F),
+		Stage:      versionStages[data[2]],
+		NonRelease: bcd(data[3]),
+		Region:     int(binary.BigEndian.Uint16(data[4:])),
+	}
+
+	if v.Stage == "" {
+		v.Stage = fmt.Sprintf("0x%02x", data[2])
+	}
+
+	v.Version = fmt.Sprintf("%d.%d", v.Major, v.Minor)
+	if v.Bugfix != 0 {
+		v.Version += fmt.Sprintf(".%d", v.Bugfix)
+	}
+	if v.Stage != "final" {
+		v.Version += fmt.Sprintf(" %s %d", v.Stage, v.NonRelease)
+	}
+
+	var err error
+	var pos int
+	v.Short, pos, err = pascalString(data, 6)
+	if err != nil {
+		return v, err
+	}
+
+	v.Long, _, err = pascalString(data, pos)
+	return v, err
+}
+
+func bcd(b byte) int {
+	return int(b>>4)*10 + int(b&0x0F)
+}
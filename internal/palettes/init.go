@@ -1,67 +1,63 @@
-//go:build !js
-
 package palettes
 
 import (
 	"encoding/json"
-	"io"
-	"os"
 	"path"
 
-	"github.com/markhughes/dirry/internal/consts"
+	"github.com/markhughes/dirry/resources"
 )
 
 func init() {
 
-	palSystemMac, err := fromFile(path.Join(consts.PalettesDir, "SystemMac.json"))
+	palSystemMac, err := fromFile(path.Join("palettes", "SystemMac.json"))
 	if err != nil {
 		panic(err)
 	}
 	RegisterPallete(ClutSystemMac, palSystemMac)
 
-	palRainbow, err := fromFile(path.Join(consts.PalettesDir, "Rainbow.json"))
+	palRainbow, err := fromFile(path.Join("palettes", "Rainbow.json"))
 	if err != nil {
 		panic(err)
 	}
 	RegisterPallete(ClutRainbow, palRainbow)
 
-	palGrayscale, err := fromFile(path.Join(consts.PalettesDir, "Grayscale.json"))
+	palGrayscale, err := fromFile(path.Join("palettes", "Grayscale.json"))
 	if err != nil {
 		panic(err)
 	}
 	RegisterPallete(ClutGrayscale, palGrayscale)
 
-	palPastels, err := fromFile(path.Join(consts.PalettesDir, "Pastels.json"))
+	palPastels, err := fromFile(path.Join("palettes", "Pastels.json"))
 	if err != nil {
 		panic(err)
 	}
 	RegisterPallete(ClutPastels, palPastels)
 
-	palVivid, err := fromFile(path.Join(consts.PalettesDir, "Vivid.json"))
+	palVivid, err := fromFile(path.Join("palettes", "Vivid.json"))
 	if err != nil {
 		panic(err)
 	}
 	RegisterPallete(ClutVivid, palVivid)
 
-	palNTSC, err := fromFile(path.Join(consts.PalettesDir, "NTSC.json"))
+	palNTSC, err := fromFile(path.Join("palettes", "NTSC.json"))
 	if err != nil {
 		panic(err)
 	}
 	RegisterPallete(ClutNTSC, palNTSC)
 
-	palMetallic, err := fromFile(path.Join(consts.PalettesDir, "Metallic.json"))
+	palMetallic, err := fromFile(path.Join("palettes", "Metallic.json"))
 	if err != nil {
 		panic(err)
 	}
 	RegisterPallete(ClutMetallic, palMetallic)
 
-	palSystemWin, err := fromFile(path.Join(consts.PalettesDir, "SystemWin.json"))
+	palSystemWin, err := fromFile(path.Join("palettes", "SystemWin.json"))
 	if err != nil {
 		panic(err)
 	}
 	RegisterPallete(ClutSystemWin, palSystemWin)
 
-	palSystemWinD5, err := fromFile(path.Join(consts.PalettesDir, "SystemWinD5.json"))
+	palSystemWinD5, err := fromFile(path.Join("palettes", "SystemWinD5.json"))
 	if err != nil {
 		panic(err)
 	}
@@ -70,15 +66,8 @@ func init() {
 }
 
 func fromFile(path string) (PaletteValue, error) {
-	// Open the file
-	file, err := os.Open(path)
-	if err != nil {
-		return PaletteValue{}, err
-	}
-	defer file.Close()
-
-	// Read the file content
-	bytes, err := io.ReadAll(file)
+	// Read the embedded file content
+	bytes, err := resources.Palettes.ReadFile(path)
 	if err != nil {
 		return PaletteValue{}, err
 	}
@@ -62,19 +62,21 @@ func RegisterPallete(clut Clut, pal PaletteValue) {
 		pal.Size = int32(len(pal.Palette))
 	}
 	registerdPalettes[clut] = pal
-
-	DumpPalleteDebug(pal, clut)
 }
 
 func DumpPalleteDebug(pal PaletteValue, clut Clut) {
+	dumpPalleteHtml(pal, "palette_"+fmt.Sprintf("%d", clut))
+}
+
+func dumpPalleteHtml(pal PaletteValue, name string) {
 	// Store as a HTML doc for reference
 	var out = bytes.NewBufferString("<html><body><table>")
 	out.Write(pal.ToHtmlDoc())
 	out.WriteString("</table></body></html>")
 
-	os.Mkdir(path.Join(consts.PathDump, "_debug"), 0755)
+	os.MkdirAll(path.Join(consts.PathDump, "_debug"), 0755)
 
-	filepath := path.Join(consts.PathDump, "_debug", "palette_"+fmt.Sprintf("%d", clut))
+	filepath := path.Join(consts.PathDump, "_debug", name)
 	os.WriteFile(filepath+".html", out.Bytes(), 0644)
 
 }
@@ -88,6 +90,44 @@ func RetrievePallete(clut Clut) (PaletteValue, error) {
 
 }
 
+// Member is a palette cast member, bitmaps reference palettes made in
+// Director by cast library and member number rather than a Clut
+type Member struct {
+	CastLib int
+	Number  int
+}
+
+var memberPalettes = map[Member]PaletteValue{}
+
+func RegisterMemberPallete(member Member, pal PaletteValue) {
+	if pal.Size == 0 {
+		pal.Size = int32(len(pal.Palette))
+	}
+	memberPalettes[member] = pal
+}
+
+func RetrieveMemberPallete(member Member) (PaletteValue, error) {
+	pallete, ok := memberPalettes[member]
+	if !ok {
+		return PaletteValue{}, fmt.Errorf("palette member %d of cast %d not found", member.Number, member.CastLib)
+	}
+	return pallete, nil
+}
+
+// ClearMemberPalletes forgets the palette members, they belong to a movie
+// and the next one opened will have its own
+func ClearMemberPalletes() {
+	memberPalettes = map[Member]PaletteValue{}
+}
+
+// StoreAsHtml writes the debug HTML of every registered palette, this used to
+// happen on registration but that meant just importing dirry wrote to disk.
 func StoreAsHtml() {
+	for clut, pal := range registerdPalettes {
+		DumpPalleteDebug(pal, clut)
+	}
 
+	for member, pal := range memberPalettes {
+		dumpPalleteHtml(pal, fmt.Sprintf("palette_%d_%d", member.CastLib, member.Number))
+	}
 }
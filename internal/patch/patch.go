@@ -0,0 +1,275 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/markhughes/dirry/internal/bitd"
+	"github.com/markhughes/dirry/internal/chunks"
+	"github.com/markhughes/dirry/internal/consts"
+	"github.com/markhughes/dirry/internal/errors"
+	"github.com/markhughes/dirry/internal/macroman"
+	"github.com/markhughes/dirry/internal/members"
+	"github.com/markhughes/dirry/internal/shockwave"
+	"github.com/markhughes/dirry/internal/unprotect"
+	"github.com/markhughes/dirry/internal/utils"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Patch swaps the content of one member for inputFile and writes the movie
+// into outputFolder, or the dump folder when it's empty. Bitmaps take an
+// image and text members a UTF-8 text file. member is castLib:number.
+func Patch(filePath string, member string, inputFile string, outputFolder string) error {
+	target, err := parseMember(member)
+	if err != nil {
+		return err
+	}
+
+	input, err := os.ReadFile(inputFile)
+	if err != nil {
+		return err
+	}
+
+	var movie shockwave.Shockwave
+	expanded, err := movie.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("error opening file: %s", err)
+	}
+	defer movie.Close()
+
+	if len(expanded) > 0 {
+		return fmt.Errorf("%s is a projector, extract the movie to patch first", filePath)
+	}
+
+	// palettes are needed to put a bitmap back at its depth
+	movie.LoadPalettes()
+
+	cast := castResource(&movie, target)
+	if cast == nil {
+		return fmt.Errorf("member %d of cast %d not found", target.Number, target.CastLib)
+	}
+
+	reader, err := cast.GetReader()
+	if err != nil {
+		return fmt.Errorf("error getting reader for CASt resource: %s", err)
+	}
+
+	castchunk, err := chunks.ReadCastChunkRaw(reader.GetUnsafeBytesReader(), movie.Version, movie.Endian, movie.IsAfterburner())
+	if err != nil {
+		if _, ok := err.(*errors.UnhandledCastTypeError); !ok {
+			return fmt.Errorf("error reading CASt chunk: %s", err)
+		}
+	}
+
+	switch castchunk.Type {
+	case chunks.Bitmap:
+		err = patchBitmap(&movie, cast, castchunk, target.CastLib, input)
+	case chunks.StyledText, chunks.Text, chunks.Button:
+		err = patchText(&movie, cast, input)
+	default:
+		err = fmt.Errorf("%s members can't be patched", castchunk.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	utils.InfoMsg("patch", "Patched %s member %s from %s", castchunk.Type, member, inputFile)
+
+	return write(&movie, filePath, outputFolder, memberResources(&movie, cast))
+}
+
+func parseMember(member string) (shockwave.CastMember, error) {
+	parts := strings.Split(member, ":")
+	if len(parts) != 2 {
+		return shockwave.CastMember{}, fmt.Errorf("member %q should be castLib:number", member)
+	}
+
+	castLib, err := strconv.Atoi(parts[0])
+	if err != nil || castLib < 1 {
+		return shockwave.CastMember{}, fmt.Errorf("bad cast library %q", parts[0])
+	}
+
+	number, err := strconv.Atoi(parts[1])
+	if err != nil || number < 1 {
+		return shockwave.CastMember{}, fmt.Errorf("bad member number %q", parts[1])
+	}
+
+	return shockwave.CastMember{CastLib: castLib, Number: number}, nil
+}
+
+func castResource(movie *shockwave.Shockwave, target shockwave.CastMember) *shockwave.ShockwaveResource {
+	for id, member := range movie.CastMembers() {
+		if member == target {
+			return movie.ChunkMap.GetResourceById(id)
+		}
+	}
+
+	return nil
+}
+
+// memberResource is the chunk of a type the member owns
+func memberResource(movie *shockwave.Shockwave, cast *shockwave.ShockwaveResource, tag string) *shockwave.ShockwaveResource {
+	for _, resource := range movie.ChunkMap.GetResourcesByTag(tag) {
+		if resource.CastId == cast.ResourceId {
+			return resource
+		}
+	}
+
+	return nil
+}
+
+// memberResources are the ids of the CASt and every chunk it owns
+func memberResources(movie *shockwave.Shockwave, cast *shockwave.ShockwaveResource) []int32 {
+	ids := []int32{cast.ResourceId}
+	for _, resource := range movie.ChunkMap.GetAllResources() {
+		if resource.CastId == cast.ResourceId {
+			ids = append(ids, resource.ResourceId)
+		}
+	}
+
+	return ids
+}
+
+func replace(resource *shockwave.ShockwaveResource, data []byte) {
+	resource.Binary = data
+	resource.CompressedSize = int32(len(data))
+	resource.UncompressedSize = int32(len(data))
+}
+
+func patchBitmap(movie *shockwave.Shockwave, cast *shockwave.ShockwaveResource, castchunk *chunks.CastChunk, castLib int, input []byte) error {
+	member, ok := castchunk.Member.(*members.MemberBitmap)
+	if !ok {
+		return fmt.Errorf("cast %d has no bitmap data", cast.ResourceId)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(input))
+	if err != nil {
+		return fmt.Errorf("error reading image: %s", err)
+	}
+
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	bitmap := memberResource(movie, cast, "BITD")
+	if bitmap == nil {
+		return fmt.Errorf("no BITD for cast %d", cast.ResourceId)
+	}
+
+	data, err := chunks.EncodeBitmap(member, castLib, img)
+	if err != nil {
+		return fmt.Errorf("error encoding bitmap: %s", err)
+	}
+
+	offset, length, err := chunks.CastSpecificData(cast.Binary, movie.Endian)
+	if err != nil {
+		return err
+	}
+
+	resized, err := member.Resize(cast.Binary[offset:offset+length], movie.Version, width, height, bitd.Pitch(width, int(member.BitsPerPixel)))
+	if err != nil {
+		return err
+	}
+
+	castData := make([]byte, len(cast.Binary))
+	copy(castData, cast.Binary)
+	copy(castData[offset:], resized)
+
+	replace(bitmap, data)
+	replace(cast, castData)
+
+	if alpha := memberResource(movie, cast, "ALFA"); alpha != nil {
+		replace(alpha, bitd.EncodeAlpha(img))
+	}
+
+	utils.DebugMsg("patch", "BITD %d is now %dx%d at %d bits, %d bytes", bitmap.ResourceId, width, height, member.BitsPerPixel, len(data))
+
+	return nil
+}
+
+func patchText(movie *shockwave.Shockwave, cast *shockwave.ShockwaveResource, input []byte) error {
+	text := memberResource(movie, cast, "STXT")
+	if text == nil {
+		return fmt.Errorf("no STXT for cast %d, text kept in XMED can't be patched", cast.ResourceId)
+	}
+
+	// Director ends lines with a carriage return, and a text file ends with
+	// a new line nobody meant to put in the member
+	content := strings.ReplaceAll(string(input), "\r\n", "\n")
+	content = strings.TrimSuffix(content, "\n")
+	content = strings.ReplaceAll(content, "\n", "\r")
+
+	encoded, err := encodeText(content, movie.IsWindows())
+	if err != nil {
+		return fmt.Errorf("error converting text: %s", err)
+	}
+
+	data, err := chunks.ReplaceStxtText(text.Binary, encoded)
+	if err != nil {
+		return err
+	}
+
+	replace(text, data)
+
+	return nil
+}
+
+// encodeText puts UTF-8 text into the character set of the movie, which is
+// Windows-1252 for one saved on Windows and Mac Roman otherwise
+func encodeText(content string, windows bool) ([]byte, error) {
+	if windows {
+		encoded, err := charmap.Windows1252.NewEncoder().String(content)
+		if err != nil {
+			return nil, fmt.Errorf("text has characters Windows-1252 doesn't: %s", err)
+		}
+
+		return []byte(encoded), nil
+	}
+
+	encoded, err := macroman.ConvertUTF8ToMacRoman(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(encoded), nil
+}
+
+func write(movie *shockwave.Shockwave, filePath string, outputFolder string, patched []int32) error {
+	if outputFolder == "" {
+		outputFolder = filepath.Join(consts.PathDump, filepath.Base(filePath), "patched")
+	}
+
+	err := os.MkdirAll(outputFolder, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Base(filePath)
+	if movie.IsAfterburner() {
+		name = unprotect.OutputName(filePath, movie.Codec.Name)
+	}
+
+	outputFile := filepath.Join(outputFolder, name)
+
+	written, err := movie.WriteRIFXFile(outputFile)
+	if err != nil {
+		return err
+	}
+	defer written.Close()
+
+	// everything but the member that was patched has to come back as it was
+	err = movie.CompareResources(written, patched...)
+	if err != nil {
+		return fmt.Errorf("%s does not match %s: %s", outputFile, filePath, err)
+	}
+
+	utils.SuccessMsg("patch", "Wrote %s", outputFile)
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/markhughes/dirry/internal/chunks"
+	"github.com/markhughes/dirry/internal/shockwave"
+)
+
+type testResource struct {
+	tag  string
+	data []byte
+}
+
+// testMovie is a Director 7 movie with a field member 1 and its STXT, saved
+// on the given platform
+func testMovie(platform int16) []byte {
+	be := binary.BigEndian
+
+	var key bytes.Buffer
+	binary.Write(&key, be, []int16{12, 12})
+	binary.Write(&key, be, []int32{1, 1, 5, 4})
+	key.WriteString("STXT")
+
+	var cast bytes.Buffer
+	binary.Write(&cast, be, []int32{int32(chunks.StyledText), 0, 0})
+
+	var stxt bytes.Buffer
+	binary.Write(&stxt, be, []uint32{12, 3, 2 + 20})
+	stxt.WriteString("old")
+	binary.Write(&stxt, be, uint16(1))
+	stxt.Write(make([]byte, 20))
+
+	config := make([]byte, 60)
+	be.PutUint16(config[56:], uint16(platform))
+
+	resources := []testResource{
+		{"KEY*", key.Bytes()},
+		{"CASt", cast.Bytes()},
+		{"STXT", stxt.Bytes()},
+		{"VWCF", config},
+		{"CAS*", []byte{0, 0, 0, 4}},
+	}
+
+	count := 3 + len(resources)
+	imapOffset := 12
+	mmapOffset := imapOffset + 8 + 24
+	mmapLength := 24 + 20*count
+	offsets := make([]int, len(resources))
+	end := mmapOffset + 8 + mmapLength
+	for i, resource := range resources {
+		offsets[i] = end
+		end += 8 + len(resource.data)
+	}
+
+	var out bytes.Buffer
+	entry := func(tag string, length, offset int) {
+		out.WriteString(tag)
+		binary.Write(&out, be, []int32{int32(length), int32(offset)})
+		binary.Write(&out, be, []int16{0, 0})
+		binary.Write(&out, be, int32(0))
+	}
+
+	out.WriteString("RIFX")
+	binary.Write(&out, be, int32(end-8))
+	out.WriteString("MV93")
+
+	out.WriteString("imap")
+	binary.Write(&out, be, []int32{24, 1, int32(mmapOffset), 1224, 0, 0, 0})
+
+	out.WriteString("mmap")
+	binary.Write(&out, be, int32(mmapLength))
+	binary.Write(&out, be, []int16{24, 20})
+	binary.Write(&out, be, []int32{int32(count), int32(count), -1, -1, -1})
+	entry("RIFX", end-8, 0)
+	entry("imap", 24, imapOffset)
+	entry("mmap", mmapLength, mmapOffset)
+	for i, resource := range resources {
+		entry(resource.tag, len(resource.data), offsets[i])
+	}
+
+	for _, resource := range resources {
+		out.WriteString(resource.tag)
+		binary.Write(&out, be, int32(len(resource.data)))
+		out.Write(resource.data)
+	}
+
+	return out.Bytes()
+}
+
+func TestPatchText(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform int16
+		text     string
+	}{
+		{"mac", chunks.PlatformMac, "caf\x8e\rit\xd5s"},
+		{"windows", chunks.PlatformWindows, "caf\xe9\rit\x92s"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			moviePath := filepath.Join(dir, "movie.dir")
+			inputPath := filepath.Join(dir, "input.txt")
+			output := filepath.Join(dir, "patched")
+
+			if err := os.WriteFile(moviePath, testMovie(test.platform), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.WriteFile(inputPath, []byte("café\nit’s\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := Patch(moviePath, "1:1", inputPath, output); err != nil {
+				t.Fatalf("could not patch: %s", err)
+			}
+
+			var movie shockwave.Shockwave
+			if _, err := movie.Open(filepath.Join(output, "movie.dir")); err != nil {
+				t.Fatal(err)
+			}
+			defer movie.Close()
+
+			stxt := movie.ChunkMap.GetResourceById(5)
+			if stxt == nil {
+				t.Fatalf("patched movie has no STXT")
+			}
+
+			length := binary.BigEndian.Uint32(stxt.Binary[4:])
+			if text := string(stxt.Binary[12 : 12+length]); text != test.text {
+				t.Errorf("text is %q, want %q", text, test.text)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package pfr
 
 import (
+	"fmt"
+
 	"github.com/markhughes/dirry/internal/binary_reader"
 )
 
@@ -18,16 +20,20 @@ type PfrFont struct {
 	Header               PFRHeader
 	LogicalFontDirectory LogicalFontDirectory
 	LogicalFontSection   LogicalFontSection
+	PhysicalFonts        []*PhysicalFont
+
+	data []byte
 }
 
 func (chunk *PfrFont) Parse(data []byte) error {
-	// TODO: find or work on a seperate pfr convert utility
 	// https://web.archive.org/web/20040720143852/http://www.bitstream.com:80/categories/developer/truedoc/pfrspec1.2.pdf
 	reader, err := binary_reader.NewBinaryReader(data, -1)
 	if err != nil {
 		return err
 	}
 
+	chunk.data = data
+
 	err = chunk.parseHeader(reader)
 	if err != nil {
 		return err
@@ -43,5 +49,41 @@ func (chunk *PfrFont) Parse(data []byte) error {
 		return err
 	}
 
+	// logical fonts are sizes and styles of the physical fonts, which are
+	// often shared between them
+	seen := make(map[uint32]*PhysicalFont)
+	for _, logFont := range chunk.LogicalFontSection.LogFonts {
+		if _, ok := seen[logFont.PhysFontOffset]; ok {
+			continue
+		}
+
+		physFont, err := chunk.parsePhysicalFont(logFont.PhysFontOffset, logFont.PhysFontSize)
+		if err != nil {
+			return fmt.Errorf("error reading physical font at %d: %s", logFont.PhysFontOffset, err)
+		}
+
+		seen[logFont.PhysFontOffset] = physFont
+		chunk.PhysicalFonts = append(chunk.PhysicalFonts, physFont)
+	}
+
 	return nil
 }
+
+// readUInt24 reads the 24 bit sizes and offsets PFR uses everywhere
+func readUInt24(reader *binary_reader.BinaryReader) (uint32, error) {
+	b, err := reader.ReadBytes(3)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]), nil
+}
+
+func readInt24(reader *binary_reader.BinaryReader) (int32, error) {
+	value, err := readUInt24(reader)
+	if err != nil {
+		return 0, err
+	}
+
+	return int32(value<<8) >> 8, nil
+}
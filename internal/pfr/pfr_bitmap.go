@@ -0,0 +1,231 @@
+package pfr
+
+import (
+	"fmt"
+)
+
+// bitmap size record flags
+const (
+	strike3ByteCount  = 0x10
+	strike3ByteOffset = 0x08
+	strike3ByteSize   = 0x04
+	strike2ByteYPpm   = 0x02
+	strike2ByteXPpm   = 0x01
+)
+
+// bitmap character table flags
+const (
+	bitmap3ByteOffset   = 0x04
+	bitmap2ByteSize     = 0x02
+	bitmap2ByteCharCode = 0x01
+)
+
+// Strike is a set of bitmaps drawn for one size
+type Strike struct {
+	XPpm       uint16
+	YPpm       uint16
+	Flags      uint8
+	BctSize    uint32
+	BctOffset  uint32
+	NumBitmaps uint32
+
+	Bitmaps []*Bitmap `json:"-"`
+}
+
+// Bitmap is one character of a strike, Bits has a byte for each pixel from
+// the top row down, and the bottom left corner is at X and Y pixels from
+// the origin
+type Bitmap struct {
+	Code   uint16
+	X      int
+	Y      int
+	Width  int
+	Height int
+
+	// Advance is in 1/256ths of a pixel
+	Advance int
+	Bits    []byte
+}
+
+// parseBitmapInfo reads the bitmap size records of the bitmap info extra
+// item
+func parseBitmapInfo(c *cursor) []*Strike {
+	c.u24() // the size of the bitmap character tables
+	flags := c.u8()
+	count := c.u8()
+
+	strikes := make([]*Strike, 0, count)
+	for i := 0; i < count && c.err == nil; i++ {
+		strike := &Strike{}
+		strike.XPpm = uint16(c.short(flags&strike2ByteXPpm != 0))
+		strike.YPpm = uint16(c.short(flags&strike2ByteYPpm != 0))
+		strike.Flags = uint8(c.u8())
+		strike.BctSize = uint32(c.long(flags&strike3ByteSize != 0))
+		strike.BctOffset = uint32(c.long(flags&strike3ByteOffset != 0))
+		strike.NumBitmaps = uint32(c.long(flags&strike3ByteCount != 0))
+
+		strikes = append(strikes, strike)
+	}
+
+	return strikes
+}
+
+// loadStrike reads the bitmap character table of a strike and every bitmap
+// in it
+func (chunk *PfrFont) loadStrike(font *PhysicalFont, strike *Strike) error {
+	c := chunk.section(font.bctOffset+strike.BctOffset, strike.BctSize)
+
+	advances := make(map[uint16]int16)
+	for _, char := range font.Characters {
+		advances[char.Code] = char.Advance
+	}
+
+	for i := 0; i < int(strike.NumBitmaps) && c.err == nil; i++ {
+		code := uint16(c.short(strike.Flags&bitmap2ByteCharCode != 0))
+		size := c.short(strike.Flags&bitmap2ByteSize != 0)
+		offset := c.long(strike.Flags&bitmap3ByteOffset != 0)
+
+		if c.err != nil {
+			break
+		}
+
+		// the advance in pixels when the bitmap doesn't say
+		advance := 0
+		if font.MetricsResolution != 0 {
+			advance = int(advances[code]) * int(strike.XPpm) * 256 / int(font.MetricsResolution)
+		}
+
+		bitmap, err := chunk.loadBitmap(uint32(offset), uint32(size), advance)
+		if err != nil {
+			return fmt.Errorf("bitmap %d: %s", code, err)
+		}
+
+		bitmap.Code = code
+		strike.Bitmaps = append(strike.Bitmaps, bitmap)
+	}
+
+	return c.err
+}
+
+// loadBitmap reads a bitmap glyph program string, its position, size and
+// advance and then the image, as bits or in one of two run length encodings
+func (chunk *PfrFont) loadBitmap(offset uint32, size uint32, advance int) (*Bitmap, error) {
+	c := chunk.section(chunk.Header.GpsSectionOffset+offset, size)
+	bitmap := &Bitmap{Advance: advance}
+
+	flags := c.u8()
+
+	switch flags & 3 {
+	case 0:
+		b := c.u8()
+		bitmap.X = int(int8(b)) >> 4
+		bitmap.Y = int(int8(b<<4)) >> 4
+	case 1:
+		bitmap.X = c.i8()
+		bitmap.Y = c.i8()
+	case 2:
+		bitmap.X = c.i16()
+		bitmap.Y = c.i16()
+	case 3:
+		bitmap.X = c.i24()
+		bitmap.Y = c.i24()
+	}
+
+	switch (flags >> 2) & 3 {
+	case 1:
+		b := c.u8()
+		bitmap.Width = b >> 4
+		bitmap.Height = b & 0x0F
+	case 2:
+		bitmap.Width = c.u8()
+		bitmap.Height = c.u8()
+	case 3:
+		bitmap.Width = c.u16()
+		bitmap.Height = c.u16()
+	}
+
+	switch (flags >> 4) & 3 {
+	case 1:
+		bitmap.Advance = c.i8() * 256
+	case 2:
+		bitmap.Advance = c.i16()
+	case 3:
+		bitmap.Advance = c.i24()
+	}
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	data := c.bytes(c.left())
+	total := bitmap.Width * bitmap.Height
+
+	switch (flags >> 6) & 3 {
+	case 0:
+		bitmap.Bits = decodeBits(data, total)
+	case 1:
+		bitmap.Bits = decodeRle1(data, total)
+	case 2:
+		bitmap.Bits = decodeRle2(data, total)
+	default:
+		return nil, fmt.Errorf("unknown bitmap format 3")
+	}
+
+	// stored from the bottom row up
+	if chunk.Header.PfrInvertBitmap != 0 {
+		for top, bottom := 0, bitmap.Height-1; top < bottom; top, bottom = top+1, bottom-1 {
+			for x := 0; x < bitmap.Width; x++ {
+				a, b := top*bitmap.Width+x, bottom*bitmap.Width+x
+				bitmap.Bits[a], bitmap.Bits[b] = bitmap.Bits[b], bitmap.Bits[a]
+			}
+		}
+	}
+
+	return bitmap, nil
+}
+
+// decodeBits reads a bit for each pixel, the rows aren't padded
+func decodeBits(data []byte, total int) []byte {
+	bits := make([]byte, total)
+	for i := 0; i < total && i/8 < len(data); i++ {
+		bits[i] = (data[i/8] >> uint(7-i%8)) & 1
+	}
+
+	return bits
+}
+
+// decodeRle1 reads bytes of a run of white in the high nibble and a run of
+// black in the low one
+func decodeRle1(data []byte, total int) []byte {
+	bits := make([]byte, 0, total)
+	for _, b := range data {
+		for i := 0; i < int(b>>4); i++ {
+			bits = append(bits, 0)
+		}
+		for i := 0; i < int(b&0x0F); i++ {
+			bits = append(bits, 1)
+		}
+	}
+
+	return fitBits(bits, total)
+}
+
+// decodeRle2 reads bytes that are each a run, white and black in turn
+func decodeRle2(data []byte, total int) []byte {
+	bits := make([]byte, 0, total)
+	for i, b := range data {
+		for j := 0; j < int(b); j++ {
+			bits = append(bits, byte(i&1))
+		}
+	}
+
+	return fitBits(bits, total)
+}
+
+func fitBits(bits []byte, total int) []byte {
+	if len(bits) >= total {
+		return bits[:total]
+	}
+
+	return append(bits, make([]byte, total-len(bits))...)
+}
@@ -0,0 +1,310 @@
+package pfr
+
+import (
+	"fmt"
+)
+
+// glyph program string flags
+const (
+	glyphIsCompound   = 0x80
+	glyphExtraItems   = 0x08
+	glyph1ByteXYCount = 0x04
+	glyphXCount       = 0x02
+	glyphYCount       = 0x01
+
+	subglyph3ByteOffset = 0x80
+	subglyph2ByteSize   = 0x40
+	subglyphYScale      = 0x20
+	subglyphXScale      = 0x10
+)
+
+// compound glyphs can be made of compound glyphs, but not forever
+const maxGlyphDepth = 8
+
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Segment is a line, or a cubic curve when Cubic is set, to To
+type Segment struct {
+	Cubic bool
+	C1    Point
+	C2    Point
+	To    Point
+}
+
+type Contour struct {
+	Start    Point
+	Segments []Segment
+}
+
+// Outline is a glyph in the outline resolution of its physical font, with
+// the compound glyphs it was built from flattened into it
+type Outline struct {
+	Contours []Contour
+}
+
+// transform moves and scales an outline the way a compound glyph places a
+// part of itself
+func (outline *Outline) transform(xScale, yScale, xDelta, yDelta float64) {
+	apply := func(p *Point) {
+		p.X = p.X*xScale + xDelta
+		p.Y = p.Y*yScale + yDelta
+	}
+
+	for i := range outline.Contours {
+		contour := &outline.Contours[i]
+		apply(&contour.Start)
+
+		for j := range contour.Segments {
+			segment := &contour.Segments[j]
+			apply(&segment.C1)
+			apply(&segment.C2)
+			apply(&segment.To)
+		}
+	}
+}
+
+// loadGlyph reads the glyph program string at offset into the glyph
+// program string section
+func (chunk *PfrFont) loadGlyph(offset uint32, size uint32, depth int) (*Outline, error) {
+	if depth > maxGlyphDepth {
+		return nil, fmt.Errorf("compound glyphs nested more than %d deep", maxGlyphDepth)
+	}
+
+	c := chunk.section(chunk.Header.GpsSectionOffset+offset, size)
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	// a space or anything else with nothing to draw
+	if size == 0 {
+		return &Outline{}, nil
+	}
+
+	if c.data[0]&glyphIsCompound != 0 {
+		return chunk.loadCompoundGlyph(c, depth)
+	}
+
+	return loadSimpleGlyph(c)
+}
+
+func loadSimpleGlyph(c *cursor) (*Outline, error) {
+	flags := c.u8()
+
+	var xCount, yCount int
+	if flags&glyph1ByteXYCount != 0 {
+		count := c.u8()
+		xCount = count & 0x0F
+		yCount = count >> 4
+	} else {
+		if flags&glyphXCount != 0 {
+			xCount = c.u8()
+		}
+		if flags&glyphYCount != 0 {
+			yCount = c.u8()
+		}
+	}
+
+	// the controlled coordinates, the x ones and then the y ones, each
+	// either absolute or a delta from the one before it
+	controls := make([]int, xCount+yCount)
+	value := 0
+	mask := 0
+	for i := range controls {
+		if i%8 == 0 {
+			mask = c.u8()
+		}
+
+		if mask&1 != 0 {
+			value = c.i16()
+		} else {
+			value += c.u8()
+		}
+
+		controls[i] = value
+		mask >>= 1
+	}
+
+	xControls := controls[:xCount]
+	yControls := controls[xCount:]
+
+	// the secondary strokes and edges are only for hinting
+	if flags&glyphExtraItems != 0 {
+		c.extraItems()
+	}
+
+	outline := &Outline{}
+	var contour *Contour
+
+	var pos [4]Point
+	for c.err == nil {
+		format := c.u8()
+		formatLow := format & 0x0F
+
+		argsFormat := 0
+		argsCount := 0
+
+		switch format >> 4 {
+		case 0:
+			// end of the glyph
+		case 1, 4, 5:
+			// line to, move to inside contour and move to outside contour
+			argsFormat = formatLow
+			argsCount = 1
+		case 2:
+			// horizontal line to a controlled x
+			if formatLow >= xCount {
+				return nil, fmt.Errorf("x control %d of %d", formatLow, xCount)
+			}
+			pos[0] = Point{float64(xControls[formatLow]), pos[3].Y}
+			pos[3] = pos[0]
+		case 3:
+			// vertical line to a controlled y
+			if formatLow >= yCount {
+				return nil, fmt.Errorf("y control %d of %d", formatLow, yCount)
+			}
+			pos[0] = Point{pos[3].X, float64(yControls[formatLow])}
+			pos[3] = pos[0]
+		case 6:
+			// horizontal then vertical curve
+			argsFormat = 0xB8E
+			argsCount = 3
+		case 7:
+			// vertical then horizontal curve
+			argsFormat = 0xE2B
+			argsCount = 3
+		default:
+			argsFormat = formatLow
+			argsCount = 4
+		}
+
+		for n := 0; n < argsCount; n++ {
+			var point Point
+
+			switch argsFormat & 3 {
+			case 0:
+				index := c.u8()
+				if index >= xCount {
+					return nil, fmt.Errorf("x control %d of %d", index, xCount)
+				}
+				point.X = float64(xControls[index])
+			case 1:
+				point.X = float64(c.i16())
+			case 2:
+				point.X = pos[3].X + float64(c.i8())
+			default:
+				point.X = pos[3].X
+			}
+
+			switch (argsFormat >> 2) & 3 {
+			case 0:
+				index := c.u8()
+				if index >= yCount {
+					return nil, fmt.Errorf("y control %d of %d", index, yCount)
+				}
+				point.Y = float64(yControls[index])
+			case 1:
+				point.Y = float64(c.i16())
+			case 2:
+				point.Y = pos[3].Y + float64(c.i8())
+			default:
+				point.Y = pos[3].Y
+			}
+
+			// a general curve says how its other two points are stored
+			// after the first
+			if n == 0 && argsCount == 4 {
+				argsFormat = c.u8()
+				argsCount--
+			} else {
+				argsFormat >>= 4
+			}
+
+			pos[n] = point
+			pos[3] = point
+		}
+
+		if c.err != nil {
+			break
+		}
+
+		switch format >> 4 {
+		case 0:
+			return outline, nil
+		case 1, 2, 3:
+			if contour == nil {
+				return nil, fmt.Errorf("line before the first move")
+			}
+			contour.Segments = append(contour.Segments, Segment{To: pos[0]})
+		case 4, 5:
+			outline.Contours = append(outline.Contours, Contour{Start: pos[0]})
+			contour = &outline.Contours[len(outline.Contours)-1]
+		default:
+			if contour == nil {
+				return nil, fmt.Errorf("curve before the first move")
+			}
+			contour.Segments = append(contour.Segments, Segment{Cubic: true, C1: pos[0], C2: pos[1], To: pos[2]})
+		}
+	}
+
+	return nil, c.err
+}
+
+// loadCompoundGlyph reads the glyphs a compound glyph is made of, each
+// placed and scaled, and flattens them into one outline
+func (chunk *PfrFont) loadCompoundGlyph(c *cursor, depth int) (*Outline, error) {
+	flags := c.u8()
+	count := flags & 0x3F
+
+	if flags&glyphExtraItems != 0 {
+		c.extraItems()
+	}
+
+	outline := &Outline{}
+	for i := 0; i < count; i++ {
+		format := c.u8()
+
+		// scales are in 1/4096ths
+		xScale, yScale := 1.0, 1.0
+		if format&subglyphXScale != 0 {
+			xScale = float64(c.i16()) / 4096
+		}
+		if format&subglyphYScale != 0 {
+			yScale = float64(c.i16()) / 4096
+		}
+
+		var xDelta, yDelta int
+		switch format & 3 {
+		case 1:
+			xDelta = c.i16()
+		case 2:
+			xDelta = c.i8()
+		}
+
+		switch (format >> 2) & 3 {
+		case 1:
+			yDelta = c.i16()
+		case 2:
+			yDelta = c.i8()
+		}
+
+		size := c.short(format&subglyph2ByteSize != 0)
+		offset := c.long(format&subglyph3ByteOffset != 0)
+
+		if c.err != nil {
+			return nil, c.err
+		}
+
+		part, err := chunk.loadGlyph(uint32(offset), uint32(size), depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("part %d: %s", i, err)
+		}
+
+		part.transform(xScale, yScale, float64(xDelta), float64(yDelta))
+		outline.Contours = append(outline.Contours, part.Contours...)
+	}
+
+	return outline, c.err
+}
@@ -74,12 +74,12 @@ func (chunk *PfrFont) parseHeader(reader *binary_reader.BinaryReader) (err error
 		return err
 	}
 
-	chunk.Header.LogFontSectionSize, err = reader.ReadUInt32(binary.BigEndian)
+	chunk.Header.LogFontSectionSize, err = readUInt24(reader)
 	if err != nil {
 		return err
 	}
 
-	chunk.Header.LogFontSectionOffset, err = reader.ReadUInt32(binary.BigEndian)
+	chunk.Header.LogFontSectionOffset, err = readUInt24(reader)
 	if err != nil {
 		return err
 	}
@@ -89,12 +89,12 @@ func (chunk *PfrFont) parseHeader(reader *binary_reader.BinaryReader) (err error
 		return err
 	}
 
-	chunk.Header.PhysFontSectionSize, err = reader.ReadUInt32(binary.BigEndian)
+	chunk.Header.PhysFontSectionSize, err = readUInt24(reader)
 	if err != nil {
 		return err
 	}
 
-	chunk.Header.PhysFontSectionOffset, err = reader.ReadUInt32(binary.BigEndian)
+	chunk.Header.PhysFontSectionOffset, err = readUInt24(reader)
 	if err != nil {
 		return err
 	}
@@ -104,12 +104,12 @@ func (chunk *PfrFont) parseHeader(reader *binary_reader.BinaryReader) (err error
 		return err
 	}
 
-	chunk.Header.GpsSectionSize, err = reader.ReadUInt32(binary.BigEndian)
+	chunk.Header.GpsSectionSize, err = readUInt24(reader)
 	if err != nil {
 		return err
 	}
 
-	chunk.Header.GpsSectionOffset, err = reader.ReadUInt32(binary.BigEndian)
+	chunk.Header.GpsSectionOffset, err = readUInt24(reader)
 	if err != nil {
 		return err
 	}
@@ -134,32 +134,28 @@ func (chunk *PfrFont) parseHeader(reader *binary_reader.BinaryReader) (err error
 		return err
 	}
 
-	chunk.Header.Zeros, err = reader.ReadUInt8()
+	// 6 zero bits, then whether bitmaps are stored bottom up and whether a
+	// set bit is black
+	colorFlags, err := reader.ReadUInt8()
 	if err != nil {
 		return err
 	}
 
-	chunk.Header.PfrInvertBitmap, err = reader.ReadUInt8()
-	if err != nil {
-		return err
-	}
-
-	chunk.Header.PfrBlackPixel, err = reader.ReadUInt8()
-	if err != nil {
-		return err
-	}
+	chunk.Header.Zeros = colorFlags >> 2
+	chunk.Header.PfrInvertBitmap = (colorFlags >> 1) & 1
+	chunk.Header.PfrBlackPixel = colorFlags & 1
 
-	chunk.Header.BctMaxSize, err = reader.ReadUInt32(binary.BigEndian)
+	chunk.Header.BctMaxSize, err = readUInt24(reader)
 	if err != nil {
 		return err
 	}
 
-	chunk.Header.BctSetMaxSize, err = reader.ReadUInt32(binary.BigEndian)
+	chunk.Header.BctSetMaxSize, err = readUInt24(reader)
 	if err != nil {
 		return err
 	}
 
-	chunk.Header.PftBctSetMaxSize, err = reader.ReadUInt32(binary.BigEndian)
+	chunk.Header.PftBctSetMaxSize, err = readUInt24(reader)
 	if err != nil {
 		return err
 	}
@@ -27,13 +27,10 @@ func (chunk *PfrFont) parseLfd(offset uint16, reader *binary_reader.BinaryReader
 			return err
 		}
 
-		// Read 24-bit LogFontOffset manually
-		offsetBytes, err := reader.ReadBytes(3)
+		chunk.LogicalFontDirectory.Fonts[i].LogFontOffset, err = readUInt24(reader)
 		if err != nil {
 			return err
 		}
-
-		chunk.LogicalFontDirectory.Fonts[i].LogFontOffset = uint32(offsetBytes[0])<<16 | uint32(offsetBytes[1])<<8 | uint32(offsetBytes[2])
 	}
 
 	return nil
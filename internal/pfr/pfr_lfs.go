@@ -2,29 +2,38 @@ package pfr
 
 import (
 	"encoding/binary"
+	"io"
 
 	"github.com/markhughes/dirry/internal/binary_reader"
 )
 
+// logical font flags
+const (
+	logExtraItems  = 0x40
+	log2ByteBold   = 0x20
+	logBold        = 0x10
+	log2ByteStroke = 0x08
+	logStroke      = 0x04
+	lineJoinMask   = 0x03
+	lineJoinMiter  = 0x00
+)
+
+type ExtraItem struct {
+	Type uint8
+	Data []byte
+}
+
 type LogicalFontRecord struct {
-	fontMatrix                  [4]int32
-	zero                        uint8
-	extraItemsPresent           uint8
-	twoByteBoldThicknessValue   uint8
-	boldFlag                    uint8
-	twoByteStrokeThicknessValue uint8
-	strokeFlag                  uint8
-	lineJoinType                uint8
-	strokeThickness             int16
-	miterLimit                  int32
-	boldThickness               int16
-	nExtraItems                 uint8
-	extraItemSize               uint8
-	extraItemType               uint8
-	extraItemData               []uint8
-	physFontSize                uint16
-	physFontOffset              uint32
-	physFontSizeIncrement       uint8
+	// FontMatrix is the 2x2 matrix the physical font is drawn with, in
+	// 1/256ths of a unit
+	FontMatrix      [4]int32
+	Flags           uint8
+	StrokeThickness int16
+	MiterLimit      int32
+	BoldThickness   int16
+	ExtraItems      []ExtraItem
+	PhysFontSize    uint32
+	PhysFontOffset  uint32
 }
 
 type LogicalFontSection struct {
@@ -32,120 +41,117 @@ type LogicalFontSection struct {
 }
 
 func (chunk *PfrFont) parseLfs(offset uint32, reader *binary_reader.BinaryReader) (err error) {
-	reader.Seek(int64(offset), 0)
-
 	section := LogicalFontSection{}
 	section.LogFonts = make([]LogicalFontRecord, 0)
 
-	for i := 0; i < int(chunk.LogicalFontDirectory.NLogFonts); i++ {
-		record := LogicalFontRecord{}
-		record.fontMatrix[0], err = reader.ReadInt32(binary.BigEndian)
-		if err != nil {
-			return err
-		}
-		record.fontMatrix[1], err = reader.ReadInt32(binary.BigEndian)
-		if err != nil {
-			return err
-		}
-
-		record.fontMatrix[2], err = reader.ReadInt32(binary.BigEndian)
-		if err != nil {
-			return err
-		}
+	for _, font := range chunk.LogicalFontDirectory.Fonts {
+		reader.Seek(int64(font.LogFontOffset), io.SeekStart)
 
-		record.fontMatrix[3], err = reader.ReadInt32(binary.BigEndian)
-		if err != nil {
-			return err
+		record := LogicalFontRecord{}
+		for i := range record.FontMatrix {
+			record.FontMatrix[i], err = readInt24(reader)
+			if err != nil {
+				return err
+			}
 		}
 
-		record.zero, err = reader.ReadUInt8()
+		record.Flags, err = reader.ReadUInt8()
 		if err != nil {
 			return err
 		}
 
-		record.extraItemsPresent, err = reader.ReadUInt8()
-		if err != nil {
-			return err
-		}
+		if record.Flags&logStroke != 0 {
+			record.StrokeThickness, err = readThickness(reader, record.Flags&log2ByteStroke != 0)
+			if err != nil {
+				return err
+			}
 
-		record.twoByteBoldThicknessValue, err = reader.ReadUInt8()
-		if err != nil {
-			return err
+			if record.Flags&lineJoinMask == lineJoinMiter {
+				record.MiterLimit, err = readInt24(reader)
+				if err != nil {
+					return err
+				}
+			}
 		}
 
-		record.boldFlag, err = reader.ReadUInt8()
-		if err != nil {
-			return err
+		if record.Flags&logBold != 0 {
+			record.BoldThickness, err = readThickness(reader, record.Flags&log2ByteBold != 0)
+			if err != nil {
+				return err
+			}
 		}
 
-		record.twoByteStrokeThicknessValue, err = reader.ReadUInt8()
-		if err != nil {
-			return err
+		if record.Flags&logExtraItems != 0 {
+			record.ExtraItems, err = readExtraItems(reader)
+			if err != nil {
+				return err
+			}
 		}
 
-		record.strokeFlag, err = reader.ReadUInt8()
+		size, err := reader.ReadUInt16(binary.BigEndian)
 		if err != nil {
 			return err
 		}
+		record.PhysFontSize = uint32(size)
 
-		record.lineJoinType, err = reader.ReadUInt8()
+		record.PhysFontOffset, err = readUInt24(reader)
 		if err != nil {
 			return err
 		}
 
-		record.strokeThickness, err = reader.ReadInt16(binary.BigEndian)
-		if err != nil {
-			return err
-		}
+		// physical fonts over 64K have the high byte of the size here
+		if chunk.Header.PhysFontMaxSizeHighByte != 0 {
+			increment, err := reader.ReadUInt8()
+			if err != nil {
+				return err
+			}
 
-		record.miterLimit, err = reader.ReadInt32(binary.BigEndian)
-		if err != nil {
-			return err
+			record.PhysFontSize += uint32(increment) << 16
 		}
 
-		record.boldThickness, err = reader.ReadInt16(binary.BigEndian)
-		if err != nil {
-			return err
-		}
+		section.LogFonts = append(section.LogFonts, record)
+	}
 
-		record.nExtraItems, err = reader.ReadUInt8()
-		if err != nil {
-			return err
-		}
+	chunk.LogicalFontSection = section
+	return nil
+}
 
-		record.extraItemSize, err = reader.ReadUInt8()
-		if err != nil {
-			return err
-		}
+func readThickness(reader *binary_reader.BinaryReader, twoBytes bool) (int16, error) {
+	if twoBytes {
+		return reader.ReadInt16(binary.BigEndian)
+	}
 
-		record.extraItemType, err = reader.ReadUInt8()
-		if err != nil {
-			return err
-		}
+	value, err := reader.ReadUInt8()
+	return int16(value), err
+}
 
-		record.extraItemData, err = reader.ReadBytes(int(record.extraItemSize))
-		if err != nil {
-			return err
-		}
+// readExtraItems reads a count and that many items, each a size, a type
+// and its data
+func readExtraItems(reader *binary_reader.BinaryReader) ([]ExtraItem, error) {
+	count, err := reader.ReadUInt8()
+	if err != nil {
+		return nil, err
+	}
 
-		record.physFontSize, err = reader.ReadUInt16(binary.BigEndian)
+	items := make([]ExtraItem, 0, count)
+	for i := 0; i < int(count); i++ {
+		size, err := reader.ReadUInt8()
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		record.physFontOffset, err = reader.ReadUInt32(binary.BigEndian)
+		itemType, err := reader.ReadUInt8()
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		record.physFontSizeIncrement, err = reader.ReadUInt8()
+		data, err := reader.ReadBytes(int(size))
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		section.LogFonts = append(section.LogFonts, record)
+		items = append(items, ExtraItem{Type: itemType, Data: data})
 	}
 
-	chunk.LogicalFontSection = section
-	return nil
+	return items, nil
 }
@@ -0,0 +1,264 @@
+package pfr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// physical font flags
+const (
+	physExtraItems      = 0x80
+	phys3ByteGpsOffset  = 0x20
+	phys2ByteGpsSize    = 0x10
+	physAsciiCode       = 0x08
+	physProportional    = 0x04
+	phys2ByteCharCode   = 0x02
+	physVerticalEscapes = 0x01
+)
+
+// physical font extra item types
+const (
+	extraBitmapInfo = 1
+	extraFontId     = 2
+	extraStemSnaps  = 3
+	extraKerning    = 4
+)
+
+// auxiliary data types, not in the spec but FreeType found them
+const (
+	auxFamilyName = 1
+	auxMetrics    = 2
+	auxStyleName  = 3
+)
+
+type PhysicalFont struct {
+	FontRefNumber     uint16
+	OutlineResolution uint16
+	MetricsResolution uint16
+	XMin              int16
+	YMin              int16
+	XMax              int16
+	YMax              int16
+	Flags             uint8
+	StandardAdvance   int16
+
+	FontId     string
+	FamilyName string
+	StyleName  string
+	Ascent     int16
+	Descent    int16
+	Leading    int16
+
+	BlueValues         []int16
+	BlueFuzz           uint8
+	BlueScale          uint8
+	VerticalStandard   uint16
+	HorizontalStandard uint16
+	VerticalSnaps      []int16
+	HorizontalSnaps    []int16
+
+	Characters []*Character
+	Kerning    []KerningPair
+	Strikes    []*Strike
+
+	// the bitmap character tables follow the physical font record
+	bctOffset uint32
+}
+
+type Character struct {
+	Code      uint16
+	Advance   int16
+	AsciiCode uint8
+	GpsSize   uint32
+	GpsOffset uint32
+
+	Outline *Outline `json:"-"`
+}
+
+// KerningPair is in the metrics resolution like the advances
+type KerningPair struct {
+	Left       uint16
+	Right      uint16
+	Adjustment int16
+}
+
+func (chunk *PfrFont) parsePhysicalFont(offset uint32, size uint32) (*PhysicalFont, error) {
+	c := chunk.section(offset, size)
+	font := &PhysicalFont{bctOffset: offset + size}
+
+	font.FontRefNumber = uint16(c.u16())
+	font.OutlineResolution = uint16(c.u16())
+	font.MetricsResolution = uint16(c.u16())
+	font.XMin = int16(c.i16())
+	font.YMin = int16(c.i16())
+	font.XMax = int16(c.i16())
+	font.YMax = int16(c.i16())
+	font.Flags = uint8(c.u8())
+
+	if font.Flags&physProportional == 0 {
+		font.StandardAdvance = int16(c.i16())
+	}
+
+	if font.Flags&physExtraItems != 0 {
+		for _, item := range c.extraItems() {
+			chunk.parsePhysicalExtraItem(font, item)
+		}
+	}
+
+	font.parseAuxiliary(c.bytes(c.u24()))
+
+	blueValues := c.u8()
+	for i := 0; i < blueValues; i++ {
+		font.BlueValues = append(font.BlueValues, int16(c.i16()))
+	}
+
+	font.BlueFuzz = uint8(c.u8())
+	font.BlueScale = uint8(c.u8())
+	font.VerticalStandard = uint16(c.u16())
+	font.HorizontalStandard = uint16(c.u16())
+
+	count := c.u16()
+	for i := 0; i < count && c.err == nil; i++ {
+		char := &Character{}
+		char.Code = uint16(c.short(font.Flags&phys2ByteCharCode != 0))
+
+		char.Advance = font.StandardAdvance
+		if font.Flags&physProportional != 0 {
+			char.Advance = int16(c.i16())
+		}
+
+		if font.Flags&physAsciiCode != 0 {
+			char.AsciiCode = uint8(c.u8())
+		}
+
+		char.GpsSize = uint32(c.short(font.Flags&phys2ByteGpsSize != 0))
+		char.GpsOffset = uint32(c.long(font.Flags&phys3ByteGpsOffset != 0))
+
+		font.Characters = append(font.Characters, char)
+	}
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	failed := 0
+	for _, char := range font.Characters {
+		var err error
+		char.Outline, err = chunk.loadGlyph(char.GpsOffset, char.GpsSize, 0)
+		if err != nil {
+			utils.DebugMsg("pfr", "Could not read the outline of %d: %s", char.Code, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		utils.WarnMsg("pfr", "%d of %d outlines could not be read", failed, len(font.Characters))
+	}
+
+	for _, strike := range font.Strikes {
+		err := chunk.loadStrike(font, strike)
+		if err != nil {
+			utils.WarnMsg("pfr", "Could not read the %dx%d bitmap strike: %s", strike.XPpm, strike.YPpm, err)
+		}
+	}
+
+	return font, nil
+}
+
+func (chunk *PfrFont) parsePhysicalExtraItem(font *PhysicalFont, item ExtraItem) {
+	c := &cursor{data: item.Data}
+
+	switch item.Type {
+	case extraBitmapInfo:
+		font.Strikes = append(font.Strikes, parseBitmapInfo(c)...)
+
+	case extraFontId:
+		font.FontId = strings.TrimRight(string(item.Data), "\x00")
+
+	case extraStemSnaps:
+		count := c.u8()
+		for i := 0; i < count&0x0F; i++ {
+			font.VerticalSnaps = append(font.VerticalSnaps, int16(c.i16()))
+		}
+		for i := 0; i < count>>4; i++ {
+			font.HorizontalSnaps = append(font.HorizontalSnaps, int16(c.i16()))
+		}
+
+	case extraKerning:
+		// the pairs are offsets from a base adjustment
+		pairs := c.u8()
+		base := c.i16()
+		flags := c.u8()
+
+		for i := 0; i < pairs && c.err == nil; i++ {
+			pair := KerningPair{
+				Left:  uint16(c.short(flags&0x01 != 0)),
+				Right: uint16(c.short(flags&0x01 != 0)),
+			}
+
+			if flags&0x02 != 0 {
+				pair.Adjustment = int16(base + c.i16())
+			} else {
+				pair.Adjustment = int16(base + c.i8())
+			}
+
+			font.Kerning = append(font.Kerning, pair)
+		}
+
+	default:
+		utils.DebugMsg("pfr", "Skipping physical font extra item %d", item.Type)
+	}
+
+	if c.err != nil {
+		utils.WarnMsg("pfr", "Physical font extra item %d is truncated", item.Type)
+	}
+}
+
+// parseAuxiliary reads the names and metrics kept in the auxiliary data,
+// records of a length, a type and the data
+func (font *PhysicalFont) parseAuxiliary(aux []byte) {
+	for len(aux) >= 4 {
+		length := int(aux[0])<<8 | int(aux[1])
+		auxType := int(aux[2])<<8 | int(aux[3])
+		if length < 4 || length > len(aux) {
+			return
+		}
+
+		data := aux[4:length]
+		aux = aux[length:]
+
+		switch auxType {
+		case auxFamilyName:
+			font.FamilyName = auxName(data)
+		case auxStyleName:
+			font.StyleName = auxName(data)
+		case auxMetrics:
+			if len(data) >= 32 {
+				c := &cursor{data: data[10:]}
+				font.Ascent = int16(c.i16())
+				font.Descent = int16(c.i16())
+				font.Leading = int16(c.i16())
+			}
+		}
+	}
+}
+
+// auxName is a name padded to an even length with zeroes
+func auxName(data []byte) string {
+	return strings.TrimRight(string(data), "\x00")
+}
+
+// Name is the best name the font has for itself
+func (font *PhysicalFont) Name() string {
+	if font.FamilyName != "" {
+		return font.FamilyName
+	}
+
+	if font.FontId != "" {
+		return font.FontId
+	}
+
+	return fmt.Sprintf("PFR %d", font.FontRefNumber)
+}
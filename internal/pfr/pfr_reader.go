@@ -0,0 +1,118 @@
+package pfr
+
+import (
+	"fmt"
+)
+
+// cursor reads the packed records of physical fonts and glyph program
+// strings, where nearly every field is 1, 2 or 3 bytes depending on a flag.
+// It keeps the first error and reads zeroes after it.
+type cursor struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// section is a cursor over size bytes at offset of the font
+func (chunk *PfrFont) section(offset uint32, size uint32) *cursor {
+	c := &cursor{}
+
+	end := uint64(offset) + uint64(size)
+	if end > uint64(len(chunk.data)) {
+		c.err = fmt.Errorf("%d bytes at %d are past the end of the font", size, offset)
+		return c
+	}
+
+	c.data = chunk.data[offset:end]
+	return c
+}
+
+func (c *cursor) bytes(n int) []byte {
+	if c.err != nil || n < 0 || c.pos+n > len(c.data) {
+		if c.err == nil {
+			c.err = fmt.Errorf("truncated at %d reading %d bytes", c.pos, n)
+		}
+		return make([]byte, maxInt(n, 0))
+	}
+
+	out := c.data[c.pos : c.pos+n]
+	c.pos += n
+	return out
+}
+
+func (c *cursor) u8() int {
+	return int(c.bytes(1)[0])
+}
+
+func (c *cursor) i8() int {
+	return int(int8(c.bytes(1)[0]))
+}
+
+func (c *cursor) u16() int {
+	b := c.bytes(2)
+	return int(b[0])<<8 | int(b[1])
+}
+
+func (c *cursor) i16() int {
+	return int(int16(c.u16()))
+}
+
+func (c *cursor) u24() int {
+	b := c.bytes(3)
+	return int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+}
+
+func (c *cursor) i24() int {
+	return int(int32(c.u24()<<8) >> 8)
+}
+
+// short reads 2 bytes when wide is set and 1 when it isn't
+func (c *cursor) short(wide bool) int {
+	if wide {
+		return c.u16()
+	}
+
+	return c.u8()
+}
+
+// long reads 3 bytes when wide is set and 2 when it isn't
+func (c *cursor) long(wide bool) int {
+	if wide {
+		return c.u24()
+	}
+
+	return c.u16()
+}
+
+func (c *cursor) left() int {
+	return len(c.data) - c.pos
+}
+
+// extraItems reads a count and that many items, each a size, a type and its
+// data
+func (c *cursor) extraItems() []ExtraItem {
+	count := c.u8()
+
+	items := make([]ExtraItem, 0, count)
+	for i := 0; i < count && c.err == nil; i++ {
+		size := c.u8()
+		itemType := uint8(c.u8())
+		items = append(items, ExtraItem{Type: itemType, Data: c.bytes(size)})
+	}
+
+	return items
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
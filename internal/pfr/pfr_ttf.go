@@ -0,0 +1,912 @@
+package pfr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// https://learn.microsoft.com/en-us/typography/opentype/spec/otff
+
+// how far, in font units, a quadratic may stray from the cubic it replaces
+const curveTolerance = 0.5
+
+// ttfGlyph is a glyph as TrueType stores it, quadratic contours in whole
+// font units
+type ttfGlyph struct {
+	code     rune
+	pfrCode  uint16
+	advance  int
+	contours [][]ttfPoint
+	xMin     int
+	yMin     int
+	xMax     int
+	yMax     int
+}
+
+type ttfPoint struct {
+	x, y    int
+	onCurve bool
+}
+
+// TrueType converts the first physical font to a TrueType font, the outlines
+// with their cubic curves split into quadratic ones, the kerning pairs as a
+// kern table and the bitmap strikes as EBLC and EBDT
+func (chunk *PfrFont) TrueType() ([]byte, error) {
+	if len(chunk.PhysicalFonts) == 0 {
+		return nil, fmt.Errorf("no physical fonts")
+	}
+
+	font := chunk.PhysicalFonts[0]
+
+	unitsPerEm := int(font.OutlineResolution)
+	scale := 1.0
+	if unitsPerEm < 16 || unitsPerEm > 16384 {
+		scale = 2048 / float64(maxInt(unitsPerEm, 1))
+		unitsPerEm = 2048
+	}
+
+	// advances and kerning are in the metrics resolution
+	metricsScale := scale
+	if font.MetricsResolution != 0 {
+		metricsScale = float64(font.OutlineResolution) / float64(font.MetricsResolution) * scale
+	}
+
+	glyphs := []*ttfGlyph{{code: -1}}
+	seen := make(map[rune]bool)
+
+	for _, char := range font.Characters {
+		code := unicodeCode(char.Code)
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+
+		glyph := &ttfGlyph{
+			code:    code,
+			pfrCode: char.Code,
+			advance: int(math.Round(float64(char.Advance) * metricsScale)),
+		}
+
+		if char.Outline != nil {
+			glyph.contours = quadraticContours(char.Outline, scale)
+		}
+
+		glyph.bounds()
+		glyphs = append(glyphs, glyph)
+	}
+
+	sort.Slice(glyphs[1:], func(i, j int) bool { return glyphs[i+1].code < glyphs[j+1].code })
+
+	// .notdef gets the advance of a standard width font or half an em
+	glyphs[0].advance = int(math.Round(float64(font.StandardAdvance) * metricsScale))
+	if glyphs[0].advance <= 0 {
+		glyphs[0].advance = unitsPerEm / 2
+	}
+
+	glyphIndex := make(map[uint16]int)
+	for i, glyph := range glyphs[1:] {
+		glyphIndex[glyph.pfrCode] = i + 1
+	}
+
+	w := &ttfWriter{
+		font:       font,
+		glyphs:     glyphs,
+		glyphIndex: glyphIndex,
+		unitsPerEm: unitsPerEm,
+		scale:      scale,
+		kernScale:  metricsScale,
+		tables:     make(map[string][]byte),
+	}
+
+	return w.write(), nil
+}
+
+// unicodeCode is the Unicode for a character code, one byte codes are
+// Latin-1 like the rest of a movie's strings
+func unicodeCode(code uint16) rune {
+	if code < 0x100 {
+		return charmap.ISO8859_1.DecodeByte(byte(code))
+	}
+
+	return rune(code)
+}
+
+func (glyph *ttfGlyph) bounds() {
+	first := true
+	for _, contour := range glyph.contours {
+		for _, p := range contour {
+			if first {
+				glyph.xMin, glyph.xMax, glyph.yMin, glyph.yMax = p.x, p.x, p.y, p.y
+				first = false
+				continue
+			}
+
+			glyph.xMin = minInt(glyph.xMin, p.x)
+			glyph.xMax = maxInt(glyph.xMax, p.x)
+			glyph.yMin = minInt(glyph.yMin, p.y)
+			glyph.yMax = maxInt(glyph.yMax, p.y)
+		}
+	}
+}
+
+func (glyph *ttfGlyph) points() int {
+	count := 0
+	for _, contour := range glyph.contours {
+		count += len(contour)
+	}
+
+	return count
+}
+
+// quadraticContours turns an outline into TrueType contours, which go
+// clockwise around what's filled whichever way the PFR went
+func quadraticContours(outline *Outline, scale float64) [][]ttfPoint {
+	contours := make([][]ttfPoint, 0, len(outline.Contours))
+
+	for _, contour := range outline.Contours {
+		points := []ttfPoint{roundPoint(contour.Start, scale, true)}
+		last := contour.Start
+
+		for _, segment := range contour.Segments {
+			if segment.Cubic {
+				for _, quad := range cubicToQuadratics(last, segment.C1, segment.C2, segment.To, 0) {
+					points = append(points, roundPoint(quad[0], scale, false), roundPoint(quad[1], scale, true))
+				}
+			} else {
+				points = append(points, roundPoint(segment.To, scale, true))
+			}
+
+			last = segment.To
+		}
+
+		// the contour closes itself
+		if len(points) > 1 && points[len(points)-1] == points[0] {
+			points = points[:len(points)-1]
+		}
+
+		if len(points) >= 2 {
+			contours = append(contours, points)
+		}
+	}
+
+	// the largest contour is an outside one, whichever way it goes is the
+	// way every outside contour goes
+	largest := 0.0
+	for _, contour := range contours {
+		if area := signedArea(contour); math.Abs(area) > math.Abs(largest) {
+			largest = area
+		}
+	}
+
+	if largest > 0 {
+		for i, contour := range contours {
+			reversed := []ttfPoint{contour[0]}
+			for j := len(contour) - 1; j > 0; j-- {
+				reversed = append(reversed, contour[j])
+			}
+			contours[i] = reversed
+		}
+	}
+
+	return contours
+}
+
+func roundPoint(p Point, scale float64, onCurve bool) ttfPoint {
+	return ttfPoint{int(math.Round(p.X * scale)), int(math.Round(p.Y * scale)), onCurve}
+}
+
+// signedArea is positive for a contour that goes anticlockwise
+func signedArea(contour []ttfPoint) float64 {
+	area := 0.0
+	for i, p := range contour {
+		q := contour[(i+1)%len(contour)]
+		area += float64(p.x*q.y - q.x*p.y)
+	}
+
+	return area / 2
+}
+
+// cubicToQuadratics splits a cubic curve until a quadratic, the control
+// point and end of each returned, is close enough to each part
+func cubicToQuadratics(p0, c1, c2, p3 Point, depth int) [][2]Point {
+	control := Point{
+		X: (3*(c1.X+c2.X) - p0.X - p3.X) / 4,
+		Y: (3*(c1.Y+c2.Y) - p0.Y - p3.Y) / 4,
+	}
+
+	// the furthest the two curves get from each other
+	dx := p3.X - 3*c2.X + 3*c1.X - p0.X
+	dy := p3.Y - 3*c2.Y + 3*c1.Y - p0.Y
+	errorDistance := math.Sqrt(3) / 36 * math.Hypot(dx, dy)
+
+	if errorDistance <= curveTolerance || depth >= 6 {
+		return [][2]Point{{control, p3}}
+	}
+
+	mid := func(a, b Point) Point { return Point{(a.X + b.X) / 2, (a.Y + b.Y) / 2} }
+	ab, bc, cd := mid(p0, c1), mid(c1, c2), mid(c2, p3)
+	abc, bcd := mid(ab, bc), mid(bc, cd)
+	middle := mid(abc, bcd)
+
+	return append(cubicToQuadratics(p0, ab, abc, middle, depth+1), cubicToQuadratics(middle, bcd, cd, p3, depth+1)...)
+}
+
+type ttfWriter struct {
+	font       *PhysicalFont
+	glyphs     []*ttfGlyph
+	glyphIndex map[uint16]int
+	unitsPerEm int
+	scale      float64
+	kernScale  float64
+
+	tables map[string][]byte
+
+	xMin, yMin, xMax, yMax int
+}
+
+func (w *ttfWriter) write() []byte {
+	w.writeGlyf()
+	w.writeHead()
+	w.writeHhea()
+	w.writeMaxp()
+	w.writeOS2()
+	w.writeCmap()
+	w.writeName()
+	w.writePost()
+	w.writeKern()
+	w.writeBitmaps()
+
+	return w.assemble()
+}
+
+// put writes big endian values, each has to be one of the sized types
+func put(buf *bytes.Buffer, values ...interface{}) {
+	for _, value := range values {
+		binary.Write(buf, binary.BigEndian, value)
+	}
+}
+
+func (w *ttfWriter) writeGlyf() {
+	var glyf, loca bytes.Buffer
+
+	first := true
+	for _, glyph := range w.glyphs {
+		put(&loca, uint32(glyf.Len()))
+
+		if len(glyph.contours) == 0 {
+			continue
+		}
+
+		if first {
+			w.xMin, w.yMin, w.xMax, w.yMax = glyph.xMin, glyph.yMin, glyph.xMax, glyph.yMax
+			first = false
+		}
+		w.xMin = minInt(w.xMin, glyph.xMin)
+		w.yMin = minInt(w.yMin, glyph.yMin)
+		w.xMax = maxInt(w.xMax, glyph.xMax)
+		w.yMax = maxInt(w.yMax, glyph.yMax)
+
+		put(&glyf, int16(len(glyph.contours)), int16(glyph.xMin), int16(glyph.yMin), int16(glyph.xMax), int16(glyph.yMax))
+
+		end := -1
+		for _, contour := range glyph.contours {
+			end += len(contour)
+			put(&glyf, uint16(end))
+		}
+
+		put(&glyf, uint16(0)) // no instructions
+
+		// each coordinate is a delta from the one before, a byte and a sign
+		// flag when it fits and nothing at all when it's the same
+		var flags, xs, ys bytes.Buffer
+		var lastX, lastY int
+		for _, contour := range glyph.contours {
+			for _, p := range contour {
+				var flag byte
+				if p.onCurve {
+					flag |= 0x01
+				}
+
+				flag |= delta(&xs, p.x-lastX, 0x02, 0x10)
+				flag |= delta(&ys, p.y-lastY, 0x04, 0x20)
+				flags.WriteByte(flag)
+
+				lastX, lastY = p.x, p.y
+			}
+		}
+
+		glyf.Write(flags.Bytes())
+		glyf.Write(xs.Bytes())
+		glyf.Write(ys.Bytes())
+
+		for glyf.Len()%4 != 0 {
+			glyf.WriteByte(0)
+		}
+	}
+
+	put(&loca, uint32(glyf.Len()))
+
+	w.tables["glyf"] = glyf.Bytes()
+	w.tables["loca"] = loca.Bytes()
+
+	var hmtx bytes.Buffer
+	for _, glyph := range w.glyphs {
+		put(&hmtx, uint16(maxInt(glyph.advance, 0)), int16(glyph.xMin))
+	}
+	w.tables["hmtx"] = hmtx.Bytes()
+}
+
+// delta writes a coordinate delta and returns the flags that say how
+func delta(buf *bytes.Buffer, d int, short byte, same byte) byte {
+	switch {
+	case d == 0:
+		return same
+	case d > 0 && d <= 255:
+		buf.WriteByte(byte(d))
+		return short | same
+	case d < 0 && d >= -255:
+		buf.WriteByte(byte(-d))
+		return short
+	}
+
+	put(buf, int16(d))
+	return 0
+}
+
+func (w *ttfWriter) ascent() (ascent int, descent int, lineGap int) {
+	if w.font.Ascent != 0 || w.font.Descent != 0 {
+		// FreeType reads these as they are, in outline units
+		ascent = int(math.Round(float64(w.font.Ascent) * w.scale))
+		descent = -int(math.Round(math.Abs(float64(w.font.Descent)) * w.scale))
+		lineGap = int(math.Round(float64(w.font.Leading) * w.scale))
+		return
+	}
+
+	return int(math.Round(float64(w.font.YMax) * w.scale)), int(math.Round(float64(w.font.YMin) * w.scale)), 0
+}
+
+func (w *ttfWriter) isBold() bool {
+	return strings.Contains(strings.ToLower(w.font.StyleName), "bold")
+}
+
+func (w *ttfWriter) isItalic() bool {
+	style := strings.ToLower(w.font.StyleName)
+	return strings.Contains(style, "italic") || strings.Contains(style, "oblique")
+}
+
+func (w *ttfWriter) writeHead() {
+	var macStyle uint16
+	if w.isBold() {
+		macStyle |= 0x01
+	}
+	if w.isItalic() {
+		macStyle |= 0x02
+	}
+
+	var head bytes.Buffer
+	put(&head,
+		uint32(0x00010000), // version
+		uint32(0x00010000), // fontRevision
+		uint32(0),          // checkSumAdjustment, set once the font is put together
+		uint32(0x5F0F3CF5), // magicNumber
+		uint16(0x000B),     // baseline and left sidebearing at 0, integer scaling
+		uint16(w.unitsPerEm),
+		int64(0), int64(0), // created and modified
+		int16(w.xMin), int16(w.yMin), int16(w.xMax), int16(w.yMax),
+		macStyle,
+		uint16(8), // lowestRecPPEM
+		int16(2),  // fontDirectionHint
+		int16(1),  // indexToLocFormat, long offsets
+		int16(0),  // glyphDataFormat
+	)
+
+	w.tables["head"] = head.Bytes()
+}
+
+func (w *ttfWriter) writeHhea() {
+	ascent, descent, lineGap := w.ascent()
+
+	advanceMax, minLsb, minRsb, maxExtent := 0, 0, 0, 0
+	first := true
+	for _, glyph := range w.glyphs {
+		advanceMax = maxInt(advanceMax, glyph.advance)
+		if len(glyph.contours) == 0 {
+			continue
+		}
+
+		lsb := glyph.xMin
+		rsb := glyph.advance - glyph.xMax
+		if first {
+			minLsb, minRsb = lsb, rsb
+			first = false
+		}
+
+		minLsb = minInt(minLsb, lsb)
+		minRsb = minInt(minRsb, rsb)
+		maxExtent = maxInt(maxExtent, glyph.xMax)
+	}
+
+	var hhea bytes.Buffer
+	put(&hhea,
+		uint32(0x00010000),
+		int16(ascent), int16(descent), int16(lineGap),
+		uint16(advanceMax), int16(minLsb), int16(minRsb), int16(maxExtent),
+		int16(1), int16(0), int16(0), // caret slope rise, run and offset
+		int16(0), int16(0), int16(0), int16(0), // reserved
+		int16(0), // metricDataFormat
+		uint16(len(w.glyphs)),
+	)
+
+	w.tables["hhea"] = hhea.Bytes()
+}
+
+func (w *ttfWriter) writeMaxp() {
+	maxPoints, maxContours := 0, 0
+	for _, glyph := range w.glyphs {
+		maxPoints = maxInt(maxPoints, glyph.points())
+		maxContours = maxInt(maxContours, len(glyph.contours))
+	}
+
+	var maxp bytes.Buffer
+	put(&maxp,
+		uint32(0x00010000),
+		uint16(len(w.glyphs)),
+		uint16(maxPoints), uint16(maxContours),
+		uint16(0), uint16(0), // composite points and contours
+		uint16(2),                       // maxZones
+		uint16(0), uint16(0), uint16(0), // twilight points, storage and function defs
+		uint16(0), uint16(0), uint16(0), // instruction defs, stack elements and instruction size
+		uint16(0), uint16(0), // component elements and depth
+	)
+
+	w.tables["maxp"] = maxp.Bytes()
+}
+
+func (w *ttfWriter) writeOS2() {
+	ascent, descent, lineGap := w.ascent()
+	em := w.unitsPerEm
+
+	total, counted := 0, 0
+	firstChar, lastChar := 0xFFFF, 0
+	for _, glyph := range w.glyphs[1:] {
+		if glyph.advance > 0 {
+			total += glyph.advance
+			counted++
+		}
+		firstChar = minInt(firstChar, int(glyph.code))
+		lastChar = maxInt(lastChar, minInt(int(glyph.code), 0xFFFF))
+	}
+
+	average := 0
+	if counted > 0 {
+		average = total / counted
+	}
+
+	weight := uint16(400)
+	selection := uint16(0)
+	if w.isBold() {
+		weight = 700
+		selection |= 0x20
+	}
+	if w.isItalic() {
+		selection |= 0x01
+	}
+	if selection == 0 {
+		selection = 0x40
+	}
+
+	var os2 bytes.Buffer
+	put(&os2,
+		uint16(4), int16(average), weight,
+		uint16(5), uint16(0), // medium width, installable
+	)
+
+	// subscript, superscript and strikeout sizes and positions
+	put(&os2,
+		int16(em*65/100), int16(em*60/100), int16(0), int16(em*14/100),
+		int16(em*65/100), int16(em*60/100), int16(0), int16(em*48/100),
+		int16(em*5/100), int16(em*26/100),
+	)
+
+	// no family class or panose, Basic Latin and Latin-1 Supplement
+	put(&os2,
+		int16(0), [10]byte{},
+		uint32(0x0003), uint32(0), uint32(0), uint32(0),
+		[4]byte{' ', ' ', ' ', ' '},
+		selection,
+		uint16(firstChar), uint16(lastChar),
+		int16(ascent), int16(descent), int16(lineGap),
+		uint16(maxInt(ascent, w.yMax)), uint16(maxInt(-descent, -w.yMin)),
+	)
+
+	// the Latin 1 code page, no x or cap height, a space to break on
+	put(&os2,
+		uint32(0x0001), uint32(0),
+		int16(0), int16(0),
+		uint16(0), uint16(' '), uint16(0),
+	)
+
+	w.tables["OS/2"] = os2.Bytes()
+}
+
+// writeCmap maps Unicode to the glyphs with a format 4 subtable, a segment
+// for each run of characters, for both the Unicode and Windows platforms
+func (w *ttfWriter) writeCmap() {
+	type segment struct {
+		start, end int
+		delta      int
+	}
+
+	segments := make([]segment, 0)
+	for i, glyph := range w.glyphs[1:] {
+		code := int(glyph.code)
+		if code > 0xFFFE {
+			continue
+		}
+
+		gid := i + 1
+		// a run of codes with a run of glyphs stays one segment
+		if n := len(segments); n > 0 && segments[n-1].end == code-1 && code+segments[n-1].delta == gid {
+			segments[n-1].end = code
+			continue
+		}
+
+		segments = append(segments, segment{start: code, end: code, delta: gid - code})
+	}
+	segments = append(segments, segment{start: 0xFFFF, end: 0xFFFF, delta: 1})
+
+	count := len(segments)
+	searchRange, entrySelector := 1, 0
+	for searchRange*2 <= count {
+		searchRange *= 2
+		entrySelector++
+	}
+	searchRange *= 2
+
+	var sub bytes.Buffer
+	put(&sub, uint16(4), uint16(16+count*8), uint16(0))
+	put(&sub, uint16(count*2), uint16(searchRange), uint16(entrySelector), uint16(count*2-searchRange))
+	for _, s := range segments {
+		put(&sub, uint16(s.end))
+	}
+	put(&sub, uint16(0))
+	for _, s := range segments {
+		put(&sub, uint16(s.start))
+	}
+	for _, s := range segments {
+		put(&sub, uint16(s.delta&0xFFFF))
+	}
+	for range segments {
+		put(&sub, uint16(0))
+	}
+
+	var cmap bytes.Buffer
+	put(&cmap, uint16(0), uint16(2))
+	put(&cmap, uint16(0), uint16(3), uint32(20))
+	put(&cmap, uint16(3), uint16(1), uint32(20))
+	cmap.Write(sub.Bytes())
+
+	w.tables["cmap"] = cmap.Bytes()
+}
+
+func (w *ttfWriter) writeName() {
+	family := w.font.Name()
+	style := w.font.StyleName
+	if style == "" {
+		style = "Regular"
+	}
+
+	full := family
+	if style != "Regular" {
+		full += " " + style
+	}
+
+	postScript := strings.Map(func(r rune) rune {
+		if r <= ' ' || r >= 0x7F || strings.ContainsRune("[](){}<>/%", r) {
+			return -1
+		}
+		return r
+	}, family+"-"+style)
+
+	names := []struct {
+		id    uint16
+		value string
+	}{
+		{1, family},
+		{2, style},
+		{3, "dirry: " + full},
+		{4, full},
+		{5, "Version 1.0"},
+		{6, postScript},
+	}
+
+	type record struct {
+		platform, encoding, language, id uint16
+		data                             []byte
+	}
+
+	records := make([]record, 0)
+	for _, name := range names {
+		// Mac Roman for the Mac, anything it can't say left out
+		mac := make([]byte, 0, len(name.value))
+		for _, r := range name.value {
+			if r < 0x80 {
+				mac = append(mac, byte(r))
+			}
+		}
+		records = append(records, record{1, 0, 0, name.id, mac})
+	}
+	for _, name := range names {
+		var utf16be bytes.Buffer
+		for _, unit := range utf16.Encode([]rune(name.value)) {
+			put(&utf16be, unit)
+		}
+		records = append(records, record{3, 1, 0x0409, name.id, utf16be.Bytes()})
+	}
+
+	var name, strs bytes.Buffer
+	put(&name, uint16(0), uint16(len(records)), uint16(6+12*len(records)))
+	for _, r := range records {
+		put(&name, r.platform, r.encoding, r.language, r.id, uint16(len(r.data)), uint16(strs.Len()))
+		strs.Write(r.data)
+	}
+	name.Write(strs.Bytes())
+
+	w.tables["name"] = name.Bytes()
+}
+
+func (w *ttfWriter) writePost() {
+	isFixedPitch := uint32(0)
+	if w.font.Flags&physProportional == 0 {
+		isFixedPitch = 1
+	}
+
+	var post bytes.Buffer
+	put(&post,
+		uint32(0x00030000), // no glyph names
+		int32(0),           // italicAngle
+		int16(-w.unitsPerEm/10), int16(w.unitsPerEm/20),
+		isFixedPitch,
+		uint32(0), uint32(0), uint32(0), uint32(0),
+	)
+
+	w.tables["post"] = post.Bytes()
+}
+
+// writeKern writes the kerning pairs as a version 0 kern table with one
+// format 0 subtable
+func (w *ttfWriter) writeKern() {
+	type pair struct {
+		left, right int
+		value       int
+	}
+
+	pairs := make([]pair, 0, len(w.font.Kerning))
+	for _, kern := range w.font.Kerning {
+		left, ok1 := w.glyphIndex[kern.Left]
+		right, ok2 := w.glyphIndex[kern.Right]
+		if !ok1 || !ok2 || kern.Adjustment == 0 {
+			continue
+		}
+
+		pairs = append(pairs, pair{left, right, int(math.Round(float64(kern.Adjustment) * w.kernScale))})
+	}
+
+	if len(pairs) == 0 {
+		return
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].left<<16|pairs[i].right < pairs[j].left<<16|pairs[j].right
+	})
+
+	searchRange, entrySelector := 1, 0
+	for searchRange*2 <= len(pairs) {
+		searchRange *= 2
+		entrySelector++
+	}
+
+	var kern bytes.Buffer
+	put(&kern, uint16(0), uint16(1))
+	put(&kern, uint16(0), uint16(14+6*len(pairs)), uint16(0x0001))
+	put(&kern, uint16(len(pairs)), uint16(searchRange*6), uint16(entrySelector), uint16((len(pairs)-searchRange)*6))
+	for _, p := range pairs {
+		put(&kern, uint16(p.left), uint16(p.right), int16(p.value))
+	}
+
+	w.tables["kern"] = kern.Bytes()
+}
+
+// writeBitmaps writes the bitmap strikes as EBLC and EBDT, each strike one
+// index subtable of format 1 over every glyph and the images in format 2,
+// small metrics and bits with no padding between the rows
+func (w *ttfWriter) writeBitmaps() {
+	strikes := make([]*Strike, 0)
+	for _, strike := range w.font.Strikes {
+		if len(strike.Bitmaps) > 0 {
+			strikes = append(strikes, strike)
+		}
+	}
+
+	if len(strikes) == 0 {
+		return
+	}
+
+	var ebdt bytes.Buffer
+	put(&ebdt, uint32(0x00020000))
+
+	firstGlyph, lastGlyph := 1, len(w.glyphs)-1
+	if lastGlyph < firstGlyph {
+		return
+	}
+
+	var sizes, subtables bytes.Buffer
+	subtablesStart := 8 + 48*len(strikes)
+
+	for _, strike := range strikes {
+		bitmaps := make(map[int]*Bitmap)
+		for _, bitmap := range strike.Bitmaps {
+			if gid, ok := w.glyphIndex[bitmap.Code]; ok && bitmap.Width <= 255 && bitmap.Height <= 255 {
+				bitmaps[gid] = bitmap
+			}
+		}
+
+		imageStart := ebdt.Len()
+		offsets := make([]uint32, 0, lastGlyph-firstGlyph+2)
+
+		metrics := &lineMetrics{}
+		for gid := firstGlyph; gid <= lastGlyph; gid++ {
+			offsets = append(offsets, uint32(ebdt.Len()-imageStart))
+
+			bitmap, ok := bitmaps[gid]
+			if !ok {
+				continue
+			}
+
+			bearingY := bitmap.Y + bitmap.Height
+			advance := (bitmap.Advance + 128) / 256
+			put(&ebdt, uint8(bitmap.Height), uint8(bitmap.Width), int8(clampInt8(bitmap.X)), int8(clampInt8(bearingY)), uint8(clampUint8(advance)))
+			metrics.add(bitmap.X, bearingY, bitmap.Width, bitmap.Height, advance)
+
+			packed := make([]byte, (len(bitmap.Bits)+7)/8)
+			for i, bit := range bitmap.Bits {
+				if bit != 0 {
+					packed[i/8] |= 0x80 >> uint(i%8)
+				}
+			}
+			ebdt.Write(packed)
+		}
+		offsets = append(offsets, uint32(ebdt.Len()-imageStart))
+
+		// the IndexSubTableArray with one entry, and the subtable after it
+		arrayOffset := subtablesStart + subtables.Len()
+		var subtable bytes.Buffer
+		put(&subtable, uint16(firstGlyph), uint16(lastGlyph), uint32(8))
+		put(&subtable, uint16(1), uint16(2), uint32(imageStart))
+		for _, offset := range offsets {
+			put(&subtable, offset)
+		}
+		subtables.Write(subtable.Bytes())
+
+		put(&sizes, uint32(arrayOffset), uint32(subtable.Len()), uint32(1), uint32(0))
+		sizes.Write(metrics.bytes())
+		sizes.Write(metrics.bytes())
+		put(&sizes, uint16(firstGlyph), uint16(lastGlyph), uint8(clampUint8(int(strike.XPpm))), uint8(clampUint8(int(strike.YPpm))), uint8(1), int8(0x01))
+	}
+
+	var eblc bytes.Buffer
+	put(&eblc, uint32(0x00020000), uint32(len(strikes)))
+	eblc.Write(sizes.Bytes())
+	eblc.Write(subtables.Bytes())
+
+	w.tables["EBLC"] = eblc.Bytes()
+	w.tables["EBDT"] = ebdt.Bytes()
+}
+
+// lineMetrics is the sbitLineMetrics of a strike
+type lineMetrics struct {
+	ascender, descender, widthMax    int
+	minOriginSB, minAdvanceSB        int
+	maxBeforeBL, minAfterBL, counted int
+}
+
+func (m *lineMetrics) add(x, bearingY, width, height, advance int) {
+	if m.counted == 0 {
+		m.ascender, m.descender = bearingY, bearingY-height
+		m.minOriginSB, m.minAdvanceSB = x, advance-x-width
+		m.maxBeforeBL, m.minAfterBL = bearingY, bearingY-height
+	}
+
+	m.ascender = maxInt(m.ascender, bearingY)
+	m.descender = minInt(m.descender, bearingY-height)
+	m.widthMax = maxInt(m.widthMax, width)
+	m.minOriginSB = minInt(m.minOriginSB, x)
+	m.minAdvanceSB = minInt(m.minAdvanceSB, advance-x-width)
+	m.maxBeforeBL = maxInt(m.maxBeforeBL, bearingY)
+	m.minAfterBL = minInt(m.minAfterBL, bearingY-height)
+	m.counted++
+}
+
+func (m *lineMetrics) bytes() []byte {
+	var buf bytes.Buffer
+	put(&buf,
+		int8(clampInt8(m.ascender)), int8(clampInt8(m.descender)), uint8(clampUint8(m.widthMax)),
+		int8(1), int8(0), int8(0), // caret slope numerator, denominator and offset
+		int8(clampInt8(m.minOriginSB)), int8(clampInt8(m.minAdvanceSB)),
+		int8(clampInt8(m.maxBeforeBL)), int8(clampInt8(m.minAfterBL)),
+		int8(0), int8(0),
+	)
+
+	return buf.Bytes()
+}
+
+func clampInt8(v int) int {
+	return maxInt(-128, minInt(127, v))
+}
+
+func clampUint8(v int) int {
+	return maxInt(0, minInt(255, v))
+}
+
+// assemble puts the tables together behind the table directory, each on a
+// 4 byte boundary, and sets the checksum adjustment in head
+func (w *ttfWriter) assemble() []byte {
+	tags := make([]string, 0, len(w.tables))
+	for tag := range w.tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	count := len(tags)
+	searchRange, entrySelector := 1, 0
+	for searchRange*2 <= count {
+		searchRange *= 2
+		entrySelector++
+	}
+
+	var out bytes.Buffer
+	put(&out, uint32(0x00010000), uint16(count), uint16(searchRange*16), uint16(entrySelector), uint16((count-searchRange)*16))
+
+	offset := 12 + 16*count
+	headOffset := 0
+	for _, tag := range tags {
+		table := w.tables[tag]
+		if tag == "head" {
+			headOffset = offset
+		}
+
+		out.WriteString(tag)
+		put(&out, checksum(table), uint32(offset), uint32(len(table)))
+		offset += (len(table) + 3) &^ 3
+	}
+
+	for _, tag := range tags {
+		out.Write(w.tables[tag])
+		for out.Len()%4 != 0 {
+			out.WriteByte(0)
+		}
+	}
+
+	font := out.Bytes()
+	binary.BigEndian.PutUint32(font[headOffset+8:], 0xB1B0AFBA-checksum(font))
+
+	return font
+}
+
+func checksum(table []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(table); i += 4 {
+		var word [4]byte
+		copy(word[:], table[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+
+	return sum
+}
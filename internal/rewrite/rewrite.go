@@ -0,0 +1,82 @@
+package rewrite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/markhughes/dirry/internal/consts"
+	"github.com/markhughes/dirry/internal/shockwave"
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// Rewrite reads a movie or cast and writes it straight back out into
+// outputFolder, or the dump folder when it's empty. With verify the written
+// file is read again and has to have the same resources as the original.
+func Rewrite(filePath string, outputFolder string, verify bool) error {
+	return rewrite(filePath, outputFolder, verify, "", 0)
+}
+
+func rewrite(filePath string, outputFolder string, verify bool, pkg string, extraOffset int64) error {
+	var movie shockwave.Shockwave
+	movie.PkgName = pkg
+	movie.DirOffset = extraOffset
+
+	expanded, err := movie.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("error opening file: %s", err)
+	}
+	defer movie.Close()
+
+	if len(expanded) > 0 {
+		utils.InfoMsg("rewrite", "Expanded %s to %d files\n", filePath, len(expanded))
+
+		for i := range expanded {
+			if expanded[i].Xtra {
+				continue
+			}
+
+			err := rewrite(expanded[i].Path, outputFolder, verify, filepath.Base(filePath), expanded[i].MinusOffset)
+			if err != nil {
+				utils.ErrorMsg("rewrite", "Error rewriting %s: %s", expanded[i].Path, err)
+			}
+		}
+
+		return nil
+	}
+
+	if outputFolder == "" {
+		if pkg == "" {
+			outputFolder = filepath.Join(consts.PathDump, filepath.Base(filePath), "rewritten")
+		} else {
+			outputFolder = filepath.Join(consts.PathDump, pkg, "file", filepath.Base(filePath), "rewritten")
+		}
+	}
+
+	err = os.MkdirAll(outputFolder, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	outputFile := filepath.Join(outputFolder, filepath.Base(filePath))
+
+	written, err := movie.WriteRIFXFile(outputFile)
+	if err != nil {
+		return err
+	}
+	defer written.Close()
+
+	if !verify {
+		utils.SuccessMsg("rewrite", "Wrote %s", outputFile)
+		return nil
+	}
+
+	err = movie.CompareResources(written)
+	if err != nil {
+		return fmt.Errorf("%s does not match %s: %s", outputFile, filePath, err)
+	}
+
+	utils.SuccessMsg("rewrite", "Wrote %s, all %d resources match", outputFile, len(written.ChunkMap.GetAllResources()))
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package shockwave
+
+import (
+	"github.com/markhughes/dirry/internal/chunks"
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// the KEY* keys a cast library's chunks to 1024 + its index, movies without
+// a MCsL only have the internal one
+const internalCastLibResourceId = 1024
+
+// CastMember is where a member sits in the cast libraries, members refer to
+// each other this way, e.g. a bitmap to its palette
+type CastMember struct {
+	CastLib int
+	Number  int
+}
+
+// CastLibs lists the cast libraries from the MCsL. Older movies don't have
+// one so their single internal library is made up from the config.
+func (shockwave *Shockwave) CastLibs() []chunks.CastLib {
+	for _, resource := range shockwave.ChunkMap.GetResourcesByTag("MCsL") {
+		reader, err := resource.GetReader()
+		if err != nil {
+			utils.WarnMsg("shockwave", "Error getting reader for MCsL resource: %s", err)
+			continue
+		}
+
+		mcsl, err := chunks.ReadMcslChunkRaw(reader, shockwave.Endian, shockwave.IsAfterburner())
+		if err != nil {
+			utils.WarnMsg("shockwave", "Error reading MCsL chunk: %s", err)
+			continue
+		}
+
+		return mcsl.CastLibs
+	}
+
+	internal := chunks.CastLib{
+		Name:        "Internal",
+		LibId:       1,
+		StorageType: 1,
+		MinMember:   1,
+		ResourceId:  internalCastLibResourceId,
+	}
+
+	for _, tag := range []string{"VWCF", "DRCF"} {
+		for _, resource := range shockwave.ChunkMap.GetResourcesByTag(tag) {
+			reader, err := resource.GetReader()
+			if err != nil {
+				continue
+			}
+
+			config, err := chunks.ReadInfoChunkRaw(reader, shockwave.Endian, shockwave.IsAfterburner())
+			if err != nil || config.CastListStart <= 0 {
+				continue
+			}
+
+			internal.MinMember = config.CastListStart
+			internal.MaxMember = config.CastListEnd
+			internal.ItemCount = config.CastListEnd - config.CastListStart + 1
+		}
+	}
+
+	return []chunks.CastLib{internal}
+}
+
+// CastMembers maps every CASt resource id to its library and member number,
+// which is its position in the CAS* of the library it is keyed to
+func (shockwave *Shockwave) CastMembers() map[int32]CastMember {
+	members := make(map[int32]CastMember)
+
+	libs := shockwave.CastLibs()
+	byResource := make(map[int32]chunks.CastLib)
+	for _, lib := range libs {
+		byResource[lib.ResourceId] = lib
+	}
+
+	for _, resource := range shockwave.ChunkMap.GetResourcesByTag("CAS*") {
+		lib, ok := byResource[resource.CastId]
+		if !ok {
+			if len(libs) != 1 {
+				utils.WarnMsg("shockwave", "No cast library for CAS* %d keyed to %d", resource.ResourceId, resource.CastId)
+				continue
+			}
+
+			// nothing to tell the libraries apart
+			lib = libs[0]
+		}
+
+		reader, err := resource.GetReader()
+		if err != nil {
+			utils.WarnMsg("shockwave", "Error getting reader for CAS* resource: %s", err)
+			continue
+		}
+
+		caschunk, err := chunks.ReadCasChunkRaw(reader, shockwave.Endian, shockwave.IsAfterburner())
+		if err != nil {
+			utils.WarnMsg("shockwave", "Error reading CAS* chunk: %s", err)
+			continue
+		}
+
+		minMember := int(lib.MinMember)
+		if minMember <= 0 {
+			minMember = 1
+		}
+
+		for i, entry := range caschunk.Entries {
+			if entry.Index == 0 {
+				continue
+			}
+
+			members[entry.Index] = CastMember{CastLib: lib.LibId, Number: minMember + i}
+		}
+	}
+
+	return members
+}
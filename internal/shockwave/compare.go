@@ -0,0 +1,51 @@
+package shockwave
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// CompareResources checks every resource of the movie is in other with the
+// same id, type, owner and payload. The container chunks aren't compared as
+// their offsets change whenever a movie is written, and neither are the
+// ignored ids, which are the ones meant to have changed.
+func (shockwave *Shockwave) CompareResources(other *Shockwave, ignore ...int32) error {
+	problems := make([]string, 0)
+
+	ignored := make(map[int32]bool, len(ignore))
+	for _, id := range ignore {
+		ignored[id] = true
+	}
+
+	compared := 0
+	for _, resource := range shockwave.ChunkMap.GetAllResources() {
+		if containerChunks[resource.ChunkType] && !(resource.ChunkType == "KEY*" && shockwave.Mmap != nil) {
+			continue
+		}
+
+		if ignored[resource.ResourceId] {
+			continue
+		}
+
+		found := other.ChunkMap.GetResourceById(resource.ResourceId)
+		switch {
+		case found == nil:
+			problems = append(problems, fmt.Sprintf("%s %d is missing", resource.ChunkType, resource.ResourceId))
+		case found.ChunkType != resource.ChunkType:
+			problems = append(problems, fmt.Sprintf("%s %d is now a %s", resource.ChunkType, resource.ResourceId, found.ChunkType))
+		case found.CastId != resource.CastId:
+			problems = append(problems, fmt.Sprintf("%s %d was keyed to %d and is now keyed to %d", resource.ChunkType, resource.ResourceId, resource.CastId, found.CastId))
+		case !bytes.Equal(found.Binary, resource.Binary):
+			problems = append(problems, fmt.Sprintf("%s %d has %d bytes that differ from the %d read", resource.ChunkType, resource.ResourceId, len(found.Binary), len(resource.Binary)))
+		default:
+			compared++
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%d of %d resources differ: %s", len(problems), compared+len(problems), strings.Join(problems, ", "))
+	}
+
+	return nil
+}
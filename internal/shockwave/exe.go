@@ -9,7 +9,6 @@ import (
 	"os"
 	"path"
 	"path/filepath"
-	"regexp"
 	"strings"
 
 	"github.com/markhughes/dirry/internal/consts"
@@ -38,20 +37,63 @@ func (shockwave *Shockwave) ExtractExe(fBytes []byte) (shockwavePath []Shockwave
 
 	var outFiles = make([]ShockwaveFile, 0)
 
+	// projectors say where their image ends and, mostly, where the movies are
+	if ne, err := readNE(fBytes); err == nil {
+		outFiles, err = shockwave.extractNE(fBytes, ne)
+		if err == nil {
+			return outFiles, nil
+		}
+
+		utils.WarnMsg("exe", "Could not extract the 16 bit projector, searching it instead: %s", err)
+	} else if executable, err := readPE(fBytes); err == nil {
+		outFiles, err = shockwave.extractPE(fBytes, executable)
+		if err == nil {
+			return outFiles, nil
+		}
+
+		utils.WarnMsg("exe", "Could not extract the projector, searching it instead: %s", err)
+	}
+
 	// Find the APPL/LPPA file
-	winFile := regexp.MustCompile(`XFIR.{4}LPPA`).FindIndex(fBytes)
-	macFile := regexp.MustCompile(`RIFX.{4}APPL`).FindIndex(fBytes)
-
-	var off int
-	if winFile != nil {
-		off = winFile[0]
-	} else if macFile != nil {
-		off = macFile[0]
-	} else {
-		// TODO: seems like D4 EXEs of a NE (not PE) are a little different.. not sure how to handle this
+	off := findApplication(fBytes)
+	if off < 0 {
 		return outFiles, fmt.Errorf("not a director application")
 	}
 
+	return shockwave.extractApplication(fBytes, off)
+}
+
+// findApplication is the offset of the first RIFX application in the file,
+// -1 when there isn't one
+func findApplication(fBytes []byte) int {
+	for _, tag := range []string{"XFIR", "RIFX"} {
+		codec := "APPL"
+		if tag == "XFIR" {
+			codec = "LPPA"
+		}
+
+		for i := 0; ; {
+			found := bytes.Index(fBytes[i:], []byte(tag))
+			if found < 0 {
+				break
+			}
+
+			off := i + found
+			if off+12 <= len(fBytes) && string(fBytes[off+8:off+12]) == codec {
+				return off
+			}
+			i = off + 1
+		}
+	}
+
+	return -1
+}
+
+// extractApplication writes out the files of the RIFX application a
+// projector carries at off, the movies come back to be opened
+func (shockwave *Shockwave) extractApplication(fBytes []byte, off int) (shockwavePath []ShockwaveFile, err error) {
+	var outFiles = make([]ShockwaveFile, 0)
+
 	utils.InfoMsg("exe", "Confirmed Director file at %d", off)
 	f := bytes.NewReader(fBytes[off:])
 
@@ -206,11 +248,17 @@ func (shockwave *Shockwave) ExtractExe(fBytes []byte) (shockwavePath []Shockwave
 		}
 
 		for i := range dictReader {
+			if i >= len(files) {
+				utils.WarnMsg("exe", "Dict names %d files but there are only %d", len(dictReader), len(files))
+				break
+			}
+
 			var currentFile = files[i]
 			var currentPath = dictReader[i]
 
 			var directory = filepath.Join(consts.PathDump, filepath.Base(shockwave.FilePath), "extracted")
-			if strings.Contains(currentPath, ".x32") || strings.Contains(currentPath, ".x16") {
+			var xtra = isXtra(currentPath)
+			if xtra {
 				directory = filepath.Join(directory, "Xtras")
 			}
 			os.MkdirAll(directory, os.ModePerm)
@@ -218,7 +266,7 @@ func (shockwave *Shockwave) ExtractExe(fBytes []byte) (shockwavePath []Shockwave
 			var file = filepath.Join(directory, path.Base(strings.ReplaceAll(currentPath, "\\", "/")))
 			ioutil.WriteFile(file, currentFile.Content, 0644)
 
-			fmt.Printf("Found file: %s at %d\n", file, int64(currentFile.Offset)+int64(off))
+			utils.InfoMsg("exe", "Found file: %s at %d", file, int64(currentFile.Offset)+int64(off))
 
 			var sfile = ShockwaveFile{
 				Path:        file,
@@ -228,20 +276,14 @@ func (shockwave *Shockwave) ExtractExe(fBytes []byte) (shockwavePath []Shockwave
 			if bytes.HasPrefix(currentFile.Content, []byte("RIFX")) || bytes.HasPrefix(currentFile.Content, []byte("XFIR")) {
 				outFiles = append(outFiles, sfile)
 
+			} else if xtra {
+				sfile.Xtra = true
+				outFiles = append(outFiles, sfile)
 			}
 		}
 
 	}
 
-	for i := range resources {
-		var directory = filepath.Join(consts.PathDump, filepath.Base(shockwave.FilePath), "exe_resources")
-		os.MkdirAll(directory, os.ModePerm)
-
-		var file = filepath.Join(directory, fmt.Sprintf("%d_%s", i, resources[i].Tag))
-		ioutil.WriteFile(file, resources[i].Content, 0644)
-
-	}
-
 	if len(outFiles) == 0 {
 		return outFiles, fmt.Errorf("no Director files found")
 	}
@@ -250,6 +292,17 @@ func (shockwave *Shockwave) ExtractExe(fBytes []byte) (shockwavePath []Shockwave
 
 }
 
+// isXtra is whether a file a projector carries is code rather than a movie,
+// Xtras from Director 5 and XObjects before that
+func isXtra(path string) bool {
+	switch strings.ToLower(filepath.Ext(strings.ReplaceAll(path, "\\", "/"))) {
+	case ".x32", ".x16", ".dll":
+		return true
+	}
+
+	return false
+}
+
 func parseDict(data []byte, endian binary.ByteOrder) ([]string, error) {
 	var err error
 	r := bytes.NewReader(data[8:])
@@ -0,0 +1,216 @@
+package shockwave
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// neExecutable is what's needed of a 16 bit Windows executable to know where
+// its image ends, projectors put everything of Director's after it
+type neExecutable struct {
+	HeaderOffset int
+	Segments     []neSegment
+	Resources    []neResource
+
+	// End is the first byte past the image, the rest is the overlay
+	End int
+}
+
+type neSegment struct {
+	Offset int
+	Length int
+	Flags  uint16
+}
+
+type neResource struct {
+	Type   string
+	Id     string
+	Offset int
+	Length int
+}
+
+// the segment has relocations straight after its data
+const neSegmentRelocations = 0x0100
+
+var neResourceTypes = map[uint16]string{
+	1:  "CURSOR",
+	2:  "BITMAP",
+	3:  "ICON",
+	4:  "MENU",
+	5:  "DIALOG",
+	6:  "STRING",
+	7:  "FONTDIR",
+	8:  "FONT",
+	9:  "ACCELERATOR",
+	10: "RCDATA",
+	12: "GROUP_CURSOR",
+	14: "GROUP_ICON",
+	16: "VERSION",
+}
+
+func readNE(b []byte) (*neExecutable, error) {
+	if len(b) < 0x40 || b[0] != 'M' || b[1] != 'Z' {
+		return nil, fmt.Errorf("not an MZ executable")
+	}
+
+	headerOffset := int(binary.LittleEndian.Uint32(b[0x3C:]))
+	if headerOffset <= 0 || headerOffset+0x40 > len(b) || string(b[headerOffset:headerOffset+2]) != "NE" {
+		return nil, fmt.Errorf("not an NE executable")
+	}
+
+	header := b[headerOffset:]
+	le := binary.LittleEndian
+
+	ne := &neExecutable{HeaderOffset: headerOffset}
+
+	segmentCount := int(le.Uint16(header[0x1C:]))
+	nonResidentSize := int(le.Uint16(header[0x20:]))
+	segmentTable := headerOffset + int(le.Uint16(header[0x22:]))
+	resourceTable := headerOffset + int(le.Uint16(header[0x24:]))
+	residentNames := headerOffset + int(le.Uint16(header[0x26:]))
+	nonResidentNames := int(le.Uint32(header[0x2C:]))
+
+	shift := uint(le.Uint16(header[0x32:]))
+	if shift == 0 {
+		shift = 9
+	}
+
+	ne.End = residentNames
+	if nonResidentSize > 0 {
+		ne.End = maxInt(ne.End, nonResidentNames+nonResidentSize)
+	}
+
+	if segmentTable+segmentCount*8 > len(b) {
+		return nil, fmt.Errorf("segment table is outside the file")
+	}
+
+	for i := 0; i < segmentCount; i++ {
+		entry := b[segmentTable+i*8:]
+
+		sector := int(le.Uint16(entry))
+		length := int(le.Uint16(entry[2:]))
+		flags := le.Uint16(entry[4:])
+
+		// no sector means there's nothing stored for it
+		if sector == 0 {
+			continue
+		}
+
+		if length == 0 {
+			length = 0x10000
+		}
+
+		segment := neSegment{Offset: sector << shift, Length: length, Flags: flags}
+		ne.Segments = append(ne.Segments, segment)
+
+		end := segment.Offset + segment.Length
+		if flags&neSegmentRelocations != 0 && end+2 <= len(b) {
+			end += 2 + int(le.Uint16(b[end:]))*8
+		}
+		ne.End = maxInt(ne.End, end)
+	}
+
+	// the resource table is empty when it runs straight into the names
+	if resourceTable < residentNames {
+		resources, end, err := readNEResources(b, resourceTable)
+		if err != nil {
+			return nil, err
+		}
+
+		ne.Resources = resources
+		ne.End = maxInt(ne.End, end)
+	}
+
+	if ne.End > len(b) {
+		return nil, fmt.Errorf("image ends at %d, past the end of the file", ne.End)
+	}
+
+	return ne, nil
+}
+
+// readNEResources lists the resources and where the last of them ends
+func readNEResources(b []byte, table int) ([]neResource, int, error) {
+	le := binary.LittleEndian
+	resources := make([]neResource, 0)
+
+	if table+2 > len(b) {
+		return nil, 0, fmt.Errorf("resource table is outside the file")
+	}
+
+	shift := uint(le.Uint16(b[table:]))
+	end := 0
+
+	pos := table + 2
+	for {
+		if pos+2 > len(b) {
+			return nil, 0, fmt.Errorf("resource table runs past the end of the file")
+		}
+
+		typeId := le.Uint16(b[pos:])
+		if typeId == 0 {
+			break
+		}
+
+		if pos+8 > len(b) {
+			return nil, 0, fmt.Errorf("resource type runs past the end of the file")
+		}
+
+		count := int(le.Uint16(b[pos+2:]))
+		typeName := neResourceName(b, table, typeId, neResourceTypes)
+		pos += 8
+
+		if pos+count*12 > len(b) {
+			return nil, 0, fmt.Errorf("%s resources run past the end of the file", typeName)
+		}
+
+		for i := 0; i < count; i++ {
+			entry := b[pos:]
+
+			resource := neResource{
+				Type:   typeName,
+				Id:     neResourceName(b, table, le.Uint16(entry[6:]), nil),
+				Offset: int(le.Uint16(entry)) << shift,
+				Length: int(le.Uint16(entry[2:])) << shift,
+			}
+
+			resources = append(resources, resource)
+			end = maxInt(end, resource.Offset+resource.Length)
+			pos += 12
+		}
+	}
+
+	return resources, end, nil
+}
+
+// neResourceName is a number when the top bit is set and otherwise points to
+// a pascal string from the start of the resource table
+func neResourceName(b []byte, table int, id uint16, known map[uint16]string) string {
+	if id&0x8000 != 0 {
+		if name, ok := known[id&0x7fff]; ok {
+			return name
+		}
+		return fmt.Sprint(id & 0x7fff)
+	}
+
+	pos := table + int(id)
+	if pos >= len(b) || pos+1+int(b[pos]) > len(b) {
+		return fmt.Sprint(id)
+	}
+
+	return string(b[pos+1 : pos+1+int(b[pos])])
+}
+
+// extractNE finds the movies of a Director 4 Windows 3.1 projector and the
+// XObjects that go with them
+func (shockwave *Shockwave) extractNE(fBytes []byte, ne *neExecutable) ([]ShockwaveFile, error) {
+	utils.InfoMsg("exe", "NE executable with %d segments and %d resources, image ends at %d", len(ne.Segments), len(ne.Resources), ne.End)
+
+	embedded := make([]embeddedData, 0, len(ne.Resources))
+	for _, resource := range ne.Resources {
+		embedded = append(embedded, embeddedData{Name: resource.Type + "_" + resource.Id, Offset: resource.Offset})
+	}
+
+	return shockwave.extractOverlay(fBytes, ne.End, len(fBytes), embedded)
+}
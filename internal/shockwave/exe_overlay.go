@@ -0,0 +1,422 @@
+package shockwave
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/markhughes/dirry/internal/consts"
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// projectorHeader is the header a Windows projector ends with, the last four
+// bytes of the file point to it. Director 4 writes PJ93, 5 and 6 write PJ95
+// and 7 onwards PJ00 or PJ01, the later ones stored backwards.
+type projectorHeader struct {
+	Tag           string
+	Offset        int
+	RifxOffset    uint32
+	FontMapOffset uint32
+	Flags         uint32
+
+	// Libraries are the DLLs the header points to, by the name they are
+	// written out as
+	Libraries []projectorLibrary
+}
+
+type projectorLibrary struct {
+	Name   string
+	Offset uint32
+}
+
+// the length of each header, the tag included
+var projectorHeaderLengths = map[string]int{
+	"PJ93": 36,
+	"PJ95": 36,
+	"PJ00": 28,
+	"PJ01": 28,
+}
+
+// embeddedData is somewhere other than the overlay a projector could keep a
+// movie or a library, such as a resource of the executable
+type embeddedData struct {
+	Name   string
+	Offset int
+}
+
+// readProjectorHeader reads the header the last four bytes before end point
+// to, it has to be between start and end
+func readProjectorHeader(b []byte, start, end int) (*projectorHeader, error) {
+	if end > len(b) || end-start < 4 {
+		return nil, fmt.Errorf("nothing after the image")
+	}
+
+	le := binary.LittleEndian
+
+	off := int(le.Uint32(b[end-4:]))
+	if off < start || off+4 > end {
+		return nil, fmt.Errorf("no projector header")
+	}
+
+	tag := string(b[off : off+4])
+	if _, ok := projectorHeaderLengths[tag]; !ok {
+		tag = string(utils.ReverseBytes([]byte(tag)))
+	}
+
+	length, ok := projectorHeaderLengths[tag]
+	if !ok {
+		return nil, fmt.Errorf("unknown projector header %q", string(b[off:off+4]))
+	}
+
+	if off+length > end {
+		return nil, fmt.Errorf("%s header at %d runs past the end of the file", tag, off)
+	}
+
+	header := b[off:]
+	projector := &projectorHeader{
+		Tag:        tag,
+		Offset:     off,
+		RifxOffset: le.Uint32(header[4:]),
+	}
+
+	switch tag {
+	case "PJ93":
+		// then two resource forks and the offset of the RIFX again
+		projector.FontMapOffset = le.Uint32(header[8:])
+		projector.Flags = le.Uint32(header[32:])
+		projector.Libraries = []projectorLibrary{
+			{"graphics.dll", le.Uint32(header[20:])},
+			{"sound.dll", le.Uint32(header[24:])},
+		}
+
+	case "PJ95":
+		// then the projector flags, the stage rect and the component and
+		// driver counts
+		projector.Flags = le.Uint32(header[12:])
+		projector.FontMapOffset = le.Uint32(header[32:])
+
+	case "PJ00", "PJ01":
+		// four unknowns and then the runtime the projector loads
+		if dll := le.Uint32(header[24:]); dll != 0 {
+			projector.Libraries = []projectorLibrary{{fmt.Sprintf("library_%d.dll", dll), dll}}
+		}
+	}
+
+	return projector, nil
+}
+
+// extractOverlay finds the movies and libraries a projector keeps between
+// start and end, past its own image. With a projector header the RIFX it
+// points to has to be there, without one the overlay is searched.
+func (shockwave *Shockwave) extractOverlay(fBytes []byte, start, end int, embedded []embeddedData) ([]ShockwaveFile, error) {
+	outFiles := make([]ShockwaveFile, 0)
+
+	// libraries already written, by offset, so the search skips over them
+	libraries := make(map[int]int)
+
+	projector, err := readProjectorHeader(fBytes, start, end)
+	if err == nil {
+		utils.InfoMsg("exe", "Found %s projector header at %d", projector.Tag, projector.Offset)
+
+		for _, library := range projector.Libraries {
+			if library.Offset == 0 {
+				continue
+			}
+
+			file, length, err := shockwave.extractLibrary(fBytes, int(library.Offset), library.Name)
+			if err != nil {
+				utils.WarnMsg("exe", "Could not extract %s: %s", library.Name, err)
+				continue
+			}
+
+			libraries[int(library.Offset)] = length
+			outFiles = append(outFiles, file)
+		}
+
+		files, err := shockwave.extractContainer(fBytes, int(projector.RifxOffset))
+		if err != nil {
+			return nil, err
+		}
+
+		outFiles = append(outFiles, files...)
+	} else {
+		utils.DebugMsg("exe", "No projector header: %s", err)
+	}
+
+	// a projector can carry more than one movie, anything else after the
+	// image or in a resource could be a movie or a library
+	candidates := []int{}
+	for i := start; i+12 <= end; i++ {
+		if length, ok := libraries[i]; ok {
+			i += length - 1
+			continue
+		}
+
+		if fBytes[i] == 'M' && fBytes[i+1] == 'Z' {
+			file, length, err := shockwave.extractLibrary(fBytes, i, fmt.Sprintf("library_%d.dll", i))
+			if err == nil {
+				outFiles = append(outFiles, file)
+				i += length - 1
+			}
+			continue
+		}
+
+		if !isContainerTag(fBytes[i : i+4]) {
+			continue
+		}
+
+		// the movies of an application are inside it, skip over them
+		length := containerLength(fBytes, i)
+		if length < 0 {
+			continue
+		}
+
+		if projector == nil || i != int(projector.RifxOffset) {
+			candidates = append(candidates, i)
+		}
+		i += 8 + length - 1
+	}
+
+	for _, data := range embedded {
+		if data.Offset+12 > len(fBytes) {
+			continue
+		}
+
+		b := fBytes[data.Offset:]
+		if isContainerTag(b[:4]) {
+			candidates = append(candidates, data.Offset)
+		} else if b[0] == 'M' && b[1] == 'Z' {
+			file, _, err := shockwave.extractLibrary(fBytes, data.Offset, data.Name+".dll")
+			if err == nil {
+				outFiles = append(outFiles, file)
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		files, err := shockwave.extractContainer(fBytes, candidate)
+		if err != nil {
+			utils.DebugMsg("exe", "Nothing at %d: %s", candidate, err)
+			continue
+		}
+
+		outFiles = append(outFiles, files...)
+	}
+
+	movies := 0
+	for _, file := range outFiles {
+		if !file.Xtra {
+			movies++
+		}
+	}
+
+	if movies == 0 {
+		return nil, fmt.Errorf("no Director files found after the image")
+	}
+
+	return outFiles, nil
+}
+
+func isContainerTag(tag []byte) bool {
+	switch string(tag) {
+	case "RIFX", "XFIR":
+		return true
+	}
+
+	return false
+}
+
+// the name a container a projector carries on its own is written out as
+var containerNames = map[string]string{
+	"MV93": "movie_%d.dir",
+	"MC95": "cast_%d.cst",
+	"FGDM": "movie_%d.dcr",
+	"FGDC": "cast_%d.cct",
+}
+
+// extractContainer handles a RIFX in a projector, either the application
+// with the movies and Xtras in it or a movie on its own
+func (shockwave *Shockwave) extractContainer(fBytes []byte, off int) ([]ShockwaveFile, error) {
+	if off < 0 || off+12 > len(fBytes) || !isContainerTag(fBytes[off:off+4]) {
+		return nil, fmt.Errorf("no RIFX at %d", off)
+	}
+
+	var endian binary.ByteOrder = binary.BigEndian
+	if string(fBytes[off:off+4]) == "XFIR" {
+		endian = binary.LittleEndian
+	}
+
+	codec := string(fBytes[off+8 : off+12])
+	if endian == binary.LittleEndian {
+		codec = string(utils.ReverseBytes([]byte(codec)))
+	}
+
+	if codec == "APPL" {
+		return shockwave.extractApplication(fBytes, off)
+	}
+
+	name, ok := containerNames[codec]
+	if !ok {
+		return nil, fmt.Errorf("unknown codec %s at %d", codec, off)
+	}
+
+	length := containerLength(fBytes, off)
+	if length < 0 {
+		return nil, fmt.Errorf("RIFX at %d runs past the end of the file", off)
+	}
+
+	movie := fBytes[off : off+8+length]
+	if codec == "FGDM" || codec == "FGDC" {
+		err := checkAfterburner(movie, fmt.Sprintf(name, off))
+		if err != nil {
+			return nil, fmt.Errorf("compressed %s at %d can't be read: %s", codec, off, err)
+		}
+	}
+
+	directory := filepath.Join(consts.PathDump, filepath.Base(shockwave.FilePath), "extracted")
+	os.MkdirAll(directory, os.ModePerm)
+
+	file := filepath.Join(directory, fmt.Sprintf(name, off))
+	err := ioutil.WriteFile(file, movie, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	utils.InfoMsg("exe", "Found movie: %s at %d", file, off)
+
+	// Afterburner offsets are from the start of the movie already
+	var minusOffset int64
+	if codec == "MV93" || codec == "MC95" {
+		minusOffset = movieOffset(fBytes[off:off+8+length], endian, off)
+	}
+
+	return []ShockwaveFile{{Path: file, MinusOffset: minusOffset}}, nil
+}
+
+// checkAfterburner decompresses an Afterburner movie the way it will be
+// read once extracted, every resource its ABMP lists has to be there and
+// come out whole
+func checkAfterburner(movie []byte, name string) error {
+	compressed := &Shockwave{}
+	_, err := compressed.OpenContent(name, movie)
+	if err != nil {
+		return err
+	}
+
+	if compressed.Abmp == nil {
+		return fmt.Errorf("no ABMP")
+	}
+
+	for _, listed := range compressed.Abmp.Resources {
+		if listed.CompressedLength == 0 {
+			continue
+		}
+
+		resource := compressed.ChunkMap.GetResourceById(int32(listed.ResourceId))
+		if resource == nil {
+			return fmt.Errorf("%s %d is missing", listed.ChunkType, listed.ResourceId)
+		}
+
+		// only zlib has a length to come out at, the rest are kept as they are
+		if listed.CompressionType == 0 && len(resource.Binary) != int(listed.DecompressedLength) {
+			return fmt.Errorf("%s %d decompressed to %d bytes, expected %d", listed.ChunkType, listed.ResourceId, len(resource.Binary), listed.DecompressedLength)
+		}
+	}
+
+	utils.DebugMsg("exe", "%s decompressed to %d resources", name, len(compressed.ChunkMap.GetAllResources()))
+
+	return nil
+}
+
+// containerLength is the length of the RIFX at off, -1 when it doesn't fit
+// in the file. Win16 files don't always keep to one endian so the length
+// that fits is the right one.
+func containerLength(fBytes []byte, off int) int {
+	if off+8 > len(fBytes) {
+		return -1
+	}
+
+	orders := []binary.ByteOrder{binary.BigEndian, binary.LittleEndian}
+	if string(fBytes[off:off+4]) == "XFIR" {
+		orders = []binary.ByteOrder{binary.LittleEndian, binary.BigEndian}
+	}
+
+	for _, endian := range orders {
+		length := int(endian.Uint32(fBytes[off+4:]))
+		if length > 0 && off+8+length <= len(fBytes) {
+			return length
+		}
+	}
+
+	return -1
+}
+
+// movieOffset is what to take off the offsets in a movie, a movie saved into
+// a projector keeps them from the start of the projector
+func movieOffset(movie []byte, endian binary.ByteOrder, off int) int64 {
+	if len(movie) < intMmapPos+4 {
+		return 0
+	}
+
+	if int(endian.Uint32(movie[intMmapPos:])) >= off {
+		return int64(off)
+	}
+
+	return 0
+}
+
+// extractLibrary writes out an executable a projector carries, as long as
+// its own image says it is, and gives that length back
+func (shockwave *Shockwave) extractLibrary(fBytes []byte, off int, name string) (ShockwaveFile, int, error) {
+	if off < 0 || off >= len(fBytes) {
+		return ShockwaveFile{}, 0, fmt.Errorf("offset %d is outside the file", off)
+	}
+
+	length, err := imageLength(fBytes[off:])
+	if err != nil {
+		return ShockwaveFile{}, 0, err
+	}
+
+	directory := filepath.Join(consts.PathDump, filepath.Base(shockwave.FilePath), "extracted", "Xtras")
+	os.MkdirAll(directory, os.ModePerm)
+
+	file := filepath.Join(directory, name)
+	err = ioutil.WriteFile(file, fBytes[off:off+length], 0644)
+	if err != nil {
+		return ShockwaveFile{}, 0, err
+	}
+
+	utils.InfoMsg("exe", "Found library: %s at %d", file, off)
+
+	return ShockwaveFile{Path: file, MinusOffset: int64(off), Xtra: true}, length, nil
+}
+
+// imageLength is how long the NE or PE executable at the start of b is
+func imageLength(b []byte) (int, error) {
+	if ne, err := readNE(b); err == nil {
+		return ne.End, nil
+	}
+
+	pe, err := readPE(b)
+	if err != nil {
+		return 0, err
+	}
+
+	return pe.End, nil
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,84 @@
+package shockwave
+
+import (
+	"bytes"
+	"debug/pe"
+	"fmt"
+
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// the data directory that holds the signature, its address is an offset in
+// the file rather than in memory
+const peDirectorySecurity = 4
+
+// peExecutable is what's needed of a 32 or 64 bit Windows executable to know
+// where its image ends, projectors put everything of Director's after it
+type peExecutable struct {
+	Sections []*pe.Section
+
+	// End is the first byte past the image, the overlay starts there
+	End int
+
+	// OverlayEnd is where the overlay stops, before a signature if the
+	// projector has been signed
+	OverlayEnd int
+}
+
+func readPE(b []byte) (*peExecutable, error) {
+	if len(b) < 0x40 || b[0] != 'M' || b[1] != 'Z' {
+		return nil, fmt.Errorf("not an MZ executable")
+	}
+
+	f, err := pe.NewFile(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("not a PE executable: %s", err)
+	}
+
+	executable := &peExecutable{Sections: f.Sections, OverlayEnd: len(b)}
+
+	var directories []pe.DataDirectory
+	switch header := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		executable.End = int(header.SizeOfHeaders)
+		directories = header.DataDirectory[:minInt(int(header.NumberOfRvaAndSizes), len(header.DataDirectory))]
+	case *pe.OptionalHeader64:
+		executable.End = int(header.SizeOfHeaders)
+		directories = header.DataDirectory[:minInt(int(header.NumberOfRvaAndSizes), len(header.DataDirectory))]
+	}
+
+	for _, section := range f.Sections {
+		// uninitialised data takes no room in the file
+		if section.Offset == 0 || section.Size == 0 {
+			continue
+		}
+
+		executable.End = maxInt(executable.End, int(section.Offset+section.Size))
+	}
+
+	if executable.End > len(b) {
+		return nil, fmt.Errorf("image ends at %d, past the end of the file", executable.End)
+	}
+
+	if len(directories) > peDirectorySecurity {
+		security := directories[peDirectorySecurity]
+		start := int(security.VirtualAddress)
+		if security.Size != 0 && start >= executable.End && start+int(security.Size) <= len(b) {
+			executable.OverlayEnd = start
+		}
+	}
+
+	return executable, nil
+}
+
+// extractPE finds the movies and Xtras of a Windows 95 or later projector in
+// the overlay after its image
+func (shockwave *Shockwave) extractPE(fBytes []byte, executable *peExecutable) ([]ShockwaveFile, error) {
+	utils.InfoMsg("exe", "PE executable with %d sections, image ends at %d", len(executable.Sections), executable.End)
+
+	if executable.OverlayEnd < len(fBytes) {
+		utils.InfoMsg("exe", "Projector is signed, the overlay stops at %d", executable.OverlayEnd)
+	}
+
+	return shockwave.extractOverlay(fBytes, executable.End, executable.OverlayEnd, nil)
+}
@@ -0,0 +1,89 @@
+package shockwave
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/markhughes/dirry/internal/consts"
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// Export writes the container chunks and the raw resource binaries into
+// consts.PathDump, parsing itself never touches the filesystem.
+func (shockwave *Shockwave) Export() error {
+	var fileName = filepath.Base(shockwave.FilePath)
+
+	if shockwave.Fver != nil {
+		shockwave.Fver.Chunk.DecompressedDump(fileName, "Fver", "fver", shockwave.PkgName)
+
+		json, err := shockwave.Fver.ToJSON()
+		if err != nil {
+			return fmt.Errorf("error converting FVER chunk to JSON: %s", err)
+		}
+		utils.SaveChunkToFileBetter("fver", int(shockwave.Fver.Chunk.StartPosition), -9, shockwave.FilePath, json, shockwave.PkgName, "")
+	}
+
+	if shockwave.Fcdr != nil {
+		shockwave.Fcdr.Chunk.DecompressedDump(fileName, "Fcdr", "fcdr", shockwave.PkgName)
+
+		json, err := shockwave.Fcdr.ToJSON()
+		if err != nil {
+			return fmt.Errorf("error converting FCDR chunk to JSON: %s", err)
+		}
+		utils.SaveChunkToFileBetter("fcdr", int(shockwave.Fcdr.Chunk.StartPosition), -9, shockwave.FilePath, json, shockwave.PkgName, "")
+	}
+
+	if shockwave.Abmp != nil {
+		shockwave.Abmp.Chunk.DecompressedDump(fileName, "ABMP", "abmp", shockwave.PkgName)
+
+		json, err := shockwave.Abmp.ToJSON()
+		if err != nil {
+			return fmt.Errorf("error converting ABMP chunk to JSON: %s", err)
+		}
+		utils.SaveChunkToFileBetter("abmp", int(shockwave.Abmp.Chunk.StartPosition), 1, shockwave.FilePath, json, shockwave.PkgName, "")
+	}
+
+	if shockwave.Fgei != nil {
+		shockwave.Fgei.Chunk.DecompressedDump(fileName, "FGEI", "FGEI", shockwave.PkgName)
+	}
+
+	if shockwave.Imap != nil {
+		json, err := shockwave.Imap.ToJSON()
+		if err != nil {
+			return fmt.Errorf("error converting imap chunk to JSON: %s", err)
+		}
+		utils.SaveChunkToFileBetter("imap", 0, 1, shockwave.FilePath, json, shockwave.PkgName, "")
+	}
+
+	if shockwave.Mmap != nil {
+		json, err := shockwave.Mmap.ToJSON()
+		if err != nil {
+			return fmt.Errorf("error converting mmap chunk to JSON: %s", err)
+		}
+		utils.SaveChunkToFileBetter("mmap", 0, 1, shockwave.FilePath, json, shockwave.PkgName, "")
+	}
+
+	if shockwave.ChunkMap == nil {
+		return nil
+	}
+
+	var baseFolder string
+	if shockwave.PkgName == "" {
+		baseFolder = filepath.Join(consts.PathDump, fileName)
+	} else {
+		baseFolder = filepath.Join(consts.PathDump, shockwave.PkgName, "file", fileName)
+	}
+
+	for _, resource := range shockwave.ChunkMap.GetAllResources() {
+		if resource.Origin == "" {
+			continue
+		}
+
+		err := resource.DumpBinary(filepath.Join(baseFolder, "chunks_"+resource.Origin))
+		if err != nil {
+			utils.ErrorMsg("shockwave", "Error dumping binary from %s: %s", resource.Origin, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,365 @@
+package shockwave
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/markhughes/dirry/internal/consts"
+	"github.com/markhughes/dirry/internal/libadf"
+	"github.com/markhughes/dirry/internal/libbinhex"
+	"github.com/markhughes/dirry/internal/libmacbinary"
+	"github.com/markhughes/dirry/internal/libmrf"
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// isMacInput is whether a file could be a Mac projector, or the fork of
+// one, rather than a movie. MacBinary has to have the header CRC, what
+// MacBinary I wrote can't be told apart from any file starting with a zero.
+func isMacInput(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+
+	if libadf.IsAppleDouble(data) || libmrf.IsResourceFork(data) {
+		return true
+	}
+
+	if libmacbinary.IsMacBinary(data) && libmacbinary.HasHeaderCRC(data) {
+		return true
+	}
+
+	return isMacProjector(data[:4])
+}
+
+// isMacProjector is whether a file starting with id is a PowerPC projector,
+// which starts with its code or the projector header
+func isMacProjector(id []byte) bool {
+	return string(id) == "Joy!" || isProjectorTag(id)
+}
+
+func isProjectorTag(tag []byte) bool {
+	if _, ok := projectorHeaderLengths[string(tag)]; ok {
+		return true
+	}
+
+	_, ok := projectorHeaderLengths[string(utils.ReverseBytes(append([]byte{}, tag...)))]
+	return ok
+}
+
+// macFile is a Mac file split into its forks, with the type and creator when
+// what it came in kept them
+type macFile struct {
+	Name    string
+	Type    string
+	Creator string
+
+	DataFork     []byte
+	ResourceFork []byte
+}
+
+// the Finder types of movies and casts, protected and Shockwave ones too
+var macMovieTypes = map[string]bool{
+	"MV93": true,
+	"MV95": true,
+	"MV97": true,
+	"MV07": true,
+	"M!93": true,
+	"M!95": true,
+	"M!97": true,
+	"M!07": true,
+	"MC95": true,
+	"MC97": true,
+	"MC07": true,
+	"FGDM": true,
+	"FGDC": true,
+}
+
+// IsMacMovie is whether a Finder type and creator are of a movie or cast
+func IsMacMovie(fileType string, creator string) bool {
+	return macMovieTypes[fileType] || (fileType != "APPL" && strings.HasPrefix(creator, "MD"))
+}
+
+// IsMacProjector is whether the data fork of an application has a movie
+// where a projector keeps it
+func IsMacProjector(dataFork []byte) bool {
+	if len(dataFork) < 12 {
+		return false
+	}
+
+	if isContainerTag(dataFork[:4]) || isProjectorTag(dataFork[:4]) {
+		return true
+	}
+
+	if string(dataFork[:4]) == "Joy!" {
+		off := int(binary.BigEndian.Uint32(dataFork[len(dataFork)-4:]))
+		return off >= 0 && off+4 <= len(dataFork) && isContainerTag(dataFork[off:off+4])
+	}
+
+	return false
+}
+
+// ExtractMac finds the movies of a Mac projector given as a resource fork,
+// an AppleDouble file, MacBinary, BinHex or its data fork. Director 4 and
+// later keep the RIFX in the data fork, any movies in resources are
+// extracted too. A movie that was archived on its own is opened as it is.
+func (shockwave *Shockwave) ExtractMac(fBytes []byte) ([]ShockwaveFile, error) {
+	file, err := shockwave.macForks(fBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.Type != "" {
+		utils.InfoMsg("mac", "%s has type %s and creator %s", file.Name, file.Type, file.Creator)
+
+		switch {
+		case file.Type == "APPL":
+			// a projector, which is what the forks are searched for anyway
+
+		case IsMacMovie(file.Type, file.Creator):
+			return shockwave.extractMacMovie(file)
+
+		default:
+			return nil, fmt.Errorf("%s is not a Director file, its resource fork can be read with mrf", file.Name)
+		}
+	}
+
+	outFiles := make([]ShockwaveFile, 0)
+
+	if len(file.DataFork) > 0 {
+		files, err := shockwave.extractMacDataFork(file.DataFork)
+		if err != nil {
+			utils.WarnMsg("mac", "Nothing found in the data fork: %s", err)
+		}
+		outFiles = append(outFiles, files...)
+	}
+
+	if len(file.ResourceFork) > 0 {
+		files, err := shockwave.extractMacResources(file.ResourceFork)
+		if err != nil {
+			utils.WarnMsg("mac", "Could not read the resource fork: %s", err)
+		}
+		outFiles = append(outFiles, files...)
+	}
+
+	if len(outFiles) == 0 {
+		return nil, fmt.Errorf("not a director application")
+	}
+
+	return outFiles, nil
+}
+
+// macForks splits what was opened into the two forks, a fork on its own
+// has the other one looked for next to it
+func (shockwave *Shockwave) macForks(fBytes []byte) (*macFile, error) {
+	name := filepath.Base(shockwave.FilePath)
+
+	if libadf.IsAppleDouble(fBytes) {
+		adf, err := libadf.UnpackAdf(fBytes)
+		if err != nil {
+			return nil, fmt.Errorf("error reading AppleDouble: %s", err)
+		}
+
+		if adf.Single {
+			utils.InfoMsg("mac", "AppleSingle file")
+		} else {
+			utils.InfoMsg("mac", "AppleDouble file")
+		}
+
+		file := &macFile{Name: name, Type: adf.Type, Creator: adf.Creator, DataFork: adf.DataFork, ResourceFork: adf.ResourceFork}
+		if adf.RealName != "" {
+			file.Name = adf.RealName
+		}
+
+		// AppleDouble leaves the data fork where it was
+		if !adf.Single {
+			file.DataFork = shockwave.siblingFork(appleDoubleDataFork)
+		}
+		return file, nil
+	}
+
+	if libmacbinary.IsMacBinary(fBytes) {
+		mb, err := libmacbinary.UnpackMacBinary(fBytes)
+		if err != nil {
+			return nil, fmt.Errorf("error reading MacBinary: %s", err)
+		}
+
+		utils.InfoMsg("mac", "MacBinary %s file", strings.Repeat("I", mb.Version))
+		return &macFile{Name: mb.Name, Type: mb.Type, Creator: mb.Creator, DataFork: mb.DataFork, ResourceFork: mb.ResourceFork}, nil
+	}
+
+	if libbinhex.IsBinHex(fBytes) {
+		bh, err := libbinhex.UnpackBinHex(fBytes)
+		if err != nil {
+			return nil, fmt.Errorf("error reading BinHex: %s", err)
+		}
+
+		utils.InfoMsg("mac", "BinHex file")
+		return &macFile{Name: bh.Name, Type: bh.Type, Creator: bh.Creator, DataFork: bh.DataFork, ResourceFork: bh.ResourceFork}, nil
+	}
+
+	if libmrf.IsResourceFork(fBytes) {
+		utils.InfoMsg("mac", "Resource fork")
+		return &macFile{Name: name, DataFork: shockwave.siblingFork(resourceForkDataFork), ResourceFork: fBytes}, nil
+	}
+
+	// anything else is taken to be the data fork
+	return &macFile{Name: name, DataFork: fBytes, ResourceFork: shockwave.siblingFork(dataForkResourceFork)}, nil
+}
+
+// siblingFork reads the other fork from the path name gives for it, when the
+// file was opened from disk
+func (shockwave *Shockwave) siblingFork(name func(string) string) []byte {
+	if shockwave.FileReader == nil {
+		return nil
+	}
+
+	path := name(shockwave.FilePath)
+	if path == "" || path == shockwave.FilePath {
+		return nil
+	}
+
+	fork, err := os.ReadFile(path)
+	if err != nil {
+		utils.DebugMsg("mac", "No other fork at %s: %s", path, err)
+		return nil
+	}
+
+	utils.InfoMsg("mac", "Reading the other fork from %s", path)
+	return fork
+}
+
+// appleDoubleDataFork is the file an AppleDouble ._ file goes with
+func appleDoubleDataFork(path string) string {
+	base := filepath.Base(path)
+	if !strings.HasPrefix(base, "._") {
+		return ""
+	}
+
+	return filepath.Join(filepath.Dir(path), strings.TrimPrefix(base, "._"))
+}
+
+// resourceForkDataFork is the data fork a resource fork was copied off with,
+// either the file of a named fork or the one a .rsrc is next to
+func resourceForkDataFork(path string) string {
+	if strings.HasSuffix(path, "/..namedfork/rsrc") {
+		return strings.TrimSuffix(path, "/..namedfork/rsrc")
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".rsrc") {
+		return strings.TrimSuffix(path, filepath.Ext(path))
+	}
+
+	return ""
+}
+
+// dataForkResourceFork is the resource fork copied off next to a data fork
+func dataForkResourceFork(path string) string {
+	return path + ".rsrc"
+}
+
+// extractMacDataFork finds the RIFX in a data fork. A 68k projector has
+// nothing else in it, a PowerPC one has its code first and says where the
+// RIFX starts in a header at the start or the last four bytes.
+func (shockwave *Shockwave) extractMacDataFork(dataFork []byte) ([]ShockwaveFile, error) {
+	if len(dataFork) < 12 {
+		return nil, fmt.Errorf("data fork is only %d bytes", len(dataFork))
+	}
+
+	be := binary.BigEndian
+
+	off := -1
+	switch {
+	case isContainerTag(dataFork[:4]):
+		off = 0
+	case isProjectorTag(dataFork[:4]):
+		off = int(be.Uint32(dataFork[4:]))
+		utils.InfoMsg("mac", "Found %s projector header", string(dataFork[:4]))
+	case string(dataFork[:4]) == "Joy!":
+		off = int(be.Uint32(dataFork[len(dataFork)-4:]))
+		utils.InfoMsg("mac", "PowerPC projector, RIFX at %d", off)
+	}
+
+	if off >= 0 {
+		files, err := shockwave.extractContainer(dataFork, off)
+		if err == nil {
+			return files, nil
+		}
+
+		utils.WarnMsg("mac", "Could not extract the projector, searching it instead: %s", err)
+	}
+
+	return shockwave.extractOverlay(dataFork, 0, len(dataFork), nil)
+}
+
+// extractMacResources extracts any resource that is a movie, Director 3
+// projectors keep theirs as resources but those aren't RIFX
+func (shockwave *Shockwave) extractMacResources(resourceFork []byte) ([]ShockwaveFile, error) {
+	fork, err := libmrf.FromBytes(resourceFork)
+	if err != nil {
+		return nil, err
+	}
+
+	// resource offsets are from the start of the resource data
+	dataOffset := int(binary.BigEndian.Uint32(resourceFork))
+
+	outFiles := make([]ShockwaveFile, 0)
+	for _, resource := range fork.Resources {
+		switch resource.Type {
+		case "VWCF":
+			utils.WarnMsg("mac", "%s is a Director 3 movie, which can't be read", resource.Name)
+			continue
+		}
+
+		if len(resource.Data) < 12 || !isContainerTag(resource.Data[:4]) {
+			continue
+		}
+
+		files, err := shockwave.extractContainer(resourceFork, dataOffset+int(resource.DataOffset)+4)
+		if err != nil {
+			utils.WarnMsg("mac", "Could not extract %s %d: %s", resource.Type, resource.Id, err)
+			continue
+		}
+
+		outFiles = append(outFiles, files...)
+	}
+
+	return outFiles, nil
+}
+
+// extractMacMovie writes out the data fork of a movie or cast under its Mac
+// name, with an extension if it had none
+func (shockwave *Shockwave) extractMacMovie(file *macFile) ([]ShockwaveFile, error) {
+	data := file.DataFork
+	if len(data) < 12 || !isContainerTag(data[:4]) {
+		return nil, fmt.Errorf("%s is a %s but its data fork isn't a RIFX", file.Name, file.Type)
+	}
+
+	codec := string(data[8:12])
+	if string(data[:4]) == "XFIR" {
+		codec = string(utils.ReverseBytes([]byte(codec)))
+	}
+
+	// Mac names can have slashes, but not colons
+	name := strings.ReplaceAll(file.Name, "/", ":")
+	if filepath.Ext(name) == "" {
+		if format, ok := containerNames[codec]; ok {
+			name += filepath.Ext(format)
+		}
+	}
+
+	directory := filepath.Join(consts.PathDump, filepath.Base(shockwave.FilePath), "extracted")
+	os.MkdirAll(directory, os.ModePerm)
+
+	path := filepath.Join(directory, name)
+	err := os.WriteFile(path, data, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	utils.InfoMsg("mac", "Found movie: %s", path)
+
+	return []ShockwaveFile{{Path: path}}, nil
+}
@@ -0,0 +1,106 @@
+package shockwave
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/markhughes/dirry/internal/consts"
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// testMacBinary is a MacBinary II file with only a data fork, crc false
+// leaves the header CRC wrong
+func testMacBinary(name, fileType string, data []byte, crc bool) []byte {
+	header := make([]byte, 128)
+	header[1] = byte(len(name))
+	copy(header[2:], name)
+	copy(header[65:], fileType)
+	copy(header[69:], "MD93")
+	binary.BigEndian.PutUint32(header[83:], uint32(len(data)))
+	header[122] = 0x81
+	header[123] = 0x81
+
+	sum := utils.CRC16(header[:124])
+	if !crc {
+		sum++
+	}
+	binary.BigEndian.PutUint16(header[124:], sum)
+
+	padded := make([]byte, (len(data)+127)/128*128)
+	copy(padded, data)
+
+	return append(header, padded...)
+}
+
+func testMacMovie() []byte {
+	movie := []byte("RIFX\x00\x00\x00\x04MV93")
+	return testMacBinary("movie", "MV93", movie, true)
+}
+
+func TestIsMacInput(t *testing.T) {
+	resourceFork := make([]byte, 256+30)
+	binary.BigEndian.PutUint32(resourceFork[0:], 256)
+	binary.BigEndian.PutUint32(resourceFork[4:], 256)
+	binary.BigEndian.PutUint32(resourceFork[12:], 30)
+
+	tests := []struct {
+		name string
+		data []byte
+		mac  bool
+	}{
+		{"MacBinary", testMacMovie(), true},
+		{"MacBinary with a bad CRC", testMacBinary("movie", "MV93", []byte("RIFX"), false), false},
+		{"MacBinary with no name", testMacBinary("", "MV93", []byte("RIFX"), true), false},
+		{"AppleDouble", []byte{0, 5, 0x16, 0x07, 0, 2, 0, 0}, true},
+		{"resource fork", resourceFork, true},
+		{"PowerPC projector", append([]byte("Joy!"), make([]byte, 12)...), true},
+		{"zeros", make([]byte, 256), false},
+		{"starts with a zero", append([]byte{0}, []byte("not a Mac file, only starting with a zero byte")...), false},
+		{"movie", []byte("RIFX\x00\x00\x00\x04MV93"), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if mac := isMacInput(test.data); mac != test.mac {
+				t.Errorf("isMacInput is %v, want %v", mac, test.mac)
+			}
+		})
+	}
+}
+
+func TestOpenNoExtract(t *testing.T) {
+	dump := t.TempDir()
+	defer func(path string) { consts.PathDump = path }(consts.PathDump)
+	consts.PathDump = dump
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"MacBinary movie", testMacMovie(), ErrMacFile},
+		{"PowerPC projector", append([]byte("Joy!"), make([]byte, 12)...), ErrProjector},
+		{"Windows projector", append([]byte("MZ"), make([]byte, 62)...), ErrProjector},
+		{"RIFX projector", []byte("RIFX\x00\x00\x00\x04APPL"), ErrProjector},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shockwave := &Shockwave{NoExtract: true}
+			if _, err := shockwave.OpenContent("movie", test.data); !errors.Is(err, test.err) {
+				t.Errorf("error is %v, want %v", err, test.err)
+			}
+		})
+	}
+
+	files, err := os.ReadDir(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 0 {
+		t.Errorf("%d files were written to the dump directory", len(files))
+	}
+}
@@ -0,0 +1,111 @@
+package shockwave
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/markhughes/dirry/internal/chunks"
+	"github.com/markhughes/dirry/internal/palettes"
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// the extensions a linked cast can have once it's been protected or shocked
+var castExtensions = []string{".cst", ".cxt", ".cct"}
+
+// LoadPalettes registers the palette members of every cast library so
+// bitmaps can find them, linked casts are read from next to the movie
+func (shockwave *Shockwave) LoadPalettes() {
+	palettes.ClearMemberPalletes()
+
+	shockwave.registerPalettes(shockwave.CastMembers(), 0)
+
+	for _, lib := range shockwave.CastLibs() {
+		if lib.Path == "" {
+			continue
+		}
+
+		castPath := findLinkedCast(shockwave.FilePath, lib.Path)
+		if castPath == "" {
+			utils.WarnMsg("shockwave", "Could not find the cast %s for its palettes", lib.Path)
+			continue
+		}
+
+		var linked Shockwave
+		expanded, err := linked.Open(castPath)
+		if err != nil || len(expanded) > 0 {
+			utils.WarnMsg("shockwave", "Could not open the cast %s: %s", castPath, err)
+			linked.Close()
+			continue
+		}
+
+		// the cast numbers its only library 1, the movie knows it by another
+		linked.registerPalettes(linked.CastMembers(), lib.LibId)
+		linked.Close()
+	}
+}
+
+func (shockwave *Shockwave) registerPalettes(members map[int32]CastMember, castLib int) {
+	for _, resource := range shockwave.ChunkMap.GetResourcesByTag("CLUT") {
+		member, ok := members[resource.CastId]
+		if !ok {
+			utils.WarnMsg("shockwave", "CLUT %d does not belong to a cast member", resource.ResourceId)
+			continue
+		}
+
+		reader, err := resource.GetReader()
+		if err != nil {
+			utils.WarnMsg("shockwave", "Error getting reader for CLUT resource: %s", err)
+			continue
+		}
+
+		clut, err := chunks.ReadClutChunkRaw(reader, shockwave.Endian, shockwave.IsAfterburner())
+		if err != nil {
+			utils.WarnMsg("shockwave", "Error reading CLUT chunk: %s", err)
+			continue
+		}
+
+		if castLib != 0 {
+			member.CastLib = castLib
+		}
+
+		utils.DebugMsg("shockwave", "CLUT %d is member %d of cast %d", resource.ResourceId, member.Number, member.CastLib)
+		palettes.RegisterMemberPallete(palettes.Member{CastLib: member.CastLib, Number: member.Number}, clut.Palette)
+	}
+}
+
+// findLinkedCast looks beside the movie for a linked cast. The path was
+// saved on whatever machine made the movie so only the file name is any
+// use, and the extension changes when the cast is protected.
+func findLinkedCast(moviePath string, castPath string) string {
+	name := castPath
+	if i := strings.LastIndexAny(name, `\/:`); i >= 0 {
+		name = name[i+1:]
+	}
+
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	if stem == "" {
+		return ""
+	}
+
+	candidates := []string{strings.ToLower(name)}
+	for _, ext := range castExtensions {
+		candidates = append(candidates, strings.ToLower(stem+ext))
+	}
+
+	dir := filepath.Dir(moviePath)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	for _, candidate := range candidates {
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.ToLower(entry.Name()) == candidate {
+				return filepath.Join(dir, entry.Name())
+			}
+		}
+	}
+
+	return ""
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/markhughes/dirry/internal/chunks"
 	"github.com/markhughes/dirry/internal/consts"
+	"github.com/markhughes/dirry/internal/libbinhex"
 	"github.com/markhughes/dirry/internal/utils"
 	"github.com/markhughes/dirry/internal/version"
 )
@@ -24,8 +26,9 @@ type Shockwave struct {
 
 	FilePath string
 
-	FileReader  *os.File
-	BytesReader *bytes.Reader
+	FileReader    *os.File
+	BytesReader   *bytes.Reader
+	SectionReader *io.SectionReader
 
 	DirOffset int64
 
@@ -40,11 +43,28 @@ type Shockwave struct {
 
 	Casts map[int32]*chunks.CastChunk
 	Fonts map[uint32]*chunks.Font
+
+	// The container chunks kept from parsing, only Export writes them out
+	Imap *chunks.ImapChunk
+	Mmap *chunks.MmapChunk
+	Fver *chunks.FverChunk
+	Fcdr *chunks.FcdrChunk
+	Abmp *chunks.ABMPChunk
+	Fgei *chunks.FgeiChunk
+	Keys *chunks.KeyChunk
+
+	// NoExtract refuses projectors and Mac files rather than extracting
+	// their movies to consts.PathDump, for when nothing may be written
+	NoExtract bool
 }
 
 type ShockwaveFile struct {
 	Path        string
 	MinusOffset int64
+
+	// Xtra is set for the Xtras, XObjects and other code a projector
+	// carries, they are extracted but there is nothing in them to open
+	Xtra bool
 }
 
 func (shockwave *Shockwave) Init() {
@@ -62,9 +82,20 @@ func (shockwave *Shockwave) GetReader() io.ReadSeeker {
 		return shockwave.BytesReader
 	}
 
+	if shockwave.SectionReader != nil {
+		return shockwave.SectionReader
+	}
+
 	panic("no reader")
 }
 
+// ErrProjector and ErrMacFile are returned instead of extracting the movies
+// of a projector or Mac file when NoExtract is set
+var (
+	ErrProjector = errors.New("the file is a projector")
+	ErrMacFile   = errors.New("the file is a Mac fork or archive")
+)
+
 func (shockwave *Shockwave) read() (expanded []ShockwaveFile, openError error) {
 	// Let's start by reading in the header
 	var id [4]byte
@@ -72,8 +103,24 @@ func (shockwave *Shockwave) read() (expanded []ShockwaveFile, openError error) {
 		return nil, err
 	}
 
+	// Mac files are looked at whole, how they start isn't enough to go by
+	var macInput []byte
+	if id[0] == 0 || isMacProjector(id[:]) {
+		shockwave.GetReader().Seek(0, io.SeekStart)
+
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(shockwave.GetReader())
+		if isMacInput(buf.Bytes()) {
+			macInput = buf.Bytes()
+		}
+	}
+
 	// Ok lets check are the first two characters in `id` MZ
 	if id[0] == 'M' && id[1] == 'Z' {
+		if shockwave.NoExtract {
+			return nil, ErrProjector
+		}
+
 		// Seek to start
 		shockwave.GetReader().Seek(0, io.SeekStart)
 
@@ -90,6 +137,18 @@ func (shockwave *Shockwave) read() (expanded []ShockwaveFile, openError error) {
 
 		return filePaths, nil
 
+	} else if macInput != nil {
+		if shockwave.NoExtract && isMacProjector(id[:]) {
+			return nil, ErrProjector
+		}
+
+		if shockwave.NoExtract {
+			return nil, ErrMacFile
+		}
+
+		// A Mac projector, or one of its forks
+		return shockwave.ExtractMac(macInput)
+
 	} else {
 		// It's something else...
 		shockwave.ID = string(id[:])
@@ -103,6 +162,19 @@ func (shockwave *Shockwave) read() (expanded []ShockwaveFile, openError error) {
 		// RIFX is big endian
 		shockwave.Endian = binary.BigEndian
 	} else {
+		// BinHex can have mail headers and all before it
+		shockwave.GetReader().Seek(0, io.SeekStart)
+
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(shockwave.GetReader())
+		if libbinhex.IsBinHex(buf.Bytes()) {
+			if shockwave.NoExtract {
+				return nil, ErrMacFile
+			}
+
+			return shockwave.ExtractMac(buf.Bytes())
+		}
+
 		return nil, fmt.Errorf("unknown shockwave type: %s", shockwave.ID)
 	}
 
@@ -123,6 +195,20 @@ func (shockwave *Shockwave) read() (expanded []ShockwaveFile, openError error) {
 
 	utils.InfoMsg("shockwave", "Codec Name: %s", string(codecName[:]))
 
+	// a projector's movies in a file of their own, as a Mac data fork is
+	if string(codecName) == "APPL" {
+		if shockwave.NoExtract {
+			return nil, ErrProjector
+		}
+
+		shockwave.GetReader().Seek(0, io.SeekStart)
+
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(shockwave.GetReader())
+
+		return shockwave.extractApplication(buf.Bytes(), 0)
+	}
+
 	var ok bool
 	shockwave.Codec, ok = CodecByName(string(codecName[:]))
 	if !ok {
@@ -136,12 +222,12 @@ func (shockwave *Shockwave) read() (expanded []ShockwaveFile, openError error) {
 	utils.DebugMsg("shockwave", "Codec Type: %s", shockwave.Codec.Type)
 
 	if shockwave.Codec.Type == Afterburner {
-		ParseAfterburner(shockwave)
+		openError = ParseAfterburner(shockwave)
 	} else {
-		ParseStandard(shockwave)
+		openError = ParseStandard(shockwave)
 	}
 
-	return nil, nil
+	return nil, openError
 
 }
 
@@ -157,6 +243,21 @@ func (shockwave *Shockwave) OpenContent(filePath string, content []byte) (expand
 	return shockwave.read()
 }
 
+/**
+ * Opens a shockwave file from any io.ReaderAt, nothing is read until needed.
+ */
+func (shockwave *Shockwave) OpenReaderAt(filePath string, r io.ReaderAt, size int64) (expanded []ShockwaveFile, openError error) {
+	utils.InfoMsg("shockwave", "Opening File: %s\n", filePath)
+
+	shockwave.FilePath = filePath
+
+	shockwave.Init()
+
+	shockwave.SectionReader = io.NewSectionReader(r, 0, size)
+
+	return shockwave.read()
+}
+
 /**
  * Opens a shockwave file, or returns a list of files to open separately.
  */
@@ -266,6 +367,27 @@ func (shockwave *Shockwave) IsAfterburner() bool {
 	return shockwave.Codec.Type == Afterburner
 }
 
+// IsWindows is whether the movie was saved on Windows, which its text is
+// encoded for. It goes by the platform in the config and when that is too
+// old to say by the byte order, Afterburner is little endian either way.
+func (shockwave *Shockwave) IsWindows() bool {
+	for _, tag := range []string{"VWCF", "DRCF"} {
+		for _, resource := range shockwave.ChunkMap.GetResourcesByTag(tag) {
+			reader, err := resource.GetReader()
+			if err != nil {
+				continue
+			}
+
+			config, err := chunks.ReadInfoChunkRaw(reader, shockwave.Endian, shockwave.IsAfterburner())
+			if err == nil && config.Platform != 0 {
+				return config.Platform == chunks.PlatformWindows
+			}
+		}
+	}
+
+	return shockwave.Endian == binary.LittleEndian && !shockwave.IsAfterburner()
+}
+
 /**
  * binary dump
  */
@@ -5,15 +5,13 @@ import (
 	"compress/zlib"
 	"fmt"
 	"io"
-	"path/filepath"
 
 	"github.com/markhughes/dirry/internal/chunks"
-	"github.com/markhughes/dirry/internal/consts"
 	"github.com/markhughes/dirry/internal/utils"
 	"github.com/markhughes/dirry/internal/version"
 )
 
-func ParseAfterburner(shockwave *Shockwave) {
+func ParseAfterburner(shockwave *Shockwave) error {
 
 	// --------------------------------------------------
 	//  FVER CHUNK
@@ -22,19 +20,10 @@ func ParseAfterburner(shockwave *Shockwave) {
 
 	fver, err := chunks.ReadFverChunk(shockwave.GetReader(), shockwave.Endian)
 	if err != nil {
-		utils.ErrorMsg("shockwave", "Error reading FVER chunk: %s", err)
-		return
+		return fmt.Errorf("error reading FVER chunk: %s", err)
 	}
 
-	fver.Chunk.DecompressedDump(filepath.Base(shockwave.FilePath), "Fver", "fver", shockwave.PkgName)
-	json, err := fver.ToJSON()
-	if err != nil {
-		utils.ErrorMsg("shockwave", "Error converting FVER chunk to JSON: %s", err)
-		return
-	} else {
-		utils.SaveChunkToFileBetter("fver", int(fver.Chunk.StartPosition), -9, shockwave.FilePath, json, shockwave.PkgName, "")
-
-	}
+	shockwave.Fver = fver
 	shockwave.Version = version.ParseVersion(int32(fver.Version))
 	utils.InfoMsg("shockwave", "Shockwave version: %s", shockwave.Version.ToString())
 
@@ -45,17 +34,10 @@ func ParseAfterburner(shockwave *Shockwave) {
 
 	fcdr, err := chunks.ReadFcdrChunk(shockwave.GetReader(), shockwave.Endian)
 	if err != nil {
-		utils.ErrorMsg("shockwave", "Error reading FCDR chunk: %s", err)
+		return fmt.Errorf("error reading FCDR chunk: %s", err)
 	}
-	fcdr.Chunk.DecompressedDump(filepath.Base(shockwave.FilePath), "Fcdr", "fcdr", shockwave.PkgName)
 
-	json, err = fcdr.ToJSON()
-	if err != nil {
-		utils.ErrorMsg("shockwave", "Error converting FCDR chunk to JSON: %s", err)
-	} else {
-		utils.SaveChunkToFileBetter("fcdr", int(fver.Chunk.StartPosition), -9, shockwave.FilePath, json, shockwave.PkgName, "")
-
-	}
+	shockwave.Fcdr = fcdr
 
 	// --------------------------------------------------
 	//  ABMP CHUNK
@@ -66,9 +48,11 @@ func ParseAfterburner(shockwave *Shockwave) {
 	// ABMP after fcdr
 	abmp, err := chunks.ReadABMPChunk(shockwave.GetReader(), shockwave.Endian)
 	if err != nil {
-		utils.ErrorMsg("shockwave", "Error reading ABMP chunk: %s", err)
+		return fmt.Errorf("error reading ABMP chunk: %s", err)
 	}
 
+	shockwave.Abmp = abmp
+
 	// create a count of resources
 	var count = make(map[string]int)
 	for i := range abmp.Resources {
@@ -80,16 +64,6 @@ func ParseAfterburner(shockwave *Shockwave) {
 		utils.DebugMsg("shockwave", "Resource type: %s, count: %d", k, v)
 	}
 
-	json, err = abmp.ToJSON()
-	if err != nil {
-		utils.ErrorMsg("shockwave", "Error converting abmp chunk to JSON: %s", err)
-	} else {
-		utils.SaveChunkToFileBetter("abmp", int(abmp.Chunk.StartPosition), 1, shockwave.FilePath, json, shockwave.PkgName, "")
-
-	}
-
-	abmp.Chunk.DecompressedDump(filepath.Base(shockwave.FilePath), "ABMP", "abmp", shockwave.PkgName)
-
 	// --------------------------------------------------
 	//  FGEI CHUNK
 	// --------------------------------------------------
@@ -98,10 +72,10 @@ func ParseAfterburner(shockwave *Shockwave) {
 
 	fgei, err := chunks.ReadFGEIChunk(shockwave.GetReader(), shockwave.Endian, abmp)
 	if err != nil {
-		utils.ErrorMsg("shockwave", "Error reading FGEI chunk: %s", err)
+		return fmt.Errorf("error reading FGEI chunk: %s", err)
 	}
 
-	fgei.Chunk.DecompressedDump(filepath.Base(shockwave.FilePath), "FGEI", "FGEI", shockwave.PkgName)
+	shockwave.Fgei = fgei
 
 	for i := range abmp.Resources {
 		if abmp.Resources[i].Offset != -1 {
@@ -117,13 +91,6 @@ func ParseAfterburner(shockwave *Shockwave) {
 	// resources from inside directory, also, we need
 	// to keep a record of what to pull from the ILS
 
-	var outputFolder string
-	if shockwave.PkgName == "" {
-		outputFolder = filepath.Join(consts.PathDump, filepath.Base(shockwave.FilePath), "chunks_abmp")
-	} else {
-		outputFolder = filepath.Join(consts.PathDump, shockwave.PkgName, "file", filepath.Base(shockwave.FilePath), "chunks_abmp")
-	}
-
 	// RESOURCE MAPPING
 	var ilsResourcesMap = make(map[uint32]*chunks.AfterburnerResource)
 
@@ -149,35 +116,34 @@ func ParseAfterburner(shockwave *Shockwave) {
 
 			_, err := shockwave.GetReader().Seek(int64(resource.Offset), io.SeekStart)
 			if err != nil {
-				fmt.Printf("Error seeking to resource offset: %s\n", err)
+				return fmt.Errorf("error seeking to resource offset: %s", err)
 			}
 
 			var data = make([]byte, resource.CompressedLength)
-			if len(data) != int(resource.CompressedLength) {
-				fmt.Printf("Size mismatch: %d != %d\n", len(data), resource.CompressedLength)
+			if _, err := io.ReadFull(shockwave.GetReader(), data); err != nil {
+				utils.WarnMsg("shockwave", "Could not read %d bytes for resource %d: %s", resource.CompressedLength, resource.ResourceId, err)
 			}
-			shockwave.GetReader().Read(data)
 
 			if resource.CompressionType == 0 {
 				zlibReader, err := zlib.NewReader(bytes.NewReader(data))
 				if err != nil {
-					fmt.Printf("error creating zlib reader: %s", err)
+					utils.ErrorMsg("shockwave", "error creating zlib reader for resource %d: %s", resource.ResourceId, err)
 					continue
 				}
-				defer zlibReader.Close()
 
 				// Set the limit on the zlib reader to the original uncompressed data length
 				limitedReader := &io.LimitedReader{R: zlibReader, N: int64(resource.DecompressedLength)}
 
 				// Read all data from the limited reader (this will be the decompressed data)
 				data, err = io.ReadAll(limitedReader)
+				zlibReader.Close()
 				if err != nil {
-					fmt.Printf("error reading decompressed data: %s", err)
+					utils.ErrorMsg("shockwave", "error reading decompressed data for resource %d: %s", resource.ResourceId, err)
 					continue
 				}
 
 				if len(data) != int(resource.DecompressedLength) {
-					fmt.Printf("Size mismatch: %d != %d\n", len(data), resource.DecompressedLength)
+					utils.WarnMsg("shockwave", "Size mismatch: %d != %d", len(data), resource.DecompressedLength)
 				}
 
 			}
@@ -189,18 +155,13 @@ func ParseAfterburner(shockwave *Shockwave) {
 				UncompressedSize: int32(resource.DecompressedLength),
 				CompressionType:  int32(resource.CompressionType),
 				ChunkType:        resource.ChunkType,
+				Origin:           OriginAbmp,
 				Binary:           data,
 			}
-			res, err = chunkMap.AddResource(res)
-			if err != nil {
-				fmt.Printf("Error adding resource: %s\n", err)
-			}
-
-			err = res.DumpBinary(outputFolder)
+			_, err = chunkMap.AddResource(res)
 			if err != nil {
-				fmt.Printf("Error dumping binary from ABMP (size: %d): %s\n", resource.Offset, err)
+				return fmt.Errorf("error adding resource: %s", err)
 			}
-
 		}
 	}
 
@@ -214,69 +175,55 @@ func ParseAfterburner(shockwave *Shockwave) {
 	for i, v := range ilsResourcesMap {
 		utils.DebugMsg("shockwave", "ILS resource: %d %s %d %d %d", i, v.ChunkType, v.CompressionType, v.CompressedLength, v.DecompressedLength)
 	}
-	if shockwave.PkgName == "" {
-		outputFolder = filepath.Join(consts.PathDump, filepath.Base(shockwave.FilePath), "chunks_ils")
-	} else {
-		outputFolder = filepath.Join(consts.PathDump, shockwave.PkgName, "file", filepath.Base(shockwave.FilePath), "chunks_ils")
-	}
 
 	res := shockwave.ChunkMap.GetResourcesByTag("ILS ")
+	if len(res) == 0 {
+		return fmt.Errorf("no ILS resources found")
+	}
 
-	if (res == nil) || (len(res) == 0) {
-		fmt.Printf("No ILS resources found\n")
-		return
-	} else {
-		var ils = res[0]
-		ilsReader, err := ils.GetReader()
+	var ils = res[0]
+	ilsReader, err := ils.GetReader()
+	if err != nil {
+		return fmt.Errorf("error getting reader for ILS resource: %s", err)
+	}
+	for {
+		resourceId, _, err := ilsReader.ReadVarInt()
 		if err != nil {
-			fmt.Printf("Error getting reader for ILS resource: %s\n", err)
-			return
-		}
-		for {
-			resourceId, _, err := ilsReader.ReadVarInt()
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
-
-				utils.ErrorMsg("shockwave", "Error reading resource id from ils: %s", err)
-				return
-			}
-
-			var afterburnerResource = ilsResourcesMap[resourceId]
-			fmt.Printf("ILS resource: %d %s %d %d %d\n", resourceId, afterburnerResource.ChunkType, afterburnerResource.CompressionType, afterburnerResource.CompressedLength, afterburnerResource.DecompressedLength)
-			if afterburnerResource == nil || afterburnerResource.CompressedLength == 0 {
+			if err == io.EOF {
 				break
 			}
 
-			bytes, err := ilsReader.ReadBytes(int(afterburnerResource.DecompressedLength))
-			if err != nil {
-				utils.ErrorMsg("shockwave", "Error reading bytes: %s", err)
-			}
+			return fmt.Errorf("error reading resource id from ils: %s", err)
+		}
 
-			var res = &ShockwaveResource{
-				ResourceId:       int32(resourceId),
-				Offset:           int32(afterburnerResource.Offset),
-				CompressedSize:   int32(afterburnerResource.CompressedLength),
-				UncompressedSize: int32(afterburnerResource.DecompressedLength),
-				CompressionType:  int32(afterburnerResource.CompressionType),
-				ChunkType:        afterburnerResource.ChunkType,
-				Binary:           bytes,
-			}
-			res, err = shockwave.ChunkMap.AddResource(res)
-			if err != nil {
-				utils.ErrorMsg("shockwave", "Error adding resource: %s", err)
-			}
+		var afterburnerResource = ilsResourcesMap[resourceId]
+		if afterburnerResource == nil || afterburnerResource.CompressedLength == 0 {
+			break
+		}
+		utils.DebugMsg("shockwave", "ILS resource: %d %s %d %d %d", resourceId, afterburnerResource.ChunkType, afterburnerResource.CompressionType, afterburnerResource.CompressedLength, afterburnerResource.DecompressedLength)
 
-			err = res.DumpBinary(outputFolder)
-			if err != nil {
-				utils.ErrorMsg("shockwave", "Error dumping binary from ILS: %s", err)
-			}
+		bytes, err := ilsReader.ReadBytes(int(afterburnerResource.DecompressedLength))
+		if err != nil {
+			utils.ErrorMsg("shockwave", "Error reading bytes: %s", err)
+		}
 
-			if len(res.Binary) != int(afterburnerResource.DecompressedLength) {
-				utils.WarnMsg("shockwave", "Warning: size mismatch: %d != %d\n", len(res.Binary), afterburnerResource.DecompressedLength)
-			}
+		var res = &ShockwaveResource{
+			ResourceId:       int32(resourceId),
+			Offset:           int32(afterburnerResource.Offset),
+			CompressedSize:   int32(afterburnerResource.CompressedLength),
+			UncompressedSize: int32(afterburnerResource.DecompressedLength),
+			CompressionType:  int32(afterburnerResource.CompressionType),
+			ChunkType:        afterburnerResource.ChunkType,
+			Origin:           OriginIls,
+			Binary:           bytes,
+		}
+		res, err = shockwave.ChunkMap.AddResource(res)
+		if err != nil {
+			return fmt.Errorf("error adding resource: %s", err)
+		}
 
+		if len(res.Binary) != int(afterburnerResource.DecompressedLength) {
+			utils.WarnMsg("shockwave", "Warning: size mismatch: %d != %d\n", len(res.Binary), afterburnerResource.DecompressedLength)
 		}
 
 	}
@@ -284,40 +231,38 @@ func ParseAfterburner(shockwave *Shockwave) {
 	// --------------------------------------------------
 	//  KEY* CHUNK
 	// --------------------------------------------------
+	// A movie without a KEY* is still readable, the
+	// resources just won't know which cast owns them.
 
 	keysFromMap := shockwave.ChunkMap.GetResourcesByTag("KEY*")
-	if err != nil {
-		utils.ErrorMsg("shockwave", "Error finding KEY* resource: %s", err)
-		return
-	}
-
 	if len(keysFromMap) == 0 {
-		utils.ErrorMsg("shockwave", "Could not find KEY* resource")
-		return
+		utils.WarnMsg("shockwave", "Could not find KEY* resource")
+		return nil
 	}
 
 	keyReader, err := keysFromMap[0].GetReader()
 	if err != nil {
-		utils.ErrorMsg("shockwave", "Error creating reader for KEY* resource: %s", err)
-		return
+		utils.WarnMsg("shockwave", "Error creating reader for KEY* resource: %s", err)
+		return nil
 	}
 
 	keys, err := chunks.ReadKeyChunkRaw(keyReader.GetUnsafeBytesReader(), shockwave.Endian)
 	if err != nil {
-		utils.ErrorMsg("shockwave", "Error reading KEY* chunk: %s", err)
-		return
+		utils.WarnMsg("shockwave", "Error reading KEY* chunk: %s", err)
+		return nil
 	}
 
+	shockwave.Keys = keys
+
 	for i := range keys.Records {
 		record := keys.Records[i] // Make a copy of the record
-		if record != nil && !record.IsValid() {
+		if record == nil || !record.IsValid() {
 			continue
 		}
 
 		if record.ElementIndex > 0 {
 			resource := shockwave.ChunkMap.GetResourceById(int32(record.ElementIndex))
-			if err != nil {
-
+			if resource != nil {
 				resource.CastId = record.CastIndex
 				utils.DebugMsg("shockwave", "mapped %s to %d", resource.ChunkType, record.CastNumber)
 			} else {
@@ -326,4 +271,5 @@ func ParseAfterburner(shockwave *Shockwave) {
 		}
 	}
 
+	return nil
 }
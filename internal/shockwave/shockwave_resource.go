@@ -8,6 +8,13 @@ import (
 	"github.com/markhughes/dirry/internal/binary_reader"
 )
 
+// Where a resource was read from, the export keeps them apart
+const (
+	OriginMmap = "mmap"
+	OriginAbmp = "abmp"
+	OriginIls  = "ils"
+)
+
 type ShockwaveResource struct {
 	ResourceId int32
 
@@ -23,6 +30,7 @@ type ShockwaveResource struct {
 	CastId    int32
 	LibId     int32
 	ChunkType string
+	Origin    string
 	Name      string
 	Children  []ShockwaveResource
 	Binary    []byte
@@ -2,28 +2,14 @@ package shockwave
 
 import (
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
 
 	"github.com/markhughes/dirry/internal/chunks"
-	"github.com/markhughes/dirry/internal/consts"
 	"github.com/markhughes/dirry/internal/utils"
 	"github.com/markhughes/dirry/internal/version"
 )
 
-func ParseStandard(shockwave *Shockwave) {
+func ParseStandard(shockwave *Shockwave) error {
 	var err error
-	var json string
-
-	var outputFolder string
-	if shockwave.PkgName == "" {
-		outputFolder = filepath.Join(consts.PathDump, filepath.Base(shockwave.FilePath), "chunks_mmap")
-	} else {
-		outputFolder = filepath.Join(consts.PathDump, shockwave.PkgName, "file", filepath.Base(shockwave.FilePath), "chunks_mmap")
-	}
-
-	os.MkdirAll(outputFolder, os.ModePerm)
 
 	var chunkMap ChunkMap = &StandardChunkMap{}
 	shockwave.ChunkMap = chunkMap
@@ -40,33 +26,24 @@ func ParseStandard(shockwave *Shockwave) {
 
 	imap, err := chunks.ReadImapChunk(shockwave.GetReader(), shockwave.Endian)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("error reading imap chunk: %s", err)
 	}
 
 	imap.MemoryMapOffset -= int32(shockwave.DirOffset)
 
+	shockwave.Imap = imap
 	shockwave.Version = version.ParseVersion(imap.MemoryMapFileVersion)
 
-	json, err = imap.ToJSON()
-	if err != nil {
-		log.Fatal(err)
-	}
-	utils.SaveChunkToFileBetter("imap", 0, 1, shockwave.FilePath, json, shockwave.PkgName, "")
-
 	// --------------------------------------------------
 	//  MMAP CHUNK
 	// --------------------------------------------------
 
 	mmap, err := chunks.ReadMmapChunk(shockwave.GetReader(), shockwave.Endian, int64(imap.MemoryMapOffset), shockwave.DirOffset)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("error reading mmap chunk: %s", err)
 	}
 
-	json, err = mmap.ToJSON()
-	if err != nil {
-		log.Fatal(err)
-	}
-	utils.SaveChunkToFileBetter("mmap", 0, 1, shockwave.FilePath, json, shockwave.PkgName, "")
+	shockwave.Mmap = mmap
 
 	utils.DebugMsg("shockwave", "Resource count: %d", len(mmap.Resources))
 
@@ -76,14 +53,16 @@ func ParseStandard(shockwave *Shockwave) {
 
 	keyFromMap, err := mmap.FindResourceByType("KEY*")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	keys, err := chunks.ReadKeyChunk(shockwave.GetReader(), shockwave.Endian, int64(keyFromMap.Offset))
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("error reading KEY* chunk: %s", err)
 	}
 
+	shockwave.Keys = keys
+
 	for i := range keys.Records {
 		record := keys.Records[i] // Make a copy of the record
 		if !record.IsValid() {
@@ -111,12 +90,12 @@ func ParseStandard(shockwave *Shockwave) {
 		var binaryData = make([]byte, mresource.Size+8)
 		_, err = shockwave.GetReader().Seek(int64(mresource.Offset), 0)
 		if err != nil {
-			log.Fatal(fmt.Errorf("error seeking to offset %d: %s", mresource.Offset, err))
+			return fmt.Errorf("error seeking to offset %d: %s", mresource.Offset, err)
 		}
 
 		_, err = shockwave.GetReader().Read(binaryData)
 		if err != nil {
-			log.Fatal(fmt.Errorf("error reading %d bytes at offset %d: %s", mresource.Size, mresource.Offset, err))
+			return fmt.Errorf("error reading %d bytes at offset %d: %s", mresource.Size, mresource.Offset, err)
 		}
 
 		var resource = &ShockwaveResource{
@@ -126,6 +105,7 @@ func ParseStandard(shockwave *Shockwave) {
 			UncompressedSize: mresource.Size,
 			CompressionType:  1,
 			ChunkType:        mresource.ChunkType,
+			Origin:           OriginMmap,
 			Binary:           binaryData[8:],
 		}
 
@@ -133,8 +113,8 @@ func ParseStandard(shockwave *Shockwave) {
 			resource.CastId = mresource.KeyRecord.CastIndex
 		}
 
-		resource.DumpBinary(outputFolder)
 		chunkMap.AddResource(resource)
 	}
 
+	return nil
 }
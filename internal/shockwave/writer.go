@@ -0,0 +1,436 @@
+package shockwave
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// the first three mmap entries are always the container, imap and mmap
+const firstResourceId = 3
+
+const (
+	imapOffset    = 12
+	imapLength    = 24
+	mmapOffset    = imapOffset + 8 + imapLength
+	mmapHeader    = 24
+	mmapEntrySize = 20
+	keyHeader     = 12
+	keyRecordSize = 12
+)
+
+// containerChunks are made by the writer, or only mean something in an
+// Afterburner file, so they are never copied
+var containerChunks = map[string]bool{
+	"RIFX": true,
+	"XFIR": true,
+	"imap": true,
+	"mmap": true,
+	"KEY*": true,
+	"ILS ": true,
+	"Fver": true,
+	"Fcdr": true,
+	"ABMP": true,
+	"FGEI": true,
+	"free": true,
+	"junk": true,
+}
+
+// the codec of a movie once it isn't Afterburner compressed
+var unprotectedCodecs = map[string]string{
+	"FGDM": "MV93",
+	"FGDC": "MC95",
+}
+
+// mapEntry is one entry of the mmap being written, the index is its id
+type mapEntry struct {
+	chunkType string
+	data      []byte
+	flags     int16
+	unknown   int16
+	next      int32
+
+	// free and junk entries keep their place but have nothing written
+	empty bool
+
+	// where it was in the file read, chunks are written in the same order
+	order  int32
+	offset int
+}
+
+// memoryMap is everything needed to write the container
+type memoryMap struct {
+	entries []*mapEntry
+
+	countMax  int32
+	countUsed int32
+	free      int32
+	junk      int32
+	junk2     int32
+
+	imap struct {
+		count     int32
+		version   int32
+		reserved  int16
+		unknown   int16
+		reserved2 int32
+	}
+}
+
+// WriteRIFX writes the resources of the chunk map as a RIFX container, XFIR
+// when the movie is little endian. A movie read from a RIFX keeps its mmap,
+// ids, free list and all, so only the offsets change. An Afterburner movie
+// has no mmap so one is made along with a KEY*, which makes it a movie
+// Director can edit again.
+func (shockwave *Shockwave) WriteRIFX(w io.Writer) error {
+	if shockwave.ChunkMap == nil {
+		return fmt.Errorf("no chunk map to write")
+	}
+
+	endian := shockwave.Endian
+	if endian == nil {
+		endian = binary.BigEndian
+	}
+
+	var table *memoryMap
+	if shockwave.Mmap != nil {
+		table = shockwave.keptMemoryMap()
+	} else {
+		table = shockwave.newMemoryMap(endian)
+	}
+
+	codec := shockwave.Codec.Name
+	if name, ok := unprotectedCodecs[codec]; ok {
+		codec = name
+	}
+
+	return table.write(w, endian, codec)
+}
+
+// WriteRIFXFile writes the movie to outputFile with WriteRIFX and reads it
+// back, the file it was read from is never overwritten. The reopened movie
+// is returned for the caller to check and close.
+func (shockwave *Shockwave) WriteRIFXFile(outputFile string) (*Shockwave, error) {
+	if abs, err := filepath.Abs(shockwave.FilePath); err == nil {
+		if out, err := filepath.Abs(outputFile); err == nil && abs == out {
+			return nil, fmt.Errorf("refusing to overwrite %s", shockwave.FilePath)
+		}
+	}
+
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return nil, err
+	}
+
+	err = shockwave.WriteRIFX(file)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error writing %s: %s", outputFile, err)
+	}
+
+	// make sure what we wrote can be read back
+	written := &Shockwave{}
+	_, err = written.Open(outputFile)
+	if err != nil {
+		written.Close()
+		return nil, fmt.Errorf("error reopening %s: %s", outputFile, err)
+	}
+
+	return written, nil
+}
+
+// keptMemoryMap is the mmap that was read with the current resources in it
+func (shockwave *Shockwave) keptMemoryMap() *memoryMap {
+	mmap := shockwave.Mmap
+
+	table := &memoryMap{
+		countMax:  mmap.NumberOfEntries,
+		countUsed: mmap.NonZeroEntries,
+		free:      mmap.FirstFreeEntry,
+		junk:      mmap.FirstJunkEntry1,
+		junk2:     mmap.FirstJunkEntry2,
+	}
+
+	if imap := shockwave.Imap; imap != nil {
+		table.imap.count = imap.MemoryMapCount
+		table.imap.version = imap.MemoryMapFileVersion
+		table.imap.reserved = imap.Reserved
+		table.imap.unknown = imap.Unknown
+		table.imap.reserved2 = imap.Reserved2
+	}
+
+	for i, resource := range mmap.Resources {
+		entry := &mapEntry{
+			chunkType: resource.ChunkType,
+			flags:     resource.Unknown1,
+			unknown:   resource.Unknown2,
+			next:      resource.Unknown3,
+			order:     resource.Offset,
+		}
+
+		if i >= firstResourceId {
+			current := shockwave.ChunkMap.GetResourceById(int32(i))
+			if current == nil || resource.Offset == -1 || resource.ChunkType == "free" || resource.ChunkType == "junk" {
+				entry.empty = true
+			} else {
+				entry.chunkType = current.ChunkType
+				entry.data = current.Binary
+			}
+		}
+
+		table.entries = append(table.entries, entry)
+	}
+
+	return table
+}
+
+// newMemoryMap lays out a chunk map that has no mmap, the ids are kept where
+// they don't land on the container entries
+func (shockwave *Shockwave) newMemoryMap(endian binary.ByteOrder) *memoryMap {
+	resources := make([]*ShockwaveResource, 0)
+	for _, resource := range shockwave.ChunkMap.GetAllResources() {
+		if containerChunks[resource.ChunkType] {
+			continue
+		}
+
+		if len(resource.Binary) != int(resource.UncompressedSize) {
+			utils.WarnMsg("shockwave", "%s %d is still compressed, it is written as it is", resource.ChunkType, resource.ResourceId)
+		}
+
+		resources = append(resources, resource)
+	}
+
+	sort.SliceStable(resources, func(i, j int) bool {
+		return resources[i].ResourceId < resources[j].ResourceId
+	})
+
+	entries := make(map[int32]*mapEntry)
+	ids := make(map[int32]int32)
+	var moved []*ShockwaveResource
+	for _, resource := range resources {
+		if resource.ResourceId < firstResourceId || entries[resource.ResourceId] != nil {
+			moved = append(moved, resource)
+			continue
+		}
+
+		ids[resource.ResourceId] = resource.ResourceId
+		entries[resource.ResourceId] = &mapEntry{chunkType: resource.ChunkType, data: resource.Binary}
+	}
+
+	nextId := int32(firstResourceId)
+	freeId := func() int32 {
+		for entries[nextId] != nil {
+			nextId++
+		}
+		return nextId
+	}
+
+	for _, resource := range moved {
+		id := freeId()
+		utils.WarnMsg("shockwave", "%s %d is written as %d", resource.ChunkType, resource.ResourceId, id)
+		ids[resource.ResourceId] = id
+		entries[id] = &mapEntry{chunkType: resource.ChunkType, data: resource.Binary}
+	}
+
+	keyId := int32(-1)
+	if keys := shockwave.ChunkMap.GetResourcesByTag("KEY*"); len(keys) > 0 && keys[0].ResourceId >= firstResourceId && entries[keys[0].ResourceId] == nil {
+		keyId = keys[0].ResourceId
+	} else {
+		keyId = freeId()
+	}
+	entries[keyId] = &mapEntry{chunkType: "KEY*", data: shockwave.keyTable(resources, ids, endian)}
+
+	var count int32 = firstResourceId
+	for id := range entries {
+		if id+1 > count {
+			count = id + 1
+		}
+	}
+
+	table := &memoryMap{countMax: count, countUsed: count, free: -1, junk: -1, junk2: -1}
+	table.imap.count = 1
+	table.imap.version = shockwave.fileVersion()
+
+	table.entries = []*mapEntry{{chunkType: "RIFX"}, {chunkType: "imap"}, {chunkType: "mmap"}}
+	for id := int32(firstResourceId); id < count; id++ {
+		entry := entries[id]
+		if entry == nil {
+			entry = &mapEntry{chunkType: "free", flags: 12, empty: true}
+		}
+		entry.order = id
+
+		table.entries = append(table.entries, entry)
+	}
+
+	return table
+}
+
+func (table *memoryMap) write(w io.Writer, endian binary.ByteOrder, codec string) error {
+	if len(table.entries) < firstResourceId {
+		return fmt.Errorf("mmap has %d entries, it needs at least %d", len(table.entries), firstResourceId)
+	}
+
+	// lay the chunks out after the mmap in the order they were read, each
+	// one padded to an even length
+	written := make([]*mapEntry, 0, len(table.entries))
+	for _, entry := range table.entries[firstResourceId:] {
+		if !entry.empty {
+			written = append(written, entry)
+		}
+	}
+
+	sort.SliceStable(written, func(i, j int) bool {
+		return written[i].order < written[j].order
+	})
+
+	mmapLength := mmapHeader + mmapEntrySize*len(table.entries)
+	offset := mmapOffset + 8 + mmapLength
+	for _, entry := range written {
+		entry.offset = offset
+		offset += 8 + len(entry.data) + len(entry.data)%2
+	}
+	total := offset
+
+	table.entries[0].offset, table.entries[0].data = 0, make([]byte, total-8)
+	table.entries[1].offset, table.entries[1].data = imapOffset, make([]byte, imapLength)
+	table.entries[2].offset, table.entries[2].data = mmapOffset, make([]byte, mmapLength)
+
+	var out bytes.Buffer
+	out.Grow(total)
+
+	out.Write(fourCC("RIFX", endian))
+	binary.Write(&out, endian, int32(total-8))
+	out.Write(fourCC(codec, endian))
+
+	out.Write(fourCC("imap", endian))
+	binary.Write(&out, endian, int32(imapLength))
+	binary.Write(&out, endian, table.imap.count)
+	binary.Write(&out, endian, int32(mmapOffset))
+	binary.Write(&out, endian, table.imap.version)
+	binary.Write(&out, endian, table.imap.reserved)
+	binary.Write(&out, endian, table.imap.unknown)
+	binary.Write(&out, endian, table.imap.reserved2)
+	out.Write(make([]byte, 4))
+
+	out.Write(fourCC("mmap", endian))
+	binary.Write(&out, endian, int32(mmapLength))
+	binary.Write(&out, endian, int16(mmapHeader))
+	binary.Write(&out, endian, int16(mmapEntrySize))
+	binary.Write(&out, endian, table.countMax)
+	binary.Write(&out, endian, table.countUsed)
+	binary.Write(&out, endian, table.free)
+	binary.Write(&out, endian, table.junk)
+	binary.Write(&out, endian, table.junk2)
+
+	for _, entry := range table.entries {
+		length, offset := len(entry.data), entry.offset
+		if entry.empty {
+			length, offset = 0, 0
+		}
+
+		out.Write(fourCC(entry.chunkType, endian))
+		binary.Write(&out, endian, int32(length))
+		binary.Write(&out, endian, int32(offset))
+		binary.Write(&out, endian, entry.flags)
+		binary.Write(&out, endian, entry.unknown)
+		binary.Write(&out, endian, entry.next)
+	}
+
+	for _, entry := range written {
+		out.Write(fourCC(entry.chunkType, endian))
+		binary.Write(&out, endian, int32(len(entry.data)))
+		out.Write(entry.data)
+		if len(entry.data)%2 == 1 {
+			out.WriteByte(0)
+		}
+	}
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// keyTable makes the KEY* for the written ids, from the movie's own KEY*
+// when there is one and otherwise from what the resources were keyed to
+func (shockwave *Shockwave) keyTable(resources []*ShockwaveResource, ids map[int32]int32, endian binary.ByteOrder) []byte {
+	type record struct {
+		element   int32
+		cast      int32
+		chunkType string
+	}
+
+	// the owner of a chunk is a resource id too, unless it's a cast library
+	owner := func(cast int32) int32 {
+		if id, ok := ids[cast]; ok {
+			return id
+		}
+		return cast
+	}
+
+	records := make([]record, 0)
+	if shockwave.Keys != nil {
+		for _, key := range shockwave.Keys.Records {
+			if key == nil || !key.IsValid() {
+				continue
+			}
+
+			id, ok := ids[key.ElementIndex]
+			if !ok {
+				continue
+			}
+
+			records = append(records, record{element: id, cast: owner(key.CastIndex), chunkType: key.ChunkType})
+		}
+	} else {
+		for _, resource := range resources {
+			if resource.CastId == 0 {
+				continue
+			}
+
+			records = append(records, record{element: ids[resource.ResourceId], cast: owner(resource.CastId), chunkType: resource.ChunkType})
+		}
+	}
+
+	var out bytes.Buffer
+	binary.Write(&out, endian, int16(keyHeader))
+	binary.Write(&out, endian, int16(keyRecordSize))
+	binary.Write(&out, endian, int32(len(records)))
+	binary.Write(&out, endian, int32(len(records)))
+	for _, r := range records {
+		binary.Write(&out, endian, r.element)
+		binary.Write(&out, endian, r.cast)
+		out.Write(fourCC(r.chunkType, endian))
+	}
+
+	return out.Bytes()
+}
+
+// fileVersion is the Director version the imap carries
+func (shockwave *Shockwave) fileVersion() int32 {
+	if shockwave.Imap != nil {
+		return shockwave.Imap.MemoryMapFileVersion
+	}
+
+	if shockwave.Fver != nil {
+		if shockwave.Fver.DirectorVersion != 0 {
+			return int32(shockwave.Fver.DirectorVersion)
+		}
+		return int32(shockwave.Fver.Version)
+	}
+
+	return 0
+}
+
+// fourCC is a chunk type as it is stored, backwards in little endian files
+func fourCC(chunkType string, endian binary.ByteOrder) []byte {
+	b := []byte(chunkType)
+	if endian == binary.LittleEndian {
+		return utils.ReverseBytes(b)
+	}
+	return b
+}
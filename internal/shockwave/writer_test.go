@@ -0,0 +1,354 @@
+package shockwave
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testEntry struct {
+	chunkType string
+	data      []byte
+	next      int32
+}
+
+// testMovie builds a container with a KEY*, a member keyed to a CASt and
+// a free entry between them, the way Director leaves one after a delete
+func testMovie(endian binary.ByteOrder, codec string) []byte {
+	var key bytes.Buffer
+	binary.Write(&key, endian, int16(keyHeader))
+	binary.Write(&key, endian, int16(keyRecordSize))
+	binary.Write(&key, endian, int32(1))
+	binary.Write(&key, endian, int32(1))
+	binary.Write(&key, endian, int32(6)) // STXT
+	binary.Write(&key, endian, int32(4)) // keyed to the CASt
+	key.Write(fourCC("STXT", endian))
+
+	entries := []testEntry{
+		{chunkType: "RIFX"},
+		{chunkType: "imap"},
+		{chunkType: "mmap"},
+		{chunkType: "KEY*", data: key.Bytes()},
+		{chunkType: "CASt", data: []byte{0, 0, 0, 3, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{chunkType: "free", next: -1},
+		{chunkType: "STXT", data: []byte("odd length")},
+	}
+
+	mmapLength := mmapHeader + mmapEntrySize*len(entries)
+	offsets := make([]int, len(entries))
+	offset := mmapOffset + 8 + mmapLength
+	for i, entry := range entries[firstResourceId:] {
+		if entry.data != nil {
+			offsets[firstResourceId+i] = offset
+			offset += 8 + len(entry.data) + len(entry.data)%2
+		}
+	}
+	offsets[1], offsets[2] = imapOffset, mmapOffset
+
+	var out bytes.Buffer
+	out.Write(fourCC("RIFX", endian))
+	binary.Write(&out, endian, int32(offset-8))
+	out.Write(fourCC(codec, endian))
+
+	out.Write(fourCC("imap", endian))
+	binary.Write(&out, endian, int32(imapLength))
+	binary.Write(&out, endian, []int32{1, mmapOffset, 1858, 0, 0, 0})
+
+	out.Write(fourCC("mmap", endian))
+	binary.Write(&out, endian, int32(mmapLength))
+	binary.Write(&out, endian, int16(mmapHeader))
+	binary.Write(&out, endian, int16(mmapEntrySize))
+	binary.Write(&out, endian, []int32{int32(len(entries)), int32(len(entries)), 5, -1, -1})
+
+	for i, entry := range entries {
+		length := len(entry.data)
+		switch i {
+		case 0:
+			length = offset - 8
+		case 1:
+			length = imapLength
+		case 2:
+			length = mmapLength
+		}
+
+		out.Write(fourCC(entry.chunkType, endian))
+		binary.Write(&out, endian, int32(length))
+		binary.Write(&out, endian, int32(offsets[i]))
+		binary.Write(&out, endian, int16(0))
+		binary.Write(&out, endian, int16(0))
+		binary.Write(&out, endian, entry.next)
+	}
+
+	for _, entry := range entries[firstResourceId:] {
+		if entry.data == nil {
+			continue
+		}
+
+		out.Write(fourCC(entry.chunkType, endian))
+		binary.Write(&out, endian, int32(len(entry.data)))
+		out.Write(entry.data)
+		if len(entry.data)%2 == 1 {
+			out.WriteByte(0)
+		}
+	}
+
+	return out.Bytes()
+}
+
+func varint(v uint32) []byte {
+	out := []byte{byte(v & 0x7f)}
+	for v >>= 7; v > 0; v >>= 7 {
+		out = append([]byte{byte(v&0x7f) | 0x80}, out...)
+	}
+
+	return out
+}
+
+func compress(data []byte) []byte {
+	var out bytes.Buffer
+	w := zlib.NewWriter(&out)
+	w.Write(data)
+	w.Close()
+
+	return out.Bytes()
+}
+
+// testAfterburner compresses a movie the way Shockwave does, an XFIR with
+// the first resource on its own after the ILS that holds the rest
+func testAfterburner(t *testing.T, movie *Shockwave, codec string) []byte {
+	t.Helper()
+
+	type resource struct {
+		id   uint32
+		tag  string
+		data []byte
+	}
+
+	var resources []resource
+	for _, r := range movie.ChunkMap.GetAllResources() {
+		switch r.ChunkType {
+		case "RIFX", "imap", "mmap", "free":
+			continue
+		case "KEY*":
+			var key bytes.Buffer
+			binary.Write(&key, binary.LittleEndian, []int16{keyHeader, keyRecordSize})
+			binary.Write(&key, binary.LittleEndian, []int32{int32(len(movie.Keys.Records)), int32(len(movie.Keys.Records))})
+			for _, record := range movie.Keys.Records {
+				binary.Write(&key, binary.LittleEndian, []int32{record.ElementIndex, record.CastIndex})
+				key.Write(fourCC(record.ChunkType, binary.LittleEndian))
+			}
+			resources = append(resources, resource{uint32(r.ResourceId), r.ChunkType, key.Bytes()})
+		default:
+			resources = append(resources, resource{uint32(r.ResourceId), r.ChunkType, r.Binary})
+		}
+	}
+
+	outside := resources[0]
+	var ils bytes.Buffer
+	for _, r := range resources[1:] {
+		ils.Write(varint(r.id))
+		ils.Write(r.data)
+	}
+	ilsCompressed := compress(ils.Bytes())
+	outsideCompressed := compress(outside.data)
+
+	var abmp bytes.Buffer
+	abmp.Write(varint(0))
+	abmp.Write(varint(0))
+	abmp.Write(varint(uint32(len(resources) + 1)))
+	for _, field := range [][]uint32{
+		{2, 0, uint32(len(ilsCompressed)), uint32(ils.Len()), 0},
+		{outside.id, uint32(len(ilsCompressed)), uint32(len(outsideCompressed)), uint32(len(outside.data)), 0},
+	} {
+		for _, value := range field {
+			abmp.Write(varint(value))
+		}
+		if field[0] == 2 {
+			abmp.Write(fourCC("ILS ", binary.LittleEndian))
+		} else {
+			abmp.Write(fourCC(outside.tag, binary.LittleEndian))
+		}
+	}
+	for _, r := range resources[1:] {
+		// in the ILS, uncompressed
+		for _, value := range []uint32{r.id, 0xffffffff, uint32(len(r.data)), uint32(len(r.data)), 1} {
+			abmp.Write(varint(value))
+		}
+		abmp.Write(fourCC(r.tag, binary.LittleEndian))
+	}
+	abmpBody := append(append(varint(0), varint(uint32(abmp.Len()))...), compress(abmp.Bytes())...)
+
+	var fcdr bytes.Buffer
+	binary.Write(&fcdr, binary.LittleEndian, uint16(1))
+	fcdr.Write(make([]byte, 16))
+	fcdr.WriteString("zlib\x00")
+	fcdrCompressed := compress(fcdr.Bytes())
+
+	fver := append(append(varint(1224), varint(1)...), varint(1224)...)
+
+	var body bytes.Buffer
+	body.Write(fourCC(codec, binary.LittleEndian))
+	for _, chunk := range []struct {
+		tag  string
+		data []byte
+	}{
+		{"Fver", fver},
+		{"Fcdr", fcdrCompressed},
+		{"ABMP", abmpBody},
+		{"FGEI", nil},
+	} {
+		body.Write(fourCC(chunk.tag, binary.LittleEndian))
+		body.Write(varint(uint32(len(chunk.data))))
+		body.Write(chunk.data)
+	}
+	body.Write(ilsCompressed)
+	body.Write(outsideCompressed)
+
+	var out bytes.Buffer
+	out.Write(fourCC("RIFX", binary.LittleEndian))
+	binary.Write(&out, binary.LittleEndian, int32(body.Len()))
+	out.Write(body.Bytes())
+
+	return out.Bytes()
+}
+
+func openTestMovie(t *testing.T, name string, data []byte) *Shockwave {
+	t.Helper()
+
+	movie := &Shockwave{}
+	if _, err := movie.OpenContent(name, data); err != nil {
+		t.Fatalf("could not open %s: %s", name, err)
+	}
+
+	return movie
+}
+
+func TestWriteRIFX(t *testing.T) {
+	tests := []struct {
+		name   string
+		endian binary.ByteOrder
+		id     string
+	}{
+		{"movie.dir", binary.BigEndian, "RIFX"},
+		{"movie.dxr", binary.LittleEndian, "XFIR"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.id, func(t *testing.T) {
+			original := openTestMovie(t, test.name, testMovie(test.endian, "MV93"))
+
+			var written bytes.Buffer
+			if err := original.WriteRIFX(&written); err != nil {
+				t.Fatalf("could not write: %s", err)
+			}
+
+			if id := string(written.Bytes()[:4]); id != test.id {
+				t.Fatalf("written as %s, want %s", id, test.id)
+			}
+
+			rewritten := openTestMovie(t, test.name, written.Bytes())
+
+			if err := original.CompareResources(rewritten); err != nil {
+				t.Fatal(err)
+			}
+
+			before, after := original.Mmap, rewritten.Mmap
+			if after.FirstFreeEntry != before.FirstFreeEntry {
+				t.Errorf("first free entry is %d, want %d", after.FirstFreeEntry, before.FirstFreeEntry)
+			}
+
+			if len(after.Resources) != len(before.Resources) {
+				t.Fatalf("mmap has %d entries, want %d", len(after.Resources), len(before.Resources))
+			}
+
+			for i, resource := range before.Resources {
+				if after.Resources[i].ChunkType != resource.ChunkType {
+					t.Errorf("entry %d is %s, want %s", i, after.Resources[i].ChunkType, resource.ChunkType)
+				}
+			}
+
+			if stxt := rewritten.ChunkMap.GetResourceById(6); stxt == nil || stxt.CastId != 4 {
+				t.Errorf("STXT 6 is not keyed to CASt 4 any more")
+			}
+		})
+	}
+}
+
+func TestWriteRIFXFile(t *testing.T) {
+	dir := t.TempDir()
+	moviePath := filepath.Join(dir, "movie.dir")
+	if err := os.WriteFile(moviePath, testMovie(binary.BigEndian, "MV93"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	movie := &Shockwave{}
+	if _, err := movie.Open(moviePath); err != nil {
+		t.Fatalf("could not open movie: %s", err)
+	}
+	defer movie.Close()
+
+	if _, err := movie.WriteRIFXFile(moviePath); err == nil {
+		t.Fatalf("overwrote the movie it was read from")
+	}
+
+	written, err := movie.WriteRIFXFile(filepath.Join(dir, "written.dir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer written.Close()
+
+	if err := movie.CompareResources(written); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteRIFXAfterburner(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec string
+		want  string
+	}{
+		{"movie.dcr", "FGDM", "MV93"},
+		{"cast.cct", "FGDC", "MC95"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.codec, func(t *testing.T) {
+			source := openTestMovie(t, "movie.dir", testMovie(binary.BigEndian, "MV93"))
+			original := openTestMovie(t, test.name, testAfterburner(t, source, test.codec))
+			if !original.IsAfterburner() {
+				t.Fatalf("%s was not read as Afterburner", test.codec)
+			}
+
+			var written bytes.Buffer
+			if err := original.WriteRIFX(&written); err != nil {
+				t.Fatalf("could not write: %s", err)
+			}
+
+			rewritten := openTestMovie(t, "movie.dir", written.Bytes())
+			if rewritten.IsAfterburner() || rewritten.Mmap == nil {
+				t.Fatalf("written movie is still Afterburner")
+			}
+
+			if rewritten.Codec.Name != test.want {
+				t.Errorf("codec is %s, want %s", rewritten.Codec.Name, test.want)
+			}
+
+			if err := original.CompareResources(rewritten); err != nil {
+				t.Fatal(err)
+			}
+
+			// the KEY* is made again from the one the Afterburner file had
+			if stxt := rewritten.ChunkMap.GetResourceById(6); stxt == nil || stxt.ChunkType != "STXT" || stxt.CastId != 4 {
+				t.Errorf("STXT 6 is not keyed to CASt 4 any more")
+			}
+
+			if cast := rewritten.ChunkMap.GetResourceById(4); cast == nil || cast.ChunkType != "CASt" {
+				t.Errorf("CASt 4 did not keep its id")
+			}
+		})
+	}
+}
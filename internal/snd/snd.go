@@ -0,0 +1,288 @@
+package snd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// Sound header encodings
+const (
+	EncodingStandard   = 0x00
+	EncodingExtended   = 0xff
+	EncodingCompressed = 0xfe
+)
+
+// a WAV's block align is 16 bits and a sample is at most 2 bytes
+const maxChannels = math.MaxUint16 / 2
+
+// Sound commands that point at a sampled sound header
+const (
+	cmdSound  = 0x8050
+	cmdBuffer = 0x8051
+)
+
+type Sound struct {
+	Format   int
+	Encoding int
+
+	Channels   int
+	SampleRate float64
+	SampleSize int
+	Frames     int
+
+	LoopStart int
+	LoopEnd   int
+
+	// Compression is the four character code from a compressed sound
+	// header, empty for the standard and extended headers
+	Compression string
+
+	// Samples is PCM the way WAV stores it, unsigned 8 bit or little
+	// endian signed 16 bit
+	Samples []byte `json:"-"`
+}
+
+func (s *Sound) HasLoop() bool {
+	return s.LoopEnd > s.LoopStart
+}
+
+// Decode reads a Mac `snd ` resource, format 1 or 2, and the sampled sound
+// header its buffer command points at. These are big endian no matter
+// which platform the movie came from.
+func Decode(data []byte) (*Sound, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("snd too short: %d bytes", len(data))
+	}
+
+	sound := &Sound{Format: int(binary.BigEndian.Uint16(data[0:2]))}
+	pos := 2
+
+	switch sound.Format {
+	case 1:
+		modifiers := int(binary.BigEndian.Uint16(data[pos:]))
+		// each is a data format id and its init options
+		pos += 2 + modifiers*6
+	case 2:
+		// reference count
+		pos += 2
+	default:
+		return nil, fmt.Errorf("unknown snd format %d", sound.Format)
+	}
+
+	if pos+2 > len(data) {
+		return nil, fmt.Errorf("snd truncated before commands")
+	}
+
+	commands := int(binary.BigEndian.Uint16(data[pos:]))
+	pos += 2
+
+	headerOffset := -1
+	for i := 0; i < commands; i++ {
+		if pos+8 > len(data) {
+			return nil, fmt.Errorf("snd truncated in command %d", i)
+		}
+
+		cmd := binary.BigEndian.Uint16(data[pos:])
+		param2 := int(binary.BigEndian.Uint32(data[pos+4:]))
+		pos += 8
+
+		// the high bit says param2 is an offset into the resource
+		if cmd == cmdSound || cmd == cmdBuffer {
+			headerOffset = param2
+		} else {
+			utils.DebugMsg("snd", "Skipping sound command 0x%04x", cmd)
+		}
+	}
+
+	if headerOffset < 0 {
+		headerOffset = pos
+	}
+
+	err := sound.readHeader(data, headerOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	err = sound.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return sound, nil
+}
+
+// validate checks the header gave something a WAV can be written from
+func (s *Sound) validate() error {
+	// the smpl chunk wants the sample period in whole nanoseconds
+	if math.IsNaN(s.SampleRate) || s.SampleRate < 1 {
+		return fmt.Errorf("invalid sample rate %v", s.SampleRate)
+	}
+
+	if s.SampleSize != 8 && s.SampleSize != 16 {
+		return fmt.Errorf("unsupported sample size %d", s.SampleSize)
+	}
+
+	if s.Channels <= 0 || s.Channels*s.SampleSize/8 > math.MaxUint16 {
+		return fmt.Errorf("invalid channel count %d", s.Channels)
+	}
+
+	return nil
+}
+
+func (s *Sound) readHeader(data []byte, offset int) error {
+	if offset+22 > len(data) {
+		return fmt.Errorf("sound header at %d is past the end", offset)
+	}
+
+	header := data[offset:]
+	samplePtr := binary.BigEndian.Uint32(header[0:4])
+	length := int(binary.BigEndian.Uint32(header[4:8]))
+	s.SampleRate = float64(binary.BigEndian.Uint32(header[8:12])) / 65536
+	s.LoopStart = int(binary.BigEndian.Uint32(header[12:16]))
+	s.LoopEnd = int(binary.BigEndian.Uint32(header[16:20]))
+	s.Encoding = int(header[20])
+
+	if samplePtr != 0 {
+		utils.WarnMsg("snd", "Sample pointer is %d, expected the samples to follow the header", samplePtr)
+	}
+
+	switch s.Encoding {
+	case EncodingStandard:
+		s.Channels = 1
+		s.SampleSize = 8
+		s.Frames = length
+
+		return s.setSamples(header[22:])
+
+	case EncodingExtended:
+		if len(header) < 64 {
+			return fmt.Errorf("extended sound header is truncated")
+		}
+
+		s.Channels = length
+		s.Frames = int(binary.BigEndian.Uint32(header[22:26]))
+		s.SampleSize = int(binary.BigEndian.Uint16(header[48:50]))
+
+		return s.setSamples(header[64:])
+
+	case EncodingCompressed:
+		if len(header) < 64 {
+			return fmt.Errorf("compressed sound header is truncated")
+		}
+
+		s.Channels = length
+		if s.Channels <= 0 || s.Channels > maxChannels {
+			return fmt.Errorf("invalid channel count %d", s.Channels)
+		}
+
+		s.Frames = int(binary.BigEndian.Uint32(header[22:26]))
+		s.Compression = string(header[40:44])
+		compressionId := int16(binary.BigEndian.Uint16(header[56:58]))
+		s.SampleSize = int(binary.BigEndian.Uint16(header[62:64]))
+
+		// older headers only give the compression id
+		switch compressionId {
+		case 3:
+			s.Compression = "MAC3"
+		case 4:
+			s.Compression = "MAC6"
+		}
+
+		return s.decompress(header[64:])
+	}
+
+	return fmt.Errorf("unknown sound header encoding 0x%02x", s.Encoding)
+}
+
+// frameCount checks the channels and sample size and how many frames the
+// header says there are against what is left of the data, before anything
+// is multiplied by them
+func (s *Sound) frameCount(data []byte) (int, error) {
+	if s.Channels <= 0 || s.Channels > maxChannels {
+		return 0, fmt.Errorf("invalid channel count %d", s.Channels)
+	}
+
+	if s.SampleSize != 8 && s.SampleSize != 16 {
+		return 0, fmt.Errorf("unsupported sample size %d", s.SampleSize)
+	}
+
+	frameSize := s.Channels * s.SampleSize / 8
+	if available := len(data) / frameSize; s.Frames < 0 || s.Frames > available {
+		utils.WarnMsg("snd", "Expected %d frames but only have %d", s.Frames, available)
+		s.Frames = available
+	}
+
+	return s.Frames, nil
+}
+
+// setSamples takes big endian PCM and stores it the WAV way round
+func (s *Sound) setSamples(data []byte) error {
+	frames, err := s.frameCount(data)
+	if err != nil {
+		return err
+	}
+
+	count := frames * s.Channels
+	if s.SampleSize == 8 {
+		s.Samples = append([]byte{}, data[:count]...)
+		return nil
+	}
+
+	s.Samples = make([]byte, count*2)
+	for i := 0; i < count; i++ {
+		s.Samples[i*2] = data[i*2+1]
+		s.Samples[i*2+1] = data[i*2]
+	}
+
+	return nil
+}
+
+func (s *Sound) decompress(data []byte) error {
+	switch s.Compression {
+	case "ima4":
+		samples, frames, err := decodeIma4(data, s.Channels)
+		if err != nil {
+			return err
+		}
+
+		s.Samples = samples
+		s.Frames = frames
+		s.SampleSize = 16
+
+	case "MAC3", "MAC6":
+		// decoding MACE needs the step tables of Apple's decoder, which
+		// aren't in dirry, and anything else only sounds close to it
+		return fmt.Errorf("%s sounds can't be decoded yet", s.Compression)
+
+	case "twos":
+		err := s.setSamples(data)
+		if err == nil && s.SampleSize == 8 {
+			// WAV wants unsigned 8 bit
+			for i := range s.Samples {
+				s.Samples[i] ^= 0x80
+			}
+		}
+		return err
+
+	case "sowt":
+		// already little endian
+		frames, err := s.frameCount(data)
+		if err != nil {
+			return err
+		}
+
+		s.Samples = append([]byte{}, data[:frames*s.Channels*s.SampleSize/8]...)
+
+	case "raw ", "NONE", "\x00\x00\x00\x00":
+		s.SampleSize = 8
+		return s.setSamples(data)
+
+	default:
+		return fmt.Errorf("unsupported sound compression %q", s.Compression)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package snd
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+var ima4StepTable = [89]int{
+	7, 8, 9, 10, 11, 12, 13, 14, 16, 17,
+	19, 21, 23, 25, 28, 31, 34, 37, 41, 45,
+	50, 55, 60, 66, 73, 80, 88, 97, 107, 118,
+	130, 143, 157, 173, 190, 209, 230, 253, 279, 307,
+	337, 371, 408, 449, 494, 544, 598, 658, 724, 796,
+	876, 963, 1060, 1166, 1282, 1411, 1552, 1707, 1878, 2066,
+	2272, 2499, 2749, 3024, 3327, 3660, 4026, 4428, 4871, 5358,
+	5894, 6484, 7132, 7845, 8630, 9493, 10442, 11487, 12635, 13899,
+	15289, 16818, 18500, 20350, 22385, 24623, 27086, 29794, 32767,
+}
+
+var ima4IndexTable = [16]int{
+	-1, -1, -1, -1, 2, 4, 6, 8,
+	-1, -1, -1, -1, 2, 4, 6, 8,
+}
+
+const (
+	ima4PacketSize    = 34
+	ima4PacketSamples = 64
+)
+
+// decodeIma4 expands Apple IMA4. Each channel has its own 34 byte packets,
+// interleaved one packet per channel, a 2 byte header with the predictor
+// and step index then 64 four bit samples low nibble first.
+func decodeIma4(data []byte, channels int) ([]byte, int, error) {
+	if channels <= 0 {
+		return nil, 0, fmt.Errorf("ima4 sound has no channels")
+	}
+
+	blocks := len(data) / (ima4PacketSize * channels)
+	frames := blocks * ima4PacketSamples
+	out := make([]byte, frames*channels*2)
+
+	for block := 0; block < blocks; block++ {
+		for channel := 0; channel < channels; channel++ {
+			packet := data[(block*channels+channel)*ima4PacketSize:]
+
+			header := binary.BigEndian.Uint16(packet[0:2])
+			predictor := int(int16(header & 0xff80))
+			index := int(header & 0x7f)
+			if index > 88 {
+				index = 88
+			}
+
+			for i := 0; i < ima4PacketSamples; i++ {
+				nibble := packet[2+i/2]
+				if i%2 == 0 {
+					nibble &= 0x0f
+				} else {
+					nibble >>= 4
+				}
+
+				step := ima4StepTable[index]
+				diff := step >> 3
+				if nibble&1 != 0 {
+					diff += step >> 2
+				}
+				if nibble&2 != 0 {
+					diff += step >> 1
+				}
+				if nibble&4 != 0 {
+					diff += step
+				}
+				if nibble&8 != 0 {
+					diff = -diff
+				}
+
+				predictor += diff
+				if predictor > 32767 {
+					predictor = 32767
+				} else if predictor < -32768 {
+					predictor = -32768
+				}
+
+				index += ima4IndexTable[nibble]
+				if index < 0 {
+					index = 0
+				} else if index > 88 {
+					index = 88
+				}
+
+				frame := block*ima4PacketSamples + i
+				binary.LittleEndian.PutUint16(out[(frame*channels+channel)*2:], uint16(int16(predictor)))
+			}
+		}
+	}
+
+	return out, frames, nil
+}
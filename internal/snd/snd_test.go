@@ -0,0 +1,185 @@
+package snd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+const testRate = 22050 << 16
+
+// sndResource wraps a sound header in a format 1 or 2 resource with a
+// buffer command pointing at it
+func sndResource(format int, header []byte) []byte {
+	var out bytes.Buffer
+	binary.Write(&out, binary.BigEndian, uint16(format))
+
+	if format == 1 {
+		// one sampled synth modifier
+		binary.Write(&out, binary.BigEndian, []uint16{1, 5})
+		binary.Write(&out, binary.BigEndian, uint32(0))
+	} else {
+		binary.Write(&out, binary.BigEndian, uint16(0))
+	}
+
+	binary.Write(&out, binary.BigEndian, uint16(1))
+	binary.Write(&out, binary.BigEndian, []uint16{cmdBuffer, 0})
+	binary.Write(&out, binary.BigEndian, uint32(out.Len()+4))
+
+	out.Write(header)
+	return out.Bytes()
+}
+
+func standardHeader(loopStart, loopEnd uint32, data []byte) []byte {
+	var out bytes.Buffer
+	binary.Write(&out, binary.BigEndian, []uint32{0, uint32(len(data)), testRate, loopStart, loopEnd})
+	out.Write([]byte{EncodingStandard, 60})
+	out.Write(data)
+
+	return out.Bytes()
+}
+
+func extendedHeader(channels, frames uint32, sampleSize uint16, data []byte) []byte {
+	header := make([]byte, 64)
+	binary.BigEndian.PutUint32(header[4:], channels)
+	binary.BigEndian.PutUint32(header[8:], testRate)
+	header[20] = EncodingExtended
+	header[21] = 60
+	binary.BigEndian.PutUint32(header[22:], frames)
+	binary.BigEndian.PutUint16(header[48:], sampleSize)
+
+	return append(header, data...)
+}
+
+func compressedHeader(channels, frames uint32, format string, sampleSize uint16, data []byte) []byte {
+	header := extendedHeader(channels, frames, 0, nil)
+	header[20] = EncodingCompressed
+	copy(header[40:], format)
+	binary.BigEndian.PutUint16(header[62:], sampleSize)
+
+	return append(header, data...)
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+
+		encoding   int
+		channels   int
+		sampleSize int
+		frames     int
+		samples    []byte
+		loop       bool
+		err        string
+	}{
+		{
+			name:     "format 1 standard",
+			data:     sndResource(1, standardHeader(1, 3, []byte{0x00, 0x80, 0xff, 0x10})),
+			encoding: EncodingStandard, channels: 1, sampleSize: 8, frames: 4,
+			samples: []byte{0x00, 0x80, 0xff, 0x10},
+			loop:    true,
+		},
+		{
+			name:     "format 2 standard",
+			data:     sndResource(2, standardHeader(0, 0, []byte{0x7f, 0x80})),
+			encoding: EncodingStandard, channels: 1, sampleSize: 8, frames: 2,
+			samples: []byte{0x7f, 0x80},
+		},
+		{
+			name:     "extended 16 bit stereo",
+			data:     sndResource(1, extendedHeader(2, 2, 16, []byte{0x12, 0x34, 0xab, 0xcd, 0x00, 0x01, 0xff, 0xfe})),
+			encoding: EncodingExtended, channels: 2, sampleSize: 16, frames: 2,
+			samples: []byte{0x34, 0x12, 0xcd, 0xab, 0x01, 0x00, 0xfe, 0xff},
+		},
+		{
+			name:     "extended short of its frames",
+			data:     sndResource(1, extendedHeader(1, 100, 16, []byte{0x12, 0x34, 0x56})),
+			encoding: EncodingExtended, channels: 1, sampleSize: 16, frames: 1,
+			samples: []byte{0x34, 0x12},
+		},
+		{
+			name:     "compressed twos",
+			data:     sndResource(1, compressedHeader(1, 3, "twos", 8, []byte{0x00, 0x7f, 0x80})),
+			encoding: EncodingCompressed, channels: 1, sampleSize: 8, frames: 3,
+			samples: []byte{0x80, 0xff, 0x00},
+		},
+		{
+			name:     "compressed sowt",
+			data:     sndResource(1, compressedHeader(1, 2, "sowt", 16, []byte{0x34, 0x12, 0xcd, 0xab})),
+			encoding: EncodingCompressed, channels: 1, sampleSize: 16, frames: 2,
+			samples: []byte{0x34, 0x12, 0xcd, 0xab},
+		},
+		{
+			name: "extended with too many channels",
+			data: sndResource(1, extendedHeader(0xffffffff, 0xffffffff, 16, []byte{0, 0})),
+			err:  "invalid channel count",
+		},
+		{
+			name: "compressed with too many channels",
+			data: sndResource(1, compressedHeader(0xffffffff, 0xffffffff, "twos", 16, []byte{0, 0})),
+			err:  "invalid channel count",
+		},
+		{
+			name:     "compressed with too many frames",
+			data:     sndResource(1, compressedHeader(2, 0xffffffff, "twos", 16, []byte{0, 1, 0, 2})),
+			encoding: EncodingCompressed, channels: 2, sampleSize: 16, frames: 1,
+			samples: []byte{1, 0, 2, 0},
+		},
+		{
+			name: "extended 12 bit",
+			data: sndResource(1, extendedHeader(1, 1, 12, []byte{0, 0})),
+			err:  "unsupported sample size",
+		},
+		{
+			name: "unknown format",
+			data: []byte{0, 3, 0, 0},
+			err:  "unknown snd format",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sound, err := Decode(test.data)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error is %v, want %q", err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sound.Encoding != test.encoding || sound.Channels != test.channels ||
+				sound.SampleSize != test.sampleSize || sound.Frames != test.frames {
+				t.Errorf("got encoding 0x%02x, %d channels, %d bit and %d frames, want 0x%02x, %d, %d and %d",
+					sound.Encoding, sound.Channels, sound.SampleSize, sound.Frames,
+					test.encoding, test.channels, test.sampleSize, test.frames)
+			}
+
+			if sound.SampleRate != 22050 {
+				t.Errorf("sample rate is %v, want 22050", sound.SampleRate)
+			}
+
+			if !bytes.Equal(sound.Samples, test.samples) {
+				t.Errorf("samples are % x, want % x", sound.Samples, test.samples)
+			}
+
+			if sound.HasLoop() != test.loop {
+				t.Errorf("loop is %v, want %v", sound.HasLoop(), test.loop)
+			}
+		})
+	}
+}
+
+func TestDecodeMace(t *testing.T) {
+	for _, format := range []string{"MAC3", "MAC6"} {
+		_, err := Decode(sndResource(1, compressedHeader(1, 6, format, 8, []byte{0x12, 0x34})))
+		if err == nil || !strings.Contains(err.Error(), format) {
+			t.Errorf("%s gave %v, want it refused", format, err)
+		}
+	}
+}
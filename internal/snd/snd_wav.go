@@ -0,0 +1,58 @@
+package snd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+func writeChunk(buf *bytes.Buffer, tag string, body []byte) {
+	buf.WriteString(tag)
+	binary.Write(buf, binary.LittleEndian, uint32(len(body)))
+	buf.Write(body)
+	if len(body)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+// WAV writes the decoded samples as a RIFF WAVE, loop points go in a smpl
+// chunk so samplers pick them up
+func (s *Sound) WAV() []byte {
+	rate := uint32(math.Round(s.SampleRate))
+	blockAlign := uint16(s.Channels * s.SampleSize / 8)
+
+	var format bytes.Buffer
+	binary.Write(&format, binary.LittleEndian, uint16(1)) // PCM
+	binary.Write(&format, binary.LittleEndian, uint16(s.Channels))
+	binary.Write(&format, binary.LittleEndian, rate)
+	binary.Write(&format, binary.LittleEndian, rate*uint32(blockAlign))
+	binary.Write(&format, binary.LittleEndian, blockAlign)
+	binary.Write(&format, binary.LittleEndian, uint16(s.SampleSize))
+
+	var body bytes.Buffer
+	body.WriteString("WAVE")
+	writeChunk(&body, "fmt ", format.Bytes())
+
+	if s.HasLoop() {
+		var sampler bytes.Buffer
+		fields := []uint32{
+			0, 0, // manufacturer, product
+			uint32(1e9 / s.SampleRate), // sample period in nanoseconds
+			60, 0,                      // unity note, pitch fraction
+			0, 0, // SMPTE format and offset
+			1, 0, // loops, sampler data
+			0, 0, // cue point id, loop type forward
+			uint32(s.LoopStart), uint32(s.LoopEnd - 1), // inclusive end
+			0, 0, // fraction, play count forever
+		}
+		binary.Write(&sampler, binary.LittleEndian, fields)
+		writeChunk(&body, "smpl", sampler.Bytes())
+	}
+
+	writeChunk(&body, "data", s.Samples)
+
+	var out bytes.Buffer
+	writeChunk(&out, "RIFF", body.Bytes())
+
+	return out.Bytes()
+}
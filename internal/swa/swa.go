@@ -1,50 +1,239 @@
 package swa
 
 import (
-	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"os"
 
 	"github.com/h2non/filetype"
+	"github.com/markhughes/dirry/internal/utils"
 )
 
+// how far into the data we look for the first frame, SWA headers are small
+const maxHeaderSize = 4096
+
+// the container header is big endian words in front of the MPEG frames.
+// Nothing published describes it. All that is known for sure is that the
+// old extractor found the frames by the 44100 Hz sample rate (0xAC44) being
+// the word before them, the other fields are placed by what they looked
+// like and could be wrong. So rather than report numbers read from the
+// wrong place, Parse refuses a header that disagrees with the frames.
+const (
+	headerLength = 32
+
+	offsetVersion         = 0x00
+	offsetFrameCount      = 0x04
+	offsetPreload         = 0x08
+	offsetBitrate         = 0x0C
+	offsetChannels        = 0x10
+	offsetSampleSize      = 0x14
+	offsetSamplesPerFrame = 0x18
+	offsetSampleRate      = 0x1C
+)
+
+// Header is the SWA container header, with what was measured from the
+// MPEG stream it wraps in Stream
+type Header struct {
+	HeaderSize int
+
+	Version    uint32
+	FrameCount int
+
+	// Preload is how many milliseconds are streamed in before it plays
+	Preload int
+
+	// Bitrate is in bits per second
+	Bitrate         int
+	Channels        int
+	SampleSize      int
+	SamplesPerFrame int
+	SampleRate      int
+
+	Stream Stream
+}
+
+// Stream is what the MPEG frames say about themselves
+type Stream struct {
+	MPEGVersion int
+	Layer       int
+	SampleRate  int
+	Channels    int
+	FrameCount  int
+
+	// Bitrate is the average in bits per second, VBR streams vary per frame
+	Bitrate  int
+	Duration float64
+}
+
+type Swa struct {
+	Header Header
+
+	// MP3 is the frames of the stream, without the container
+	MP3 []byte `json:"-"`
+}
+
+// Parse reads the SWA header and collects the MPEG audio frames after it
+// into an MP3. Frames past the count in the header and trailing data that
+// isn't a frame are dropped, a header the frames disagree with is an error.
+func Parse(data []byte) (*Swa, error) {
+	start := findStream(data)
+	if start < 0 {
+		return nil, errors.New("no mpeg audio found in swa")
+	}
+
+	swa := &Swa{}
+	header := &swa.Header
+	header.HeaderSize = start
+
+	hasHeader := start >= headerLength
+	if hasHeader {
+		word := func(offset int) uint32 {
+			return binary.BigEndian.Uint32(data[offset:])
+		}
+
+		header.Version = word(offsetVersion)
+		header.FrameCount = int(word(offsetFrameCount))
+		header.Preload = int(word(offsetPreload))
+		header.Bitrate = int(word(offsetBitrate))
+		header.Channels = int(word(offsetChannels))
+		header.SampleSize = int(word(offsetSampleSize))
+		header.SamplesPerFrame = int(word(offsetSamplesPerFrame))
+		header.SampleRate = int(word(offsetSampleRate))
+
+		if start != headerLength {
+			return nil, fmt.Errorf("swa frames start at %d, not after the %d byte header", start, headerLength)
+		}
+	} else {
+		utils.WarnMsg("swa", "SWA header is only %d bytes, using what the frames say", start)
+	}
+
+	first, _ := readFrameHeader(data[start:])
+	stream := &header.Stream
+	stream.MPEGVersion = first.Version
+	stream.Layer = first.Layer
+	stream.SampleRate = first.SampleRate
+	stream.Channels = first.Channels
+
+	samples := 0
+	pos := start
+	for pos < len(data) {
+		if hasHeader && header.FrameCount > 0 && stream.FrameCount == header.FrameCount {
+			break
+		}
+
+		frame, ok := readFrameHeader(data[pos:])
+		if !ok || !frame.sameStream(first) || pos+frame.Length > len(data) {
+			break
+		}
+
+		stream.FrameCount++
+		samples += frame.Samples
+		pos += frame.Length
+	}
+
+	swa.MP3 = data[start:pos]
+	stream.Duration = float64(samples) / float64(stream.SampleRate)
+	if stream.Duration > 0 {
+		stream.Bitrate = int(float64(len(swa.MP3)*8) / stream.Duration)
+	}
+
+	if hasHeader {
+		err := header.check(first)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		header.FrameCount = stream.FrameCount
+		header.Bitrate = stream.Bitrate
+		header.Channels = stream.Channels
+		header.SampleRate = stream.SampleRate
+		header.SamplesPerFrame = first.Samples
+	}
+
+	return swa, nil
+}
+
+// check is an error when the header disagrees with the frames, the frame
+// count can only be more than there are as the frames after it are dropped
+func (header *Header) check(first frameHeader) error {
+	stream := header.Stream
+
+	if header.FrameCount != stream.FrameCount {
+		return fmt.Errorf("swa header has %d frames but the stream has %d", header.FrameCount, stream.FrameCount)
+	}
+
+	if header.SampleRate != stream.SampleRate {
+		return fmt.Errorf("swa header is %d Hz but the frames are %d Hz", header.SampleRate, stream.SampleRate)
+	}
+
+	if header.Channels != stream.Channels {
+		return fmt.Errorf("swa header has %d channels but the frames have %d", header.Channels, stream.Channels)
+	}
+
+	if header.SamplesPerFrame != first.Samples {
+		return fmt.Errorf("swa header has %d samples a frame but the frames have %d", header.SamplesPerFrame, first.Samples)
+	}
+
+	// the header gives the rate it was encoded at, VBR averages a little off
+	if header.Bitrate != first.Bitrate && header.Bitrate != stream.Bitrate {
+		utils.DebugMsg("swa", "Header is %d bps, the first frame is %d and the average %d", header.Bitrate, first.Bitrate, stream.Bitrate)
+	}
+
+	// a short sound is loaded whole whatever the preload
+	if duration := int(stream.Duration * 1000); header.Preload > duration {
+		utils.DebugMsg("swa", "Preload of %dms is longer than the %dms sound", header.Preload, duration)
+	}
+
+	return nil
+}
+
+// findStream is the offset of the first run of frames
+func findStream(data []byte) int {
+	limit := len(data) - 4
+	if limit > maxHeaderSize {
+		limit = maxHeaderSize
+	}
+
+	for pos := 0; pos <= limit; pos++ {
+		if data[pos] == 0xff && chainAt(data, pos, 3) {
+			return pos
+		}
+	}
+
+	return -1
+}
+
+// IsSwa is true for data with an SWA header in front of MPEG audio, plain
+// MP3 files don't count
+func IsSwa(data []byte) bool {
+	kind, _ := filetype.Match(data)
+	if kind != filetype.Unknown {
+		return false
+	}
+
+	return findStream(data) > 0
+}
+
 func Swa2Mp3FromFile(filePath string) ([]byte, string, error) {
 	fileBytes, err := os.ReadFile(filePath)
 	if err != nil {
-		fmt.Printf("Error: %v", err)
-		return nil, "", err
+		return nil, "", fmt.Errorf("could not read swa: %s", err)
 	}
 	return Swa2Mp3FromBytes(fileBytes)
 }
 
 func Swa2Mp3FromBytes(swaBytes []byte) ([]byte, string, error) {
-
 	// is it already an mp3?
 	kind, _ := filetype.Match(swaBytes)
-	if kind.MIME.Type == "mp3" {
+	if kind.Extension == "mp3" {
 		return swaBytes, "mp3", nil
 	}
 
-	// AC 44
-	ac44 := []byte{0xAC, 0x44}
-
-	// 49 44 33
-	newHeader := []byte{0x49, 0x44, 0x33}
-
-	// Locate AC 44 in the input
-	ac44Index := bytes.Index(swaBytes, ac44)
-
-	if ac44Index != -1 {
-		// Remove everything before and including AC44
-		updatedSwaBytes := swaBytes[ac44Index+len(ac44):]
-
-		// Insert 494433 at the start
-		result := append(newHeader, updatedSwaBytes...)
-		return result, "mp3", nil
+	swa, err := Parse(swaBytes)
+	if err != nil {
+		return nil, "", err
 	}
 
-	// TODO: I don't understand this container format just yet.. it's an mp3 in some weird container
-	//		 ffmpeg seems to be good at finding and extracting?
-	return nil, "", errors.New("unknown swa format")
+	return swa.MP3, "mp3", nil
 }
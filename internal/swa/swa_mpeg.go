@@ -0,0 +1,125 @@
+package swa
+
+// kbps, indexed by [version][layer][index], 0 is free format which SWA
+// never uses
+var mpegBitrates = map[int]map[int][15]int{
+	mpegVersion1: {
+		1: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		2: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		3: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	},
+	mpegVersion2: {
+		1: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		2: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		3: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	},
+}
+
+var mpegSampleRates = map[int][3]int{
+	mpegVersion1:  {44100, 48000, 32000},
+	mpegVersion2:  {22050, 24000, 16000},
+	mpegVersion25: {11025, 12000, 8000},
+}
+
+const (
+	mpegVersion1  = 1
+	mpegVersion2  = 2
+	mpegVersion25 = 25
+)
+
+type frameHeader struct {
+	Version    int
+	Layer      int
+	Bitrate    int
+	SampleRate int
+	Channels   int
+	Length     int
+	Samples    int
+}
+
+// readFrameHeader checks the four bytes at the start of data are an MPEG
+// audio frame header
+func readFrameHeader(data []byte) (frameHeader, bool) {
+	var header frameHeader
+	if len(data) < 4 || data[0] != 0xff || data[1]&0xe0 != 0xe0 {
+		return header, false
+	}
+
+	switch (data[1] >> 3) & 3 {
+	case 0:
+		header.Version = mpegVersion25
+	case 2:
+		header.Version = mpegVersion2
+	case 3:
+		header.Version = mpegVersion1
+	default:
+		return header, false
+	}
+
+	// the bits count down, 3 is layer I
+	header.Layer = 4 - int((data[1]>>1)&3)
+	if header.Layer == 4 {
+		return header, false
+	}
+
+	bitrateIndex := int(data[2] >> 4)
+	sampleRateIndex := int((data[2] >> 2) & 3)
+	padding := int((data[2] >> 1) & 1)
+	if bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return header, false
+	}
+
+	bitrates := mpegBitrates[header.Version]
+	if header.Version == mpegVersion25 {
+		bitrates = mpegBitrates[mpegVersion2]
+	}
+	header.Bitrate = bitrates[header.Layer][bitrateIndex] * 1000
+	header.SampleRate = mpegSampleRates[header.Version][sampleRateIndex]
+
+	header.Channels = 2
+	if data[3]>>6 == 3 {
+		header.Channels = 1
+	}
+
+	switch {
+	case header.Layer == 1:
+		header.Samples = 384
+		header.Length = (12*header.Bitrate/header.SampleRate + padding) * 4
+	case header.Layer == 3 && header.Version != mpegVersion1:
+		header.Samples = 576
+		header.Length = 72*header.Bitrate/header.SampleRate + padding
+	default:
+		header.Samples = 1152
+		header.Length = 144*header.Bitrate/header.SampleRate + padding
+	}
+
+	return header, true
+}
+
+func (h frameHeader) sameStream(other frameHeader) bool {
+	return h.Version == other.Version && h.Layer == other.Layer && h.SampleRate == other.SampleRate
+}
+
+// chainAt says whether a run of frames starts at pos, a sync word on its
+// own is too easy to find by chance
+func chainAt(data []byte, pos int, want int) bool {
+	first, ok := readFrameHeader(data[pos:])
+	if !ok {
+		return false
+	}
+
+	for n := 0; n < want; n++ {
+		header, ok := readFrameHeader(data[pos:])
+		if !ok || !header.sameStream(first) || pos+header.Length > len(data) {
+			return false
+		}
+
+		pos += header.Length
+		if pos == len(data) {
+			// a short sound can end before we have seen enough frames
+			return true
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,93 @@
+package swa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// an MPEG 1 layer III frame at 128 kbps and 44100 Hz is 417 bytes
+const testFrameLength = 417
+
+func testFrames(count int, mono bool) []byte {
+	frame := make([]byte, testFrameLength)
+	copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+	if mono {
+		frame[3] = 0xc0
+	}
+
+	return bytes.Repeat(frame, count)
+}
+
+func testSwa(frameCount, channels, samplesPerFrame, sampleRate uint32, frames []byte) []byte {
+	header := make([]byte, headerLength)
+	for offset, value := range map[int]uint32{
+		offsetVersion:         1,
+		offsetFrameCount:      frameCount,
+		offsetPreload:         1000,
+		offsetBitrate:         128000,
+		offsetChannels:        channels,
+		offsetSampleSize:      16,
+		offsetSamplesPerFrame: samplesPerFrame,
+		offsetSampleRate:      sampleRate,
+	} {
+		binary.BigEndian.PutUint32(header[offset:], value)
+	}
+
+	return append(header, frames...)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		frames int
+		err    string
+	}{
+		{"header", testSwa(3, 2, 1152, 44100, testFrames(3, false)), 3, ""},
+		{"frames past the count", testSwa(2, 2, 1152, 44100, testFrames(3, false)), 2, ""},
+		{"trailing data", testSwa(3, 2, 1152, 44100, append(testFrames(3, false), 1, 2, 3)), 3, ""},
+		{"too few frames", testSwa(5, 2, 1152, 44100, testFrames(3, false)), 0, "5 frames"},
+		{"sample rate", testSwa(3, 2, 1152, 22050, testFrames(3, false)), 0, "22050 Hz"},
+		{"channels", testSwa(3, 2, 1152, 44100, testFrames(3, true)), 0, "2 channels"},
+		{"samples a frame", testSwa(3, 2, 576, 44100, testFrames(3, false)), 0, "576 samples"},
+		{"header too long", append(make([]byte, 4), testSwa(3, 2, 1152, 44100, testFrames(3, false))...), 0, "start at 36"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			swa, err := Parse(test.data)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error is %v, want %q", err, test.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if swa.Header.Stream.FrameCount != test.frames || len(swa.MP3) != test.frames*testFrameLength {
+				t.Errorf("%d frames in %d bytes, want %d", swa.Header.Stream.FrameCount, len(swa.MP3), test.frames)
+			}
+
+			if swa.Header.Preload != 1000 || swa.Header.Bitrate != 128000 {
+				t.Errorf("preload %d and bitrate %d", swa.Header.Preload, swa.Header.Bitrate)
+			}
+		})
+	}
+}
+
+func TestParseWithoutHeader(t *testing.T) {
+	swa, err := Parse(append([]byte{0, 0, 0, 0}, testFrames(2, true)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := swa.Header
+	if header.FrameCount != 2 || header.Channels != 1 || header.SampleRate != 44100 || header.SamplesPerFrame != 1152 {
+		t.Errorf("header is %+v, want it from the frames", header)
+	}
+}
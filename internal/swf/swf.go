@@ -0,0 +1,215 @@
+package swf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// https://open-flash.github.io/mirrors/swf-spec-19.pdf
+const (
+	signatureUncompressed = "FWS"
+	signatureZlib         = "CWS"
+	signatureLzma         = "ZWS"
+
+	// the signature, version and length come before any compression
+	headerLength = 8
+
+	// a tag with this length has the real one after it
+	tagLongLength = 0x3F
+)
+
+const tagEnd = 0
+
+var tagNames = map[uint16]string{
+	0:  "End",
+	1:  "ShowFrame",
+	2:  "DefineShape",
+	4:  "PlaceObject",
+	5:  "RemoveObject",
+	6:  "DefineBits",
+	7:  "DefineButton",
+	8:  "JPEGTables",
+	9:  "SetBackgroundColor",
+	10: "DefineFont",
+	11: "DefineText",
+	12: "DoAction",
+	13: "DefineFontInfo",
+	14: "DefineSound",
+	15: "StartSound",
+	17: "DefineButtonSound",
+	18: "SoundStreamHead",
+	19: "SoundStreamBlock",
+	20: "DefineBitsLossless",
+	21: "DefineBitsJPEG2",
+	22: "DefineShape2",
+	23: "DefineButtonCxform",
+	24: "Protect",
+	26: "PlaceObject2",
+	28: "RemoveObject2",
+	32: "DefineShape3",
+	33: "DefineText2",
+	34: "DefineButton2",
+	35: "DefineBitsJPEG3",
+	36: "DefineBitsLossless2",
+	37: "DefineEditText",
+	39: "DefineSprite",
+	43: "FrameLabel",
+	45: "SoundStreamHead2",
+	46: "DefineMorphShape",
+	48: "DefineFont2",
+	56: "ExportAssets",
+	57: "ImportAssets",
+	58: "EnableDebugger",
+	59: "DoInitAction",
+	60: "DefineVideoStream",
+	61: "VideoFrame",
+	62: "DefineFontInfo2",
+	64: "EnableDebugger2",
+	65: "ScriptLimits",
+	66: "SetTabIndex",
+	69: "FileAttributes",
+	70: "PlaceObject3",
+	71: "ImportAssets2",
+	73: "DefineFontAlignZones",
+	74: "CSMTextSettings",
+	75: "DefineFont3",
+	76: "SymbolClass",
+	77: "Metadata",
+	78: "DefineScalingGrid",
+	82: "DoABC",
+	83: "DefineShape4",
+	84: "DefineMorphShape2",
+	86: "DefineSceneAndFrameLabelData",
+	87: "DefineBinaryData",
+	88: "DefineFontName",
+	89: "StartSound2",
+	90: "DefineBitsJPEG4",
+	91: "DefineFont4",
+}
+
+// Movie is the header of a SWF and an index of its tags, the header is
+// read as it was and not checked against the tags
+type Movie struct {
+	Signature  string
+	Version    uint8
+	FileLength uint32
+	FrameSize  Rect
+	FrameRate  float64
+	FrameCount uint16
+	Tags       []Tag
+
+	// Trailing is how many bytes come after the end tag
+	Trailing int `json:",omitempty"`
+
+	// body is the movie after the header, uncompressed
+	body []byte
+}
+
+// Tag is where a tag is in the body of the movie, after the header and
+// uncompressed
+type Tag struct {
+	Code   uint16
+	Name   string
+	Offset int
+	Length int
+}
+
+// Parse reads the header of a SWF, uncompressing it if it was, and the tags
+// after it
+func Parse(data []byte) (*Movie, error) {
+	if len(data) < headerLength {
+		return nil, fmt.Errorf("too short for a SWF header")
+	}
+
+	movie := &Movie{
+		Signature:  string(data[:3]),
+		Version:    data[3],
+		FileLength: uint32(data[4]) | uint32(data[5])<<8 | uint32(data[6])<<16 | uint32(data[7])<<24,
+	}
+
+	switch movie.Signature {
+	case signatureUncompressed:
+		movie.body = data[headerLength:]
+
+	case signatureZlib:
+		zr, err := zlib.NewReader(bytes.NewReader(data[headerLength:]))
+		if err != nil {
+			return nil, fmt.Errorf("could not uncompress SWF: %s", err)
+		}
+		defer zr.Close()
+
+		movie.body, err = io.ReadAll(zr)
+		if err != nil && len(movie.body) == 0 {
+			return nil, fmt.Errorf("could not uncompress SWF: %s", err)
+		} else if err != nil {
+			utils.WarnMsg("swf", "SWF is truncated after %d bytes: %s", len(movie.body), err)
+		}
+
+	case signatureLzma:
+		return nil, fmt.Errorf("LZMA compressed SWF is not supported")
+
+	default:
+		return nil, fmt.Errorf("%q is not a SWF signature", data[:3])
+	}
+
+	if int(movie.FileLength) != len(movie.body)+headerLength {
+		utils.WarnMsg("swf", "SWF says it is %d bytes but it is %d", movie.FileLength, len(movie.body)+headerLength)
+	}
+
+	r := &bitReader{data: movie.body}
+	movie.FrameSize = r.rect()
+
+	// 8.8 fixed point, the fraction first
+	rate := r.u16()
+	movie.FrameRate = float64(rate>>8) + float64(rate&0xFF)/256
+	movie.FrameCount = r.u16()
+
+	if r.err != nil {
+		return nil, fmt.Errorf("could not read SWF header: %s", r.err)
+	}
+
+	for r.left() > 0 {
+		codeAndLength := r.u16()
+
+		tag := Tag{
+			Code:   codeAndLength >> 6,
+			Length: int(codeAndLength & tagLongLength),
+		}
+
+		if tag.Length == tagLongLength {
+			tag.Length = int(r.u32())
+		}
+
+		tag.Offset = r.pos / 8
+		tag.Name = tagNames[tag.Code]
+		if tag.Name == "" {
+			tag.Name = fmt.Sprintf("Unknown%d", tag.Code)
+		}
+
+		if r.err != nil || tag.Length > r.left() {
+			utils.WarnMsg("swf", "Tag %s at %d is past the end of the SWF", tag.Name, tag.Offset)
+			break
+		}
+
+		r.bytes(tag.Length)
+		movie.Tags = append(movie.Tags, tag)
+
+		if tag.Code == tagEnd {
+			movie.Trailing = r.left()
+			break
+		}
+	}
+
+	utils.DebugMsg("swf", "SWF %d of %d frames at %.2f fps with %d tags", movie.Version, movie.FrameCount, movie.FrameRate, len(movie.Tags))
+
+	return movie, nil
+}
+
+// data is the bytes of a tag
+func (movie *Movie) data(tag Tag) []byte {
+	return movie.body[tag.Offset : tag.Offset+tag.Length]
+}
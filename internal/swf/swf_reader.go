@@ -0,0 +1,168 @@
+package swf
+
+import (
+	"fmt"
+)
+
+// bitReader reads SWF, where the bit fields go from the high bit down and
+// everything else is little endian on a byte boundary. It keeps the first
+// error and reads zeroes after it.
+type bitReader struct {
+	data []byte
+	pos  int // in bits
+	err  error
+}
+
+func (r *bitReader) fail(what string) {
+	if r.err == nil {
+		r.err = fmt.Errorf("truncated at byte %d reading %s", r.pos/8, what)
+	}
+}
+
+func (r *bitReader) align() {
+	r.pos = (r.pos + 7) &^ 7
+}
+
+// ub reads an unsigned bit field
+func (r *bitReader) ub(n int) uint32 {
+	if r.err != nil {
+		return 0
+	}
+
+	if r.pos+n > len(r.data)*8 {
+		r.fail("bits")
+		return 0
+	}
+
+	var value uint32
+	for i := 0; i < n; i++ {
+		bit := (r.data[r.pos/8] >> uint(7-r.pos%8)) & 1
+		value = value<<1 | uint32(bit)
+		r.pos++
+	}
+
+	return value
+}
+
+// sb reads a signed bit field
+func (r *bitReader) sb(n int) int32 {
+	if n == 0 {
+		return 0
+	}
+
+	value := r.ub(n)
+	return int32(value<<uint(32-n)) >> uint(32-n)
+}
+
+func (r *bitReader) bytes(n int) []byte {
+	r.align()
+	if r.err != nil || n < 0 || r.pos/8+n > len(r.data) {
+		r.fail("bytes")
+		return make([]byte, maxInt(n, 0))
+	}
+
+	out := r.data[r.pos/8 : r.pos/8+n]
+	r.pos += n * 8
+	return out
+}
+
+func (r *bitReader) u8() uint8 {
+	return r.bytes(1)[0]
+}
+
+func (r *bitReader) u16() uint16 {
+	b := r.bytes(2)
+	return uint16(b[0]) | uint16(b[1])<<8
+}
+
+func (r *bitReader) u32() uint32 {
+	b := r.bytes(4)
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+func (r *bitReader) left() int {
+	return len(r.data) - (r.pos+7)/8
+}
+
+// Rect is in twips, 20 to a pixel
+type Rect struct {
+	XMin int32
+	XMax int32
+	YMin int32
+	YMax int32
+}
+
+func (r *bitReader) rect() Rect {
+	r.align()
+	bits := int(r.ub(5))
+
+	rect := Rect{
+		XMin: r.sb(bits),
+		XMax: r.sb(bits),
+		YMin: r.sb(bits),
+		YMax: r.sb(bits),
+	}
+
+	r.align()
+	return rect
+}
+
+// Matrix places a gradient or bitmap fill, the scale and rotation are 16.16
+// fixed point and the translation is in twips
+type Matrix struct {
+	ScaleX      float64
+	ScaleY      float64
+	RotateSkew0 float64
+	RotateSkew1 float64
+	TranslateX  int32
+	TranslateY  int32
+}
+
+func (r *bitReader) matrix() Matrix {
+	r.align()
+	matrix := Matrix{ScaleX: 1, ScaleY: 1}
+
+	if r.ub(1) == 1 {
+		bits := int(r.ub(5))
+		matrix.ScaleX = float64(r.sb(bits)) / 65536
+		matrix.ScaleY = float64(r.sb(bits)) / 65536
+	}
+
+	if r.ub(1) == 1 {
+		bits := int(r.ub(5))
+		matrix.RotateSkew0 = float64(r.sb(bits)) / 65536
+		matrix.RotateSkew1 = float64(r.sb(bits)) / 65536
+	}
+
+	bits := int(r.ub(5))
+	matrix.TranslateX = r.sb(bits)
+	matrix.TranslateY = r.sb(bits)
+
+	r.align()
+	return matrix
+}
+
+// Colour is RGB or RGBA, it is opaque when there was no alpha
+type Colour struct {
+	R uint8
+	G uint8
+	B uint8
+	A uint8
+}
+
+// colour reads RGB, or RGBA when alpha is set
+func (r *bitReader) colour(alpha bool) Colour {
+	colour := Colour{R: r.u8(), G: r.u8(), B: r.u8(), A: 0xFF}
+	if alpha {
+		colour.A = r.u8()
+	}
+
+	return colour
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,597 @@
+package swf
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// the shape tags, which add to what the one before them could do
+const (
+	tagDefineShape  = 2
+	tagDefineShape2 = 22
+	tagDefineShape3 = 32
+	tagDefineShape4 = 83
+)
+
+// fill style types
+const (
+	fillSolid                = 0x00
+	fillLinearGradient       = 0x10
+	fillRadialGradient       = 0x12
+	fillFocalRadialGradient  = 0x13
+	fillRepeatingBitmap      = 0x40
+	fillClippedBitmap        = 0x41
+	fillNonSmoothedRepeating = 0x42
+	fillNonSmoothedClipped   = 0x43
+)
+
+// a gradient is drawn in a square this many twips across each way from the
+// middle, and then moved by its matrix
+const gradientSquare = 16384
+
+const twipsPerPixel = 20
+
+// Shape is a DefineShape, with its styles and edges put into groups that
+// each start when the shape has new styles
+type Shape struct {
+	Id         uint16
+	Version    int
+	Bounds     Rect
+	EdgeBounds Rect
+
+	// NonZero is set when the fills use the non-zero winding rule instead
+	// of even-odd
+	NonZero bool
+
+	groups []*shapeGroup
+}
+
+type FillStyle struct {
+	Type     uint8
+	Colour   Colour
+	Matrix   Matrix
+	Gradient Gradient
+	BitmapId uint16
+}
+
+type Gradient struct {
+	Spread        uint8
+	Interpolation uint8
+	Records       []GradientRecord
+	Focal         float64
+}
+
+type GradientRecord struct {
+	Ratio  uint8
+	Colour Colour
+}
+
+type LineStyle struct {
+	Width      uint16
+	StartCap   uint8
+	EndCap     uint8
+	Join       uint8
+	MiterLimit float64
+	NoClose    bool
+	Colour     Colour
+	Fill       *FillStyle
+}
+
+type point struct {
+	x, y int32
+}
+
+type edge struct {
+	from, control, to point
+	curved            bool
+}
+
+func (e edge) reverse() edge {
+	return edge{from: e.to, control: e.control, to: e.from, curved: e.curved}
+}
+
+// shapeGroup is the styles of a shape and the edges drawn with each of them,
+// the edges of a fill are turned so the fill is always on the same side
+type shapeGroup struct {
+	fills []FillStyle
+	lines []LineStyle
+
+	fillEdges [][]edge
+	lineEdges [][]edge
+}
+
+func newShapeGroup(fills []FillStyle, lines []LineStyle) *shapeGroup {
+	return &shapeGroup{
+		fills:     fills,
+		lines:     lines,
+		fillEdges: make([][]edge, len(fills)),
+		lineEdges: make([][]edge, len(lines)),
+	}
+}
+
+// add gives an edge to the styles it's drawn with, which count from one
+func (group *shapeGroup) add(e edge, fill0, fill1, line int) {
+	if fill0 > 0 && fill0 <= len(group.fills) {
+		group.fillEdges[fill0-1] = append(group.fillEdges[fill0-1], e.reverse())
+	}
+
+	if fill1 > 0 && fill1 <= len(group.fills) {
+		group.fillEdges[fill1-1] = append(group.fillEdges[fill1-1], e)
+	}
+
+	if line > 0 && line <= len(group.lines) {
+		group.lineEdges[line-1] = append(group.lineEdges[line-1], e)
+	}
+}
+
+// Shapes reads the DefineShape tags of the movie
+func (movie *Movie) Shapes() ([]*Shape, error) {
+	var shapes []*Shape
+
+	for _, tag := range movie.Tags {
+		var version int
+
+		switch tag.Code {
+		case tagDefineShape:
+			version = 1
+		case tagDefineShape2:
+			version = 2
+		case tagDefineShape3:
+			version = 3
+		case tagDefineShape4:
+			version = 4
+		default:
+			continue
+		}
+
+		shape, err := readShape(movie.data(tag), version)
+		if err != nil {
+			return shapes, fmt.Errorf("could not read %s at %d: %s", tag.Name, tag.Offset, err)
+		}
+
+		shapes = append(shapes, shape)
+	}
+
+	return shapes, nil
+}
+
+func readShape(data []byte, version int) (*Shape, error) {
+	r := &bitReader{data: data}
+
+	shape := &Shape{
+		Id:      r.u16(),
+		Version: version,
+		Bounds:  r.rect(),
+	}
+
+	if version >= 4 {
+		shape.EdgeBounds = r.rect()
+		r.ub(5) // reserved
+		shape.NonZero = r.ub(1) == 1
+		r.ub(1) // uses non-scaling strokes
+		r.ub(1) // uses scaling strokes
+		r.align()
+	}
+
+	group := newShapeGroup(readFillStyles(r, version), readLineStyles(r, version))
+	shape.groups = append(shape.groups, group)
+
+	fillBits := int(r.ub(4))
+	lineBits := int(r.ub(4))
+
+	var x, y int32
+	var fill0, fill1, line int
+
+	for r.err == nil {
+		if r.ub(1) == 0 {
+			newStyles := r.ub(1) == 1
+			changeLine := r.ub(1) == 1
+			changeFill1 := r.ub(1) == 1
+			changeFill0 := r.ub(1) == 1
+			moveTo := r.ub(1) == 1
+
+			if !newStyles && !changeLine && !changeFill1 && !changeFill0 && !moveTo {
+				break
+			}
+
+			if moveTo {
+				bits := int(r.ub(5))
+				x = r.sb(bits)
+				y = r.sb(bits)
+			}
+
+			if changeFill0 {
+				fill0 = int(r.ub(fillBits))
+			}
+
+			if changeFill1 {
+				fill1 = int(r.ub(fillBits))
+			}
+
+			if changeLine {
+				line = int(r.ub(lineBits))
+			}
+
+			// the first shape can't have new styles, but some writers set
+			// the flag anyway
+			if newStyles && version >= 2 {
+				group = newShapeGroup(readFillStyles(r, version), readLineStyles(r, version))
+				shape.groups = append(shape.groups, group)
+
+				fillBits = int(r.ub(4))
+				lineBits = int(r.ub(4))
+			}
+
+			continue
+		}
+
+		from := point{x, y}
+		e := edge{from: from}
+
+		if r.ub(1) == 1 {
+			bits := int(r.ub(4)) + 2
+
+			if r.ub(1) == 1 {
+				x += r.sb(bits)
+				y += r.sb(bits)
+			} else if r.ub(1) == 1 {
+				y += r.sb(bits)
+			} else {
+				x += r.sb(bits)
+			}
+		} else {
+			bits := int(r.ub(4)) + 2
+
+			e.curved = true
+			x += r.sb(bits)
+			y += r.sb(bits)
+			e.control = point{x, y}
+			x += r.sb(bits)
+			y += r.sb(bits)
+		}
+
+		e.to = point{x, y}
+		group.add(e, fill0, fill1, line)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return shape, nil
+}
+
+// readStyleCount reads how many styles there are, which has more after it
+// when it's 0xFF, except for the fills of the first shape version
+func readStyleCount(r *bitReader, extended bool) int {
+	count := int(r.u8())
+	if count == 0xFF && extended {
+		count = int(r.u16())
+	}
+
+	return count
+}
+
+func readFillStyles(r *bitReader, version int) []FillStyle {
+	count := readStyleCount(r, version >= 2)
+
+	var fills []FillStyle
+	for i := 0; i < count && r.err == nil; i++ {
+		fills = append(fills, readFillStyle(r, version))
+	}
+
+	return fills
+}
+
+func readFillStyle(r *bitReader, version int) FillStyle {
+	fill := FillStyle{Type: r.u8()}
+
+	switch fill.Type {
+	case fillSolid:
+		fill.Colour = r.colour(version >= 3)
+
+	case fillLinearGradient, fillRadialGradient, fillFocalRadialGradient:
+		fill.Matrix = r.matrix()
+
+		fill.Gradient.Spread = uint8(r.ub(2))
+		fill.Gradient.Interpolation = uint8(r.ub(2))
+		count := int(r.ub(4))
+
+		for i := 0; i < count && r.err == nil; i++ {
+			fill.Gradient.Records = append(fill.Gradient.Records, GradientRecord{
+				Ratio:  r.u8(),
+				Colour: r.colour(version >= 3),
+			})
+		}
+
+		if fill.Type == fillFocalRadialGradient {
+			// 8.8 fixed point
+			fill.Gradient.Focal = float64(int16(r.u16())) / 256
+		}
+
+	case fillRepeatingBitmap, fillClippedBitmap, fillNonSmoothedRepeating, fillNonSmoothedClipped:
+		fill.BitmapId = r.u16()
+		fill.Matrix = r.matrix()
+
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("fill style type 0x%02x is not known", fill.Type)
+		}
+	}
+
+	return fill
+}
+
+func readLineStyles(r *bitReader, version int) []LineStyle {
+	count := readStyleCount(r, true)
+
+	var lines []LineStyle
+	for i := 0; i < count && r.err == nil; i++ {
+		line := LineStyle{Width: r.u16()}
+
+		if version < 4 {
+			line.Colour = r.colour(version >= 3)
+			lines = append(lines, line)
+			continue
+		}
+
+		line.StartCap = uint8(r.ub(2))
+		line.Join = uint8(r.ub(2))
+		hasFill := r.ub(1) == 1
+		r.ub(1) // no horizontal scale
+		r.ub(1) // no vertical scale
+		r.ub(1) // pixel hinting
+		r.ub(5) // reserved
+		line.NoClose = r.ub(1) == 1
+		line.EndCap = uint8(r.ub(2))
+
+		if line.Join == 2 {
+			line.MiterLimit = float64(r.u16()) / 256
+		}
+
+		if hasFill {
+			fill := readFillStyle(r, version)
+			line.Fill = &fill
+		} else {
+			line.Colour = r.colour(true)
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// SVG draws the shape, with its fills under its lines as Flash does. Bitmap
+// fills are grey as the bitmaps aren't in the shape.
+func (shape *Shape) SVG() []byte {
+	var defs, body strings.Builder
+	var gradients int
+
+	paint := func(fill FillStyle) (string, float64) {
+		switch fill.Type {
+		case fillSolid:
+			return fill.Colour.hex(), fill.Colour.opacity()
+
+		case fillLinearGradient, fillRadialGradient, fillFocalRadialGradient:
+			gradients++
+			id := fmt.Sprintf("gradient%d", gradients)
+			writeGradient(&defs, id, fill)
+			return "url(#" + id + ")", 1
+
+		default:
+			return "#808080", 1
+		}
+	}
+
+	fillRule := "evenodd"
+	if shape.NonZero {
+		fillRule = "nonzero"
+	}
+
+	for _, group := range shape.groups {
+		for i, edges := range group.fillEdges {
+			if len(edges) == 0 {
+				continue
+			}
+
+			colour, opacity := paint(group.fills[i])
+			fmt.Fprintf(&body, `<path fill="%s"`, colour)
+			if opacity < 1 {
+				fmt.Fprintf(&body, ` fill-opacity="%s"`, formatNumber(opacity))
+			}
+			fmt.Fprintf(&body, ` fill-rule="%s" d="%s"/>`+"\n", fillRule, closedPath(edges))
+		}
+
+		for i, edges := range group.lineEdges {
+			if len(edges) == 0 {
+				continue
+			}
+
+			line := group.lines[i]
+
+			colour, opacity := line.Colour.hex(), line.Colour.opacity()
+			if line.Fill != nil {
+				colour, opacity = paint(*line.Fill)
+			}
+
+			// hairlines are a pixel wide whatever the scale
+			width := float64(line.Width) / twipsPerPixel
+			if line.Width == 0 {
+				width = 1
+			}
+
+			fmt.Fprintf(&body, `<path fill="none" stroke="%s" stroke-width="%s"`, colour, formatNumber(width))
+			if opacity < 1 {
+				fmt.Fprintf(&body, ` stroke-opacity="%s"`, formatNumber(opacity))
+			}
+
+			// svg has the one cap for both ends, so the start one is used
+			fmt.Fprintf(&body, ` stroke-linecap="%s"`, [...]string{"round", "butt", "square", "round"}[line.StartCap&3])
+			switch line.Join {
+			case 1:
+				body.WriteString(` stroke-linejoin="bevel"`)
+			case 2:
+				fmt.Fprintf(&body, ` stroke-linejoin="miter" stroke-miterlimit="%s"`, formatNumber(math.Max(line.MiterLimit, 1)))
+			default:
+				body.WriteString(` stroke-linejoin="round"`)
+			}
+
+			fmt.Fprintf(&body, ` d="%s"/>`+"\n", openPath(edges))
+		}
+	}
+
+	bounds := shape.Bounds
+	width := float64(bounds.XMax-bounds.XMin) / twipsPerPixel
+	height := float64(bounds.YMax-bounds.YMin) / twipsPerPixel
+
+	var out strings.Builder
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&out, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="%s %s %s %s">`+"\n",
+		formatNumber(width), formatNumber(height),
+		formatTwips(bounds.XMin), formatTwips(bounds.YMin), formatNumber(width), formatNumber(height))
+
+	if defs.Len() > 0 {
+		out.WriteString("<defs>\n")
+		out.WriteString(defs.String())
+		out.WriteString("</defs>\n")
+	}
+
+	out.WriteString(body.String())
+	out.WriteString("</svg>\n")
+
+	return []byte(out.String())
+}
+
+func writeGradient(out *strings.Builder, id string, fill FillStyle) {
+	square := formatTwips(gradientSquare)
+
+	m := fill.Matrix
+	transform := fmt.Sprintf("matrix(%s %s %s %s %s %s)",
+		formatNumber(m.ScaleX), formatNumber(m.RotateSkew0),
+		formatNumber(m.RotateSkew1), formatNumber(m.ScaleY),
+		formatTwips(m.TranslateX), formatTwips(m.TranslateY))
+
+	spread := [...]string{"pad", "reflect", "repeat", "pad"}[fill.Gradient.Spread&3]
+
+	element := "linearGradient"
+	if fill.Type == fillLinearGradient {
+		fmt.Fprintf(out, `<linearGradient id="%s" x1="-%s" y1="0" x2="%s" y2="0"`, id, square, square)
+	} else {
+		element = "radialGradient"
+		fmt.Fprintf(out, `<radialGradient id="%s" cx="0" cy="0" r="%s"`, id, square)
+		if fill.Gradient.Focal != 0 {
+			fmt.Fprintf(out, ` fx="%s" fy="0"`, formatNumber(fill.Gradient.Focal*gradientSquare/twipsPerPixel))
+		}
+	}
+
+	fmt.Fprintf(out, ` gradientUnits="userSpaceOnUse" gradientTransform="%s" spreadMethod="%s"`, transform, spread)
+	if fill.Gradient.Interpolation == 1 {
+		out.WriteString(` color-interpolation="linearRGB"`)
+	}
+	out.WriteString(">\n")
+
+	for _, record := range fill.Gradient.Records {
+		fmt.Fprintf(out, `<stop offset="%s" stop-color="%s"`, formatNumber(float64(record.Ratio)/255), record.Colour.hex())
+		if opacity := record.Colour.opacity(); opacity < 1 {
+			fmt.Fprintf(out, ` stop-opacity="%s"`, formatNumber(opacity))
+		}
+		out.WriteString("/>\n")
+	}
+
+	fmt.Fprintf(out, "</%s>\n", element)
+}
+
+// closedPath joins the edges of a fill end to end into the outlines they
+// make, which the shape records can have in any order
+func closedPath(edges []edge) string {
+	starts := map[point][]int{}
+	for i, e := range edges {
+		starts[e.from] = append(starts[e.from], i)
+	}
+
+	used := make([]bool, len(edges))
+
+	var out strings.Builder
+	for i := range edges {
+		if used[i] {
+			continue
+		}
+
+		first := edges[i].from
+		writeMove(&out, first)
+
+		current := i
+		for {
+			used[current] = true
+			writeEdge(&out, edges[current])
+
+			end := edges[current].to
+			if end == first {
+				out.WriteString("Z")
+				break
+			}
+
+			next := -1
+			for _, j := range starts[end] {
+				if !used[j] {
+					next = j
+					break
+				}
+			}
+
+			if next < 0 {
+				break
+			}
+
+			current = next
+		}
+	}
+
+	return out.String()
+}
+
+// openPath draws the edges of a line in the order they were drawn in
+func openPath(edges []edge) string {
+	var out strings.Builder
+
+	for i, e := range edges {
+		if i == 0 || edges[i-1].to != e.from {
+			writeMove(&out, e.from)
+		}
+
+		writeEdge(&out, e)
+	}
+
+	return out.String()
+}
+
+func writeMove(out *strings.Builder, p point) {
+	fmt.Fprintf(out, "M%s %s", formatTwips(p.x), formatTwips(p.y))
+}
+
+func writeEdge(out *strings.Builder, e edge) {
+	if e.curved {
+		fmt.Fprintf(out, "Q%s %s %s %s", formatTwips(e.control.x), formatTwips(e.control.y), formatTwips(e.to.x), formatTwips(e.to.y))
+	} else {
+		fmt.Fprintf(out, "L%s %s", formatTwips(e.to.x), formatTwips(e.to.y))
+	}
+}
+
+func formatTwips(twips int32) string {
+	return formatNumber(float64(twips) / twipsPerPixel)
+}
+
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func (colour Colour) hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", colour.R, colour.G, colour.B)
+}
+
+func (colour Colour) opacity() float64 {
+	return math.Round(float64(colour.A)/255*1000) / 1000
+}
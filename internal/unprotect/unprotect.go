@@ -0,0 +1,99 @@
+package unprotect
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/markhughes/dirry/internal/consts"
+	"github.com/markhughes/dirry/internal/shockwave"
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// the editable extension of each protected one
+var extensions = map[string]string{
+	".dcr": ".dir",
+	".dxr": ".dir",
+	".cct": ".cst",
+	".cxt": ".cst",
+}
+
+// Unprotect rewrites a movie or cast as a plain RIFX into outputFolder, or
+// the dump folder when it's empty. Projectors have each of their movies
+// written out.
+func Unprotect(filePath string, outputFolder string) error {
+	return unprotect(filePath, outputFolder, "", 0)
+}
+
+func unprotect(filePath string, outputFolder string, pkg string, extraOffset int64) error {
+	var movie shockwave.Shockwave
+	movie.PkgName = pkg
+	movie.DirOffset = extraOffset
+
+	expanded, err := movie.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("error opening file: %s", err)
+	}
+	defer movie.Close()
+
+	if len(expanded) > 0 {
+		utils.InfoMsg("unprotect", "Expanded %s to %d files\n", filePath, len(expanded))
+
+		for i := range expanded {
+			if expanded[i].Xtra {
+				continue
+			}
+
+			err := unprotect(expanded[i].Path, outputFolder, filepath.Base(filePath), expanded[i].MinusOffset)
+			if err != nil {
+				utils.ErrorMsg("unprotect", "Error unprotecting %s: %s", expanded[i].Path, err)
+			}
+		}
+
+		return nil
+	}
+
+	if outputFolder == "" {
+		if pkg == "" {
+			outputFolder = filepath.Join(consts.PathDump, filepath.Base(filePath), "unprotected")
+		} else {
+			outputFolder = filepath.Join(consts.PathDump, pkg, "file", filepath.Base(filePath), "unprotected")
+		}
+	}
+
+	err = os.MkdirAll(outputFolder, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	outputFile := filepath.Join(outputFolder, OutputName(filePath, movie.Codec.Name))
+
+	written, err := movie.WriteRIFXFile(outputFile)
+	if err != nil {
+		return err
+	}
+	defer written.Close()
+
+	utils.SuccessMsg("unprotect", "Wrote %s with %d resources", outputFile, len(written.ChunkMap.GetAllResources()))
+
+	return nil
+}
+
+// OutputName swaps the extension for the editable one, casts are told apart
+// by their codec when the extension doesn't say
+func OutputName(filePath string, codec string) string {
+	name := filepath.Base(filePath)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+
+	if editable, ok := extensions[strings.ToLower(ext)]; ok {
+		return stem + editable
+	}
+
+	if codec == "FGDC" || codec == "MC95" {
+		return stem + ".cst"
+	}
+
+	return stem + ".dir"
+}
@@ -2,11 +2,13 @@ package utils
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/markhughes/dirry/internal/consts"
@@ -21,6 +23,25 @@ var EnabledDebugAll bool = false
 
 var EnableLogging bool = false
 
+// Output is where messages are printed, set it to io.Discard to keep quiet
+var Output io.Writer = os.Stdout
+
+var redirectLock sync.Mutex
+
+// Redirect prints messages to w until restore is called, for a caller that
+// wants them somewhere else for a while. Redirects wait for each other.
+func Redirect(w io.Writer) (restore func()) {
+	redirectLock.Lock()
+
+	previous := Output
+	Output = w
+
+	return func() {
+		Output = previous
+		redirectLock.Unlock()
+	}
+}
+
 // log ifle name sohudl be dirry_<timestamp>.log
 var LogPrefix string = "dirry_" + time.Now().Format("2006-01-02-15.04.05")
 var LogfileName string = LogPrefix + ".log"
@@ -70,22 +91,22 @@ func SaveLog(logType string, category string, format string, a ...interface{}) {
 }
 
 func InfoMsg(category string, format string, a ...interface{}) {
-	fmt.Printf(format+"\n", a...)
+	fmt.Fprintf(Output, format+"\n", a...)
 	SaveLog("INFO", category, format, a...)
 }
 
 func WarnMsg(category string, format string, a ...interface{}) {
-	fmt.Printf("\033[33m⚠️\033[0m "+format+"\n", a...)
+	fmt.Fprintf(Output, "\033[33m⚠️\033[0m "+format+"\n", a...)
 	SaveLog("WARN", category, format, a...)
 }
 
 func SuccessMsg(category string, format string, a ...interface{}) {
-	fmt.Printf("\033[32m✔\033[0m "+format+"\n", a...)
+	fmt.Fprintf(Output, "\033[32m✔\033[0m "+format+"\n", a...)
 	SaveLog("INFO", category, format, a...)
 }
 
 func ErrorMsg(category string, format string, a ...interface{}) {
-	fmt.Printf("\033[31m✖\033[0m "+format+"\n", a...)
+	fmt.Fprintf(Output, "\033[31m✖\033[0m "+format+"\n", a...)
 	SaveLog("ERRO", category, format, a...)
 }
 
@@ -131,6 +152,6 @@ func DebugMsg(category string, format string, a ...interface{}) {
 		coloredPaddedLineNumber2 += white + paddedLineNumber2[nonZeroIndex2:] + reset
 	}
 
-	fmt.Printf("%s - [%s:%s]>[%s:%s]: ", category, fileName1, coloredPaddedLineNumber1, fileName2, coloredPaddedLineNumber2)
-	fmt.Printf(format+reset+"\n", a...)
+	fmt.Fprintf(Output, "%s - [%s:%s]>[%s:%s]: ", category, fileName1, coloredPaddedLineNumber1, fileName2, coloredPaddedLineNumber2)
+	fmt.Fprintf(Output, format+reset+"\n", a...)
 }
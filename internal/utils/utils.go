@@ -143,3 +143,20 @@ func ReadUInt8(r io.Reader) (uint8, error) {
 func CleanString(str string) string {
 	return regexp.MustCompile(`[^a-zA-Z0-9 ]+`).ReplaceAllString(str, "")
 }
+
+// CRC16 is the CCITT CRC that MacBinary and BinHex check their data with,
+// the XMODEM one starting from zero
+func CRC16(b []byte) uint16 {
+	var crc uint16
+	for _, c := range b {
+		crc ^= uint16(c) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
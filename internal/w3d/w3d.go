@@ -0,0 +1,277 @@
+package w3d
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// Shockwave 3D is the IFX format Intel later gave away as U3D (ECMA-363), a
+// list of blocks that are each a type, the size of the data, the size of the
+// meta data and then both padded to 4 bytes
+// https://www.ecma-international.org/publications-and-standards/standards/ecma-363/
+const blockHeaderLength = 12
+
+// block types
+const (
+	blockU3DHeader         = 0x00443355 // "U3D"
+	blockIFXHeader         = 0x00584649 // "IFX"
+	blockFileReference     = 0xFFFFFF12
+	blockModifierChain     = 0xFFFFFF14
+	blockPriorityUpdate    = 0xFFFFFF15
+	blockNewObjectType     = 0xFFFFFF16
+	blockGroupNode         = 0xFFFFFF21
+	blockModelNode         = 0xFFFFFF22
+	blockLightNode         = 0xFFFFFF23
+	blockViewNode          = 0xFFFFFF24
+	blockMeshDeclaration   = 0xFFFFFF31
+	blockPointSet          = 0xFFFFFF36
+	blockLineSet           = 0xFFFFFF37
+	blockBaseMesh          = 0xFFFFFF3B
+	blockProgressiveMesh   = 0xFFFFFF3C
+	blockGlyphModifier     = 0xFFFFFF41
+	blockSubdivision       = 0xFFFFFF42
+	blockAnimationModifier = 0xFFFFFF43
+	blockBoneWeight        = 0xFFFFFF44
+	blockShadingModifier   = 0xFFFFFF45
+	blockClodModifier      = 0xFFFFFF46
+	blockLightResource     = 0xFFFFFF51
+	blockViewResource      = 0xFFFFFF52
+	blockLitTextureShader  = 0xFFFFFF53
+	blockMaterial          = 0xFFFFFF54
+	blockTexture           = 0xFFFFFF55
+	blockMotion            = 0xFFFFFF56
+	blockTextureContinued  = 0xFFFFFF5C
+)
+
+// modifier chain attributes
+const (
+	chainBoundingSphere = 0x01
+	chainBoundingBox    = 0x02
+)
+
+var chainTypes = []string{"node", "model", "texture"}
+
+type Header struct {
+	MajorVersion      int16
+	MinorVersion      int16
+	Profile           uint32
+	DeclarationSize   uint32
+	FileSize          uint64
+	CharacterEncoding uint32
+	UnitsScale        float64 `json:",omitempty"`
+}
+
+// Scene is everything read out of a Shockwave 3D member, resources are kept
+// by their names which is how the nodes and shaders refer to them
+type Scene struct {
+	Header Header
+
+	Nodes     []*Node
+	Meshes    map[string]*Mesh
+	Shading   map[string]*ShadingModifier
+	Shaders   map[string]*Shader
+	Materials map[string]*Material
+	Textures  map[string]*Texture
+	Lights    map[string]*Light
+	Views     []string
+
+	Motions    map[string]*Motion
+	Animations map[string]*AnimationModifier
+
+	// Skipped counts the blocks that aren't read yet by their type
+	Skipped map[string]int `json:",omitempty"`
+}
+
+// Parse reads the blocks of a Shockwave 3D member, a block that can't be
+// read is skipped rather than giving up on the rest
+func Parse(data []byte) (*Scene, error) {
+	scene := &Scene{
+		Meshes:     make(map[string]*Mesh),
+		Shading:    make(map[string]*ShadingModifier),
+		Shaders:    make(map[string]*Shader),
+		Materials:  make(map[string]*Material),
+		Textures:   make(map[string]*Texture),
+		Lights:     make(map[string]*Light),
+		Motions:    make(map[string]*Motion),
+		Animations: make(map[string]*AnimationModifier),
+	}
+
+	if len(data) < blockHeaderLength {
+		return nil, fmt.Errorf("too short for a block")
+	}
+
+	magic := binary.LittleEndian.Uint32(data)
+	if magic != blockU3DHeader && magic != blockIFXHeader {
+		return nil, fmt.Errorf("does not start with a file header block (%08X)", magic)
+	}
+
+	err := scene.readBlocks(data, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	return scene, nil
+}
+
+// readBlocks reads count blocks from data, or all of them when count is -1
+func (scene *Scene) readBlocks(data []byte, count int) error {
+	offset := 0
+	for i := 0; (count < 0 || i < count) && offset < len(data); i++ {
+		if offset+blockHeaderLength > len(data) {
+			return fmt.Errorf("block header at %d is past the end", offset)
+		}
+
+		blockType := binary.LittleEndian.Uint32(data[offset:])
+		dataSize := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		metaSize := int(binary.LittleEndian.Uint32(data[offset+8:]))
+
+		start := offset + blockHeaderLength
+		if dataSize < 0 || start+dataSize > len(data) {
+			return fmt.Errorf("block %08X at %d has %d bytes, past the end", blockType, offset, dataSize)
+		}
+
+		err := scene.readBlock(blockType, data[start:start+dataSize])
+		if err != nil {
+			utils.WarnMsg("w3d", "Could not read block %08X at %d: %s", blockType, offset, err)
+		}
+
+		offset = start + pad4(dataSize) + pad4(metaSize)
+	}
+
+	return nil
+}
+
+func (scene *Scene) readBlock(blockType uint32, data []byte) error {
+	s := newBitStream(data)
+
+	switch blockType {
+	case blockU3DHeader, blockIFXHeader:
+		scene.Header.MajorVersion = s.i16()
+		scene.Header.MinorVersion = s.i16()
+		scene.Header.Profile = s.u32()
+		scene.Header.DeclarationSize = s.u32()
+		scene.Header.FileSize = s.u64()
+		scene.Header.CharacterEncoding = s.u32()
+
+		// the profile says the units are scaled
+		if scene.Header.Profile&0x08 != 0 {
+			scene.Header.UnitsScale = s.f64()
+		}
+
+	case blockModifierChain:
+		return scene.readModifierChain(s)
+
+	case blockGroupNode, blockModelNode, blockLightNode, blockViewNode:
+		scene.Nodes = append(scene.Nodes, readNode(s, blockType))
+
+	case blockMeshDeclaration:
+		mesh := readMeshDeclaration(s)
+		scene.Meshes[mesh.Name] = mesh
+
+	case blockBaseMesh:
+		name := s.str()
+		mesh, ok := scene.Meshes[name]
+		if !ok {
+			return fmt.Errorf("base mesh of %q was not declared", name)
+		}
+
+		mesh.readBaseMesh(s)
+
+	case blockProgressiveMesh:
+		name := s.str()
+		mesh, ok := scene.Meshes[name]
+		if !ok {
+			return fmt.Errorf("progressive mesh of %q was not declared", name)
+		}
+
+		mesh.readProgressiveMesh(s)
+
+	case blockShadingModifier:
+		shading := readShadingModifier(s)
+		scene.Shading[shading.Name] = shading
+
+	case blockLitTextureShader:
+		shader := readShader(s)
+		scene.Shaders[shader.Name] = shader
+
+	case blockMaterial:
+		material := readMaterial(s)
+		scene.Materials[material.Name] = material
+
+	case blockTexture:
+		texture := readTexture(s)
+		scene.Textures[texture.Name] = texture
+
+	case blockTextureContinued:
+		name := s.str()
+		texture, ok := scene.Textures[name]
+		if !ok {
+			return fmt.Errorf("texture %q was not declared", name)
+		}
+
+		texture.readContinuation(s)
+
+	case blockLightResource:
+		light := readLight(s)
+		scene.Lights[light.Name] = light
+
+	case blockViewResource:
+		scene.Views = append(scene.Views, s.str())
+
+	case blockMotion:
+		motion := readMotion(s)
+		scene.Motions[motion.Name] = motion
+
+	case blockAnimationModifier:
+		animation := readAnimationModifier(s)
+		scene.Animations[animation.Name] = animation
+
+	default:
+		if scene.Skipped == nil {
+			scene.Skipped = make(map[string]int)
+		}
+
+		scene.Skipped[fmt.Sprintf("%08X", blockType)]++
+		return nil
+	}
+
+	return s.err
+}
+
+// readModifierChain reads the blocks of a node or a resource, and what they
+// have done to it
+func (scene *Scene) readModifierChain(s *bitStream) error {
+	name := s.str()
+	chainType := s.u32()
+	attributes := s.u32()
+
+	if attributes&chainBoundingSphere != 0 {
+		s.floats(4)
+	}
+
+	if attributes&chainBoundingBox != 0 {
+		s.floats(6)
+	}
+
+	// the blocks in it start on 4 bytes
+	start := pad4((s.pos + 7) / 8)
+	if s.err != nil || start+4 > len(s.data) {
+		return fmt.Errorf("modifier chain %q is truncated", name)
+	}
+
+	count := int(binary.LittleEndian.Uint32(s.data[start:]))
+
+	kind := fmt.Sprint(chainType)
+	if int(chainType) < len(chainTypes) {
+		kind = chainTypes[chainType]
+	}
+	utils.DebugMsg("w3d", "Modifier chain %q of a %s with %d blocks", name, kind, count)
+
+	return scene.readBlocks(s.data[start+4:], count)
+}
+
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
@@ -0,0 +1,312 @@
+package w3d
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// The block data is a bit stream read through an arithmetic coder. Values
+// that aren't compressed come out the same as the bytes they were, and the
+// compressed ones are read with a context, either a histogram that learns
+// the values as they're read or a range the value is evenly spread over.
+const (
+	// contexts from acStaticFull up are a range of the context less
+	// acStaticFull, and past acMaxRange they're written without compression
+	acStaticFull = 0x400
+	acMaxRange   = acStaticFull + 0x3FFF
+
+	// a histogram is halved when it counts this many
+	acElephant = 0x1FFF
+
+	acHalf    = 0x8000
+	acQuarter = 0x4000
+)
+
+// histogram counts the symbols read with a dynamic context in order of the
+// symbol, symbol 0 is the escape that says the value follows uncompressed
+type histogram struct {
+	symbols []uint32
+	counts  []uint32
+	total   uint32
+}
+
+func newHistogram() *histogram {
+	return &histogram{symbols: []uint32{0}, counts: []uint32{1}, total: 1}
+}
+
+func (h *histogram) add(symbol uint32) {
+	i := sort.Search(len(h.symbols), func(i int) bool {
+		return h.symbols[i] >= symbol
+	})
+
+	if i == len(h.symbols) || h.symbols[i] != symbol {
+		h.symbols = append(h.symbols, 0)
+		h.counts = append(h.counts, 0)
+		copy(h.symbols[i+1:], h.symbols[i:])
+		copy(h.counts[i+1:], h.counts[i:])
+		h.symbols[i] = symbol
+		h.counts[i] = 0
+	}
+
+	h.counts[i]++
+	h.total++
+
+	if h.total > acElephant {
+		h.total = 0
+		for i := range h.counts {
+			h.counts[i] >>= 1
+			if i == 0 && h.counts[i] == 0 {
+				h.counts[i] = 1
+			}
+			h.total += h.counts[i]
+		}
+	}
+}
+
+// bitStream reads one block's data. It keeps the first error and reads
+// zeroes after it.
+type bitStream struct {
+	data []byte
+	pos  int // in bits
+
+	low       uint32
+	high      uint32
+	underflow int
+
+	contexts map[uint32]*histogram
+
+	err error
+}
+
+func newBitStream(data []byte) *bitStream {
+	return &bitStream{
+		data:     data,
+		high:     0xFFFF,
+		contexts: make(map[uint32]*histogram),
+	}
+}
+
+func (s *bitStream) bit(pos int) uint32 {
+	if pos/8 >= len(s.data) {
+		return 0
+	}
+
+	return uint32(s.data[pos/8]>>uint(pos%8)) & 1
+}
+
+// code is the 16 bits the coder decodes from, the first bit and then what's
+// after the bits that underflowed
+func (s *bitStream) code() uint32 {
+	code := s.bit(s.pos) << 15
+
+	pos := s.pos + 1 + s.underflow
+	for i := 14; i >= 0; i-- {
+		code |= s.bit(pos) << uint(i)
+		pos++
+	}
+
+	return code
+}
+
+// decode finds the symbol the code falls in out of total, find gives the
+// symbol a count is in and the counts it covers
+func (s *bitStream) decode(total uint32, find func(count uint32) (symbol uint32, low uint32, high uint32)) uint32 {
+	if s.err != nil {
+		return 0
+	}
+
+	rng := s.high + 1 - s.low
+	count := ((s.code()-s.low+1)*total - 1) / rng
+	symbol, cumLow, cumHigh := find(count)
+
+	s.high = s.low - 1 + rng*cumHigh/total
+	s.low = s.low + rng*cumLow/total
+
+	for {
+		if s.low&acHalf == s.high&acHalf {
+			s.low = (s.low << 1) & 0xFFFF
+			s.high = ((s.high << 1) & 0xFFFF) | 1
+			s.pos += 1 + s.underflow
+			s.underflow = 0
+		} else if s.low&acQuarter != 0 && s.high&acQuarter == 0 {
+			s.low = (s.low & (acQuarter - 1)) << 1
+			s.high = (((s.high | acQuarter) << 1) & 0xFFFF) | 1
+			s.underflow++
+		} else {
+			break
+		}
+	}
+
+	if s.pos > len(s.data)*8 {
+		s.err = fmt.Errorf("read past the end of the block")
+	}
+
+	return symbol
+}
+
+// uniform reads a symbol of a range where every symbol is as likely
+func (s *bitStream) uniform(total uint32) uint32 {
+	return s.decode(total, func(count uint32) (uint32, uint32, uint32) {
+		return count, count, count + 1
+	})
+}
+
+// raw is whether the coder is where bytes can be taken as they are
+func (s *bitStream) raw() bool {
+	return s.low == 0 && s.high == 0xFFFF && s.underflow == 0 && s.pos%8 == 0
+}
+
+func (s *bitStream) u8() uint8 {
+	if s.raw() {
+		if s.pos/8 >= len(s.data) {
+			if s.err == nil {
+				s.err = fmt.Errorf("read past the end of the block")
+			}
+			return 0
+		}
+
+		value := s.data[s.pos/8]
+		s.pos += 8
+		return value
+	}
+
+	// the symbols are written with the bits the other way round
+	symbol := s.uniform(256)
+
+	var value uint8
+	for i := 0; i < 8; i++ {
+		value |= uint8((symbol>>uint(i))&1) << uint(7-i)
+	}
+
+	return value
+}
+
+func (s *bitStream) u16() uint16 {
+	return uint16(s.u8()) | uint16(s.u8())<<8
+}
+
+func (s *bitStream) u32() uint32 {
+	return uint32(s.u16()) | uint32(s.u16())<<16
+}
+
+func (s *bitStream) u64() uint64 {
+	return uint64(s.u32()) | uint64(s.u32())<<32
+}
+
+func (s *bitStream) i16() int16 {
+	return int16(s.u16())
+}
+
+func (s *bitStream) f32() float32 {
+	return math.Float32frombits(s.u32())
+}
+
+func (s *bitStream) f64() float64 {
+	return math.Float64frombits(s.u64())
+}
+
+func (s *bitStream) floats(n int) []float32 {
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = s.f32()
+	}
+
+	return out
+}
+
+func (s *bitStream) str() string {
+	length := int(s.u16())
+
+	out := make([]byte, 0, length)
+	for i := 0; i < length && s.err == nil; i++ {
+		out = append(out, s.u8())
+	}
+
+	return string(out)
+}
+
+// bytes reads what's left of the block
+func (s *bitStream) bytes() []byte {
+	if s.raw() {
+		out := s.data[minInt(s.pos/8, len(s.data)):]
+		s.pos = len(s.data) * 8
+		return out
+	}
+
+	var out []byte
+	for s.pos < len(s.data)*8 && s.err == nil {
+		out = append(out, s.u8())
+	}
+
+	return out
+}
+
+// compressed reads a value with a context, a histogram for the contexts
+// under acStaticFull, a range up to acMaxRange and uncompressed past it
+func (s *bitStream) compressed(context uint32) uint32 {
+	return s.compressedValue(context, func() uint32 {
+		return s.u32()
+	})
+}
+
+// compressedU8 is the same for a byte, which is what follows an escape
+func (s *bitStream) compressedU8(context uint32) uint8 {
+	return uint8(s.compressedValue(context, func() uint32 {
+		return uint32(s.u8())
+	}))
+}
+
+func (s *bitStream) compressedValue(context uint32, literal func() uint32) uint32 {
+	if context == 0 || context >= acMaxRange {
+		return literal()
+	}
+
+	if context >= acStaticFull {
+		return s.uniform(context - acStaticFull)
+	}
+
+	h, ok := s.contexts[context]
+	if !ok {
+		h = newHistogram()
+		s.contexts[context] = h
+	}
+
+	symbol := s.decode(h.total, func(count uint32) (uint32, uint32, uint32) {
+		var cumulative uint32
+		for i, n := range h.counts {
+			if count < cumulative+n {
+				return h.symbols[i], cumulative, cumulative + n
+			}
+			cumulative += n
+		}
+
+		// only a broken stream gets here
+		return 0, 0, h.counts[0]
+	})
+
+	if symbol != 0 {
+		h.add(symbol)
+		return symbol - 1
+	}
+
+	value := literal()
+	h.add(value + 1)
+	return value
+}
+
+// index reads an index into a list of count things
+func (s *bitStream) index(count uint32) uint32 {
+	if count == 0 {
+		return 0
+	}
+
+	return s.compressed(acStaticFull + count)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,525 @@
+package w3d
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"math"
+
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// glTF 2.0, with the buffer and the images in the file as data URIs
+// https://registry.khronos.org/glTF/specs/2.0/glTF-2.0.html
+const (
+	gltfFloat        = 5126
+	gltfUnsignedInt  = 5125
+	gltfArrayBuffer  = 34962
+	gltfElementArray = 34963
+	gltfTriangles    = 4
+)
+
+type gltfDocument struct {
+	Asset       gltfAsset        `json:"asset"`
+	Scene       int              `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes,omitempty"`
+	Meshes      []gltfMesh       `json:"meshes,omitempty"`
+	Materials   []gltfMaterial   `json:"materials,omitempty"`
+	Textures    []gltfTexture    `json:"textures,omitempty"`
+	Images      []gltfImage      `json:"images,omitempty"`
+	Accessors   []gltfAccessor   `json:"accessors,omitempty"`
+	BufferViews []gltfBufferView `json:"bufferViews,omitempty"`
+	Buffers     []gltfBuffer     `json:"buffers,omitempty"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name     string    `json:"name,omitempty"`
+	Mesh     *int      `json:"mesh,omitempty"`
+	Matrix   []float32 `json:"matrix,omitempty"`
+	Children []int     `json:"children,omitempty"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name,omitempty"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Material   *int           `json:"material,omitempty"`
+	Mode       int            `json:"mode"`
+}
+
+type gltfMaterial struct {
+	Name           string            `json:"name,omitempty"`
+	Pbr            gltfPbr           `json:"pbrMetallicRoughness"`
+	EmissiveFactor []float32         `json:"emissiveFactor,omitempty"`
+	AlphaMode      string            `json:"alphaMode,omitempty"`
+	Extras         map[string]string `json:"extras,omitempty"`
+}
+
+type gltfPbr struct {
+	BaseColorFactor  []float32       `json:"baseColorFactor"`
+	BaseColorTexture *gltfTextureRef `json:"baseColorTexture,omitempty"`
+	MetallicFactor   float32         `json:"metallicFactor"`
+	RoughnessFactor  float32         `json:"roughnessFactor"`
+}
+
+type gltfTextureRef struct {
+	Index int `json:"index"`
+}
+
+type gltfTexture struct {
+	Source int `json:"source"`
+}
+
+type gltfImage struct {
+	Name string `json:"name,omitempty"`
+	Uri  string `json:"uri"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float32 `json:"min,omitempty"`
+	Max           []float32 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target,omitempty"`
+}
+
+type gltfBuffer struct {
+	ByteLength int    `json:"byteLength"`
+	Uri        string `json:"uri"`
+}
+
+// gltfWriter builds the document and its one buffer, the materials and
+// textures are made once however many meshes use them
+type gltfWriter struct {
+	scene *Scene
+	doc   gltfDocument
+	buf   bytes.Buffer
+
+	meshes      map[string]int
+	materials   map[string]int
+	textures    map[string]int
+	transparent map[string]bool
+}
+
+// vertex is a corner of a face as glTF wants it, with one index for all of
+// what it has
+type vertex struct {
+	position uint32
+	normal   uint32
+	texCoord uint32
+	diffuse  uint32
+}
+
+// GLTF converts the scene to glTF, the nodes with the meshes of their models
+// and the materials and textures of their shaders
+func (scene *Scene) GLTF() ([]byte, error) {
+	w := &gltfWriter{
+		scene:       scene,
+		meshes:      make(map[string]int),
+		materials:   make(map[string]int),
+		textures:    make(map[string]int),
+		transparent: make(map[string]bool),
+	}
+
+	w.doc.Asset = gltfAsset{Version: "2.0", Generator: "dirry"}
+	w.doc.Scenes = []gltfScene{{Nodes: []int{}}}
+
+	indices := make(map[string]int)
+	for _, node := range scene.Nodes {
+		out := gltfNode{Name: node.Name}
+
+		if len(node.Parents) > 0 && !isIdentity(node.Parents[0].Transform) {
+			out.Matrix = node.Parents[0].Transform
+		}
+
+		if node.Type == "model" {
+			mesh, ok := w.mesh(node)
+			if ok {
+				out.Mesh = &mesh
+			}
+		}
+
+		indices[node.Name] = len(w.doc.Nodes)
+		w.doc.Nodes = append(w.doc.Nodes, out)
+	}
+
+	// glTF has a node under one parent, the first is the one it's put under
+	for _, node := range scene.Nodes {
+		index := indices[node.Name]
+
+		parent, ok := -1, false
+		if len(node.Parents) > 0 && node.Parents[0].Name != worldNode {
+			parent, ok = indices[node.Parents[0].Name]
+		}
+
+		if ok && parent != index {
+			w.doc.Nodes[parent].Children = append(w.doc.Nodes[parent].Children, index)
+		} else {
+			w.doc.Scenes[0].Nodes = append(w.doc.Scenes[0].Nodes, index)
+		}
+	}
+
+	if w.buf.Len() > 0 {
+		w.doc.Buffers = []gltfBuffer{{
+			ByteLength: w.buf.Len(),
+			Uri:        "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(w.buf.Bytes()),
+		}}
+	}
+
+	return json.MarshalIndent(w.doc, "", "  ")
+}
+
+// mesh is the glTF mesh of the model a node shows, made the first time it's
+// asked for
+func (w *gltfWriter) mesh(node *Node) (int, bool) {
+	if index, ok := w.meshes[node.Resource]; ok {
+		return index, true
+	}
+
+	mesh, ok := w.scene.Meshes[node.Resource]
+	if !ok || len(mesh.Faces) == 0 {
+		utils.WarnMsg("w3d", "Model %q has no mesh %q to show", node.Name, node.Resource)
+		return 0, false
+	}
+
+	if mesh.Progressive > mesh.Resolution {
+		utils.WarnMsg("w3d", "Mesh %q only has %d of its %d positions, the rest are progressive", mesh.Name, mesh.Resolution, mesh.Progressive)
+	}
+
+	// the shaders are picked by the model resource, or by the node
+	shading, ok := w.scene.Shading[mesh.Name]
+	if !ok {
+		shading = w.scene.Shading[node.Name]
+	}
+
+	out := gltfMesh{Name: mesh.Name}
+	for shadingId := range mesh.Shadings {
+		primitive, ok := w.primitive(mesh, uint32(shadingId))
+		if !ok {
+			continue
+		}
+
+		if shading != nil && shadingId < len(shading.Shaders) && len(shading.Shaders[shadingId]) > 0 {
+			material := w.material(shading.Shaders[shadingId][0])
+			primitive.Material = &material
+		}
+
+		out.Primitives = append(out.Primitives, primitive)
+	}
+
+	if len(out.Primitives) == 0 {
+		return 0, false
+	}
+
+	index := len(w.doc.Meshes)
+	w.doc.Meshes = append(w.doc.Meshes, out)
+	w.meshes[node.Resource] = index
+
+	return index, true
+}
+
+// primitive is the faces of a mesh drawn with one shading
+func (w *gltfWriter) primitive(mesh *Mesh, shadingId uint32) (gltfPrimitive, bool) {
+	shading := mesh.Shadings[shadingId]
+
+	hasNormals := mesh.Attributes&meshExcludeNormals == 0 && len(mesh.Normals) > 0
+	hasTexCoords := len(shading.TextureLayers) > 0 && len(mesh.TexCoords) > 0
+	hasDiffuse := shading.Attributes&shadingDiffuseColours != 0 && len(mesh.Diffuse) > 0
+
+	var vertices []vertex
+	var indices []uint32
+	seen := make(map[vertex]uint32)
+
+	for _, face := range mesh.Faces {
+		if face.Shading != shadingId {
+			continue
+		}
+
+		for _, corner := range face.Corners {
+			v := vertex{position: corner.Position}
+			if hasNormals {
+				v.normal = corner.Normal
+			}
+			if hasTexCoords && len(corner.TexCoords) > 0 {
+				v.texCoord = corner.TexCoords[0]
+			}
+			if hasDiffuse {
+				v.diffuse = corner.Diffuse
+			}
+
+			index, ok := seen[v]
+			if !ok {
+				index = uint32(len(vertices))
+				seen[v] = index
+				vertices = append(vertices, v)
+			}
+
+			indices = append(indices, index)
+		}
+	}
+
+	if len(indices) == 0 {
+		return gltfPrimitive{}, false
+	}
+
+	primitive := gltfPrimitive{Attributes: make(map[string]int), Mode: gltfTriangles}
+
+	positions := make([]float32, 0, len(vertices)*3)
+	for _, v := range vertices {
+		p := at3(mesh.Positions, v.position)
+		positions = append(positions, p[:]...)
+	}
+	primitive.Attributes["POSITION"] = w.accessor(positions, "VEC3", len(vertices), true)
+
+	if hasNormals {
+		normals := make([]float32, 0, len(vertices)*3)
+		for _, v := range vertices {
+			normals = append(normals, normalise(at3(mesh.Normals, v.normal))...)
+		}
+		primitive.Attributes["NORMAL"] = w.accessor(normals, "VEC3", len(vertices), false)
+	}
+
+	if hasTexCoords {
+		// glTF has v going down the image
+		texCoords := make([]float32, 0, len(vertices)*2)
+		for _, v := range vertices {
+			t := at4(mesh.TexCoords, v.texCoord)
+			texCoords = append(texCoords, t[0], 1-t[1])
+		}
+		primitive.Attributes["TEXCOORD_0"] = w.accessor(texCoords, "VEC2", len(vertices), false)
+	}
+
+	if hasDiffuse {
+		colours := make([]float32, 0, len(vertices)*4)
+		for _, v := range vertices {
+			c := at4(mesh.Diffuse, v.diffuse)
+			colours = append(colours, c[:]...)
+		}
+		primitive.Attributes["COLOR_0"] = w.accessor(colours, "VEC4", len(vertices), false)
+	}
+
+	primitive.Indices = w.indices(indices)
+
+	return primitive, true
+}
+
+// material is the glTF material of a shader, its material's colours and the
+// texture of its first layer
+func (w *gltfWriter) material(name string) int {
+	if index, ok := w.materials[name]; ok {
+		return index
+	}
+
+	out := gltfMaterial{
+		Name: name,
+		Pbr: gltfPbr{
+			BaseColorFactor: []float32{1, 1, 1, 1},
+			RoughnessFactor: 1,
+		},
+	}
+
+	if shader, ok := w.scene.Shaders[name]; ok {
+		if material, ok := w.scene.Materials[shader.Material]; ok && len(material.Diffuse) == 3 {
+			out.Pbr.BaseColorFactor = []float32{material.Diffuse[0], material.Diffuse[1], material.Diffuse[2], material.Opacity}
+			out.EmissiveFactor = material.Emissive
+
+			if material.Opacity < 1 {
+				out.AlphaMode = "BLEND"
+			}
+		}
+
+		if len(shader.Layers) > 0 {
+			texture, transparent, ok := w.texture(shader.Layers[0].Texture)
+			if ok {
+				out.Pbr.BaseColorTexture = &gltfTextureRef{Index: texture}
+
+				if transparent {
+					out.AlphaMode = "BLEND"
+				}
+			}
+
+			// glTF only takes the one
+			for _, layer := range shader.Layers[1:] {
+				if out.Extras == nil {
+					out.Extras = make(map[string]string)
+				}
+				out.Extras[fmt.Sprintf("layer%d", layer.Channel)] = layer.Texture
+			}
+		}
+	}
+
+	index := len(w.doc.Materials)
+	w.doc.Materials = append(w.doc.Materials, out)
+	w.materials[name] = index
+
+	return index
+}
+
+// texture is the glTF texture of a texture as a PNG, and whether it has any
+// alpha to it
+func (w *gltfWriter) texture(name string) (int, bool, bool) {
+	if index, ok := w.textures[name]; ok {
+		return index, w.transparent[name], true
+	}
+
+	texture, ok := w.scene.Textures[name]
+	if !ok {
+		return 0, false, false
+	}
+
+	img, err := texture.Image()
+	if err != nil {
+		utils.WarnMsg("w3d", "Could not convert texture %q: %s", name, err)
+		return 0, false, false
+	}
+
+	transparent := false
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] != 0xFF {
+			transparent = true
+			break
+		}
+	}
+
+	var encoded bytes.Buffer
+	err = png.Encode(&encoded, img)
+	if err != nil {
+		utils.WarnMsg("w3d", "Could not encode texture %q: %s", name, err)
+		return 0, false, false
+	}
+
+	w.doc.Images = append(w.doc.Images, gltfImage{
+		Name: name,
+		Uri:  "data:image/png;base64," + base64.StdEncoding.EncodeToString(encoded.Bytes()),
+	})
+
+	index := len(w.doc.Textures)
+	w.doc.Textures = append(w.doc.Textures, gltfTexture{Source: len(w.doc.Images) - 1})
+	w.textures[name] = index
+	w.transparent[name] = transparent
+
+	return index, transparent, true
+}
+
+func (w *gltfWriter) view(data interface{}, length int, target int) int {
+	offset := w.buf.Len()
+	binary.Write(&w.buf, binary.LittleEndian, data)
+
+	// accessors start on 4 bytes
+	for w.buf.Len()%4 != 0 {
+		w.buf.WriteByte(0)
+	}
+
+	w.doc.BufferViews = append(w.doc.BufferViews, gltfBufferView{
+		ByteOffset: offset,
+		ByteLength: length,
+		Target:     target,
+	})
+
+	return len(w.doc.BufferViews) - 1
+}
+
+func (w *gltfWriter) accessor(values []float32, kind string, count int, bounds bool) int {
+	accessor := gltfAccessor{
+		BufferView:    w.view(values, len(values)*4, gltfArrayBuffer),
+		ComponentType: gltfFloat,
+		Count:         count,
+		Type:          kind,
+	}
+
+	// positions have to say how far they go
+	if bounds && count > 0 {
+		size := len(values) / count
+		accessor.Min = append([]float32{}, values[:size]...)
+		accessor.Max = append([]float32{}, values[:size]...)
+
+		for i, value := range values {
+			accessor.Min[i%size] = float32(math.Min(float64(accessor.Min[i%size]), float64(value)))
+			accessor.Max[i%size] = float32(math.Max(float64(accessor.Max[i%size]), float64(value)))
+		}
+	}
+
+	w.doc.Accessors = append(w.doc.Accessors, accessor)
+	return len(w.doc.Accessors) - 1
+}
+
+func (w *gltfWriter) indices(values []uint32) int {
+	w.doc.Accessors = append(w.doc.Accessors, gltfAccessor{
+		BufferView:    w.view(values, len(values)*4, gltfElementArray),
+		ComponentType: gltfUnsignedInt,
+		Count:         len(values),
+		Type:          "SCALAR",
+	})
+
+	return len(w.doc.Accessors) - 1
+}
+
+// at3 and at4 give zeroes for an index past the end, which only a broken
+// mesh has
+func at3(values [][3]float32, index uint32) [3]float32 {
+	if int(index) < len(values) {
+		return values[index]
+	}
+	return [3]float32{}
+}
+
+func at4(values [][4]float32, index uint32) [4]float32 {
+	if int(index) < len(values) {
+		return values[index]
+	}
+	return [4]float32{}
+}
+
+// normalise makes a normal a unit long, as glTF wants them
+func normalise(n [3]float32) []float32 {
+	length := float32(math.Sqrt(float64(n[0]*n[0] + n[1]*n[1] + n[2]*n[2])))
+	if length == 0 {
+		return []float32{0, 0, 1}
+	}
+
+	return []float32{n[0] / length, n[1] / length, n[2] / length}
+}
+
+func isIdentity(matrix []float32) bool {
+	if len(matrix) != 16 {
+		return true
+	}
+
+	for i, value := range matrix {
+		expected := float32(0)
+		if i%5 == 0 {
+			expected = 1
+		}
+
+		if value != expected {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,185 @@
+package w3d
+
+// mesh attributes
+const meshExcludeNormals = 0x01
+
+// shading attributes
+const (
+	shadingDiffuseColours  = 0x01
+	shadingSpecularColours = 0x02
+)
+
+// the context the shading of the faces of the base mesh is read with
+const contextShading = 1
+
+// Mesh is a CLOD (continuous level of detail) mesh, a base mesh that the
+// progressive blocks add positions and faces to. Only the base mesh is read
+// so far, Resolution says how much of the mesh that is.
+type Mesh struct {
+	Name       string
+	Attributes uint32
+	Shadings   []MeshShading
+
+	MaxFaces      uint32
+	MaxPositions  uint32
+	MinResolution uint32
+	MaxResolution uint32
+	Bones         uint32
+
+	// Resolution is how many of the positions the base mesh has
+	Resolution  uint32
+	Progressive uint32
+
+	Positions [][3]float32 `json:"-"`
+	Normals   [][3]float32 `json:"-"`
+	Diffuse   [][4]float32 `json:"-"`
+	Specular  [][4]float32 `json:"-"`
+	TexCoords [][4]float32 `json:"-"`
+	Faces     []Face       `json:"-"`
+}
+
+// MeshShading says what the corners of the faces drawn with a shader have,
+// TextureLayers is the dimensions of the coordinates of each texture layer
+type MeshShading struct {
+	Attributes    uint32
+	TextureLayers []uint32
+	OriginalId    uint32
+}
+
+type Face struct {
+	Shading uint32
+	Corners [3]Corner
+}
+
+// Corner is the indices of a corner of a face in the lists of the mesh
+type Corner struct {
+	Position  uint32
+	Normal    uint32
+	Diffuse   uint32
+	Specular  uint32
+	TexCoords []uint32
+}
+
+func readMeshDeclaration(s *bitStream) *Mesh {
+	mesh := &Mesh{Name: s.str()}
+	s.u32() // chain index
+
+	mesh.Attributes = s.u32()
+	mesh.MaxFaces = s.u32()
+	mesh.MaxPositions = s.u32()
+	s.u32() // normals
+	s.u32() // diffuse colours
+	s.u32() // specular colours
+	s.u32() // texture coordinates
+
+	count := int(s.u32())
+	for i := 0; i < count && s.err == nil; i++ {
+		shading := MeshShading{Attributes: s.u32()}
+
+		layers := int(s.u32())
+		for j := 0; j < layers && s.err == nil; j++ {
+			shading.TextureLayers = append(shading.TextureLayers, s.u32())
+		}
+
+		shading.OriginalId = s.u32()
+		mesh.Shadings = append(mesh.Shadings, shading)
+	}
+
+	mesh.MinResolution = s.u32()
+	mesh.MaxResolution = s.u32()
+
+	// the quality factors, inverse quantisation and normal parameters are
+	// only for the progressive mesh
+	s.floats(11)
+
+	// a skeleton is left to the bone weight modifier
+	mesh.Bones = s.u32()
+
+	return mesh
+}
+
+func (mesh *Mesh) readBaseMesh(s *bitStream) {
+	s.u32() // chain index
+
+	faces := s.u32()
+	positions := s.u32()
+	normals := s.u32()
+	diffuse := s.u32()
+	specular := s.u32()
+	texCoords := s.u32()
+
+	if s.err != nil {
+		return
+	}
+
+	for i := uint32(0); i < positions && s.err == nil; i++ {
+		mesh.Positions = append(mesh.Positions, read3(s))
+	}
+
+	for i := uint32(0); i < normals && s.err == nil; i++ {
+		mesh.Normals = append(mesh.Normals, read3(s))
+	}
+
+	for i := uint32(0); i < diffuse && s.err == nil; i++ {
+		mesh.Diffuse = append(mesh.Diffuse, read4(s))
+	}
+
+	for i := uint32(0); i < specular && s.err == nil; i++ {
+		mesh.Specular = append(mesh.Specular, read4(s))
+	}
+
+	for i := uint32(0); i < texCoords && s.err == nil; i++ {
+		mesh.TexCoords = append(mesh.TexCoords, read4(s))
+	}
+
+	for i := uint32(0); i < faces && s.err == nil; i++ {
+		face := Face{Shading: s.compressed(contextShading)}
+
+		shading := MeshShading{}
+		if int(face.Shading) < len(mesh.Shadings) {
+			shading = mesh.Shadings[face.Shading]
+		}
+
+		for j := range face.Corners {
+			corner := &face.Corners[j]
+			corner.Position = s.index(positions)
+
+			if mesh.Attributes&meshExcludeNormals == 0 {
+				corner.Normal = s.index(normals)
+			}
+
+			if shading.Attributes&shadingDiffuseColours != 0 {
+				corner.Diffuse = s.index(diffuse)
+			}
+
+			if shading.Attributes&shadingSpecularColours != 0 {
+				corner.Specular = s.index(specular)
+			}
+
+			for range shading.TextureLayers {
+				corner.TexCoords = append(corner.TexCoords, s.index(texCoords))
+			}
+		}
+
+		mesh.Faces = append(mesh.Faces, face)
+	}
+
+	mesh.Resolution = positions
+}
+
+// readProgressiveMesh only notes how far the mesh would go, the updates
+// that add to it aren't read yet
+func (mesh *Mesh) readProgressiveMesh(s *bitStream) {
+	s.u32() // chain index
+	s.u32() // start resolution
+
+	mesh.Progressive = s.u32()
+}
+
+func read3(s *bitStream) [3]float32 {
+	return [3]float32{s.f32(), s.f32(), s.f32()}
+}
+
+func read4(s *bitStream) [4]float32 {
+	return [4]float32{s.f32(), s.f32(), s.f32(), s.f32()}
+}
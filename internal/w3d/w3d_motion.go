@@ -0,0 +1,125 @@
+package w3d
+
+// the contexts the key frames after the first are read with
+const (
+	contextTimeSign = 1 + iota
+	contextTimeDiff
+	contextDisplacementSign
+	contextDisplacementDiff
+	contextRotationSign
+	contextRotationDiff
+	contextScaleSign
+	contextScaleDiff
+)
+
+// Motion is an animation of the bones of a skeleton, a track for each bone
+type Motion struct {
+	Name   string
+	Tracks []MotionTrack
+}
+
+// MotionTrack has the first key frame as it was and when the others are,
+// the rest of them are quantised changes from the one before that aren't
+// put back together yet
+type MotionTrack struct {
+	Name         string
+	Times        []float32
+	Displacement []float32
+	Rotation     []float32
+	Scale        []float32
+}
+
+// AnimationModifier plays motions on the node or model it's in the chain of
+type AnimationModifier struct {
+	Name       string
+	Attributes uint32
+	TimeScale  float32
+	Motions    []AnimationMotion
+	BlendTime  float32
+}
+
+type AnimationMotion struct {
+	Name       string
+	Attributes uint32
+	TimeOffset float32
+	TimeScale  float32
+}
+
+func readMotion(s *bitStream) *Motion {
+	motion := &Motion{Name: s.str()}
+
+	tracks := int(s.u32())
+	timeQuant := s.f32()
+	s.f32() // rotation inverse quantisation
+
+	for i := 0; i < tracks && s.err == nil; i++ {
+		track := MotionTrack{Name: s.str()}
+
+		keys := int(s.u32())
+		s.f32() // displacement inverse quantisation
+		s.f32() // rotation inverse quantisation
+
+		var time float32
+		for j := 0; j < keys && s.err == nil; j++ {
+			if j == 0 {
+				time = s.f32()
+				track.Displacement = s.floats(3)
+				track.Rotation = s.floats(4)
+				track.Scale = s.floats(3)
+
+				track.Times = append(track.Times, time)
+				continue
+			}
+
+			sign := s.compressedU8(contextTimeSign)
+			diff := float32(s.compressed(contextTimeDiff)) * timeQuant
+			if sign&1 != 0 {
+				diff = -diff
+			}
+
+			time += diff
+			track.Times = append(track.Times, time)
+
+			s.compressedU8(contextDisplacementSign)
+			for k := 0; k < 3; k++ {
+				s.compressed(contextDisplacementDiff)
+			}
+
+			s.compressedU8(contextRotationSign)
+			for k := 0; k < 3; k++ {
+				s.compressed(contextRotationDiff)
+			}
+
+			s.compressedU8(contextScaleSign)
+			for k := 0; k < 3; k++ {
+				s.compressed(contextScaleDiff)
+			}
+		}
+
+		motion.Tracks = append(motion.Tracks, track)
+	}
+
+	return motion
+}
+
+func readAnimationModifier(s *bitStream) *AnimationModifier {
+	animation := &AnimationModifier{Name: s.str()}
+	s.u32() // chain index
+
+	animation.Attributes = s.u32()
+	animation.TimeScale = s.f32()
+
+	count := int(s.u32())
+	for i := 0; i < count && s.err == nil; i++ {
+		animation.Motions = append(animation.Motions, AnimationMotion{
+			Name:       s.str(),
+			Attributes: s.u32(),
+			TimeOffset: s.f32(),
+			TimeScale:  s.f32(),
+		})
+	}
+
+	animation.BlendTime = s.f32()
+
+	return animation
+}
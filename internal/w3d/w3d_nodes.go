@@ -0,0 +1,79 @@
+package w3d
+
+// the world every node hangs off in the end
+const worldNode = "<world>"
+
+var nodeTypes = map[uint32]string{
+	blockGroupNode: "group",
+	blockModelNode: "model",
+	blockLightNode: "light",
+	blockViewNode:  "view",
+}
+
+var lightTypes = []string{"ambient", "directional", "point", "spot"}
+
+// Node places a model, light or view, or groups other nodes. Resource is the
+// model, light or view resource it shows.
+type Node struct {
+	Type     string
+	Name     string
+	Parents  []Parent
+	Resource string `json:",omitempty"`
+}
+
+// Parent is a node this one is under, with its place relative to it as a 4x4
+// matrix a column at a time
+type Parent struct {
+	Name      string
+	Transform []float32
+}
+
+type Light struct {
+	Name        string
+	Attributes  uint32
+	Type        string
+	Colour      []float32
+	Attenuation []float32
+	SpotAngle   float32
+	Intensity   float32
+}
+
+func readNode(s *bitStream, blockType uint32) *Node {
+	node := &Node{
+		Type: nodeTypes[blockType],
+		Name: s.str(),
+	}
+
+	count := int(s.u32())
+	for i := 0; i < count && s.err == nil; i++ {
+		node.Parents = append(node.Parents, Parent{
+			Name:      s.str(),
+			Transform: s.floats(16),
+		})
+	}
+
+	if blockType != blockGroupNode {
+		node.Resource = s.str()
+	}
+
+	return node
+}
+
+func readLight(s *bitStream) *Light {
+	light := &Light{
+		Name:       s.str(),
+		Attributes: s.u32(),
+	}
+
+	lightType := int(s.u8())
+	if lightType < len(lightTypes) {
+		light.Type = lightTypes[lightType]
+	}
+
+	light.Colour = s.floats(3)
+	light.Attenuation = s.floats(3)
+	light.SpotAngle = s.f32()
+	light.Intensity = s.f32()
+
+	return light
+}
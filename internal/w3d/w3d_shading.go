@@ -0,0 +1,120 @@
+package w3d
+
+// a lit texture shader can have up to 8 texture layers, each a bit of its
+// channels
+const shaderLayers = 8
+
+// ShadingModifier picks the shaders of a model, a list for each shading of
+// its mesh, and is named after the node or model it's in the chain of
+type ShadingModifier struct {
+	Name       string
+	Attributes uint32
+	Shaders    [][]string
+}
+
+type Shader struct {
+	Name       string
+	Attributes uint32
+	Material   string
+	Layers     []ShaderLayer
+}
+
+// ShaderLayer is a texture drawn by a shader, Transform is how its
+// coordinates are moved as a 4x4 matrix a column at a time
+type ShaderLayer struct {
+	Channel   int
+	Texture   string
+	Intensity float32
+	Blend     uint8
+	Mode      uint8
+	Transform []float32
+	Repeat    uint8
+}
+
+// Material has colours as red, green and blue from 0 to 1
+type Material struct {
+	Name         string
+	Attributes   uint32
+	Ambient      []float32
+	Diffuse      []float32
+	Specular     []float32
+	Emissive     []float32
+	Reflectivity float32
+	Opacity      float32
+}
+
+func readShadingModifier(s *bitStream) *ShadingModifier {
+	shading := &ShadingModifier{Name: s.str()}
+	s.u32() // chain index
+
+	shading.Attributes = s.u32()
+
+	lists := int(s.u32())
+	for i := 0; i < lists && s.err == nil; i++ {
+		var shaders []string
+
+		count := int(s.u32())
+		for j := 0; j < count && s.err == nil; j++ {
+			shaders = append(shaders, s.str())
+		}
+
+		shading.Shaders = append(shading.Shaders, shaders)
+	}
+
+	return shading
+}
+
+func readShader(s *bitStream) *Shader {
+	shader := &Shader{
+		Name:       s.str(),
+		Attributes: s.u32(),
+	}
+
+	s.f32() // alpha test reference
+	s.u32() // alpha test function
+	s.u32() // colour blend function
+	s.u32() // render passes
+	channels := s.u32()
+	s.u32() // alpha texture channels
+
+	shader.Material = s.str()
+
+	for channel := 0; channel < shaderLayers && s.err == nil; channel++ {
+		if channels&(1<<uint(channel)) == 0 {
+			continue
+		}
+
+		layer := ShaderLayer{
+			Channel:   channel,
+			Texture:   s.str(),
+			Intensity: s.f32(),
+			Blend:     s.u8(),
+		}
+
+		s.u8()  // blend source
+		s.f32() // blend constant
+
+		layer.Mode = s.u8()
+		layer.Transform = s.floats(16)
+		s.floats(16) // wrap transform
+
+		layer.Repeat = s.u8()
+
+		shader.Layers = append(shader.Layers, layer)
+	}
+
+	return shader
+}
+
+func readMaterial(s *bitStream) *Material {
+	return &Material{
+		Name:         s.str(),
+		Attributes:   s.u32(),
+		Ambient:      s.floats(3),
+		Diffuse:      s.floats(3),
+		Specular:     s.floats(3),
+		Emissive:     s.floats(3),
+		Reflectivity: s.f32(),
+		Opacity:      s.f32(),
+	}
+}
@@ -0,0 +1,173 @@
+package w3d
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+)
+
+// image compression types
+const (
+	compressionJpeg24 = 1
+	compressionPng    = 2
+	compressionJpeg8  = 3
+	compressionTiff   = 4
+)
+
+// image channels
+const (
+	channelAlpha     = 0x01
+	channelBlue      = 0x02
+	channelGreen     = 0x04
+	channelRed       = 0x08
+	channelLuminance = 0x10
+)
+
+// continuation image attributes
+const imageExternal = 0x0001
+
+// Texture is made of one or more images that each give some of its
+// channels, often a JPEG for the colour and another for the alpha
+type Texture struct {
+	Name      string
+	Width     uint32
+	Height    uint32
+	ImageType uint8
+	Images    []TextureImage
+}
+
+type TextureImage struct {
+	Compression uint8
+	Channels    uint8
+	Attributes  uint16
+	Size        uint32
+	External    []string `json:",omitempty"`
+
+	Data []byte `json:"-"`
+}
+
+func readTexture(s *bitStream) *Texture {
+	texture := &Texture{
+		Name:      s.str(),
+		Height:    s.u32(),
+		Width:     s.u32(),
+		ImageType: s.u8(),
+	}
+
+	count := int(s.u32())
+	for i := 0; i < count && s.err == nil; i++ {
+		img := TextureImage{
+			Compression: s.u8(),
+			Channels:    s.u8(),
+			Attributes:  s.u16(),
+		}
+
+		if img.Attributes&imageExternal != 0 {
+			files := int(s.u32())
+			for j := 0; j < files && s.err == nil; j++ {
+				img.External = append(img.External, s.str())
+			}
+		} else {
+			img.Size = s.u32()
+		}
+
+		texture.Images = append(texture.Images, img)
+	}
+
+	return texture
+}
+
+func (texture *Texture) readContinuation(s *bitStream) {
+	index := int(s.u32())
+	data := s.bytes()
+
+	if index >= len(texture.Images) {
+		s.err = fmt.Errorf("texture %q has no image %d", texture.Name, index)
+		return
+	}
+
+	texture.Images[index].Data = append(texture.Images[index].Data, data...)
+}
+
+// Image puts the channels of the images of the texture together
+func (texture *Texture) Image() (*image.NRGBA, error) {
+	var out *image.NRGBA
+
+	for i, img := range texture.Images {
+		if len(img.Data) == 0 {
+			continue
+		}
+
+		var decoded image.Image
+		var err error
+
+		switch img.Compression {
+		case compressionJpeg24, compressionJpeg8:
+			decoded, err = jpeg.Decode(bytes.NewReader(img.Data))
+		case compressionPng:
+			decoded, err = png.Decode(bytes.NewReader(img.Data))
+		default:
+			err = fmt.Errorf("compression %d is not supported", img.Compression)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %s", i, err)
+		}
+
+		bounds := decoded.Bounds()
+		if out == nil {
+			out = image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+			for j := 3; j < len(out.Pix); j += 4 {
+				out.Pix[j] = 0xFF
+			}
+		}
+
+		for y := 0; y < bounds.Dy() && y < out.Rect.Dy(); y++ {
+			for x := 0; x < bounds.Dx() && x < out.Rect.Dx(); x++ {
+				pixel := color.NRGBAModel.Convert(decoded.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+				offset := out.PixOffset(x, y)
+
+				setChannels(out.Pix[offset:offset+4], pixel, img.Channels)
+			}
+		}
+	}
+
+	if out == nil {
+		return nil, fmt.Errorf("texture %q has no images", texture.Name)
+	}
+
+	return out, nil
+}
+
+// setChannels takes the channels an image gives from a pixel of it, an
+// image that is only alpha gives it as grey
+func setChannels(pix []byte, pixel color.NRGBA, channels uint8) {
+	if channels == channelAlpha {
+		pix[3] = color.GrayModel.Convert(pixel).(color.Gray).Y
+		return
+	}
+
+	if channels&channelLuminance != 0 {
+		grey := color.GrayModel.Convert(pixel).(color.Gray).Y
+		pix[0], pix[1], pix[2] = grey, grey, grey
+	}
+
+	if channels&channelRed != 0 {
+		pix[0] = pixel.R
+	}
+
+	if channels&channelGreen != 0 {
+		pix[1] = pixel.G
+	}
+
+	if channels&channelBlue != 0 {
+		pix[2] = pixel.B
+	}
+
+	if channels&channelAlpha != 0 {
+		pix[3] = pixel.A
+	}
+}
@@ -8,31 +8,41 @@ import (
 	"github.com/markhughes/dirry/internal/utils"
 )
 
-func CreateFlashBinary(reader io.Reader, endian binary.ByteOrder) ([]byte, error) {
-	unknown1, err := utils.ReadUInt32(reader, endian) // not sure what this is
+// FlashHeader is what comes before the SWF of a flash or vector shape
+// member, only the length is known
+type FlashHeader struct {
+	Unknown1   uint32
+	Unknown2   uint32
+	DataLength uint32
+}
+
+// CreateFlashBinary returns the SWF of a flash or vector shape member and
+// the header it had before it
+func CreateFlashBinary(reader io.Reader, endian binary.ByteOrder) (data []byte, header FlashHeader, err error) {
+	header.Unknown1, err = utils.ReadUInt32(reader, endian) // not sure what this is
 	if err != nil {
-		return nil, fmt.Errorf("could not read value1: %v", err)
+		return nil, header, fmt.Errorf("could not read value1: %v", err)
 	}
 
-	unknown2, err := utils.ReadUInt32(reader, endian) // not sure what this is
+	header.Unknown2, err = utils.ReadUInt32(reader, endian) // not sure what this is
 	if err != nil {
-		return nil, fmt.Errorf("could not read value2: %v", err)
+		return nil, header, fmt.Errorf("could not read value2: %v", err)
 	}
 
-	dataLength, err := utils.ReadUInt32(reader, endian) // not sure what this is
+	header.DataLength, err = utils.ReadUInt32(reader, endian)
 	if err != nil {
-		return nil, fmt.Errorf("could not read value3: %v", err)
+		return nil, header, fmt.Errorf("could not read data length: %v", err)
 	}
 
-	utils.DebugMsg("xmed/flash", "value1: %d\n", unknown1)
-	utils.DebugMsg("xmed/flash", "value2: %d\n", unknown2)
-	utils.DebugMsg("xmed/flash", "value3: %d\n", dataLength)
+	utils.DebugMsg("xmed/flash", "value1: %d\n", header.Unknown1)
+	utils.DebugMsg("xmed/flash", "value2: %d\n", header.Unknown2)
+	utils.DebugMsg("xmed/flash", "data length: %d\n", header.DataLength)
 
-	data := make([]byte, dataLength)
+	data = make([]byte, header.DataLength)
 	_, err = io.ReadFull(reader, data)
 	if err != nil {
-		return nil, fmt.Errorf("could not read data: %v", err)
+		return nil, header, fmt.Errorf("could not read data: %v", err)
 	}
-	return data, nil
+	return data, header, nil
 
 }
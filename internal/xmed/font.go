@@ -6,16 +6,20 @@ import (
 
 	"github.com/markhughes/dirry/internal/binary_reader"
 	"github.com/markhughes/dirry/internal/pfr"
+	"github.com/markhughes/dirry/internal/utils"
 )
 
-func CreateFontBinary(reader *binary_reader.BinaryReader, endian binary.ByteOrder) (data []byte, meta []byte, err error) {
+// CreateFontBinary returns the PFR with the signature other readers expect,
+// what was read of it as JSON and the font converted to TrueType, which is
+// nil when it couldn't be
+func CreateFontBinary(reader *binary_reader.BinaryReader, endian binary.ByteOrder) (data []byte, meta []byte, ttf []byte, err error) {
 
 	var fontMeta = pfr.PfrFont{}
 
 	// read all of reader into bytes
 	originalData, err := reader.ReadAllBytes()
 	if err != nil {
-		return nil, meta, err
+		return nil, meta, nil, err
 	}
 
 	// remove first 4 bytes
@@ -24,14 +28,22 @@ func CreateFontBinary(reader *binary_reader.BinaryReader, endian binary.ByteOrde
 	// add new 4 bytes for signature PFR0 - for some reason shockwave added PFR1?
 	data = append([]byte("PFR0"), data...)
 
-	fontMeta.Parse(originalData)
+	err = fontMeta.Parse(originalData)
+	if err != nil {
+		utils.WarnMsg("xmed/font", "Could not read PFR: %s", err)
+	} else {
+		ttf, err = fontMeta.TrueType()
+		if err != nil {
+			utils.WarnMsg("xmed/font", "Could not convert PFR to TrueType: %s", err)
+		}
+	}
 
 	// convert meta to json encoded bytes
 	metaBytes, err := json.Marshal(fontMeta)
 	if err != nil {
-		return nil, meta, err
+		return nil, meta, nil, err
 	}
 
-	return data, (metaBytes), nil
+	return data, (metaBytes), ttf, nil
 
 }
@@ -0,0 +1,272 @@
+package xmed
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/markhughes/dirry/internal/binary_reader"
+	"github.com/markhughes/dirry/internal/palettes"
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// The Text xtra keeps a member as text rather than binary, "FFFF" and then
+// sections of a 4 digit hex id, an 8 digit hex length and the data. Numbers
+// in the sections are hex of a fixed width and strings are a hex length, a
+// comma and the bytes.
+//
+// Macromedia never published the format, so the sections below are the ones
+// this reader expects rather than a spec. Sections it doesn't know are kept
+// under Unknown as they are.
+const textMagic = "FFFF"
+
+// text sections
+const (
+	textSectionSettings   = 0x0000
+	textSectionText       = 0x0002
+	textSectionFonts      = 0x0004
+	textSectionParagraphs = 0x0005
+	textSectionRuns       = 0x0006
+)
+
+// settings flags
+const (
+	textAntiAlias = 0x01
+	textKerning   = 0x02
+	textWordWrap  = 0x04
+)
+
+// run style flags
+const (
+	textBold      = 0x01
+	textItalic    = 0x02
+	textUnderline = 0x04
+	textStrikeout = 0x08
+)
+
+var textAlignments = []string{"left", "center", "right", "justify"}
+
+// Text is a text member as the Text xtra keeps it
+type Text struct {
+	Version            int
+	AntiAlias          bool
+	AntiAliasThreshold int
+	Kerning            bool
+	KerningThreshold   int
+	WordWrap           bool
+
+	Text       string
+	Fonts      map[int]string
+	Paragraphs []TextParagraph
+	Runs       []TextRun
+
+	// Unknown has the sections that aren't understood yet by their id, kept
+	// as they were
+	Unknown map[string]string `json:",omitempty"`
+}
+
+// TextParagraph is the layout from Offset in the text on, indents and
+// spacing are in points
+type TextParagraph struct {
+	Offset      int
+	Alignment   string
+	LeftIndent  int
+	RightIndent int
+	FirstIndent int
+	SpaceBefore int
+	SpaceAfter  int
+	LineSpacing int
+}
+
+// TextRun is the style of the characters from Offset in the text on,
+// CharSpacing is how much wider or narrower than the font they are spaced
+type TextRun struct {
+	Offset      int
+	FontId      int
+	FontSize    int
+	Bold        bool
+	Italic      bool
+	Underline   bool
+	Strikeout   bool
+	Colour      palettes.Pixel24
+	CharSpacing int
+}
+
+// textReader reads the hex fields of a section, it keeps the first error
+// and reads zeroes after it
+type textReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *textReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || r.pos+n > len(r.data) {
+		if r.err == nil {
+			r.err = fmt.Errorf("truncated at %d reading %d bytes", r.pos, n)
+		}
+		return nil
+	}
+
+	out := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return out
+}
+
+func (r *textReader) hex(digits int) int {
+	field := r.bytes(digits)
+	if r.err != nil {
+		return 0
+	}
+
+	value, err := strconv.ParseUint(string(field), 16, 32)
+	if err != nil {
+		r.err = fmt.Errorf("%q at %d is not hex", field, r.pos-digits)
+		return 0
+	}
+
+	return int(value)
+}
+
+// signed reads a 4 digit hex field as a 16 bit signed number
+func (r *textReader) signed() int {
+	return int(int16(r.hex(4)))
+}
+
+func (r *textReader) str() string {
+	start := r.pos
+	for r.pos < len(r.data) && r.data[r.pos] != ',' {
+		r.pos++
+	}
+
+	if r.err == nil && r.pos == len(r.data) {
+		r.err = fmt.Errorf("string at %d has no length", start)
+		return ""
+	}
+
+	length, err := strconv.ParseUint(string(r.data[start:r.pos]), 16, 32)
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("string at %d has a bad length", start)
+	}
+
+	r.pos++ // the comma
+	return string(r.bytes(int(length)))
+}
+
+// ReadText reads the XMED of a text member
+func ReadText(reader *binary_reader.BinaryReader) (*Text, error) {
+	data, err := reader.ReadAllBytes()
+	if err != nil {
+		return nil, fmt.Errorf("could not read text: %v", err)
+	}
+
+	if len(data) < len(textMagic) || string(data[:len(textMagic)]) != textMagic {
+		return nil, fmt.Errorf("text does not start with %s", textMagic)
+	}
+
+	text := &Text{
+		Fonts: make(map[int]string),
+	}
+
+	r := &textReader{data: data, pos: len(textMagic)}
+	for r.pos < len(data) && r.err == nil {
+		id := r.hex(4)
+		length := r.hex(8)
+		section := r.bytes(length)
+		if r.err != nil {
+			return nil, fmt.Errorf("could not read text section: %v", r.err)
+		}
+
+		err = text.readSection(id, section)
+		if err != nil {
+			return nil, fmt.Errorf("could not read text section %04X: %v", id, err)
+		}
+	}
+
+	utils.DebugMsg("xmed/text", "%d characters, %d paragraphs, %d runs and %d fonts", len(text.Text), len(text.Paragraphs), len(text.Runs), len(text.Fonts))
+
+	return text, nil
+}
+
+func (text *Text) readSection(id int, data []byte) error {
+	r := &textReader{data: data}
+
+	switch id {
+	case textSectionSettings:
+		text.Version = r.hex(4)
+		flags := r.hex(4)
+		text.AntiAliasThreshold = r.hex(4)
+		text.KerningThreshold = r.hex(4)
+
+		text.AntiAlias = flags&textAntiAlias != 0
+		text.Kerning = flags&textKerning != 0
+		text.WordWrap = flags&textWordWrap != 0
+
+	case textSectionText:
+		text.Text = string(data)
+
+	case textSectionFonts:
+		count := r.hex(4)
+		for i := 0; i < count && r.err == nil; i++ {
+			fontId := r.hex(4)
+			text.Fonts[fontId] = r.str()
+		}
+
+	case textSectionParagraphs:
+		count := r.hex(4)
+		for i := 0; i < count && r.err == nil; i++ {
+			paragraph := TextParagraph{}
+			paragraph.Offset = r.hex(8)
+
+			alignment := r.hex(2)
+			if alignment < len(textAlignments) {
+				paragraph.Alignment = textAlignments[alignment]
+			} else {
+				utils.WarnMsg("xmed/text", "Unknown paragraph alignment %d", alignment)
+			}
+
+			paragraph.LeftIndent = r.signed()
+			paragraph.RightIndent = r.signed()
+			paragraph.FirstIndent = r.signed()
+			paragraph.SpaceBefore = r.signed()
+			paragraph.SpaceAfter = r.signed()
+			paragraph.LineSpacing = r.signed()
+
+			text.Paragraphs = append(text.Paragraphs, paragraph)
+		}
+
+	case textSectionRuns:
+		count := r.hex(4)
+		for i := 0; i < count && r.err == nil; i++ {
+			run := TextRun{}
+			run.Offset = r.hex(8)
+			run.FontId = r.hex(4)
+			run.FontSize = r.hex(4)
+
+			style := r.hex(4)
+			run.Bold = style&textBold != 0
+			run.Italic = style&textItalic != 0
+			run.Underline = style&textUnderline != 0
+			run.Strikeout = style&textStrikeout != 0
+
+			run.Colour = palettes.Pixel24{
+				R: uint8(r.hex(2)),
+				G: uint8(r.hex(2)),
+				B: uint8(r.hex(2)),
+			}
+
+			run.CharSpacing = r.signed()
+
+			text.Runs = append(text.Runs, run)
+		}
+
+	default:
+		if text.Unknown == nil {
+			text.Unknown = make(map[string]string)
+		}
+
+		text.Unknown[fmt.Sprintf("%04X", id)] = string(data)
+		utils.DebugMsg("xmed/text", "Keeping unknown section %04X of %d bytes", id, len(data))
+	}
+
+	return r.err
+}
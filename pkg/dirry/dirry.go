@@ -0,0 +1,313 @@
+// Package dirry is the public API for reading Director movies and casts.
+//
+// Unlike the dirry command it never writes to the filesystem or prints to
+// stdout, everything is decoded in memory from the reader you give it.
+package dirry
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/markhughes/dirry/internal/chunks"
+	dirryerrors "github.com/markhughes/dirry/internal/errors"
+	"github.com/markhughes/dirry/internal/shockwave"
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// ErrProjector is returned when the input is a projector (EXE / APPL) rather
+// than a movie, the movies inside need to be extracted first.
+var ErrProjector = errors.New("dirry: projectors are not supported, extract the movies first")
+
+// ErrMacFile is returned when the input is a resource fork, AppleDouble,
+// MacBinary or BinHex file, the movie inside needs to be unpacked first.
+var ErrMacFile = errors.New("dirry: Mac forks and archives are not supported, unpack the movie first")
+
+// ErrUnknownSize is returned for a reader that can't say how big it is,
+// wrap it in an io.SectionReader to give it one.
+var ErrUnknownSize = errors.New("dirry: the size of the input is unknown")
+
+// Options change how a movie is opened, the zero value is what Open uses.
+type Options struct {
+	// Log gets the messages the decoders print as they go, nothing is
+	// printed when it is nil.
+	Log io.Writer
+}
+
+// Movie is an opened Director movie or cast file.
+type Movie struct {
+	shockwave *shockwave.Shockwave
+
+	members  map[int32]*Member
+	fonts    []Font
+	palettes map[int32]Palette
+	castLibs []CastLib
+
+	errs []error
+}
+
+// Open reads a Director movie (.dir, .dxr, .dcr) or cast (.cst, .cxt, .cct).
+// The reader has to have a Size method, as io.SectionReader and
+// bytes.Reader do, or be an *os.File.
+func Open(r io.ReaderAt) (*Movie, error) {
+	return OpenWithOptions(r, Options{})
+}
+
+// OpenWithOptions is Open with the messages sent to options.Log. The
+// decoders share where they print, so movies opened at the same time
+// wait for each other.
+func OpenWithOptions(r io.ReaderAt, options Options) (*Movie, error) {
+	log := options.Log
+	if log == nil {
+		log = io.Discard
+	}
+	defer utils.Redirect(log)()
+
+	size, err := readerSize(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// projectors and Mac files have their movies extracted to disk before
+	// they can be read, which a library mustn't do
+	sw := &shockwave.Shockwave{NoExtract: true}
+	expanded, err := sw.OpenReaderAt("movie", r, size)
+	switch {
+	case errors.Is(err, shockwave.ErrProjector):
+		return nil, ErrProjector
+	case errors.Is(err, shockwave.ErrMacFile):
+		return nil, ErrMacFile
+	case err != nil:
+		return nil, fmt.Errorf("dirry: %s", err)
+	}
+
+	if len(expanded) > 0 {
+		return nil, fmt.Errorf("dirry: the input holds %d files rather than a movie", len(expanded))
+	}
+
+	if sw.ChunkMap == nil {
+		return nil, fmt.Errorf("dirry: could not read the chunk map")
+	}
+
+	movie := &Movie{
+		shockwave: sw,
+		members:   make(map[int32]*Member),
+		palettes:  make(map[int32]Palette),
+	}
+	movie.decode()
+
+	return movie, nil
+}
+
+// readerSize finds out how big the input is, the chunk map is checked
+// against it
+func readerSize(r io.ReaderAt) (int64, error) {
+	switch v := r.(type) {
+	case interface{ Size() int64 }:
+		return v.Size(), nil
+	case *os.File:
+		info, err := v.Stat()
+		if err != nil {
+			return 0, fmt.Errorf("dirry: could not get the size of the input: %s", err)
+		}
+
+		return info.Size(), nil
+	}
+
+	return 0, ErrUnknownSize
+}
+
+func (movie *Movie) decode() {
+	sw := movie.shockwave
+	castMembers := sw.CastMembers()
+
+	for _, resource := range sw.ChunkMap.GetAllResources() {
+		if resource.UncompressedSize == 0 {
+			continue
+		}
+
+		switch resource.ChunkType {
+		case "CASt":
+			reader, err := resource.GetReader()
+			if err != nil {
+				movie.fail(resource, err)
+				continue
+			}
+
+			cast, err := chunks.ReadCastChunkRaw(reader.GetUnsafeBytesReader(), sw.Version, sw.Endian, sw.IsAfterburner())
+			if err != nil {
+				if _, ok := err.(*dirryerrors.UnhandledCastTypeError); !ok || cast == nil {
+					movie.fail(resource, err)
+					continue
+				}
+			}
+
+			sw.Casts[resource.ResourceId] = cast
+			movie.members[resource.ResourceId] = &Member{ID: resource.ResourceId, cast: cast}
+
+		case "Fmap":
+			reader, err := resource.GetReader()
+			if err != nil {
+				movie.fail(resource, err)
+				continue
+			}
+
+			fmap, err := chunks.ReadFmapChunkRaw(reader, sw.Endian, sw.IsAfterburner())
+			if err != nil {
+				movie.fail(resource, err)
+				continue
+			}
+
+			for _, font := range fmap.Fonts {
+				sw.Fonts[uint32(font.FontID)] = font
+				movie.fonts = append(movie.fonts, Font{ID: font.FontID, Name: font.Name, Platform: font.Platform})
+			}
+
+		case "CLUT":
+			reader, err := resource.GetReader()
+			if err != nil {
+				movie.fail(resource, err)
+				continue
+			}
+
+			clut, err := chunks.ReadClutChunkRaw(reader, sw.Endian, sw.IsAfterburner())
+			if err != nil {
+				movie.fail(resource, err)
+				continue
+			}
+
+			member := castMembers[resource.CastId]
+			palette := Palette{ID: resource.ResourceId, CastID: resource.CastId, CastLib: member.CastLib, Member: member.Number}
+			for i := 0; i < int(clut.Palette.Size); i++ {
+				pixel := clut.Palette.Palette[i]
+				palette.Colors = append(palette.Colors, Color{R: pixel.R, G: pixel.G, B: pixel.B})
+			}
+			movie.palettes[resource.ResourceId] = palette
+
+		case "MCsL":
+			reader, err := resource.GetReader()
+			if err != nil {
+				movie.fail(resource, err)
+				continue
+			}
+
+			mcsl, err := chunks.ReadMcslChunkRaw(reader, sw.Endian, sw.IsAfterburner())
+			if err != nil {
+				movie.fail(resource, err)
+				continue
+			}
+
+			for _, lib := range mcsl.CastLibs {
+				movie.castLibs = append(movie.castLibs, CastLib{
+					ID:          lib.LibId,
+					Name:        lib.Name,
+					Path:        lib.Path,
+					MemberCount: int(lib.ItemCount),
+					External:    lib.StorageType == 0,
+				})
+			}
+		}
+	}
+}
+
+func (movie *Movie) fail(resource *shockwave.ShockwaveResource, err error) {
+	movie.errs = append(movie.errs, fmt.Errorf("%s %d: %s", resource.ChunkType, resource.ResourceId, err))
+}
+
+// Errors lists the chunks that could not be decoded, the movie is still usable
+// but those chunks are only available raw through Chunks.
+func (movie *Movie) Errors() []error {
+	return movie.errs
+}
+
+// Version is the Director version that saved the movie, e.g. "Macromedia Director 8.5.0".
+func (movie *Movie) Version() string {
+	return movie.shockwave.Version.ToString()
+}
+
+// Codec is the four character codec of the movie (MV93, FGDM, ...).
+func (movie *Movie) Codec() string {
+	return movie.shockwave.Codec.Name
+}
+
+// Afterburner reports if the movie is compressed (shockwave .dcr / .cct).
+func (movie *Movie) Afterburner() bool {
+	return movie.shockwave.IsAfterburner()
+}
+
+// ByteOrder is the byte order of the container, RIFX is big endian and XFIR is little.
+func (movie *Movie) ByteOrder() binary.ByteOrder {
+	return movie.shockwave.Endian
+}
+
+// Chunks lists every resource in the chunk map in file order.
+func (movie *Movie) Chunks() []Chunk {
+	resources := movie.shockwave.ChunkMap.GetAllResources()
+
+	out := make([]Chunk, 0, len(resources))
+	for _, resource := range resources {
+		out = append(out, newChunk(resource))
+	}
+
+	return out
+}
+
+// ChunksByType lists the resources with the given four character type, e.g. "BITD".
+func (movie *Movie) ChunksByType(chunkType string) []Chunk {
+	resources := movie.shockwave.ChunkMap.GetResourcesByTag(chunkType)
+
+	out := make([]Chunk, 0, len(resources))
+	for _, resource := range resources {
+		out = append(out, newChunk(resource))
+	}
+
+	return out
+}
+
+// Chunk finds a resource by its id.
+func (movie *Movie) Chunk(id int32) (Chunk, bool) {
+	resource := movie.shockwave.ChunkMap.GetResourceById(id)
+	if resource == nil {
+		return Chunk{}, false
+	}
+
+	return newChunk(resource), true
+}
+
+// Members lists the decoded cast members ordered by their CASt resource id.
+func (movie *Movie) Members() []*Member {
+	out := make([]*Member, 0, len(movie.members))
+	for _, member := range movie.members {
+		out = append(out, member)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+
+	return out
+}
+
+// Member finds a cast member by its CASt resource id.
+func (movie *Movie) Member(id int32) (*Member, bool) {
+	member, ok := movie.members[id]
+	return member, ok
+}
+
+// CastLibs lists the cast libraries of the movie (internal and external).
+func (movie *Movie) CastLibs() []CastLib {
+	return movie.castLibs
+}
+
+// Fonts lists the fonts from the font map.
+func (movie *Movie) Fonts() []Font {
+	return movie.fonts
+}
+
+// Palettes lists the palettes stored in the movie keyed by their CLUT resource id.
+func (movie *Movie) Palettes() map[int32]Palette {
+	return movie.palettes
+}
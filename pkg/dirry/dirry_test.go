@@ -0,0 +1,183 @@
+package dirry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/markhughes/dirry/internal/utils"
+)
+
+// testMovie is a Director 7 movie with a script member named hello and a
+// two colour palette keyed to it
+func testMovie() []byte {
+	be := binary.BigEndian
+
+	var info bytes.Buffer
+	strs := []string{"on mouseUp\rend", "hello"}
+	binary.Write(&info, be, []int32{0x14, 0, 0, 0, 1})
+	binary.Write(&info, be, int16(len(strs)))
+	offset := int32(0)
+	binary.Write(&info, be, offset)
+	for _, s := range strs {
+		offset += int32(len(s))
+		binary.Write(&info, be, offset)
+	}
+	for _, s := range strs {
+		info.WriteString(s)
+	}
+
+	var cast bytes.Buffer
+	binary.Write(&cast, be, []int32{11, int32(info.Len()), 2})
+	cast.Write(info.Bytes())
+	cast.Write([]byte{0, 1})
+
+	var key bytes.Buffer
+	binary.Write(&key, be, []int16{12, 12})
+	binary.Write(&key, be, []int32{1, 1, 5, 4})
+	key.WriteString("CLUT")
+
+	var clut bytes.Buffer
+	binary.Write(&clut, be, int32(2))
+	clut.Write([]byte{0xff, 0, 0, 0, 0, 0, 0, 0, 0xff, 0, 0, 0})
+
+	resources := []struct {
+		tag  string
+		data []byte
+	}{
+		{"KEY*", key.Bytes()},
+		{"CASt", cast.Bytes()},
+		{"CLUT", clut.Bytes()},
+	}
+
+	count := 3 + len(resources)
+	imapOffset := 12
+	mmapOffset := imapOffset + 8 + 24
+	mmapLength := 24 + 20*count
+	offsets := make([]int, len(resources))
+	end := mmapOffset + 8 + mmapLength
+	for i, resource := range resources {
+		offsets[i] = end
+		end += 8 + len(resource.data)
+	}
+
+	var out bytes.Buffer
+	entry := func(tag string, length, offset int) {
+		out.WriteString(tag)
+		binary.Write(&out, be, []int32{int32(length), int32(offset)})
+		binary.Write(&out, be, []int16{0, 0})
+		binary.Write(&out, be, int32(0))
+	}
+
+	out.WriteString("RIFX")
+	binary.Write(&out, be, int32(end-8))
+	out.WriteString("MV93")
+
+	out.WriteString("imap")
+	binary.Write(&out, be, []int32{24, 1, int32(mmapOffset), 1224, 0, 0, 0})
+
+	out.WriteString("mmap")
+	binary.Write(&out, be, int32(mmapLength))
+	binary.Write(&out, be, []int16{24, 20})
+	binary.Write(&out, be, []int32{int32(count), int32(count), -1, -1, -1})
+	entry("RIFX", end-8, 0)
+	entry("imap", 24, imapOffset)
+	entry("mmap", mmapLength, mmapOffset)
+	for i, resource := range resources {
+		entry(resource.tag, len(resource.data), offsets[i])
+	}
+
+	for _, resource := range resources {
+		out.WriteString(resource.tag)
+		binary.Write(&out, be, int32(len(resource.data)))
+		out.Write(resource.data)
+	}
+
+	return out.Bytes()
+}
+
+func TestOpen(t *testing.T) {
+	movie, err := Open(bytes.NewReader(testMovie()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := movie.Errors(); len(errs) > 0 {
+		t.Errorf("errors decoding: %v", errs)
+	}
+
+	if movie.Codec() != "MV93" || movie.Afterburner() || movie.ByteOrder() != binary.BigEndian {
+		t.Errorf("codec is %s, afterburner %v and %v", movie.Codec(), movie.Afterburner(), movie.ByteOrder())
+	}
+
+	if chunks := movie.Chunks(); len(chunks) != 6 {
+		t.Errorf("%d chunks, want 6", len(chunks))
+	}
+
+	if clut, ok := movie.Chunk(5); !ok || clut.Type != "CLUT" || clut.CastID != 4 {
+		t.Errorf("chunk 5 is %+v, want the CLUT keyed to CASt 4", clut)
+	}
+
+	members := movie.Members()
+	if len(members) != 1 {
+		t.Fatalf("%d members, want 1", len(members))
+	}
+
+	if member := members[0]; member.ID != 4 || member.Name() != "hello" || member.ScriptText() != "on mouseUp\rend" {
+		t.Errorf("member is %d %q with %q", member.ID, member.Name(), member.ScriptText())
+	}
+
+	palette, ok := movie.Palettes()[5]
+	if !ok || len(palette.Colors) != 2 || palette.Colors[0] != (Color{R: 0xff}) || palette.Colors[1] != (Color{G: 0xff}) {
+		t.Errorf("palette is %+v", palette)
+	}
+}
+
+func TestOpenLog(t *testing.T) {
+	before := utils.Output
+
+	var log bytes.Buffer
+	if _, err := OpenWithOptions(bytes.NewReader(testMovie()), Options{Log: &log}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(log.String(), "MV93") {
+		t.Errorf("nothing was logged")
+	}
+
+	if utils.Output != before {
+		t.Errorf("opening a movie changed where messages go")
+	}
+}
+
+func TestOpenRefused(t *testing.T) {
+	tests := []struct {
+		name string
+		r    io.ReaderAt
+		err  error
+	}{
+		{"projector", bytes.NewReader(append([]byte("MZ"), make([]byte, 62)...)), ErrProjector},
+		{"Mac projector", bytes.NewReader(append([]byte("Joy!"), make([]byte, 12)...)), ErrProjector},
+		{"AppleDouble", bytes.NewReader([]byte{0, 5, 0x16, 0x07, 0, 2, 0, 0}), ErrMacFile},
+		{"no size", struct{ io.ReaderAt }{bytes.NewReader(testMovie())}, ErrUnknownSize},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Open(test.r); !errors.Is(err, test.err) {
+				t.Errorf("error is %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+// a file that only starts with a zero isn't taken for a Mac one
+func TestOpenLeadingZero(t *testing.T) {
+	_, err := Open(bytes.NewReader(make([]byte, 256)))
+	if err == nil || errors.Is(err, ErrMacFile) {
+		t.Errorf("error is %v, want it to not be a movie", err)
+	}
+}
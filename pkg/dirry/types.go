@@ -0,0 +1,144 @@
+package dirry
+
+import (
+	"github.com/markhughes/dirry/internal/chunks"
+	"github.com/markhughes/dirry/internal/members"
+	"github.com/markhughes/dirry/internal/shockwave"
+)
+
+// Chunk is a single resource from the chunk map.
+type Chunk struct {
+	ID     int32
+	Type   string
+	Offset int32
+	Size   int32
+
+	// CastID is the owning CASt resource when the KEY* table maps one
+	CastID int32
+
+	// Data is the decompressed payload, it is shared with the movie so treat
+	// it as read only.
+	Data []byte
+}
+
+func newChunk(resource *shockwave.ShockwaveResource) Chunk {
+	return Chunk{
+		ID:     resource.ResourceId,
+		Type:   resource.ChunkType,
+		Offset: resource.Offset,
+		Size:   resource.UncompressedSize,
+		CastID: resource.CastId,
+		Data:   resource.Binary,
+	}
+}
+
+// Member is a decoded CASt chunk.
+type Member struct {
+	ID int32
+
+	cast *chunks.CastChunk
+}
+
+// Type is the cast member type name, e.g. "Bitmap" or "Script".
+func (member *Member) Type() string {
+	return member.cast.Type.String()
+}
+
+// Name is the cast member name.
+func (member *Member) Name() string {
+	return member.cast.Properties.Name
+}
+
+// ScriptText is the Lingo source attached to the member, if it was saved with it.
+func (member *Member) ScriptText() string {
+	return member.cast.Properties.ScriptText
+}
+
+// FileName is the linked file name of the member, if any.
+func (member *Member) FileName() string {
+	return member.cast.Properties.FileName
+}
+
+// Comments is the comment text from the member properties.
+func (member *Member) Comments() string {
+	return member.cast.Properties.Comments
+}
+
+// XtraName is the xtra type of an Xtra member, e.g. "flash" or "vectorShape".
+func (member *Member) XtraName() string {
+	if xtra, ok := member.cast.Member.(*members.MemberXtra); ok {
+		return xtra.Type
+	}
+
+	return member.cast.Properties.XtraName
+}
+
+// Bitmap returns the bitmap details when the member is a bitmap.
+func (member *Member) Bitmap() (BitmapInfo, bool) {
+	bitmap, ok := member.cast.Member.(*members.MemberBitmap)
+	if !ok {
+		return BitmapInfo{}, false
+	}
+
+	return BitmapInfo{
+		Width:        int(bitmap.InitialRect.Width),
+		Height:       int(bitmap.InitialRect.Height),
+		BitsPerPixel: int(bitmap.BitsPerPixel),
+		RegX:         int(bitmap.RegX),
+		RegY:         int(bitmap.RegY),
+		Palette:      int(bitmap.Clut),
+		PaletteLib:   bitmap.CastMemberID,
+	}, true
+}
+
+// JSON is the full decoded member as JSON, the same as `dirry dump` writes.
+func (member *Member) JSON() (string, error) {
+	return member.cast.ToJSON()
+}
+
+// BitmapInfo describes a bitmap cast member.
+type BitmapInfo struct {
+	Width        int
+	Height       int
+	BitsPerPixel int
+	RegX         int
+	RegY         int
+
+	// Palette is the palette member number, builtin palettes are negative.
+	// PaletteLib is the cast library of the member, 0 is the bitmap's own.
+	Palette    int
+	PaletteLib int
+}
+
+// CastLib is a cast library entry from the MCsL chunk.
+type CastLib struct {
+	ID          int
+	Name        string
+	Path        string
+	MemberCount int
+	External    bool
+}
+
+// Font is a font map entry.
+type Font struct {
+	ID       int16
+	Name     string
+	Platform uint16
+}
+
+// Color is a single 24 bit palette entry.
+type Color struct {
+	R, G, B uint8
+}
+
+// Palette is a CLUT chunk.
+type Palette struct {
+	ID     int32
+	CastID int32
+
+	// CastLib and Member are how bitmaps refer to the palette
+	CastLib int
+	Member  int
+
+	Colors []Color
+}
@@ -10,7 +10,6 @@ import (
 
 	"github.com/markhughes/dirry/internal/chunks"
 	"github.com/markhughes/dirry/internal/errors"
-	"github.com/markhughes/dirry/internal/palettes"
 	"github.com/markhughes/dirry/internal/shockwave"
 	"github.com/markhughes/dirry/internal/utils"
 )
@@ -298,8 +297,6 @@ func extractor(filePath string, fileContent []byte, pkg string, extraOffset int6
 				break
 			}
 
-			palettes.RegisterPallete(palettes.Clut(resource.ResourceId), clutchunk.Palette)
-
 			// clutchunk.Save(filepath.Base(shockwave.Reader.Name()), fmt.Sprint(resource.ResourceId), "")
 
 		case "LctX":
@@ -347,7 +344,7 @@ func extractor(filePath string, fileContent []byte, pkg string, extraOffset int6
 				break
 			}
 
-			lscrchunk, err := chunks.ReadLscrChunkRaw(reader, shockwave.Endian, shockwave.IsAfterburner())
+			lscrchunk, err := chunks.ReadLscrChunkRaw(reader, shockwave.Version, len(shockwave.ChunkMap.GetResourcesByTag("LctX")) > 0, shockwave.Endian, shockwave.IsAfterburner())
 			if err != nil {
 				utils.ErrorMsg("dump", "Error reading Lscr chunk: %s", err)
 				break
@@ -505,6 +502,10 @@ func extractor(filePath string, fileContent []byte, pkg string, extraOffset int6
 		}
 	}
 
+	// palettes are members, the bitmaps look them up by cast library and number
+	shockwave.LoadPalettes()
+	var castMembers = shockwave.CastMembers()
+
 	// These resources are dependent on a cast chunk or something else being parsed first
 	for _, i := range pendingResourceIds {
 		content = ""
@@ -531,8 +532,23 @@ func extractor(filePath string, fileContent []byte, pkg string, extraOffset int6
 			}
 
 		case "snd ":
-			// TODO
-			break
+			reader, err := resource.GetReader()
+			if err != nil {
+				utils.ErrorMsg("dump", "Error getting reader for snd resource: %s", err)
+				break
+			}
+
+			chunk, err := chunks.ReadSndChunkRaw(reader, shockwave.Endian, shockwave.IsAfterburner())
+			if err != nil {
+				utils.ErrorMsg("dump", "Error reading snd chunk: %s", err)
+				break
+			}
+
+			content, err = chunk.ToJSON()
+			if err != nil {
+				utils.ErrorMsg("dump", "Error converting snd chunk to JSON: %s", err)
+				break
+			}
 
 		case "BITD":
 			var cast = shockwave.Casts[resource.CastId]
@@ -547,7 +563,7 @@ func extractor(filePath string, fileContent []byte, pkg string, extraOffset int6
 				break
 			}
 
-			chunk, err := chunks.ReadBitmapChunkRaw(reader, shockwave.Endian, cast, shockwave.IsAfterburner())
+			chunk, err := chunks.ReadBitmapChunkRaw(reader, shockwave.Endian, cast, castMembers[resource.CastId].CastLib, shockwave.IsAfterburner())
 			if err != nil {
 				utils.ErrorMsg("dump", "Error reading BITD chunk: %s", err)
 				break
@@ -574,7 +590,7 @@ func extractor(filePath string, fileContent []byte, pkg string, extraOffset int6
 				break
 			}
 
-			chunk, err := chunks.ReadXmedChunkRaw(reader, cast, shockwave.Endian, shockwave.IsAfterburner())
+			chunk, err := chunks.ReadXmedChunkRaw(reader, cast, shockwave.IsWindows(), shockwave.Endian, shockwave.IsAfterburner())
 			if err != nil {
 				if _, ok := err.(*errors.UnhandledXtraTypeError); ok {
 					utils.WarnMsg("dump", "Could not decode XMED chunk: %s", err)
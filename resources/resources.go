@@ -0,0 +1,9 @@
+package resources
+
+import "embed"
+
+// Palettes holds the built-in Director palettes so they are available without
+// a ~/dirry/resources install (and without touching the filesystem at all).
+//
+//go:embed palettes/*.json
+var Palettes embed.FS